@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// adminRerenderPath is where the admin endpoint accepts re-render requests
+const adminRerenderPath = "/rerender"
+
+// defaultRerenderTTL is how long a re-render request waits for the composites it selects
+// Crossplane re-renders every composite about once a minute, so an hour covers even long reconcile queues
+const defaultRerenderTTL = time.Hour
+
+// rerenderRequest forces the next render of every composite matching selector to recompute from scratch
+type rerenderRequest struct {
+	ID          int               `json:"id"`
+	Selector    string            `json:"selector"`
+	RequestedAt time.Time         `json:"requestedAt"`
+	ExpiresAt   time.Time         `json:"expiresAt"`
+	Rendered    map[string]string `json:"rendered"`
+	// Dropped lists the cache entries invalidated when the request was made
+	Dropped map[string][]string `json:"dropped"`
+
+	selector labels.Selector
+}
+
+// rerenderQueue holds the pending re-render requests
+// State is kept in memory per replica; after a restart every render recomputes anyway
+type rerenderQueue struct {
+	mu       sync.Mutex
+	nextID   int
+	requests []*rerenderRequest
+}
+
+// newRerenderQueue creates an empty queue
+func newRerenderQueue() *rerenderQueue {
+	return &rerenderQueue{nextID: 1}
+}
+
+// add registers a re-render request for the composites matching selector
+func (q *rerenderQueue) add(selector labels.Selector, ttl time.Duration, dropped map[string][]string) rerenderRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current := now().UTC()
+	request := &rerenderRequest{
+		ID:          q.nextID,
+		Selector:    selector.String(),
+		RequestedAt: current,
+		ExpiresAt:   current.Add(ttl),
+		Rendered:    map[string]string{},
+		Dropped:     dropped,
+		selector:    selector,
+	}
+	q.nextID++
+	q.requests = append(q.requests, request)
+	return request.snapshot()
+}
+
+// snapshot copies the request, so it can be encoded while renders mark composites in the original
+// Callers hold the queue's lock
+func (r *rerenderRequest) snapshot() rerenderRequest {
+	snapshot := *r
+	snapshot.Rendered = maps.Clone(r.Rendered)
+	return snapshot
+}
+
+// claim reports whether a pending request selects the composite and it wasn't re-rendered for it yet,
+// marking it as re-rendered; expired requests are dropped
+func (q *rerenderQueue) claim(composite *fnv1.Resource) bool {
+	if q == nil || composite == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current := now().UTC()
+	compositeLabels, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetStringObject("metadata.labels")
+	identity := resourceIdentity(composite)
+	claimed := false
+	pending := q.requests[:0]
+	for _, request := range q.requests {
+		if !current.Before(request.ExpiresAt) {
+			continue
+		}
+		pending = append(pending, request)
+		if _, ok := request.Rendered[identity]; ok || !request.selector.Matches(labels.Set(compositeLabels)) {
+			continue
+		}
+		request.Rendered[identity] = current.Format(time.RFC3339)
+		claimed = true
+	}
+	q.requests = pending
+	return claimed
+}
+
+// list returns copies of the pending requests
+func (q *rerenderQueue) list() []rerenderRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current := now().UTC()
+	var requests []rerenderRequest
+	for _, request := range q.requests {
+		if current.Before(request.ExpiresAt) {
+			requests = append(requests, request.snapshot())
+		}
+	}
+	return requests
+}
+
+// AdminServer lets platform operators force re-renders, e.g., when rolling out an urgent config fix fleet-wide
+type AdminServer struct {
+	log     logr.Logger
+	manager *Manager
+}
+
+// NewAdminServer creates an admin server acting on the manager's caches and renders
+func NewAdminServer(log logr.Logger, manager *Manager) *AdminServer {
+	return &AdminServer{log: log.WithValues("component", "admin"), manager: manager}
+}
+
+// Handler returns the HTTP handler of the admin endpoint
+// POST /rerender {"selector": "appcat.vshn.io/service=redis", "ttl": "1h"} drops the caches of remote documents
+// and forces the next render of the selected composites to recompute; GET /rerender lists pending requests
+func (a *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(adminRerenderPath, a.serveRerender)
+	return mux
+}
+
+// serveRerender registers or lists re-render requests
+func (a *AdminServer) serveRerender(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeAdminJSON(rw, http.StatusOK, a.manager.rerenders.list())
+	case http.MethodPost:
+		var body struct {
+			Selector string `json:"selector"`
+			TTL      string `json:"ttl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(rw, fmt.Sprintf("failed to decode request: %v", err), http.StatusBadRequest)
+			return
+		}
+		selector, err := labels.Parse(body.Selector)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("invalid selector: %v", err), http.StatusBadRequest)
+			return
+		}
+		ttl := defaultRerenderTTL
+		if body.TTL != "" {
+			if ttl, err = time.ParseDuration(body.TTL); err != nil || ttl <= 0 {
+				http.Error(rw, fmt.Sprintf("invalid ttl %q", body.TTL), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// The caches are keyed by document, not composite, and shared by all composites using a document
+		dropped := map[string][]string{
+			"appVersions": a.manager.appVersions.flush(),
+			"schemas":     a.manager.schemas.flush(),
+			"values":      a.manager.values.flush(),
+		}
+		request := a.manager.rerenders.add(selector, ttl, dropped)
+		a.log.Info("Registered re-render request", "id", request.ID, "selector", request.Selector, "expiresAt", request.ExpiresAt,
+			"droppedAppVersions", len(dropped["appVersions"]), "droppedSchemas", len(dropped["schemas"]), "droppedValues", len(dropped["values"]))
+		writeAdminJSON(rw, http.StatusAccepted, request)
+	default:
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeAdminJSON writes a JSON response
+func writeAdminJSON(rw http.ResponseWriter, status int, body any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(body)
+}
+
+// checkAdminAddr rejects admin listen addresses other than loopback ones
+// The endpoint has no authentication and serves plaintext, so it's reached through kubectl port-forward only
+func checkAdminAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s is not a loopback address, e.g. 127.0.0.1:9445", addr)
+	}
+	return nil
+}
+
+// serveAdmin serves the admin endpoint in plaintext on the loopback address addr until ctx is done
+func serveAdmin(ctx context.Context, addr string, admin *AdminServer) error {
+	if err := checkAdminAddr(addr); err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           admin.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// AlertRule is an alert rule template of a service's alert bundle
+// Expr, labels and annotations support ${instanceName}, ${namespace}, ${claimName} and ${claimNamespace}
+type AlertRule struct {
+	Alert       string
+	Expr        string
+	For         string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// AlertsConfig is the alert bundle of a service, e.g. memory high, instance down and replication broken
+type AlertsConfig struct {
+	// Labels are set on the PrometheusRule, e.g. the label the platform's Prometheus selects rules by
+	Labels map[string]string
+	Rules  []AlertRule
+}
+
+// getAlertsConfig extracts alerts configuration from merged config
+// Returns nil without error if the service has no alert bundle
+func getAlertsConfig(mergedConfig map[string]any) (*AlertsConfig, error) {
+	alerts, ok := mergedConfig["alerts"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &AlertsConfig{}
+	var err error
+	if config.Labels, err = stringMap(alerts, "labels"); err != nil {
+		return nil, err
+	}
+	rulesRaw, _ := alerts["rules"].([]any)
+	if len(rulesRaw) == 0 {
+		return nil, fmt.Errorf("rules requires at least one rule")
+	}
+	for i, ruleRaw := range rulesRaw {
+		ruleMap, ok := ruleRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("rules[%d] must be a map", i)
+		}
+		rule := AlertRule{}
+		rule.Alert, _ = ruleMap["alert"].(string)
+		rule.Expr, _ = ruleMap["expr"].(string)
+		rule.For, _ = ruleMap["for"].(string)
+		if rule.Alert == "" || rule.Expr == "" {
+			return nil, fmt.Errorf("rules[%d] requires alert and expr", i)
+		}
+		if rule.Labels, err = stringMap(ruleMap, "labels"); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		if rule.Annotations, err = stringMap(ruleMap, "annotations"); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+	return config, nil
+}
+
+// stringMap reads an optional map of strings from a config section
+func stringMap(section map[string]any, field string) (map[string]string, error) {
+	values := map[string]string{}
+	valuesRaw, _ := section[field].(map[string]any)
+	for key, valueRaw := range valuesRaw {
+		value, ok := valueRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s.%s must be a string", field, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// generateAlertRules renders the service's alert bundle for the instance as a PrometheusRule
+// Skipped on clusters without Prometheus Operator (see capabilities)
+func generateAlertRules(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	variables map[string]string,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	config, err := getAlertsConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid alerts config: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	rules := make([]any, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		// Every alert carries the instance, so alert routing can tell instances apart
+		labels := map[string]any{"namespace": instanceNamespace, "appcat_instance": instanceName}
+		for key, value := range rule.Labels {
+			labels[key] = substituteVariables(value, variables)
+		}
+		annotations := map[string]any{}
+		for key, value := range rule.Annotations {
+			annotations[key] = substituteVariables(value, variables)
+		}
+
+		rendered := map[string]any{
+			"alert":       rule.Alert,
+			"expr":        substituteVariables(rule.Expr, variables),
+			"labels":      labels,
+			"annotations": annotations,
+		}
+		if rule.For != "" {
+			rendered["for"] = rule.For
+		}
+		rules = append(rules, rendered)
+	}
+
+	builder := NewPrometheusRuleBuilder(instanceName+"-alerts", instanceNamespace).
+		WithGroup(instanceName, rules)
+	for key, value := range config.Labels {
+		builder = builder.WithLabel(key, value)
+	}
+	rule := builder.
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace).
+		Build()
+
+	ruleResource, err := toFunctionResource(rule)
+	if err != nil {
+		return fmt.Errorf("failed to convert prometheus rule: %w", err)
+	}
+	resources["prometheusrule"] = ruleResource
+
+	log.Info("Created alert rules", "rules", len(rules))
+	return nil
+}
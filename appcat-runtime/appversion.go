@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/singleflight"
+	"sigs.k8s.io/yaml"
+)
+
+// indexFetchTimeout bounds how long fetching a chart repository index may take
+const indexFetchTimeout = 30 * time.Second
+
+// LabelAppVersion is the metering label carrying the deployed application version
+const LabelAppVersion = "appcat.vshn.io/app-version"
+
+// indexRefreshInterval is how long the chart versions listed in a repository index are cached
+const indexRefreshInterval = 10 * time.Minute
+
+// chartIndex is the subset of a Helm repository index.yaml needed to resolve versions
+type chartIndex struct {
+	Entries map[string][]struct {
+		Version    string `json:"version"`
+		AppVersion string `json:"appVersion"`
+	} `json:"entries"`
+}
+
+// chartVersionList is the cached list of a chart's versions in a repository
+type chartVersionList struct {
+	versions  []string
+	fetchedAt time.Time
+}
+
+// appVersionResolver resolves and caches chart appVersions and version lists from repository indexes
+// Only the resolved versions are cached, not the (potentially large) index documents
+type appVersionResolver struct {
+	mu       sync.Mutex
+	versions map[string]string
+	lists    map[string]*chartVersionList
+	client   *http.Client
+	// fetches deduplicates concurrent fetches of the same index, which run without holding mu
+	fetches singleflight.Group
+}
+
+// newAppVersionResolver creates an empty resolver
+func newAppVersionResolver() *appVersionResolver {
+	return &appVersionResolver{
+		versions: make(map[string]string),
+		lists:    make(map[string]*chartVersionList),
+		client:   &http.Client{Timeout: indexFetchTimeout},
+	}
+}
+
+// resolve returns the appVersion of chart name@version in repo, fetching the repository index on first use
+func (r *appVersionResolver) resolve(ctx context.Context, repo, name, version string) (string, error) {
+	cacheKey := fmt.Sprintf("%s|%s|%s", repo, name, version)
+
+	r.mu.Lock()
+	appVersion, ok := r.versions[cacheKey]
+	r.mu.Unlock()
+	if ok {
+		return appVersion, nil
+	}
+	if _, err := r.fetchIndex(ctx, repo, name); err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	appVersion, ok = r.versions[cacheKey]
+	r.mu.Unlock()
+	if ok {
+		return appVersion, nil
+	}
+	return "", fmt.Errorf("chart %s version %s not found in %s", name, version, repo)
+}
+
+// chartVersions returns the versions of chart name listed in repo's index, refreshed every indexRefreshInterval
+func (r *appVersionResolver) chartVersions(ctx context.Context, repo, name string) ([]string, error) {
+	r.mu.Lock()
+	list, ok := r.lists[repo+"|"+name]
+	r.mu.Unlock()
+	if ok && now().Sub(list.fetchedAt) < indexRefreshInterval {
+		return list.versions, nil
+	}
+	return r.fetchIndex(ctx, repo, name)
+}
+
+// flush drops all cached appVersions and version lists and returns their keys, sorted
+func (r *appVersionResolver) flush() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dropped := slices.Sorted(maps.Keys(r.versions))
+	dropped = append(dropped, slices.Sorted(maps.Keys(r.lists))...)
+	clear(r.versions)
+	clear(r.lists)
+	return dropped
+}
+
+// fetchIndex fetches repo's index and caches the versions and appVersions of chart name
+// A slow repository only blocks the renders waiting for it; the fetch outlives a cancelled caller, bounded by
+// indexFetchTimeout, so the renders sharing it still get the versions
+func (r *appVersionResolver) fetchIndex(ctx context.Context, repo, name string) ([]string, error) {
+	fetch := r.fetches.DoChan(repo+"|"+name, func() (any, error) {
+		index, err := r.downloadIndex(context.WithoutCancel(ctx), repo)
+		if err != nil {
+			return nil, err
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		versions := make([]string, 0, len(index.Entries[name]))
+		for _, entry := range index.Entries[name] {
+			r.versions[fmt.Sprintf("%s|%s|%s", repo, name, entry.Version)] = entry.AppVersion
+			versions = append(versions, entry.Version)
+		}
+		r.lists[repo+"|"+name] = &chartVersionList{versions: versions, fetchedAt: now()}
+		return versions, nil
+	})
+	select {
+	case result := <-fetch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]string), nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to fetch index of %s: %w", repo, ctx.Err())
+	}
+}
+
+// downloadIndex fetches and parses repo's index
+func (r *appVersionResolver) downloadIndex(ctx context.Context, repo string) (*chartIndex, error) {
+	indexURL := strings.TrimSuffix(repo, "/") + "/index.yaml"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for %s: %w", indexURL, err)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", indexURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", indexURL, resp.Status)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", indexURL, err)
+	}
+
+	var index chartIndex
+	if err := yaml.Unmarshal(raw, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", indexURL, err)
+	}
+	return &index, nil
+}
+
+// resolveChartAppVersion determines the chart's appVersion and records it in mergedConfig chart.appVersion
+// A configured chart.appVersion wins; chart.resolveAppVersion enables lookup in the repository index
+// Lookup failures are reported as a warning, since the version is informational only
+func resolveChartAppVersion(ctx context.Context, resolver *appVersionResolver, mergedConfig map[string]any, results *Results, log logr.Logger) error {
+	chart, ok := mergedConfig["chart"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("chart not found in merged config")
+	}
+	if appVersion, _ := chart["appVersion"].(string); appVersion != "" {
+		return nil
+	}
+	if resolve, _ := chart["resolveAppVersion"].(bool); !resolve {
+		return nil
+	}
+
+	repo, name, version, err := extractChartConfig(mergedConfig)
+	if err != nil {
+		return err
+	}
+	appVersion, err := resolver.resolve(ctx, repo, name, version)
+	if err != nil {
+		log.Error(err, "Failed to resolve chart app version", "chart", name, "version", version)
+		results.Warning("AppVersionUnknown", "Could not resolve app version of chart %s %s: %v", name, version, err)
+		return nil
+	}
+
+	chart["appVersion"] = appVersion
+	log.Info("Resolved chart app version", "chart", name, "version", version, "appVersion", appVersion)
+	return nil
+}
+
+// getChartAppVersion returns chart.appVersion from merged config, or "" if unknown
+func getChartAppVersion(mergedConfig map[string]any) string {
+	chart, _ := mergedConfig["chart"].(map[string]any)
+	appVersion, _ := chart["appVersion"].(string)
+	return appVersion
+}
+
+// sanitizeLabelValue makes a version usable as a label value (max 63 chars of [A-Za-z0-9._-])
+func sanitizeLabelValue(value string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+	if len(sanitized) > 63 {
+		sanitized = sanitized[:63]
+	}
+	return strings.Trim(sanitized, "._-")
+}
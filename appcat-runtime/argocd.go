@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	helmv1 "github.com/crossplane-contrib/provider-helm/apis/namespaced/release/v1beta1"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// argoCDApplicationKind is the kind of Argo CD's applications, telling them apart from provider-helm Releases when observed
+const argoCDApplicationKind = "Application"
+
+// defaultArgoCDNamespace is the namespace Argo CD watches for Applications
+const defaultArgoCDNamespace = "argocd"
+
+// inClusterServer is the Argo CD destination of the cluster Argo CD runs on
+const inClusterServer = "https://kubernetes.default.svc"
+
+// argoCDHealthyQuery marks an Application's Object ready once Argo CD synced it and reports it healthy
+const argoCDHealthyQuery = "object.status.health.status == 'Healthy' && object.status.sync.status == 'Synced'"
+
+// ArgoCDOutput configures the Applications the argocd output mode hands the releases to
+type ArgoCDOutput struct {
+	// Namespace is where Argo CD watches for Applications
+	Namespace string
+	Project   string
+	// ProviderConfigRef is the provider-kubernetes ProviderConfig of Argo CD's cluster; empty uses the default
+	ProviderConfigRef string
+}
+
+// getArgoCDOutput extracts output.argocd, e.g. {namespace: argocd, project: appcat, providerConfigRef: mgmt}
+func getArgoCDOutput(output map[string]any) (*ArgoCDOutput, error) {
+	config := &ArgoCDOutput{Namespace: defaultArgoCDNamespace, Project: "default"}
+	argocd, ok := output["argocd"]
+	if !ok {
+		return config, nil
+	}
+	settings, ok := argocd.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("argocd must be a map")
+	}
+	if namespace, _ := settings["namespace"].(string); namespace != "" {
+		config.Namespace = namespace
+	}
+	if project, _ := settings["project"].(string); project != "" {
+		config.Project = project
+	}
+	config.ProviderConfigRef, _ = settings["providerConfigRef"].(string)
+	return config, nil
+}
+
+// argoCDReleaseResources translates a Release into an Argo CD Application wrapped into an Object under the
+// Release's key, so Argo CD owns the helm release's lifecycle
+// The Application is applied to Argo CD's cluster and deploys to the cluster of the release's placement
+func argoCDReleaseResources(key string, release *helmv1.Release, config *ArgoCDOutput) (map[string]*fnv1.Resource, error) {
+	forProvider := release.Spec.ForProvider
+	// Argo CD renders the chart itself, it can't read values from the instance's Secrets and ConfigMaps
+	if len(forProvider.ValuesFrom) > 0 || len(forProvider.Set) > 0 {
+		return nil, fmt.Errorf("release %s: the argocd output mode can't read values from Secrets and ConfigMaps, remove valuesFrom or raise valuesExternalization.thresholdBytes", release.Name)
+	}
+
+	values := map[string]any{}
+	if len(forProvider.Values.Raw) > 0 {
+		if err := json.Unmarshal(forProvider.Values.Raw, &values); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal release values: %w", err)
+		}
+	}
+	helm := map[string]any{"releaseName": release.Name}
+	if len(values) > 0 {
+		helm["valuesObject"] = values
+	}
+	if forProvider.SkipCRDs {
+		helm["skipCrds"] = true
+	}
+
+	// Argo CD's OCI helm repositories are given without scheme
+	repoURL := strings.TrimPrefix(forProvider.Chart.Repository, "oci://")
+
+	// Argo CD clusters are named like the provider configs of the same cluster
+	destination := map[string]any{"server": inClusterServer, "namespace": release.Namespace}
+	if ref := release.Spec.ProviderConfigReference; ref != nil {
+		destination = map[string]any{"name": ref.Name, "namespace": release.Namespace}
+	}
+
+	syncPolicy := map[string]any{
+		"automated": map[string]any{"prune": true, "selfHeal": true},
+	}
+	if !forProvider.SkipCreateNamespace {
+		syncPolicy["syncOptions"] = []any{"CreateNamespace=true"}
+	}
+	if limit := release.Spec.RollbackRetriesLimit; limit != nil {
+		syncPolicy["retry"] = map[string]any{"limit": int64(*limit)}
+	}
+
+	labels := make(map[string]any, len(release.Labels))
+	for label, value := range release.Labels {
+		labels[label] = value
+	}
+	application := map[string]any{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       argoCDApplicationKind,
+		"metadata": map[string]any{
+			// Applications of all instances share Argo CD's namespace
+			"name":      truncateName(release.Namespace+"-"+release.Name, 253),
+			"namespace": config.Namespace,
+			"labels":    labels,
+			// Deleting the Application uninstalls the release
+			"finalizers": []any{"resources-finalizer.argocd.argoproj.io"},
+		},
+		"spec": map[string]any{
+			"project": config.Project,
+			"source": map[string]any{
+				"repoURL":        repoURL,
+				"chart":          forProvider.Chart.Name,
+				"targetRevision": forProvider.Chart.Version,
+				"helm":           helm,
+			},
+			"destination": destination,
+			"syncPolicy":  syncPolicy,
+		},
+	}
+
+	builder := newReleaseObject(release.Name, application, release).
+		WithProviderConfigRef("", config.ProviderConfigRef).
+		WithReadinessQuery(argoCDHealthyQuery)
+	resource, err := toFunctionResource(builder.Build())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert argo cd application: %w", err)
+	}
+	return map[string]*fnv1.Resource{key: resource}, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// Auto-upgrade policies from versionPolicy.autoUpgrade
+const (
+	// AutoUpgradeNone only moves instances when the configured version changes
+	AutoUpgradeNone = "none"
+	// AutoUpgradePatch moves instances to the newest patch of their minor version
+	AutoUpgradePatch = "patch"
+	// AutoUpgradeMinor moves instances to the newest minor version of their major version
+	AutoUpgradeMinor = "minor"
+)
+
+// autoUpgradeDecision is the outcome of the auto-upgrade policy for a render
+type autoUpgradeDecision struct {
+	// status holds status.autoUpgrade, the latest auto upgrade of the instance
+	status map[string]any
+}
+
+// getAutoUpgradePolicy extracts versionPolicy.autoUpgrade from service config, defaulting to none
+func getAutoUpgradePolicy(serviceConfig map[string]any) (string, error) {
+	versionPolicy, _ := serviceConfig["versionPolicy"].(map[string]any)
+	policy, _ := versionPolicy["autoUpgrade"].(string)
+	switch policy {
+	case "":
+		return AutoUpgradeNone, nil
+	case AutoUpgradeNone, AutoUpgradePatch, AutoUpgradeMinor:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown autoUpgrade policy %q", policy)
+	}
+}
+
+// inUpgradeTrack reports whether version may be auto-upgraded to from base under the policy
+func inUpgradeTrack(policy string, base, version *semver.Version) bool {
+	switch policy {
+	case AutoUpgradePatch:
+		return version.Major() == base.Major() && version.Minor() == base.Minor()
+	case AutoUpgradeMinor:
+		return version.Major() == base.Major()
+	default:
+		return false
+	}
+}
+
+// applyAutoUpgrade moves instances to the newest chart version the auto-upgrade policy allows during their
+// maintenance window, recording the upgrade in status.autoUpgrade
+// Outside the window auto-upgraded instances keep their observed version instead of falling back to the
+// configured one; exact spec.version pins are never auto-upgraded
+func applyAutoUpgrade(
+	ctx context.Context,
+	resolver *appVersionResolver,
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	serviceConfig, mergedConfig, userSpec map[string]any,
+	results *Results,
+	log logr.Logger,
+) (*autoUpgradeDecision, error) {
+	decision := &autoUpgradeDecision{}
+	if previous, err := fieldpath.Pave(composite.GetResource().AsMap()).GetValue("status.autoUpgrade"); err == nil {
+		decision.status, _ = previous.(map[string]any)
+	}
+
+	policy, err := getAutoUpgradePolicy(serviceConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid versionPolicy: %w", err)
+	}
+	observedVersion := observedChartVersion(observedResources[activeReleaseKey(composite)])
+	if policy == AutoUpgradeNone || observedVersion == "" || isPinnedVersion(userSpec) {
+		return decision, nil
+	}
+	versionPolicy, err := getVersionPolicy(serviceConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid versionPolicy: %w", err)
+	}
+	chart, ok := mergedConfig["chart"].(map[string]any)
+	if !ok {
+		return decision, fmt.Errorf("chart not found in merged config")
+	}
+	targetRaw, _ := chart["defaultVersion"].(string)
+	target, err := semver.NewVersion(targetRaw)
+	if err != nil {
+		return decision, fmt.Errorf("invalid chart version %q: %w", targetRaw, err)
+	}
+
+	// Keep an auto-upgraded instance on its version rather than downgrading it to the configured one
+	floor := target
+	if observed, err := semver.NewVersion(observedVersion); err == nil && observed.GreaterThan(target) && inUpgradeTrack(policy, target, observed) {
+		chart["defaultVersion"] = observedVersion
+		floor = observed
+	}
+
+	window, err := getMaintenanceWindow(userSpec, serviceConfig)
+	if err != nil {
+		return decision, err
+	}
+	if window == nil {
+		return decision, nil
+	}
+	if inWindow, _ := window.open(now()); !inWindow {
+		return decision, nil
+	}
+
+	available, err := versionPolicy.availableVersions(ctx, resolver, serviceConfig)
+	if err != nil {
+		// The instance stays on its version until the next render
+		log.Error(err, "Failed to list chart versions for auto upgrade")
+		results.Warning("AutoUpgradeFailed", "Could not look up newer chart versions: %v", err)
+		return decision, nil
+	}
+	for _, version := range available {
+		if version.Prerelease() != "" || !version.GreaterThan(floor) || !inUpgradeTrack(policy, target, version) {
+			continue
+		}
+		// available is sorted newest first
+		chart["defaultVersion"] = version.Original()
+		decision.status = map[string]any{
+			"fromVersion": observedVersion,
+			"toVersion":   version.Original(),
+			"policy":      policy,
+			"upgradedAt":  now().UTC().Format(time.RFC3339),
+		}
+		log.Info("Auto-upgrading chart", "policy", policy, "from", observedVersion, "to", version.Original())
+		results.Normal("AutoUpgrade", "Auto-upgrading chart from %s to %s (%s policy)", observedVersion, version.Original(), policy)
+		break
+	}
+	return decision, nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// BackupProviderK8up backs instances up with a K8up Schedule into a restic repository on S3
+// Velero isn't supported: its Schedules live in Velero's namespace, which namespaced composites can't compose into
+const BackupProviderK8up = "k8up"
+
+// Desired resource keys of the backup resources
+const (
+	backupScheduleKey   = "backup-schedule"
+	backupRepositoryKey = "backup-repository"
+	backupBucketKey     = "backup-bucket"
+)
+
+// requiredBackupCredentials names the required resource holding the platform's bucket credentials
+const requiredBackupCredentials = "backup-credentials"
+
+// Defaults of the backup configuration
+const (
+	defaultBackupSchedule      = "@daily-random"
+	defaultBackupCheckSchedule = "@weekly-random"
+)
+
+// backupCredentialKeys are the keys copied from the platform's bucket credentials Secret
+var backupCredentialKeys = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+
+// backupRetentionKeys are the restic retention settings accepted in retention maps
+var backupRetentionKeys = []string{"keepLast", "keepHourly", "keepDaily", "keepWeekly", "keepMonthly", "keepYearly"}
+
+// BackupConfig controls how spec.backup is rendered
+type BackupConfig struct {
+	Provider string
+	// Schedule and Retention apply unless the tenant sets their own in spec.backup
+	Schedule      string
+	CheckSchedule string
+	Retention     map[string]int64
+	// Endpoint and Bucket locate the S3 bucket; Bucket supports ${instanceName}, ${namespace}, ...
+	Endpoint string
+	Bucket   string
+	// CredentialsSecretName and CredentialsSecretNamespace select the platform Secret holding the bucket
+	// credentials, which is copied into the instance namespace
+	CredentialsSecretName      string
+	CredentialsSecretNamespace string
+}
+
+// BackupSpec is the tenant's backup request from spec.backup
+type BackupSpec struct {
+	Enabled   bool
+	Schedule  string
+	Retention map[string]int64
+}
+
+// backupDecision is the outcome of resolving spec.backup
+type backupDecision struct {
+	requirements *fnv1.Requirements
+}
+
+// getBackupConfig extracts backup configuration from service config
+// Returns nil without error if the service doesn't support backups
+func getBackupConfig(serviceConfig map[string]any) (*BackupConfig, error) {
+	backup, ok := serviceConfig["backup"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &BackupConfig{Provider: BackupProviderK8up, Schedule: defaultBackupSchedule, CheckSchedule: defaultBackupCheckSchedule}
+	if provider, _ := backup["provider"].(string); provider != "" {
+		config.Provider = provider
+	}
+	if config.Provider != BackupProviderK8up {
+		return nil, fmt.Errorf("unknown backup provider %q (supported: %s)", config.Provider, BackupProviderK8up)
+	}
+	if schedule, _ := backup["schedule"].(string); schedule != "" {
+		config.Schedule = schedule
+	}
+	if schedule, _ := backup["checkSchedule"].(string); schedule != "" {
+		config.CheckSchedule = schedule
+	}
+	retention, err := getBackupRetention(backup["retention"])
+	if err != nil {
+		return nil, err
+	}
+	config.Retention = retention
+
+	bucket, ok := backup["bucket"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("bucket is required")
+	}
+	config.Endpoint, _ = bucket["endpoint"].(string)
+	config.Bucket, _ = bucket["name"].(string)
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("bucket.endpoint and bucket.name are required")
+	}
+	credentials, ok := bucket["credentialsSecretRef"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("bucket.credentialsSecretRef is required")
+	}
+	config.CredentialsSecretName, _ = credentials["name"].(string)
+	config.CredentialsSecretNamespace, _ = credentials["namespace"].(string)
+	if config.CredentialsSecretName == "" || config.CredentialsSecretNamespace == "" {
+		return nil, fmt.Errorf("bucket.credentialsSecretRef.name and bucket.credentialsSecretRef.namespace are required")
+	}
+	return config, nil
+}
+
+// getBackupRetention parses a retention map, e.g. {keepDaily: 7, keepWeekly: 4}
+// Returns nil without error if no retention is set
+func getBackupRetention(raw any) (map[string]int64, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	retentionRaw, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("retention must be a map")
+	}
+
+	retention := map[string]int64{}
+	for key, valueRaw := range retentionRaw {
+		if !slices.Contains(backupRetentionKeys, key) {
+			return nil, fmt.Errorf("unknown retention %q (known: %s)", key, strings.Join(backupRetentionKeys, ", "))
+		}
+		value, ok := valueRaw.(float64)
+		if !ok || value < 1 || value != float64(int64(value)) {
+			return nil, fmt.Errorf("retention.%s must be a positive integer", key)
+		}
+		retention[key] = int64(value)
+	}
+	return retention, nil
+}
+
+// getBackupSpec extracts spec.backup from the user spec
+// Returns nil without error if the tenant didn't configure backups
+func getBackupSpec(userSpec map[string]any) (*BackupSpec, error) {
+	backup, ok := userSpec["backup"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	spec := &BackupSpec{Enabled: true}
+	if enabled, ok := backup["enabled"].(bool); ok {
+		spec.Enabled = enabled
+	}
+	spec.Schedule, _ = backup["schedule"].(string)
+	retention, err := getBackupRetention(backup["retention"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.backup: %w", err)
+	}
+	spec.Retention = retention
+	return spec, nil
+}
+
+// applyBackup resolves spec.backup against the service's backup configuration
+// Services with a backup configuration back up every instance unless spec.backup.enabled is false
+// The resolved settings and the bucket credentials are recorded as mergedConfig["backup"] for generateBackup
+func applyBackup(req *fnv1.RunFunctionRequest, mergedConfig, serviceConfig, userSpec map[string]any, results *Results, log logr.Logger) (*backupDecision, error) {
+	decision := &backupDecision{}
+	spec, err := getBackupSpec(userSpec)
+	if err != nil {
+		return decision, err
+	}
+	config, err := getBackupConfig(serviceConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid backup config: %w", err)
+	}
+	if config == nil {
+		if spec != nil && spec.Enabled {
+			log.Info("Service doesn't support backups, ignoring spec.backup")
+			results.Warning("BackupUnsupported", "This service doesn't support backups, spec.backup is ignored")
+		}
+		return decision, nil
+	}
+	if spec == nil {
+		spec = &BackupSpec{Enabled: true}
+	}
+	if !spec.Enabled {
+		mergedConfig["backup"] = map[string]any{"enabled": false}
+		return decision, nil
+	}
+
+	schedule := config.Schedule
+	if spec.Schedule != "" {
+		schedule = spec.Schedule
+	}
+	// The tenant's retention replaces the default as a whole, so dropping e.g. keepWeekly is possible
+	retention := config.Retention
+	if spec.Retention != nil {
+		retention = spec.Retention
+	}
+	retentionValues := map[string]any{}
+	for key, value := range retention {
+		retentionValues[key] = value
+	}
+	backup := map[string]any{
+		"enabled":       true,
+		"schedule":      schedule,
+		"checkSchedule": config.CheckSchedule,
+		"retention":     retentionValues,
+		"endpoint":      config.Endpoint,
+		"bucket":        config.Bucket,
+	}
+	mergedConfig["backup"] = backup
+
+	// The bucket credentials are copied from the platform's Secret, which Crossplane fetches for the function
+	namespace := config.CredentialsSecretNamespace
+	decision.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+		requiredBackupCredentials: {
+			ApiVersion: "v1",
+			Kind:       "Secret",
+			Match:      &fnv1.ResourceSelector_MatchName{MatchName: config.CredentialsSecretName},
+			Namespace:  &namespace,
+		},
+	}}
+	found, fetched := getRequiredResources(req, requiredBackupCredentials)
+	if !fetched {
+		backup["pending"] = true
+		return decision, nil
+	}
+	if len(found) == 0 {
+		log.Info("Backup bucket credentials not found", "secret", namespace+"/"+config.CredentialsSecretName)
+		results.Warning("BackupCredentialsMissing", "Bucket credentials Secret %s/%s not found, backups are not scheduled", namespace, config.CredentialsSecretName)
+		backup["credentialsMissing"] = true
+		return decision, nil
+	}
+
+	paved := fieldpath.Pave(found[0].GetResource().AsMap())
+	credentials := map[string]any{}
+	for _, key := range backupCredentialKeys {
+		encoded, _ := paved.GetString(fmt.Sprintf("data[%s]", key))
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(value) == 0 {
+			return decision, fmt.Errorf("bucket credentials Secret %s/%s has no valid %s", namespace, config.CredentialsSecretName, key)
+		}
+		credentials[key] = string(value)
+	}
+	backup["credentials"] = credentials
+	return decision, nil
+}
+
+// generateBackup creates the K8up Schedule backing up the instance namespace, the Secret holding the
+// restic repository password and the instance's copy of the bucket credentials
+// The repository password is kept once generated: snapshots can't be restored without it
+func generateBackup(
+	resources map[string]*fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	variables map[string]string,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	backup, ok := mergedConfig["backup"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	credentials, ok := backup["credentials"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	bucketName := instanceName + "-backup-bucket"
+	bucketSecret := NewSecretBuilder(bucketName, instanceNamespace).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel("app.kubernetes.io/component", "backup").
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace)
+	for _, key := range slices.Sorted(maps.Keys(credentials)) {
+		value, _ := credentials[key].(string)
+		bucketSecret = bucketSecret.WithData(key, []byte(value))
+	}
+	bucketResource, err := toFunctionResource(bucketSecret.Build())
+	if err != nil {
+		return fmt.Errorf("failed to convert backup bucket secret: %w", err)
+	}
+	resources[backupBucketKey] = bucketResource
+
+	repositoryName := instanceName + "-backup-repo"
+	password, err := observedRepositoryPassword(observedResources[backupRepositoryKey])
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		password, err = generateRandomPassword(defaultPasswordPolicy, instanceNamespace+"/"+instanceName+"/backup-repository")
+		if err != nil {
+			return fmt.Errorf("failed to generate backup repository password: %w", err)
+		}
+		log.Info("Generated backup repository password", "instance", instanceName)
+	}
+	repositorySecret := NewSecretBuilder(repositoryName, instanceNamespace).
+		WithData("password", []byte(password)).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel("app.kubernetes.io/component", "backup").
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace).
+		Build()
+	repositoryResource, err := toFunctionResource(repositorySecret)
+	if err != nil {
+		return fmt.Errorf("failed to convert backup repository secret: %w", err)
+	}
+	resources[backupRepositoryKey] = repositoryResource
+
+	schedule, _ := backup["schedule"].(string)
+	checkSchedule, _ := backup["checkSchedule"].(string)
+	endpoint, _ := backup["endpoint"].(string)
+	bucket, _ := backup["bucket"].(string)
+	retention := map[string]int64{}
+	if retentionRaw, ok := backup["retention"].(map[string]any); ok {
+		for key, value := range retentionRaw {
+			retention[key], _ = value.(int64)
+		}
+	}
+	builder := NewBackupScheduleBuilder(instanceName+"-backup", instanceNamespace).
+		WithS3Backend(endpoint, substituteVariables(bucket, variables), bucketName).
+		WithRepositoryPassword(repositoryName, "password").
+		WithBackup(schedule).
+		WithCheck(checkSchedule).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel("app.kubernetes.io/component", "backup").
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace)
+	// Without a retention restic keeps every snapshot, so the Schedule doesn't prune
+	if len(retention) > 0 {
+		builder = builder.WithPrune(schedule, retention)
+	}
+	scheduleResource, err := toFunctionResource(builder.Build())
+	if err != nil {
+		return fmt.Errorf("failed to convert backup schedule: %w", err)
+	}
+	resources[backupScheduleKey] = scheduleResource
+
+	log.Info("Created backup schedule", "schedule", schedule, "bucket", substituteVariables(bucket, variables))
+	return nil
+}
+
+// observedRepositoryPassword returns the restic repository password of the observed repository Secret
+func observedRepositoryPassword(secret *fnv1.Resource) (string, error) {
+	if secret == nil {
+		return "", nil
+	}
+	encoded, err := fieldpath.Pave(secret.GetResource().AsMap()).GetString("data.password")
+	if err != nil || encoded == "" {
+		return "", nil
+	}
+	password, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		// A new password would lock the instance out of its existing snapshots
+		return "", fmt.Errorf("backup repository password is not valid base64: %w", err)
+	}
+	return string(password), nil
+}
+
+// backupConfiguredCondition reports whether the instance's backups are scheduled
+func backupConfiguredCondition(schedule *fnv1.Resource, mergedConfig map[string]any) *fnv1.Condition {
+	backup, ok := mergedConfig["backup"].(map[string]any)
+	if !ok {
+		return newCondition(ConditionBackupConfigured, fnv1.Status_STATUS_CONDITION_FALSE, "NotConfigured", "Service has no backup configuration")
+	}
+	if enabled, _ := backup["enabled"].(bool); !enabled {
+		return newCondition(ConditionBackupConfigured, fnv1.Status_STATUS_CONDITION_FALSE, "Disabled", "Backups are disabled in spec.backup")
+	}
+	if missing, _ := backup["credentialsMissing"].(bool); missing {
+		return newCondition(ConditionBackupConfigured, fnv1.Status_STATUS_CONDITION_FALSE, "CredentialsMissing", "Backup bucket credentials are not available")
+	}
+	if schedule == nil {
+		return newCondition(ConditionBackupConfigured, fnv1.Status_STATUS_CONDITION_FALSE, "Pending", "Backup schedule has not been created yet")
+	}
+	scheduleValue, _ := backup["schedule"].(string)
+	return newCondition(ConditionBackupConfigured, fnv1.Status_STATUS_CONDITION_TRUE, "Scheduled", fmt.Sprintf("Backups are scheduled %s", scheduleValue))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Upgrade strategies
+const (
+	// UpgradeStrategyInPlace upgrades the existing release (helm upgrade)
+	UpgradeStrategyInPlace = "inPlace"
+	// UpgradeStrategyBlueGreen deploys the new version as a second release and flips over once it's ready
+	UpgradeStrategyBlueGreen = "blueGreen"
+)
+
+// Blue/green upgrade phases reported in status.upgrade.phase
+const (
+	// UpgradePhaseDeploying means the new release is being deployed while the old one serves
+	UpgradePhaseDeploying = "Deploying"
+	// UpgradePhaseFlipped means connection details point at the new release; the old one is removed next
+	UpgradePhaseFlipped = "Flipped"
+	// UpgradePhaseCompleted means only the new release is left
+	UpgradePhaseCompleted = "Completed"
+)
+
+// Release slots; blue/green upgrades alternate between them
+const (
+	releaseKey     = "helmrelease"
+	nextReleaseKey = "helmrelease-next"
+	nextSuffix     = "-next"
+)
+
+// ReleaseTarget identifies the Helm release generateResources renders
+type ReleaseTarget struct {
+	// Key is the desired resource key of the Release
+	Key string
+	// Name is the Release (and helm release) name
+	Name string
+	// ServingName is the release connection details point at (${instanceName})
+	ServingName string
+}
+
+// blueGreenRender is the upgrade strategy's contribution to a render
+type blueGreenRender struct {
+	release ReleaseTarget
+	// servingKey is the key of the release currently serving the instance, which readiness is based on
+	servingKey string
+	// retained holds the previous release, re-emitted as observed while it's still needed
+	retained map[string]*fnv1.Resource
+	// status holds status.upgrade, nil if no blue/green upgrade ever ran
+	status map[string]any
+}
+
+// getUpgradeStrategy extracts upgradeStrategy.type from service config, defaulting to inPlace
+func getUpgradeStrategy(serviceConfig map[string]any) (string, error) {
+	upgrade, ok := serviceConfig["upgradeStrategy"].(map[string]any)
+	if !ok {
+		return UpgradeStrategyInPlace, nil
+	}
+	strategy, _ := upgrade["type"].(string)
+	switch strategy {
+	case "":
+		return UpgradeStrategyInPlace, nil
+	case UpgradeStrategyInPlace, UpgradeStrategyBlueGreen:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown upgrade strategy %q", strategy)
+	}
+}
+
+// activeReleaseKey returns the desired resource key of the release currently serving the instance
+func activeReleaseKey(composite *fnv1.Resource) string {
+	key, err := fieldpath.Pave(composite.GetResource().AsMap()).GetString("status.upgrade.activeKey")
+	if err != nil || key == "" {
+		return releaseKey
+	}
+	return key
+}
+
+// planBlueGreen decides which release to render for the chart version in mergedConfig
+// With the blueGreen strategy a version change deploys a second release in the other slot,
+// flips connection details once it's ready and drops the old release on the following reconcile
+func planBlueGreen(
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	serviceConfig, mergedConfig map[string]any,
+	results *Results,
+	log logr.Logger,
+) (*blueGreenRender, error) {
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+	instanceName, err := getInstanceName(composite, mergedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The active slot is recorded in status once a blue/green upgrade has flipped
+	active := ReleaseTarget{Key: releaseKey, Name: instanceName}
+	var upgrade map[string]any
+	if observed, err := paved.GetValue("status.upgrade"); err == nil {
+		upgrade, _ = observed.(map[string]any)
+	}
+	if key, _ := upgrade["activeKey"].(string); key != "" {
+		active.Key = key
+		active.Name, _ = upgrade["activeRelease"].(string)
+	}
+	active.ServingName = active.Name
+
+	render := &blueGreenRender{release: active, servingKey: active.Key, status: upgrade}
+
+	strategy, err := getUpgradeStrategy(serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+	if strategy != UpgradeStrategyBlueGreen {
+		return render, nil
+	}
+
+	_, _, targetVersion, err := extractChartConfig(mergedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// The previous render flipped over; the old release is no longer needed
+	if phase, _ := upgrade["phase"].(string); phase == UpgradePhaseFlipped {
+		render.status = upgradeStatus(active, upgrade["fromVersion"], upgrade["toVersion"], UpgradePhaseCompleted)
+		log.Info("Blue/green upgrade complete, removing previous release", "active", active.Name)
+		results.Normal("UpgradeCompleted", "Removed previous release, %s serves version %v", active.Name, upgrade["toVersion"])
+		return render, nil
+	}
+
+	observedActive := observedResources[active.Key]
+	activeVersion := observedChartVersion(observedActive)
+	if observedActive == nil || activeVersion == targetVersion {
+		return render, nil
+	}
+
+	next := ReleaseTarget{Key: nextReleaseKey, Name: instanceName + nextSuffix}
+	if active.Key == nextReleaseKey {
+		next = ReleaseTarget{Key: releaseKey, Name: instanceName}
+	}
+
+	// The old release keeps its spec, externalized values and, with Flux, its chart source until the flip
+	render.retained = map[string]*fnv1.Resource{}
+	for _, key := range []string{active.Key, valuesSecretKey(active.Key), fluxRepositoryKey(active.Key)} {
+		observed, ok := observedResources[key]
+		if !ok {
+			continue
+		}
+		retained, err := observedDesired(observed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retain %s of release %s: %w", key, active.Name, err)
+		}
+		render.retained[key] = retained
+	}
+
+	observedNext := observedResources[next.Key]
+	if observedNext == nil || observedChartVersion(observedNext) != targetVersion || !isObservedReady(observedNext) {
+		next.ServingName = active.Name
+		render.release = next
+		render.status = upgradeStatus(active, activeVersion, targetVersion, UpgradePhaseDeploying)
+		log.Info("Blue/green upgrade deploying next release", "active", active.Name, "next", next.Name, "version", targetVersion)
+		results.Normal("UpgradeDeploying", "Deploying %s with version %s alongside %s", next.Name, targetVersion, active.Name)
+		return render, nil
+	}
+
+	// The next release is ready: point connection details at it and make it the active slot
+	next.ServingName = next.Name
+	render.release = next
+	render.servingKey = next.Key
+	render.status = upgradeStatus(next, activeVersion, targetVersion, UpgradePhaseFlipped)
+	log.Info("Blue/green upgrade flipped to next release", "previous", active.Name, "active", next.Name)
+	results.Normal("UpgradeFlipped", "Connection details now point at %s (version %s), removing %s next", next.Name, targetVersion, active.Name)
+	return render, nil
+}
+
+// valuesSecretKey returns the desired resource key of the externalized values of a release slot
+func valuesSecretKey(key string) string {
+	if key == nextReleaseKey {
+		return "values-secret" + nextSuffix
+	}
+	return "values-secret"
+}
+
+// observedChartVersion returns the chart version of an observed Release
+func observedChartVersion(release *fnv1.Resource) string {
+	if release == nil {
+		return ""
+	}
+	version, _ := fieldpath.Pave(release.GetResource().AsMap()).GetString(releaseChartVersionPath(release))
+	return version
+}
+
+// observedDesired returns an observed resource as a desired resource, keeping its spec or data unchanged
+// Server-populated metadata and status are dropped
+func observedDesired(res *fnv1.Resource) (*fnv1.Resource, error) {
+	paved := fieldpath.Pave(res.GetResource().AsMap())
+
+	desired := map[string]any{}
+	for _, path := range []string{"apiVersion", "kind", "metadata.name", "metadata.namespace", "metadata.labels", "metadata.annotations", "spec", "data"} {
+		value, err := paved.GetValue(path)
+		if err != nil {
+			continue
+		}
+		if err := setValueByPath(desired, path, value); err != nil {
+			return nil, err
+		}
+	}
+
+	desiredStruct, err := structpb.NewStruct(desired)
+	if err != nil {
+		return nil, err
+	}
+	return &fnv1.Resource{Resource: desiredStruct}, nil
+}
+
+// upgradeStatus builds status.upgrade
+func upgradeStatus(active ReleaseTarget, fromVersion, toVersion any, phase string) map[string]any {
+	return map[string]any{
+		"activeKey":     active.Key,
+		"activeRelease": active.Name,
+		"fromVersion":   fromVersion,
+		"toVersion":     toVersion,
+		"phase":         phase,
+	}
+}
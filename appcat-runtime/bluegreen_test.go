@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPlanBlueGreen(t *testing.T) {
+	toResource := func(object map[string]any) *fnv1.Resource {
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	release := func(version, ready string) *fnv1.Resource {
+		return toResource(map[string]any{
+			"apiVersion": "helm.m.crossplane.io/v1beta1",
+			"kind":       "Release",
+			"spec":       map[string]any{"forProvider": map[string]any{"chart": map[string]any{"version": version}}},
+			"status":     map[string]any{"conditions": []any{map[string]any{"type": "Ready", "status": ready}}},
+		})
+	}
+	composite := func(upgrade map[string]any) *fnv1.Resource {
+		object := map[string]any{"metadata": map[string]any{"name": "redis-a", "namespace": "team"}}
+		if upgrade != nil {
+			object["status"] = map[string]any{"upgrade": upgrade}
+		}
+		return toResource(object)
+	}
+	blueGreen := map[string]any{"upgradeStrategy": map[string]any{"type": UpgradeStrategyBlueGreen}}
+	nextActive := map[string]any{"activeKey": nextReleaseKey, "activeRelease": "redis-a-next", "phase": UpgradePhaseCompleted}
+
+	cases := map[string]struct {
+		serviceConfig map[string]any
+		upgrade       map[string]any
+		observed      map[string]*fnv1.Resource
+		wantRelease   ReleaseTarget
+		wantServing   string
+		wantPhase     string
+		wantRetained  bool
+	}{
+		"InPlace": {
+			serviceConfig: map[string]any{},
+			observed:      map[string]*fnv1.Resource{releaseKey: release("1.0.0", "True")},
+			wantRelease:   ReleaseTarget{Key: releaseKey, Name: "redis-a", ServingName: "redis-a"},
+			wantServing:   releaseKey,
+		},
+		"NewInstance": {
+			serviceConfig: blueGreen,
+			wantRelease:   ReleaseTarget{Key: releaseKey, Name: "redis-a", ServingName: "redis-a"},
+			wantServing:   releaseKey,
+		},
+		"SameVersion": {
+			serviceConfig: blueGreen,
+			observed:      map[string]*fnv1.Resource{releaseKey: release("2.0.0", "True")},
+			wantRelease:   ReleaseTarget{Key: releaseKey, Name: "redis-a", ServingName: "redis-a"},
+			wantServing:   releaseKey,
+		},
+		"Deploying": {
+			serviceConfig: blueGreen,
+			observed:      map[string]*fnv1.Resource{releaseKey: release("1.0.0", "True")},
+			wantRelease:   ReleaseTarget{Key: nextReleaseKey, Name: "redis-a-next", ServingName: "redis-a"},
+			wantServing:   releaseKey,
+			wantPhase:     UpgradePhaseDeploying,
+			wantRetained:  true,
+		},
+		"NextNotReady": {
+			serviceConfig: blueGreen,
+			observed:      map[string]*fnv1.Resource{releaseKey: release("1.0.0", "True"), nextReleaseKey: release("2.0.0", "False")},
+			wantRelease:   ReleaseTarget{Key: nextReleaseKey, Name: "redis-a-next", ServingName: "redis-a"},
+			wantServing:   releaseKey,
+			wantPhase:     UpgradePhaseDeploying,
+			wantRetained:  true,
+		},
+		"Flipped": {
+			serviceConfig: blueGreen,
+			observed:      map[string]*fnv1.Resource{releaseKey: release("1.0.0", "True"), nextReleaseKey: release("2.0.0", "True")},
+			wantRelease:   ReleaseTarget{Key: nextReleaseKey, Name: "redis-a-next", ServingName: "redis-a-next"},
+			wantServing:   nextReleaseKey,
+			wantPhase:     UpgradePhaseFlipped,
+			wantRetained:  true,
+		},
+		"Completed": {
+			serviceConfig: blueGreen,
+			upgrade:       map[string]any{"activeKey": nextReleaseKey, "activeRelease": "redis-a-next", "phase": UpgradePhaseFlipped},
+			observed:      map[string]*fnv1.Resource{releaseKey: release("1.0.0", "True"), nextReleaseKey: release("2.0.0", "True")},
+			wantRelease:   ReleaseTarget{Key: nextReleaseKey, Name: "redis-a-next", ServingName: "redis-a-next"},
+			wantServing:   nextReleaseKey,
+			wantPhase:     UpgradePhaseCompleted,
+		},
+		"DeployingBackToFirstSlot": {
+			serviceConfig: blueGreen,
+			upgrade:       nextActive,
+			observed:      map[string]*fnv1.Resource{nextReleaseKey: release("1.0.0", "True")},
+			wantRelease:   ReleaseTarget{Key: releaseKey, Name: "redis-a", ServingName: "redis-a-next"},
+			wantServing:   nextReleaseKey,
+			wantPhase:     UpgradePhaseDeploying,
+			wantRetained:  true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mergedConfig := map[string]any{"chart": map[string]any{"repository": "https://charts.example.com", "name": "redis", "defaultVersion": "2.0.0"}}
+			render, err := planBlueGreen(composite(tc.upgrade), tc.observed, tc.serviceConfig, mergedConfig, &Results{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("planBlueGreen() error = %v", err)
+			}
+			if render.release != tc.wantRelease {
+				t.Errorf("release = %+v, want %+v", render.release, tc.wantRelease)
+			}
+			if render.servingKey != tc.wantServing {
+				t.Errorf("servingKey = %q, want %q", render.servingKey, tc.wantServing)
+			}
+			if phase, _ := render.status["phase"].(string); tc.wantPhase != "" && phase != tc.wantPhase {
+				t.Errorf("phase = %q, want %q", phase, tc.wantPhase)
+			}
+			if retained := len(render.retained) > 0; retained != tc.wantRetained {
+				t.Errorf("previous release retained = %v, want %v", retained, tc.wantRetained)
+			}
+		})
+	}
+}
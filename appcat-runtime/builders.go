@@ -2,28 +2,41 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
 	helmv1 "github.com/crossplane-contrib/provider-helm/apis/namespaced/release/v1beta1"
+	xpcommon "github.com/crossplane/crossplane-runtime/v2/apis/common"
+	xpv2 "github.com/crossplane/crossplane-runtime/v2/apis/common/v2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // SecretBuilder builds Kubernetes Secret objects using fluent API
 type SecretBuilder struct {
-	name      string
-	namespace string
-	data      map[string][]byte
-	labels    map[string]string
+	name        string
+	namespace   string
+	data        map[string][]byte
+	labels      map[string]string
+	annotations map[string]string
+	err         error
 }
 
 // NewSecretBuilder creates a new secret builder
 func NewSecretBuilder(name, namespace string) *SecretBuilder {
 	return &SecretBuilder{
-		name:      name,
-		namespace: namespace,
-		data:      make(map[string][]byte),
-		labels:    make(map[string]string),
+		name:        name,
+		namespace:   namespace,
+		data:        make(map[string][]byte),
+		labels:      make(map[string]string),
+		annotations: make(map[string]string),
 	}
 }
 
@@ -33,19 +46,110 @@ func (b *SecretBuilder) WithData(key string, value []byte) *SecretBuilder {
 	return b
 }
 
+// WithGeneratedTLS adds a self-signed certificate for the given SANs (DNS names or IPs) as tls.crt, its key
+// as tls.key and the certificate as ca.crt, for services that need TLS without cert-manager
+// The keypair is derived from seed, a stable secret such as a generated password, so every render
+// produces the same certificate; changing the seed rotates it
+func (b *SecretBuilder) WithGeneratedTLS(seed []byte, commonName string, sans ...string) *SecretBuilder {
+	cert, key, err := generateSelfSignedTLS(seed, commonName, sans)
+	if err != nil {
+		b.err = fmt.Errorf("failed to generate TLS keypair: %w", err)
+		return b
+	}
+	b.data["tls.crt"] = cert
+	b.data["tls.key"] = key
+	b.data["ca.crt"] = cert
+	return b
+}
+
+// WithGeneratedSSHKeypair adds an Ed25519 SSH keypair as ssh-privatekey (OpenSSH format) and
+// ssh-publickey (authorized_keys line), derived from seed like WithGeneratedTLS
+func (b *SecretBuilder) WithGeneratedSSHKeypair(seed []byte, comment string) *SecretBuilder {
+	privateKey, publicKey, err := generateSSHKeypair(seed, comment)
+	if err != nil {
+		b.err = fmt.Errorf("failed to generate SSH keypair: %w", err)
+		return b
+	}
+	b.data[corev1.SSHAuthPrivateKey] = privateKey
+	b.data["ssh-publickey"] = publicKey
+	return b
+}
+
+// Err returns the error of a failed generator (e.g. WithGeneratedTLS), to be checked before Build
+func (b *SecretBuilder) Err() error {
+	return b.err
+}
+
 // WithLabel adds a label to the secret
 func (b *SecretBuilder) WithLabel(key, value string) *SecretBuilder {
 	b.labels[key] = value
 	return b
 }
 
+// WithAnnotation adds an annotation to the secret
+func (b *SecretBuilder) WithAnnotation(key, value string) *SecretBuilder {
+	b.annotations[key] = value
+	return b
+}
+
 // Build creates the Secret object
 func (b *SecretBuilder) Build() *corev1.Secret {
+	var annotations map[string]string
+	if len(b.annotations) > 0 {
+		annotations = b.annotations
+	}
 	return &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
 			Kind:       "Secret",
 		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        b.name,
+			Namespace:   b.namespace,
+			Labels:      b.labels,
+			Annotations: annotations,
+		},
+		Data: b.data,
+	}
+}
+
+// ConfigMapBuilder builds Kubernetes ConfigMap objects using fluent API
+type ConfigMapBuilder struct {
+	name      string
+	namespace string
+	data      map[string]string
+	labels    map[string]string
+}
+
+// NewConfigMapBuilder creates a new ConfigMap builder
+func NewConfigMapBuilder(name, namespace string) *ConfigMapBuilder {
+	return &ConfigMapBuilder{
+		name:      name,
+		namespace: namespace,
+		data:      make(map[string]string),
+		labels:    make(map[string]string),
+	}
+}
+
+// WithData adds string data to the ConfigMap
+func (b *ConfigMapBuilder) WithData(key, value string) *ConfigMapBuilder {
+	b.data[key] = value
+	return b
+}
+
+// WithLabel adds a label to the ConfigMap
+func (b *ConfigMapBuilder) WithLabel(key, value string) *ConfigMapBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the ConfigMap object
+func (b *ConfigMapBuilder) Build() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      b.name,
 			Namespace: b.namespace,
@@ -62,8 +166,23 @@ type HelmReleaseBuilder struct {
 	chartRepo    string
 	chartName    string
 	chartVersion string
+	chartURL     string
 	values       map[string]any
+	valuesFrom   []helmv1.ValueFromSource
+	set          []helmv1.SetVal
 	labels       map[string]string
+	// pullSecret names the Secret in the Release's namespace holding the repository's username and password
+	pullSecret string
+	// providerConfig selects the target cluster; nil uses the provider's default provider config
+	providerConfig      *xpcommon.ProviderConfigReference
+	wait                bool
+	waitTimeout         time.Duration
+	skipCreateNamespace bool
+	skipCRDs            bool
+	rollbackLimit       *int32
+	// connectionDetails are read from the deployed objects into the connection secret
+	connectionDetails []helmv1.ConnectionDetail
+	connectionSecret  string
 }
 
 // NewHelmReleaseBuilder creates a new HelmRelease builder
@@ -72,6 +191,8 @@ func NewHelmReleaseBuilder(name string) *HelmReleaseBuilder {
 		name:   name,
 		values: make(map[string]any),
 		labels: make(map[string]string),
+		// The Release lives in the instance namespace, which exists already
+		skipCreateNamespace: true,
 	}
 }
 
@@ -89,12 +210,102 @@ func (b *HelmReleaseBuilder) WithChart(repo, name, version string) *HelmReleaseB
 	return b
 }
 
+// WithChartURL sets the URL the chart is pulled from, e.g. oci://ghcr.io/vshn/charts/redis:18.0.0
+// provider-helm pulls from the URL instead of the repository; the chart's repository, name and version are kept
+// for readers of the Release
+func (b *HelmReleaseBuilder) WithChartURL(url string) *HelmReleaseBuilder {
+	b.chartURL = url
+	return b
+}
+
+// WithPullSecretRef sets the Secret, in the Release's namespace, holding the username and password of an
+// authenticated chart repository or OCI registry
+func (b *HelmReleaseBuilder) WithPullSecretRef(name string) *HelmReleaseBuilder {
+	b.pullSecret = name
+	return b
+}
+
 // WithValues sets the Helm values
 func (b *HelmReleaseBuilder) WithValues(values map[string]any) *HelmReleaseBuilder {
 	b.values = values
 	return b
 }
 
+// WithValuesFrom adds a Secret or ConfigMap key holding a values document
+// provider-helm merges valuesFrom in order and before inline values, so later sources and inline values take precedence
+func (b *HelmReleaseBuilder) WithValuesFrom(source helmv1.ValueFromSource) *HelmReleaseBuilder {
+	b.valuesFrom = append(b.valuesFrom, source)
+	return b
+}
+
+// WithValuesFromConfigMap adds a ConfigMap key holding a values document
+func (b *HelmReleaseBuilder) WithValuesFromConfigMap(name, key string) *HelmReleaseBuilder {
+	return b.WithValuesFrom(helmv1.ValueFromSource{
+		ConfigMapKeyRef: &helmv1.DataKeySelector{Name: name, Key: key},
+	})
+}
+
+// WithValuesFromSecret adds a Secret key holding a values document, e.g. a license kept out of the Release spec
+func (b *HelmReleaseBuilder) WithValuesFromSecret(name, key string) *HelmReleaseBuilder {
+	return b.WithValuesFrom(helmv1.ValueFromSource{
+		SecretKeyRef: &helmv1.DataKeySelector{Name: name, Key: key},
+	})
+}
+
+// WithValueFrom sets a single value at path from a Secret or ConfigMap key, e.g. auth.password
+// provider-helm applies these last, so they take precedence over all values documents
+func (b *HelmReleaseBuilder) WithValueFrom(path string, source helmv1.ValueFromSource) *HelmReleaseBuilder {
+	b.set = append(b.set, helmv1.SetVal{Name: path, ValueFrom: &source})
+	return b
+}
+
+// WithProviderConfigRef sets the provider config, and so the cluster, the chart is installed on
+// kind is ProviderConfig (in the Release's namespace) or ClusterProviderConfig
+func (b *HelmReleaseBuilder) WithProviderConfigRef(kind, name string) *HelmReleaseBuilder {
+	b.providerConfig = &xpcommon.ProviderConfigReference{Kind: kind, Name: name}
+	return b
+}
+
+// WithWait makes helm wait until the release's resources are ready before marking it deployed
+// A zero timeout keeps helm's default of 5m
+func (b *HelmReleaseBuilder) WithWait(timeout time.Duration) *HelmReleaseBuilder {
+	b.wait = true
+	b.waitTimeout = timeout
+	return b
+}
+
+// WithSkipCreateNamespace sets whether helm leaves creating the release namespace to others
+// Releases skip it by default; target clusters other than the control plane may need it created
+func (b *HelmReleaseBuilder) WithSkipCreateNamespace(skip bool) *HelmReleaseBuilder {
+	b.skipCreateNamespace = skip
+	return b
+}
+
+// WithSkipCRDs skips installing the chart's CRDs, e.g. if the platform manages them
+func (b *HelmReleaseBuilder) WithSkipCRDs(skip bool) *HelmReleaseBuilder {
+	b.skipCRDs = skip
+	return b
+}
+
+// WithRollbackLimit sets how often provider-helm rolls back and retries a failed release before giving up
+func (b *HelmReleaseBuilder) WithRollbackLimit(limit int32) *HelmReleaseBuilder {
+	b.rollbackLimit = &limit
+	return b
+}
+
+// WithConnectionDetail adds a field of a deployed object, e.g. a Service's spec.clusterIP, to the Release's
+// connection secret; keys of Secrets (fieldPath data.<key>) are decoded
+func (b *HelmReleaseBuilder) WithConnectionDetail(detail helmv1.ConnectionDetail) *HelmReleaseBuilder {
+	b.connectionDetails = append(b.connectionDetails, detail)
+	return b
+}
+
+// WithConnectionSecret sets the Secret, in the Release's namespace, provider-helm writes the connection details to
+func (b *HelmReleaseBuilder) WithConnectionSecret(name string) *HelmReleaseBuilder {
+	b.connectionSecret = name
+	return b
+}
+
 // WithLabel adds a label to the HelmRelease
 func (b *HelmReleaseBuilder) WithLabel(key, value string) *HelmReleaseBuilder {
 	b.labels[key] = value
@@ -110,6 +321,15 @@ func (b *HelmReleaseBuilder) Build() *helmv1.Release {
 		valuesRaw.Raw = valuesJSON
 	}
 
+	var waitTimeout *metav1.Duration
+	if b.wait && b.waitTimeout > 0 {
+		waitTimeout = &metav1.Duration{Duration: b.waitTimeout}
+	}
+	var connectionSecret *xpcommon.LocalSecretReference
+	if b.connectionSecret != "" {
+		connectionSecret = &xpcommon.LocalSecretReference{Name: b.connectionSecret}
+	}
+
 	return &helmv1.Release{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "helm.m.crossplane.io/v1beta1",
@@ -121,17 +341,1277 @@ func (b *HelmReleaseBuilder) Build() *helmv1.Release {
 			Labels:    b.labels,
 		},
 		Spec: helmv1.ReleaseSpec{
+			ManagedResourceSpec: xpv2.ManagedResourceSpec{
+				ProviderConfigReference:          b.providerConfig,
+				WriteConnectionSecretToReference: connectionSecret,
+			},
+			ConnectionDetails:    b.connectionDetails,
+			RollbackRetriesLimit: b.rollbackLimit,
 			ForProvider: helmv1.ReleaseParameters{
 				Chart: helmv1.ChartSpec{
 					Repository: b.chartRepo,
 					Name:       b.chartName,
 					Version:    b.chartVersion,
+					URL:        b.chartURL,
+					PullSecretRef: xpcommon.LocalSecretReference{
+						Name: b.pullSecret,
+					},
 				},
-				SkipCreateNamespace: true,
+				SkipCreateNamespace: b.skipCreateNamespace,
+				Wait:                b.wait,
+				WaitTimeout:         waitTimeout,
+				SkipCRDs:            b.skipCRDs,
 				ValuesSpec: helmv1.ValuesSpec{
-					Values: valuesRaw,
+					Values:     valuesRaw,
+					ValuesFrom: b.valuesFrom,
+					Set:        b.set,
+				},
+			},
+		},
+	}
+}
+
+// NetworkPolicyBuilder builds networking.k8s.io/v1 NetworkPolicy objects using fluent API
+// Policies are ingress-only; a policy without any Allow* rule denies all ingress to the selected pods
+type NetworkPolicyBuilder struct {
+	name        string
+	namespace   string
+	podSelector map[string]string
+	ingress     []networkingv1.NetworkPolicyIngressRule
+	labels      map[string]string
+}
+
+// NewNetworkPolicyBuilder creates a new NetworkPolicy builder
+func NewNetworkPolicyBuilder(name, namespace string) *NetworkPolicyBuilder {
+	return &NetworkPolicyBuilder{
+		name:        name,
+		namespace:   namespace,
+		podSelector: make(map[string]string),
+		labels:      make(map[string]string),
+	}
+}
+
+// WithPodSelector sets the labels of the pods the policy applies to (empty selects all pods)
+func (b *NetworkPolicyBuilder) WithPodSelector(selector map[string]string) *NetworkPolicyBuilder {
+	b.podSelector = selector
+	return b
+}
+
+// AllowFromNamespace adds an ingress rule allowing traffic from all pods in the given namespace
+func (b *NetworkPolicyBuilder) AllowFromNamespace(namespace string) *NetworkPolicyBuilder {
+	b.ingress = append(b.ingress, networkingv1.NetworkPolicyIngressRule{
+		From: []networkingv1.NetworkPolicyPeer{
+			{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{corev1.LabelMetadataName: namespace},
 				},
 			},
 		},
+	})
+	return b
+}
+
+// AllowFromCIDRs adds an ingress rule allowing traffic from the given IP ranges to a single TCP port
+func (b *NetworkPolicyBuilder) AllowFromCIDRs(cidrs []string, port int32) *NetworkPolicyBuilder {
+	peers := make([]networkingv1.NetworkPolicyPeer, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+	}
+	protocol := corev1.ProtocolTCP
+	targetPort := intstr.FromInt32(port)
+	b.ingress = append(b.ingress, networkingv1.NetworkPolicyIngressRule{
+		From:  peers,
+		Ports: []networkingv1.NetworkPolicyPort{{Protocol: &protocol, Port: &targetPort}},
+	})
+	return b
+}
+
+// WithLabel adds a label to the NetworkPolicy
+func (b *NetworkPolicyBuilder) WithLabel(key, value string) *NetworkPolicyBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the NetworkPolicy object
+func (b *NetworkPolicyBuilder) Build() *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: b.namespace,
+			Labels:    b.labels,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: b.podSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     b.ingress,
+		},
+	}
+}
+
+// PodDisruptionBudgetBuilder builds policy/v1 PodDisruptionBudget objects using fluent API
+type PodDisruptionBudgetBuilder struct {
+	name         string
+	namespace    string
+	selector     map[string]string
+	minAvailable intstr.IntOrString
+	labels       map[string]string
+}
+
+// NewPodDisruptionBudgetBuilder creates a new PodDisruptionBudget builder
+// minAvailable defaults to 1
+func NewPodDisruptionBudgetBuilder(name, namespace string) *PodDisruptionBudgetBuilder {
+	return &PodDisruptionBudgetBuilder{
+		name:         name,
+		namespace:    namespace,
+		selector:     make(map[string]string),
+		minAvailable: intstr.FromInt32(1),
+		labels:       make(map[string]string),
+	}
+}
+
+// WithSelector sets the labels of the pods covered by the budget
+func (b *PodDisruptionBudgetBuilder) WithSelector(selector map[string]string) *PodDisruptionBudgetBuilder {
+	b.selector = selector
+	return b
+}
+
+// WithMinAvailable sets the minimum number (or percentage, e.g. "50%") of available pods
+func (b *PodDisruptionBudgetBuilder) WithMinAvailable(minAvailable intstr.IntOrString) *PodDisruptionBudgetBuilder {
+	b.minAvailable = minAvailable
+	return b
+}
+
+// WithLabel adds a label to the PodDisruptionBudget
+func (b *PodDisruptionBudgetBuilder) WithLabel(key, value string) *PodDisruptionBudgetBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the PodDisruptionBudget object
+func (b *PodDisruptionBudgetBuilder) Build() *policyv1.PodDisruptionBudget {
+	minAvailable := b.minAvailable
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "policy/v1",
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: b.namespace,
+			Labels:    b.labels,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector:     &metav1.LabelSelector{MatchLabels: b.selector},
+			MinAvailable: &minAvailable,
+		},
+	}
+}
+
+// LogOutputBuilder builds fluentbit.fluent.io/v1alpha2 Output objects using fluent API
+// fluent-operator's CRDs aren't vendored, so the Output is built unstructured
+type LogOutputBuilder struct {
+	name      string
+	namespace string
+	match     string
+	plugin    string
+	settings  map[string]any
+	tls       bool
+	labels    map[string]string
+}
+
+// NewLogOutputBuilder creates a new Output builder
+func NewLogOutputBuilder(name, namespace string) *LogOutputBuilder {
+	return &LogOutputBuilder{
+		name:      name,
+		namespace: namespace,
+		settings:  make(map[string]any),
+		labels:    make(map[string]string),
+	}
+}
+
+// WithMatch sets the tag pattern of the log records shipped by this Output
+func (b *LogOutputBuilder) WithMatch(match string) *LogOutputBuilder {
+	b.match = match
+	return b
+}
+
+// WithPlugin sets the output plugin (e.g. "loki", "http", "syslog") and its settings
+func (b *LogOutputBuilder) WithPlugin(plugin string, settings map[string]any) *LogOutputBuilder {
+	b.plugin = plugin
+	b.settings = settings
+	return b
+}
+
+// WithTLS enables TLS with certificate verification towards the sink
+func (b *LogOutputBuilder) WithTLS() *LogOutputBuilder {
+	b.tls = true
+	return b
+}
+
+// WithLabel adds a label to the Output
+func (b *LogOutputBuilder) WithLabel(key, value string) *LogOutputBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Output object
+func (b *LogOutputBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	spec := map[string]any{
+		"match":  b.match,
+		b.plugin: b.settings,
+	}
+	if b.tls {
+		spec["tls"] = map[string]any{"verify": true}
+	}
+
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "fluentbit.fluent.io/v1alpha2",
+		"kind":       "Output",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": spec,
+	}}
+}
+
+// PushSecretBuilder builds external-secrets.io/v1alpha1 PushSecret objects using fluent API
+// ESO's CRDs aren't vendored, so the PushSecret is built unstructured
+type PushSecretBuilder struct {
+	name            string
+	namespace       string
+	storeName       string
+	storeKind       string
+	secretName      string
+	refreshInterval string
+	data            []any
+	labels          map[string]string
+}
+
+// NewPushSecretBuilder creates a new PushSecret builder
+func NewPushSecretBuilder(name, namespace string) *PushSecretBuilder {
+	return &PushSecretBuilder{
+		name:      name,
+		namespace: namespace,
+		labels:    make(map[string]string),
+	}
+}
+
+// WithStore sets the SecretStore or ClusterSecretStore the Secret is pushed to
+func (b *PushSecretBuilder) WithStore(name, kind string) *PushSecretBuilder {
+	b.storeName = name
+	b.storeKind = kind
+	return b
+}
+
+// WithSecret sets the Secret, in the PushSecret's namespace, that is pushed
+func (b *PushSecretBuilder) WithSecret(name string) *PushSecretBuilder {
+	b.secretName = name
+	return b
+}
+
+// WithRefreshInterval sets how often the Secret is pushed again
+func (b *PushSecretBuilder) WithRefreshInterval(interval string) *PushSecretBuilder {
+	b.refreshInterval = interval
+	return b
+}
+
+// WithData pushes a key of the Secret to a property of the remote key
+func (b *PushSecretBuilder) WithData(secretKey, remoteKey, property string) *PushSecretBuilder {
+	b.data = append(b.data, map[string]any{
+		"match": map[string]any{
+			"secretKey": secretKey,
+			"remoteRef": map[string]any{
+				"remoteKey": remoteKey,
+				"property":  property,
+			},
+		},
+	})
+	return b
+}
+
+// WithLabel adds a label to the PushSecret
+func (b *PushSecretBuilder) WithLabel(key, value string) *PushSecretBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured PushSecret object
+func (b *PushSecretBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "external-secrets.io/v1alpha1",
+		"kind":       "PushSecret",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]any{
+			"refreshInterval": b.refreshInterval,
+			"secretStoreRefs": []any{map[string]any{"name": b.storeName, "kind": b.storeKind}},
+			"selector":        map[string]any{"secret": map[string]any{"name": b.secretName}},
+			"data":            b.data,
+		},
+	}}
+}
+
+// ExternalSecretBuilder builds external-secrets.io/v1beta1 ExternalSecret objects using fluent API
+// ESO's CRDs aren't vendored, so the ExternalSecret is built unstructured
+type ExternalSecretBuilder struct {
+	name            string
+	namespace       string
+	storeName       string
+	storeKind       string
+	targetName      string
+	refreshInterval string
+	data            []any
+	labels          map[string]string
+}
+
+// NewExternalSecretBuilder creates a new ExternalSecret builder
+func NewExternalSecretBuilder(name, namespace string) *ExternalSecretBuilder {
+	return &ExternalSecretBuilder{
+		name:       name,
+		namespace:  namespace,
+		targetName: name,
+		labels:     make(map[string]string),
+	}
+}
+
+// WithStore sets the SecretStore or ClusterSecretStore the Secret is synced from
+func (b *ExternalSecretBuilder) WithStore(name, kind string) *ExternalSecretBuilder {
+	b.storeName = name
+	b.storeKind = kind
+	return b
+}
+
+// WithTarget sets the Secret, in the ExternalSecret's namespace, ESO creates and owns
+func (b *ExternalSecretBuilder) WithTarget(name string) *ExternalSecretBuilder {
+	b.targetName = name
+	return b
+}
+
+// WithRefreshInterval sets how often the Secret is synced again
+func (b *ExternalSecretBuilder) WithRefreshInterval(interval string) *ExternalSecretBuilder {
+	b.refreshInterval = interval
+	return b
+}
+
+// WithData syncs a property of the remote key into a key of the Secret
+func (b *ExternalSecretBuilder) WithData(secretKey, remoteKey, property string) *ExternalSecretBuilder {
+	remoteRef := map[string]any{"key": remoteKey}
+	if property != "" {
+		remoteRef["property"] = property
+	}
+	b.data = append(b.data, map[string]any{"secretKey": secretKey, "remoteRef": remoteRef})
+	return b
+}
+
+// WithLabel adds a label to the ExternalSecret
+func (b *ExternalSecretBuilder) WithLabel(key, value string) *ExternalSecretBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured ExternalSecret object
+func (b *ExternalSecretBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "external-secrets.io/v1beta1",
+		"kind":       "ExternalSecret",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]any{
+			"refreshInterval": b.refreshInterval,
+			"secretStoreRef":  map[string]any{"name": b.storeName, "kind": b.storeKind},
+			"target":          map[string]any{"name": b.targetName, "creationPolicy": "Owner"},
+			"data":            b.data,
+		},
+	}}
+}
+
+// ServiceAccountBuilder builds Kubernetes ServiceAccount objects using fluent API
+type ServiceAccountBuilder struct {
+	name      string
+	namespace string
+	labels    map[string]string
+}
+
+// NewServiceAccountBuilder creates a new ServiceAccount builder
+func NewServiceAccountBuilder(name, namespace string) *ServiceAccountBuilder {
+	return &ServiceAccountBuilder{
+		name:      name,
+		namespace: namespace,
+		labels:    make(map[string]string),
+	}
+}
+
+// WithLabel adds a label to the ServiceAccount
+func (b *ServiceAccountBuilder) WithLabel(key, value string) *ServiceAccountBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the ServiceAccount object
+func (b *ServiceAccountBuilder) Build() *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: b.namespace,
+			Labels:    b.labels,
+		},
+	}
+}
+
+// RoleBuilder builds rbac.authorization.k8s.io/v1 Role objects using fluent API
+type RoleBuilder struct {
+	name      string
+	namespace string
+	rules     []rbacv1.PolicyRule
+	labels    map[string]string
+}
+
+// NewRoleBuilder creates a new Role builder
+func NewRoleBuilder(name, namespace string) *RoleBuilder {
+	return &RoleBuilder{
+		name:      name,
+		namespace: namespace,
+		labels:    make(map[string]string),
+	}
+}
+
+// WithRule allows verbs on resources of an API group ("" for the core group)
+func (b *RoleBuilder) WithRule(apiGroup string, resources, verbs []string) *RoleBuilder {
+	b.rules = append(b.rules, rbacv1.PolicyRule{
+		APIGroups: []string{apiGroup},
+		Resources: resources,
+		Verbs:     verbs,
+	})
+	return b
+}
+
+// WithLabel adds a label to the Role
+func (b *RoleBuilder) WithLabel(key, value string) *RoleBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the Role object
+func (b *RoleBuilder) Build() *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: b.namespace,
+			Labels:    b.labels,
+		},
+		Rules: b.rules,
+	}
+}
+
+// RoleBindingBuilder builds rbac.authorization.k8s.io/v1 RoleBinding objects using fluent API
+type RoleBindingBuilder struct {
+	name      string
+	namespace string
+	roleRef   rbacv1.RoleRef
+	subjects  []rbacv1.Subject
+	labels    map[string]string
+}
+
+// NewRoleBindingBuilder creates a new RoleBinding builder
+func NewRoleBindingBuilder(name, namespace string) *RoleBindingBuilder {
+	return &RoleBindingBuilder{
+		name:      name,
+		namespace: namespace,
+		labels:    make(map[string]string),
+	}
+}
+
+// WithRole binds a Role of the binding's namespace
+func (b *RoleBindingBuilder) WithRole(name string) *RoleBindingBuilder {
+	b.roleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name}
+	return b
+}
+
+// WithClusterRole binds a ClusterRole within the binding's namespace
+func (b *RoleBindingBuilder) WithClusterRole(name string) *RoleBindingBuilder {
+	b.roleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name}
+	return b
+}
+
+// WithGroup adds a user group as subject
+func (b *RoleBindingBuilder) WithGroup(group string) *RoleBindingBuilder {
+	b.subjects = append(b.subjects, rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: group})
+	return b
+}
+
+// WithServiceAccount adds a ServiceAccount as subject
+func (b *RoleBindingBuilder) WithServiceAccount(name, namespace string) *RoleBindingBuilder {
+	b.subjects = append(b.subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: namespace})
+	return b
+}
+
+// WithLabel adds a label to the RoleBinding
+func (b *RoleBindingBuilder) WithLabel(key, value string) *RoleBindingBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the RoleBinding object
+func (b *RoleBindingBuilder) Build() *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "rbac.authorization.k8s.io/v1",
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: b.namespace,
+			Labels:    b.labels,
+		},
+		RoleRef:  b.roleRef,
+		Subjects: b.subjects,
+	}
+}
+
+// MonitorEndpoint is a scrape endpoint of a ServiceMonitor or PodMonitor
+type MonitorEndpoint struct {
+	// Port is the name of the metrics port; TargetPort is used if it's empty
+	Port       string
+	TargetPort int
+	Path       string
+	Interval   string
+	// Relabelings and MetricRelabelings are passed through as Prometheus Operator RelabelConfigs
+	Relabelings       []any
+	MetricRelabelings []any
+}
+
+// toMap renders the endpoint as a ServiceMonitor endpoint or PodMonitor podMetricsEndpoint
+func (e MonitorEndpoint) toMap() map[string]any {
+	endpoint := map[string]any{"path": e.Path}
+	if e.Port != "" {
+		endpoint["port"] = e.Port
+	} else {
+		endpoint["targetPort"] = int64(e.TargetPort)
+	}
+	if e.Interval != "" {
+		endpoint["interval"] = e.Interval
+	}
+	if len(e.Relabelings) > 0 {
+		endpoint["relabelings"] = e.Relabelings
+	}
+	if len(e.MetricRelabelings) > 0 {
+		endpoint["metricRelabelings"] = e.MetricRelabelings
+	}
+	return endpoint
+}
+
+// MonitorBuilder builds monitoring.coreos.com/v1 ServiceMonitor and PodMonitor objects using fluent API
+// Prometheus Operator's CRDs aren't vendored, so monitors are built unstructured
+type MonitorBuilder struct {
+	kind      string
+	name      string
+	namespace string
+	selector  map[string]string
+	endpoints []MonitorEndpoint
+	labels    map[string]string
+}
+
+// NewServiceMonitorBuilder creates a new ServiceMonitor builder, selecting Services
+func NewServiceMonitorBuilder(name, namespace string) *MonitorBuilder {
+	return &MonitorBuilder{kind: "ServiceMonitor", name: name, namespace: namespace, labels: make(map[string]string)}
+}
+
+// NewPodMonitorBuilder creates a new PodMonitor builder, selecting Pods
+func NewPodMonitorBuilder(name, namespace string) *MonitorBuilder {
+	return &MonitorBuilder{kind: "PodMonitor", name: name, namespace: namespace, labels: make(map[string]string)}
+}
+
+// WithSelector sets the labels of the scraped Services or Pods
+func (b *MonitorBuilder) WithSelector(selector map[string]string) *MonitorBuilder {
+	b.selector = selector
+	return b
+}
+
+// WithEndpoint adds a scrape endpoint
+func (b *MonitorBuilder) WithEndpoint(endpoint MonitorEndpoint) *MonitorBuilder {
+	b.endpoints = append(b.endpoints, endpoint)
+	return b
+}
+
+// WithLabel adds a label to the monitor, e.g. the label the platform's Prometheus selects monitors by
+func (b *MonitorBuilder) WithLabel(key, value string) *MonitorBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured ServiceMonitor or PodMonitor object
+func (b *MonitorBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	matchLabels := make(map[string]any, len(b.selector))
+	for key, value := range b.selector {
+		matchLabels[key] = value
+	}
+	endpoints := make([]any, 0, len(b.endpoints))
+	for _, endpoint := range b.endpoints {
+		endpoints = append(endpoints, endpoint.toMap())
+	}
+
+	endpointsField := "endpoints"
+	if b.kind == "PodMonitor" {
+		endpointsField = "podMetricsEndpoints"
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       b.kind,
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]any{
+			"selector":     map[string]any{"matchLabels": matchLabels},
+			endpointsField: endpoints,
+		},
+	}}
+}
+
+// PrometheusRuleBuilder builds monitoring.coreos.com/v1 PrometheusRule objects using fluent API
+// Prometheus Operator's CRDs aren't vendored, so the PrometheusRule is built unstructured
+type PrometheusRuleBuilder struct {
+	name      string
+	namespace string
+	groups    []any
+	labels    map[string]string
+}
+
+// NewPrometheusRuleBuilder creates a new PrometheusRule builder
+func NewPrometheusRuleBuilder(name, namespace string) *PrometheusRuleBuilder {
+	return &PrometheusRuleBuilder{
+		name:      name,
+		namespace: namespace,
+		labels:    make(map[string]string),
+	}
+}
+
+// WithGroup adds a rule group; rules are Prometheus alerting or recording rules
+func (b *PrometheusRuleBuilder) WithGroup(name string, rules []any) *PrometheusRuleBuilder {
+	b.groups = append(b.groups, map[string]any{"name": name, "rules": rules})
+	return b
+}
+
+// WithLabel adds a label to the PrometheusRule, e.g. the label the platform's Prometheus selects rules by
+func (b *PrometheusRuleBuilder) WithLabel(key, value string) *PrometheusRuleBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured PrometheusRule object
+func (b *PrometheusRuleBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       "PrometheusRule",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]any{"groups": b.groups},
+	}}
+}
+
+// BackupScheduleBuilder builds k8up.io/v1 Schedule objects using fluent API
+// K8up's CRDs aren't vendored, so the Schedule is built unstructured
+type BackupScheduleBuilder struct {
+	name      string
+	namespace string
+	backend   map[string]any
+	spec      map[string]any
+	labels    map[string]string
+}
+
+// NewBackupScheduleBuilder creates a new K8up Schedule builder
+func NewBackupScheduleBuilder(name, namespace string) *BackupScheduleBuilder {
+	return &BackupScheduleBuilder{
+		name:      name,
+		namespace: namespace,
+		backend:   map[string]any{},
+		spec:      map[string]any{},
+		labels:    make(map[string]string),
+	}
+}
+
+// WithS3Backend stores the restic repository in an S3 bucket
+// The credentials Secret holds the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY keys
+func (b *BackupScheduleBuilder) WithS3Backend(endpoint, bucket, credentialsSecret string) *BackupScheduleBuilder {
+	b.backend["s3"] = k8upS3Backend(endpoint, bucket, credentialsSecret)
+	return b
+}
+
+// WithRepositoryPassword sets the Secret key holding the password the restic repository is encrypted with
+func (b *BackupScheduleBuilder) WithRepositoryPassword(secretName, key string) *BackupScheduleBuilder {
+	b.backend["repoPasswordSecretRef"] = map[string]any{"name": secretName, "key": key}
+	return b
+}
+
+// WithBackup schedules backups; K8up accepts cron expressions and e.g. "@daily-random"
+func (b *BackupScheduleBuilder) WithBackup(schedule string) *BackupScheduleBuilder {
+	b.spec["backup"] = map[string]any{"schedule": schedule}
+	return b
+}
+
+// WithCheck schedules repository integrity checks
+func (b *BackupScheduleBuilder) WithCheck(schedule string) *BackupScheduleBuilder {
+	b.spec["check"] = map[string]any{"schedule": schedule}
+	return b
+}
+
+// WithPrune schedules pruning of snapshots outside the retention, e.g. {"keepDaily": 7}
+func (b *BackupScheduleBuilder) WithPrune(schedule string, retention map[string]int64) *BackupScheduleBuilder {
+	keep := make(map[string]any, len(retention))
+	for key, value := range retention {
+		keep[key] = value
+	}
+	b.spec["prune"] = map[string]any{"schedule": schedule, "retention": keep}
+	return b
+}
+
+// WithLabel adds a label to the Schedule
+func (b *BackupScheduleBuilder) WithLabel(key, value string) *BackupScheduleBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Schedule object
+func (b *BackupScheduleBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	spec := make(map[string]any, len(b.spec)+1)
+	for key, value := range b.spec {
+		spec[key] = value
+	}
+	spec["backend"] = b.backend
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "k8up.io/v1",
+		"kind":       "Schedule",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": spec,
+	}}
+}
+
+// UsageBuilder builds protection.crossplane.io/v1beta1 Usage objects using fluent API
+// A Usage blocks deleting the used resource while the using resource exists
+type UsageBuilder struct {
+	name      string
+	namespace string
+	of        map[string]any
+	by        map[string]any
+	reason    string
+	labels    map[string]string
+}
+
+// NewUsageBuilder creates a new Usage builder
+func NewUsageBuilder(name, namespace string) *UsageBuilder {
+	return &UsageBuilder{
+		name:      name,
+		namespace: namespace,
+		labels:    make(map[string]string),
+	}
+}
+
+// Of sets the resource that must not be deleted while it is used
+func (b *UsageBuilder) Of(apiVersion, kind, name string) *UsageBuilder {
+	b.of = map[string]any{"apiVersion": apiVersion, "kind": kind, "resourceRef": map[string]any{"name": name}}
+	return b
+}
+
+// By sets the resource using it
+func (b *UsageBuilder) By(apiVersion, kind, name string) *UsageBuilder {
+	b.by = map[string]any{"apiVersion": apiVersion, "kind": kind, "resourceRef": map[string]any{"name": name}}
+	return b
+}
+
+// WithReason blocks deletion for the given reason instead of while a using resource exists
+func (b *UsageBuilder) WithReason(reason string) *UsageBuilder {
+	b.reason = reason
+	return b
+}
+
+// WithLabel adds a label to the Usage
+func (b *UsageBuilder) WithLabel(key, value string) *UsageBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Usage object
+// Deletion of the used resource is replayed once the Usage is gone, so it doesn't wait for the next backoff
+func (b *UsageBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	spec := map[string]any{
+		"of":             b.of,
+		"replayDeletion": true,
+	}
+	if b.by != nil {
+		spec["by"] = b.by
+	}
+	if b.reason != "" {
+		spec["reason"] = b.reason
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "protection.crossplane.io/v1beta1",
+		"kind":       "Usage",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": spec,
+	}}
+}
+
+// k8upS3Backend is the S3 section of a K8up backend, shared by Schedules and Restores
+func k8upS3Backend(endpoint, bucket, credentialsSecret string) map[string]any {
+	return map[string]any{
+		"endpoint":                 endpoint,
+		"bucket":                   bucket,
+		"accessKeyIDSecretRef":     map[string]any{"name": credentialsSecret, "key": "AWS_ACCESS_KEY_ID"},
+		"secretAccessKeySecretRef": map[string]any{"name": credentialsSecret, "key": "AWS_SECRET_ACCESS_KEY"},
+	}
+}
+
+// RestoreBuilder builds k8up.io/v1 Restore objects using fluent API
+// Restores a restic snapshot into a PersistentVolumeClaim
+type RestoreBuilder struct {
+	name      string
+	namespace string
+	backend   map[string]any
+	snapshot  string
+	claimName string
+	labels    map[string]string
+}
+
+// NewRestoreBuilder creates a new K8up Restore builder
+func NewRestoreBuilder(name, namespace string) *RestoreBuilder {
+	return &RestoreBuilder{
+		name:      name,
+		namespace: namespace,
+		backend:   map[string]any{},
+		labels:    make(map[string]string),
+	}
+}
+
+// WithS3Backend reads the restic repository from an S3 bucket, see BackupScheduleBuilder.WithS3Backend
+func (b *RestoreBuilder) WithS3Backend(endpoint, bucket, credentialsSecret string) *RestoreBuilder {
+	b.backend["s3"] = k8upS3Backend(endpoint, bucket, credentialsSecret)
+	return b
+}
+
+// WithRepositoryPassword sets the Secret key holding the restic repository password
+func (b *RestoreBuilder) WithRepositoryPassword(secretName, key string) *RestoreBuilder {
+	b.backend["repoPasswordSecretRef"] = map[string]any{"name": secretName, "key": key}
+	return b
+}
+
+// WithSnapshot restores the given snapshot ID instead of the latest one
+func (b *RestoreBuilder) WithSnapshot(snapshot string) *RestoreBuilder {
+	b.snapshot = snapshot
+	return b
+}
+
+// WithClaim restores into the given PersistentVolumeClaim
+func (b *RestoreBuilder) WithClaim(claimName string) *RestoreBuilder {
+	b.claimName = claimName
+	return b
+}
+
+// WithLabel adds a label to the Restore
+func (b *RestoreBuilder) WithLabel(key, value string) *RestoreBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Restore object
+func (b *RestoreBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	spec := map[string]any{
+		"backend":       b.backend,
+		"restoreMethod": map[string]any{"folder": map[string]any{"claimName": b.claimName}},
+	}
+	if b.snapshot != "" {
+		spec["snapshot"] = b.snapshot
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "k8up.io/v1",
+		"kind":       "Restore",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": spec,
+	}}
+}
+
+// PersistentVolumeClaimBuilder builds Kubernetes PersistentVolumeClaim objects using fluent API
+type PersistentVolumeClaimBuilder struct {
+	name         string
+	namespace    string
+	size         resource.Quantity
+	storageClass string
+	labels       map[string]string
+}
+
+// NewPersistentVolumeClaimBuilder creates a new ReadWriteOnce PersistentVolumeClaim builder
+func NewPersistentVolumeClaimBuilder(name, namespace string, size resource.Quantity) *PersistentVolumeClaimBuilder {
+	return &PersistentVolumeClaimBuilder{
+		name:      name,
+		namespace: namespace,
+		size:      size,
+		labels:    make(map[string]string),
+	}
+}
+
+// WithStorageClass sets the storage class; the cluster default is used otherwise
+func (b *PersistentVolumeClaimBuilder) WithStorageClass(storageClass string) *PersistentVolumeClaimBuilder {
+	b.storageClass = storageClass
+	return b
+}
+
+// WithLabel adds a label to the PersistentVolumeClaim
+func (b *PersistentVolumeClaimBuilder) WithLabel(key, value string) *PersistentVolumeClaimBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the PersistentVolumeClaim object
+func (b *PersistentVolumeClaimBuilder) Build() *corev1.PersistentVolumeClaim {
+	claim := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.name,
+			Namespace: b.namespace,
+			Labels:    b.labels,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: b.size},
+			},
+		},
+	}
+	if b.storageClass != "" {
+		claim.Spec.StorageClassName = &b.storageClass
+	}
+	return claim
+}
+
+// IssuerBuilder builds cert-manager.io/v1 Issuer objects using fluent API
+type IssuerBuilder struct {
+	name      string
+	namespace string
+	spec      map[string]any
+	labels    map[string]string
+}
+
+// NewIssuerBuilder creates a new Issuer builder, issuing self-signed certificates unless configured otherwise
+func NewIssuerBuilder(name, namespace string) *IssuerBuilder {
+	return &IssuerBuilder{
+		name:      name,
+		namespace: namespace,
+		spec:      map[string]any{"selfSigned": map[string]any{}},
+		labels:    make(map[string]string),
+	}
+}
+
+// WithCA issues certificates signed by the CA keypair in the given Secret
+func (b *IssuerBuilder) WithCA(secretName string) *IssuerBuilder {
+	b.spec = map[string]any{"ca": map[string]any{"secretName": secretName}}
+	return b
+}
+
+// WithLabel adds a label to the Issuer
+func (b *IssuerBuilder) WithLabel(key, value string) *IssuerBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Issuer object
+func (b *IssuerBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Issuer",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": b.spec,
+	}}
+}
+
+// CertificateBuilder builds cert-manager.io/v1 Certificate objects using fluent API
+type CertificateBuilder struct {
+	name        string
+	namespace   string
+	secretName  string
+	issuer      string
+	commonName  string
+	dnsNames    []string
+	duration    string
+	renewBefore string
+	isCA        bool
+	labels      map[string]string
+}
+
+// NewCertificateBuilder creates a new Certificate builder storing the keypair in secretName
+func NewCertificateBuilder(name, namespace, secretName string) *CertificateBuilder {
+	return &CertificateBuilder{
+		name:       name,
+		namespace:  namespace,
+		secretName: secretName,
+		labels:     make(map[string]string),
+	}
+}
+
+// WithIssuer sets the Issuer in the Certificate's namespace signing it
+func (b *CertificateBuilder) WithIssuer(name string) *CertificateBuilder {
+	b.issuer = name
+	return b
+}
+
+// WithCommonName sets the certificate's common name
+func (b *CertificateBuilder) WithCommonName(commonName string) *CertificateBuilder {
+	b.commonName = commonName
+	return b
+}
+
+// WithDNSNames sets the certificate's subject alternative names
+func (b *CertificateBuilder) WithDNSNames(dnsNames ...string) *CertificateBuilder {
+	b.dnsNames = append(b.dnsNames, dnsNames...)
+	return b
+}
+
+// WithValidity sets how long the certificate is valid and how long before expiry it's renewed (e.g. "2160h", "360h")
+func (b *CertificateBuilder) WithValidity(duration, renewBefore string) *CertificateBuilder {
+	b.duration = duration
+	b.renewBefore = renewBefore
+	return b
+}
+
+// AsCA marks the certificate as a CA, e.g. for a CA Issuer
+func (b *CertificateBuilder) AsCA() *CertificateBuilder {
+	b.isCA = true
+	return b
+}
+
+// WithLabel adds a label to the Certificate
+func (b *CertificateBuilder) WithLabel(key, value string) *CertificateBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Certificate object
+func (b *CertificateBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	spec := map[string]any{
+		"secretName": b.secretName,
+		"issuerRef":  map[string]any{"name": b.issuer, "kind": "Issuer", "group": "cert-manager.io"},
+		"privateKey": map[string]any{"algorithm": "ECDSA", "size": int64(256), "rotationPolicy": "Always"},
+	}
+	if b.commonName != "" {
+		spec["commonName"] = b.commonName
+	}
+	if len(b.dnsNames) > 0 {
+		dnsNames := make([]any, 0, len(b.dnsNames))
+		for _, dnsName := range b.dnsNames {
+			dnsNames = append(dnsNames, dnsName)
+		}
+		spec["dnsNames"] = dnsNames
+	}
+	if b.duration != "" {
+		spec["duration"] = b.duration
+	}
+	if b.renewBefore != "" {
+		spec["renewBefore"] = b.renewBefore
+	}
+	if b.isCA {
+		spec["isCA"] = true
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "cert-manager.io/v1",
+		"kind":       "Certificate",
+		"metadata": map[string]any{
+			"name":      b.name,
+			"namespace": b.namespace,
+			"labels":    labels,
+		},
+		"spec": spec,
+	}}
+}
+
+// ObjectBuilder builds provider-kubernetes Object objects using fluent API
+// An Object wraps any manifest, which provider-kubernetes applies to the cluster of the Object's provider config
+// provider-kubernetes' CRDs aren't vendored, so the Object is built unstructured
+type ObjectBuilder struct {
+	name string
+	// namespace selects the namespaced kubernetes.m.crossplane.io/v1alpha1 Object; empty builds a cluster-scoped
+	// kubernetes.crossplane.io/v1alpha2 Object
+	namespace          string
+	manifest           map[string]any
+	managementPolicies []string
+	providerConfigKind string
+	providerConfig     string
+	readiness          map[string]any
+	labels             map[string]string
+}
+
+// NewObjectBuilder creates a new Object builder wrapping manifest
+func NewObjectBuilder(name string, manifest map[string]any) *ObjectBuilder {
+	return &ObjectBuilder{
+		name:     name,
+		manifest: manifest,
+		labels:   make(map[string]string),
+	}
+}
+
+// WithManagementPolicies sets what provider-kubernetes may do with the manifest, e.g. "Observe", "Create"
+// Without policies the Object is fully managed ("*")
+func (b *ObjectBuilder) WithManagementPolicies(policies ...string) *ObjectBuilder {
+	b.managementPolicies = append(b.managementPolicies, policies...)
+	return b
+}
+
+// WithNamespace builds a namespaced Object, which namespaced composites can compose
+func (b *ObjectBuilder) WithNamespace(namespace string) *ObjectBuilder {
+	b.namespace = namespace
+	return b
+}
+
+// WithProviderConfigRef sets the provider config, and so the cluster, the manifest is applied to
+// kind is ProviderConfig or ClusterProviderConfig and only set on namespaced Objects
+func (b *ObjectBuilder) WithProviderConfigRef(kind, name string) *ObjectBuilder {
+	b.providerConfigKind = kind
+	b.providerConfig = name
+	return b
+}
+
+// WithReadiness sets how the Object's readiness is derived, e.g. "SuccessfulCreate" or "DeriveFromObject"
+func (b *ObjectBuilder) WithReadiness(policy string) *ObjectBuilder {
+	b.readiness = map[string]any{"policy": policy}
+	return b
+}
+
+// WithReadinessQuery derives the Object's readiness from a CEL query over the observed manifest,
+// e.g. "object.status.phase == 'Running'"
+func (b *ObjectBuilder) WithReadinessQuery(query string) *ObjectBuilder {
+	b.readiness = map[string]any{"policy": "DeriveFromCelQuery", "celQuery": query}
+	return b
+}
+
+// WithLabel adds a label to the Object
+func (b *ObjectBuilder) WithLabel(key, value string) *ObjectBuilder {
+	b.labels[key] = value
+	return b
+}
+
+// Build creates the unstructured Object object
+func (b *ObjectBuilder) Build() *unstructured.Unstructured {
+	labels := make(map[string]any, len(b.labels))
+	for key, value := range b.labels {
+		labels[key] = value
+	}
+	spec := map[string]any{
+		"forProvider": map[string]any{"manifest": b.manifest},
+	}
+	if len(b.managementPolicies) > 0 {
+		policies := make([]any, 0, len(b.managementPolicies))
+		for _, policy := range b.managementPolicies {
+			policies = append(policies, policy)
+		}
+		spec["managementPolicies"] = policies
+	}
+	if b.readiness != nil {
+		spec["readiness"] = b.readiness
+	}
+	metadata := map[string]any{
+		"name":   b.name,
+		"labels": labels,
+	}
+	apiVersion := "kubernetes.crossplane.io/v1alpha2"
+	if b.namespace != "" {
+		apiVersion = "kubernetes.m.crossplane.io/v1alpha1"
+		metadata["namespace"] = b.namespace
+	}
+	if b.providerConfig != "" {
+		ref := map[string]any{"name": b.providerConfig}
+		if b.namespace != "" {
+			kind := b.providerConfigKind
+			if kind == "" {
+				kind = defaultProviderConfigKind
+			}
+			ref["kind"] = kind
+		}
+		spec["providerConfigRef"] = ref
 	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       "Object",
+		"metadata":   metadata,
+		"spec":       spec,
+	}}
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// Optional cluster capabilities generated resources may depend on
+const (
+	CapabilityPrometheusOperator = "PrometheusOperator"
+	CapabilityK8up               = "K8up"
+	CapabilityCertManager        = "CertManager"
+	CapabilityExternalSecrets    = "ExternalSecrets"
+	CapabilityFluentBit          = "FluentBit"
+	CapabilityNetworkPolicy      = "NetworkPolicy"
+)
+
+// requiredCapabilityPrefix prefixes the required resource names of capability probes
+const requiredCapabilityPrefix = "capability-"
+
+// capability is an optional API whose resources are skipped if the cluster doesn't provide it
+type capability struct {
+	// crd is the CustomResourceDefinition probed to detect the capability
+	// Capabilities without one can't be probed (e.g. NetworkPolicy enforcement by the CNI) and are assumed present
+	crd string
+	// kinds are the "group/Kind"s requiring the capability, "group/*" for all kinds of a group
+	kinds []string
+}
+
+// capabilities lists the optional APIs known to the function
+var capabilities = map[string]capability{
+	CapabilityPrometheusOperator: {crd: "servicemonitors.monitoring.coreos.com", kinds: []string{"monitoring.coreos.com/*"}},
+	CapabilityK8up:               {crd: "schedules.k8up.io", kinds: []string{"k8up.io/*"}},
+	CapabilityCertManager:        {crd: "certificates.cert-manager.io", kinds: []string{"cert-manager.io/*"}},
+	CapabilityExternalSecrets:    {crd: "pushsecrets.external-secrets.io", kinds: []string{"external-secrets.io/*"}},
+	CapabilityFluentBit:          {crd: "outputs.fluentbit.fluent.io", kinds: []string{"fluentbit.fluent.io/*"}},
+	CapabilityNetworkPolicy:      {kinds: []string{"networking.k8s.io/NetworkPolicy"}},
+}
+
+// capabilityDetection tells which capabilities the rendered resources need and whether the cluster provides them
+type capabilityDetection struct {
+	requirements *fnv1.Requirements
+}
+
+// getCapabilityOverrides extracts the capabilities section from service config
+// Overrides replace probing, e.g. {NetworkPolicy: false} on clusters whose CNI doesn't enforce policies
+// Returns nil without error if nothing is overridden
+func getCapabilityOverrides(serviceConfig map[string]any) (map[string]bool, error) {
+	overridesRaw, ok := serviceConfig["capabilities"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	overrides := map[string]bool{}
+	for name, valueRaw := range overridesRaw {
+		if _, ok := capabilities[name]; !ok {
+			return nil, fmt.Errorf("unknown capability %q (known: %s)", name, strings.Join(slices.Sorted(maps.Keys(capabilities)), ", "))
+		}
+		value, ok := valueRaw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("capabilities.%s must be a boolean", name)
+		}
+		overrides[name] = value
+	}
+	return overrides, nil
+}
+
+// requiredCapability returns the capability a resource depends on, if any
+func requiredCapability(res *fnv1.Resource) string {
+	paved := fieldpath.Pave(res.GetResource().AsMap())
+	apiVersion, _ := paved.GetString("apiVersion")
+	kind, _ := paved.GetString("kind")
+	group := ""
+	if g, _, ok := strings.Cut(apiVersion, "/"); ok {
+		group = g
+	}
+
+	for name, c := range capabilities {
+		for _, k := range c.kinds {
+			if k == group+"/"+kind || k == group+"/*" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// skipUnsupportedResources removes rendered resources whose optional API the cluster doesn't provide,
+// warning instead of failing so instances can be provisioned on clusters without e.g. K8up
+// Absent CRDs are detected by requiring them from Crossplane; until it has fetched them they count as present
+func skipUnsupportedResources(req *fnv1.RunFunctionRequest, resources map[string]*fnv1.Resource, serviceConfig map[string]any, results *Results, log logr.Logger) (*capabilityDetection, error) {
+	overrides, err := getCapabilityOverrides(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capabilities config: %w", err)
+	}
+
+	detection := &capabilityDetection{}
+	present := map[string]bool{}
+	for _, key := range slices.Sorted(maps.Keys(resources)) {
+		name := requiredCapability(resources[key])
+		if name == "" {
+			continue
+		}
+
+		available, detected := present[name]
+		if !detected {
+			available = detection.probe(req, name, overrides)
+			present[name] = available
+		}
+		if available {
+			continue
+		}
+
+		delete(resources, key)
+		log.Info("Skipping resource of absent capability", "resource", key, "capability", name)
+		results.Warning("CapabilityMissing", "Skipped %s: the cluster doesn't provide %s", key, name)
+	}
+	return detection, nil
+}
+
+// probe reports whether the cluster provides a capability, requiring its CRD from Crossplane
+func (d *capabilityDetection) probe(req *fnv1.RunFunctionRequest, name string, overrides map[string]bool) bool {
+	if value, ok := overrides[name]; ok {
+		return value
+	}
+	crd := capabilities[name].crd
+	if crd == "" {
+		return true
+	}
+
+	if d.requirements == nil {
+		d.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}
+	}
+	requirement := requiredCapabilityPrefix + strings.ToLower(name)
+	d.requirements.Resources[requirement] = &fnv1.ResourceSelector{
+		ApiVersion: "apiextensions.k8s.io/v1",
+		Kind:       "CustomResourceDefinition",
+		Match:      &fnv1.ResourceSelector_MatchName{MatchName: crd},
+	}
+
+	found, fetched := getRequiredResources(req, requirement)
+	return !fetched || len(found) > 0
+}
+
+// mergeRequirements combines the requirements of several checks into one
+func mergeRequirements(requirements ...*fnv1.Requirements) *fnv1.Requirements {
+	var merged *fnv1.Requirements
+	for _, r := range requirements {
+		if r == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}
+		}
+		maps.Copy(merged.Resources, r.GetResources())
+	}
+	return merged
+}
+
+// requirementsFetched reports whether Crossplane already fetched every required resource of the requirements,
+// so it won't call the function again for the same reconcile
+func requirementsFetched(req *fnv1.RunFunctionRequest, requirements *fnv1.Requirements) bool {
+	for name := range requirements.GetResources() {
+		if _, ok := getRequiredResources(req, name); !ok {
+			return false
+		}
+	}
+	return true
+}
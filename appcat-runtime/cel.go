@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// celEnvironment declares the variables mapping expressions see
+// spec is the user spec, metadata the composite's metadata, config the service config and self the value of
+// the entry's spec field (null if unset)
+var celEnvironment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("spec", cel.DynType),
+		cel.Variable("metadata", cel.DynType),
+		cel.Variable("config", cel.DynType),
+		cel.Variable("self", cel.DynType),
+		cel.CrossTypeNumericComparisons(true),
+	)
+})
+
+// celPrograms caches compiled expressions, as the same mappings are evaluated on every render
+var celPrograms sync.Map
+
+// compileExpression compiles a CEL expression, reusing earlier compilations
+func compileExpression(expression string) (cel.Program, error) {
+	if program, ok := celPrograms.Load(expression); ok {
+		return program.(cel.Program), nil
+	}
+	env, err := celEnvironment()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CEL environment: %w", err)
+	}
+	// Expressions are parsed, not type-checked: all variables are dynamic, and checking would reject common
+	// expressions such as "has(spec.x) ? spec.x : null" for mixing types
+	ast, issues := env.Parse(expression)
+	if issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expression, issues.Err())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	celPrograms.Store(expression, program)
+	return program, nil
+}
+
+// evaluateExpression evaluates a CEL expression and returns its result as a JSON value; null results are nil
+func evaluateExpression(expression string, vars map[string]any) (any, error) {
+	program, err := compileExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %q: %w", expression, err)
+	}
+	value, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("expression %q returned %s, which isn't a JSON value", expression, out.Type().TypeName())
+	}
+	return value.(*structpb.Value).AsInterface(), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateExpression(t *testing.T) {
+	vars := map[string]any{
+		"spec":     map[string]any{"size": "4Gi", "replicas": float64(3), "tls": map[string]any{"enabled": true}},
+		"metadata": map[string]any{"name": "redis-a"},
+		"config":   map[string]any{},
+		"self":     nil,
+	}
+
+	cases := map[string]struct {
+		expression string
+		want       any
+		wantErr    bool
+	}{
+		"String":                 {expression: `metadata.name + "-master"`, want: "redis-a-master"},
+		"NullSelf":               {expression: `self`, want: nil},
+		"NullLiteral":            {expression: `has(spec.version) ? spec.version : null`, want: nil},
+		"SelfDefault":            {expression: `self == null ? "standard" : self`, want: "standard"},
+		"CrossTypeComparison":    {expression: `spec.replicas > 1`, want: true},
+		"Map":                    {expression: `{"enabled": spec.tls.enabled}`, want: map[string]any{"enabled": true}},
+		"List":                   {expression: `[spec.size, metadata.name]`, want: []any{"4Gi", "redis-a"}},
+		"MissingField":           {expression: `spec.version.major`, wantErr: true},
+		"FieldOfNull":            {expression: `self.size`, wantErr: true},
+		"TypeMismatch":           {expression: `spec.size + 1`, wantErr: true},
+		"NonBooleanCondition":    {expression: `spec.size ? 1 : 2`, wantErr: true},
+		"DurationAsString":       {expression: `duration("1h")`, want: "3600s"},
+		"NonJSONResult":          {expression: `type(spec)`, wantErr: true},
+		"SyntaxError":            {expression: `spec.size +`, wantErr: true},
+		"UndeclaredVariable":     {expression: `status.phase`, wantErr: true},
+		"DivisionByZero":         {expression: `1 / 0`, wantErr: true},
+		"MixedArithmetic":        {expression: `spec.replicas * 2`, wantErr: true},
+		"NumberStaysFloatInJSON": {expression: `spec.replicas * 2.0`, want: float64(6)},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := evaluateExpression(tc.expression, vars)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("evaluateExpression(%q) error = %v, want error %v", tc.expression, err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("evaluateExpression(%q) = %#v, want %#v", tc.expression, got, tc.want)
+			}
+		})
+	}
+}
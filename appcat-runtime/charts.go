@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// companionReleaseKeyPrefix prefixes the desired resource keys of the Releases of additional charts
+const companionReleaseKeyPrefix = releaseKey + "-"
+
+// CompanionChart is an additional chart of a multi-chart service, e.g. a metrics exporter next to the database
+type CompanionChart struct {
+	// Key names the chart's values below the helm values, e.g. "exporter" for exporter.config.port, and
+	// suffixes its resource key and Release name
+	Key        string
+	Repository string
+	Name       string
+	Version    string
+	// After lists the keys of the charts whose Releases must be ready first
+	After []string
+}
+
+// resourceKey returns the desired resource key of the chart's Release
+func (c CompanionChart) resourceKey() string {
+	return companionReleaseKeyPrefix + c.Key
+}
+
+// normalizeCharts splits a chart list into the main chart, its first entry, and the additional charts
+// The main chart stays in chart, so versions, upgrades, readiness and the connection details keep following it;
+// the others move to charts
+func normalizeCharts(serviceConfig map[string]any) error {
+	list, ok := serviceConfig["chart"].([]any)
+	if !ok {
+		return nil
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("chart list is empty")
+	}
+	main, ok := list[0].(map[string]any)
+	if !ok {
+		return fmt.Errorf("chart[0] must be a map")
+	}
+	serviceConfig["chart"] = main
+	serviceConfig["charts"] = list[1:]
+	return nil
+}
+
+// getMainChartKey returns the key the main chart is referred to by in after, defaulting to its name
+func getMainChartKey(config map[string]any) string {
+	chart, _ := config["chart"].(map[string]any)
+	if key, _ := chart["key"].(string); key != "" {
+		return key
+	}
+	name, _ := chart["name"].(string)
+	return name
+}
+
+// getCompanionCharts extracts the additional charts of a multi-chart service from service or merged config
+// Returns nil without error for single-chart services
+func getCompanionCharts(config map[string]any) ([]CompanionChart, error) {
+	entries, _ := config["charts"].([]any)
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	mainKey := getMainChartKey(config)
+	keys := []string{mainKey}
+	var charts []CompanionChart
+	for i, entryRaw := range entries {
+		entry, ok := entryRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("chart[%d] must be a map", i+1)
+		}
+		chart := CompanionChart{}
+		chart.Name, _ = entry["name"].(string)
+		chart.Repository, _ = entry["repository"].(string)
+		chart.Version, _ = entry["defaultVersion"].(string)
+		if chart.Name == "" || chart.Repository == "" || chart.Version == "" {
+			return nil, fmt.Errorf("chart[%d] requires name, repository and defaultVersion", i+1)
+		}
+		chart.Key, _ = entry["key"].(string)
+		if chart.Key == "" {
+			chart.Key = chart.Name
+		}
+		if errs := validation.IsDNS1123Label(chart.Key); len(errs) > 0 {
+			return nil, fmt.Errorf("chart[%d]: key %q is not a valid RFC 1123 label: %s", i+1, chart.Key, strings.Join(errs, "; "))
+		}
+		if chart.resourceKey() == nextReleaseKey {
+			return nil, fmt.Errorf("chart[%d]: key %q is reserved for blue/green upgrades", i+1, chart.Key)
+		}
+		if slices.Contains(keys, chart.Key) {
+			return nil, fmt.Errorf("chart[%d]: key %q is used by another chart", i+1, chart.Key)
+		}
+		keys = append(keys, chart.Key)
+		after, err := getChartAfter(entry)
+		if err != nil {
+			return nil, fmt.Errorf("chart[%d]: %w", i+1, err)
+		}
+		chart.After = after
+		charts = append(charts, chart)
+	}
+
+	mainChart, _ := config["chart"].(map[string]any)
+	mainAfter, err := getChartAfter(mainChart)
+	if err != nil {
+		return nil, fmt.Errorf("chart[0]: %w", err)
+	}
+	afters := [][]string{mainAfter}
+	for _, chart := range charts {
+		afters = append(afters, chart.After)
+	}
+	for _, after := range slices.Concat(afters...) {
+		if !slices.Contains(keys, after) {
+			return nil, fmt.Errorf("after refers to unknown chart %q, charts are %s", after, strings.Join(keys, ", "))
+		}
+	}
+	return charts, nil
+}
+
+// getChartAfter reads the after list of a chart entry
+func getChartAfter(entry map[string]any) ([]string, error) {
+	afterRaw, ok := entry["after"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := afterRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("after must be a list of chart keys")
+	}
+	var after []string
+	for _, keyRaw := range list {
+		key, _ := keyRaw.(string)
+		if key == "" {
+			return nil, fmt.Errorf("after must be a list of chart keys")
+		}
+		after = append(after, key)
+	}
+	return after, nil
+}
+
+// getChartDependencies translates the after lists of a multi-chart service into resource dependencies
+// Returns nil without error for single-chart services
+func getChartDependencies(serviceConfig map[string]any) (map[string][]string, error) {
+	charts, err := getCompanionCharts(serviceConfig)
+	if err != nil || charts == nil {
+		return nil, err
+	}
+	resourceKeys := map[string]string{getMainChartKey(serviceConfig): releaseKey}
+	for _, chart := range charts {
+		resourceKeys[chart.Key] = chart.resourceKey()
+	}
+
+	dependencies := map[string][]string{}
+	mainChart, _ := serviceConfig["chart"].(map[string]any)
+	mainAfter, _ := getChartAfter(mainChart)
+	for _, after := range mainAfter {
+		dependencies[releaseKey] = append(dependencies[releaseKey], resourceKeys[after])
+	}
+	for _, chart := range charts {
+		for _, after := range chart.After {
+			dependencies[chart.resourceKey()] = append(dependencies[chart.resourceKey()], resourceKeys[after])
+		}
+	}
+	return dependencies, nil
+}
+
+// splitChartValues separates the values of the additional charts, kept below their keys, from the main chart's
+func splitChartValues(helmValues map[string]any, charts []CompanionChart) (map[string]any, map[string]map[string]any, error) {
+	if len(charts) == 0 {
+		return helmValues, nil, nil
+	}
+	main := maps.Clone(helmValues)
+	byChart := make(map[string]map[string]any, len(charts))
+	for _, chart := range charts {
+		switch values := main[chart.Key].(type) {
+		case nil:
+			byChart[chart.Key] = map[string]any{}
+		case map[string]any:
+			byChart[chart.Key] = values
+		default:
+			return nil, nil, fmt.Errorf("helm values of chart %s must be a map, got %T", chart.Key, values)
+		}
+		delete(main, chart.Key)
+	}
+	return main, byChart, nil
+}
+
+// generateCompanionReleases creates a Release for each additional chart, named <instance>-<key>
+// Their versions are fixed by the service config; blue/green upgrades and version policies only concern the
+// main chart. They're pulled with the service's chartPullSecret like the main chart
+func generateCompanionReleases(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	charts []CompanionChart,
+	values map[string]map[string]any,
+	pullSecret string,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	service, _ := mergedConfig["service"].(string)
+	// Validated by generateResources
+	releaseOptions, _ := getReleaseOptions(mergedConfig)
+	output, _ := getOutputConfig(mergedConfig)
+	for _, chart := range charts {
+		builder := NewHelmReleaseBuilder(truncateName(instanceName+"-"+chart.Key, defaultNamingMaxLength)).
+			WithNamespace(instanceNamespace).
+			WithChart(chart.Repository, chart.Name, chart.Version).
+			WithValues(values[chart.Key]).
+			WithLabel("app.kubernetes.io/component", chart.Key).
+			WithLabel(LabelClaimName, claim.Name).
+			WithLabel(LabelClaimNamespace, claim.Namespace)
+		if service != "" {
+			builder = builder.WithLabel(LabelService, service)
+		}
+		builder = applyReleaseOptions(builder, releaseOptions)
+		if isOCIRepository(chart.Repository) {
+			builder = builder.WithChartURL(ociChartURL(chart.Repository, chart.Name, chart.Version))
+		}
+		if pullSecret != "" {
+			builder = builder.WithPullSecretRef(pullSecret)
+		}
+		// Additional charts are installed on the main chart's cluster
+		if ref := getProviderConfigRef(mergedConfig); ref != nil {
+			builder = builder.WithProviderConfigRef(ref.Kind, ref.Name)
+		}
+
+		releaseResources, err := releaseOutputResources(chart.resourceKey(), builder.Build(), output)
+		if err != nil {
+			return fmt.Errorf("failed to convert helm release of chart %s: %w", chart.Key, err)
+		}
+		maps.Copy(resources, releaseResources)
+	}
+	log.Info("Created Releases of additional charts", "charts", len(charts))
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// requiredCloneSource names the required source composite of spec.cloneFrom
+const requiredCloneSource = "clone-source"
+
+// cloneExcludedFields are spec fields never copied from the clone source, besides Crossplane's own
+var cloneExcludedFields = []string{"cloneFrom", "restore"}
+
+// cloneDecision is the outcome of resolving spec.cloneFrom
+type cloneDecision struct {
+	requirements *fnv1.Requirements
+	// pending is true until Crossplane fetched the source composite
+	pending bool
+	// status holds status.clone, which keeps the copied spec once the source was resolved
+	status map[string]any
+}
+
+// getCloneExcludedFields extracts clone.excludeFields from service config, e.g. spec fields holding credentials
+func getCloneExcludedFields(serviceConfig map[string]any) ([]string, error) {
+	excluded := slices.Concat(crossplaneSpecFields, cloneExcludedFields)
+	clone, ok := serviceConfig["clone"].(map[string]any)
+	if !ok {
+		return excluded, nil
+	}
+	fieldsRaw, _ := clone["excludeFields"].([]any)
+	for i, fieldRaw := range fieldsRaw {
+		field, _ := fieldRaw.(string)
+		if field == "" {
+			return nil, fmt.Errorf("excludeFields[%d] must be a spec field path", i)
+		}
+		excluded = append(excluded, field)
+	}
+	return excluded, nil
+}
+
+// applyCloneFrom fills the user spec from the composite referenced by spec.cloneFrom and restores the
+// source's latest backup into the new instance through spec.restore
+// Fields set on the clone win over copied ones. The copied spec is kept in status.clone, so later
+// changes of the source (or its deletion) don't affect the clone
+func applyCloneFrom(
+	req *fnv1.RunFunctionRequest,
+	composite *fnv1.Resource,
+	serviceConfig, userSpec map[string]any,
+	log logr.Logger,
+) (*cloneDecision, error) {
+	decision := &cloneDecision{}
+	cloneFrom, ok := userSpec["cloneFrom"].(map[string]any)
+	if !ok {
+		return decision, nil
+	}
+	sourceName, _ := cloneFrom["name"].(string)
+	if sourceName == "" {
+		return decision, fmt.Errorf("cloneFrom.name is required")
+	}
+
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	if previous, err := paved.GetValue("status.clone"); err == nil {
+		if status, ok := previous.(map[string]any); ok && status["source"] == sourceName {
+			decision.status = status
+		}
+	}
+
+	if decision.status == nil {
+		apiVersion, _ := paved.GetString("apiVersion")
+		kind, _ := paved.GetString("kind")
+		namespace, _ := paved.GetString("metadata.namespace")
+		decision.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+			requiredCloneSource: {
+				ApiVersion: apiVersion,
+				Kind:       kind,
+				Match:      &fnv1.ResourceSelector_MatchName{MatchName: sourceName},
+				Namespace:  &namespace,
+			},
+		}}
+		found, fetched := getRequiredResources(req, requiredCloneSource)
+		if !fetched {
+			decision.pending = true
+			return decision, nil
+		}
+		if len(found) == 0 {
+			return decision, fmt.Errorf("clone source %s not found in namespace %s", sourceName, namespace)
+		}
+		status, err := cloneSourceStatus(found[0], sourceName, serviceConfig)
+		if err != nil {
+			return decision, err
+		}
+		decision.status = status
+		log.Info("Resolved clone source", "source", sourceName, "instance", status["instance"])
+	}
+
+	copied, _ := decision.status["spec"].(map[string]any)
+	merged := deepMerge(deepCopy(copied), userSpec, replaceLists)
+	for key, value := range merged {
+		userSpec[key] = value
+	}
+	// Seed the data from the source's latest backup, unless the tenant chose a snapshot
+	if _, ok := userSpec["restore"]; !ok {
+		instance, _ := decision.status["instance"].(string)
+		userSpec["restore"] = map[string]any{"source": instance}
+	}
+	return decision, nil
+}
+
+// cloneSourceStatus builds status.clone from the fetched source composite: its effective spec without
+// excluded fields and the instance name its backups are stored under
+func cloneSourceStatus(source *fnv1.Resource, sourceName string, serviceConfig map[string]any) (map[string]any, error) {
+	hubSource, err := convertCompositeToHub(source, serviceConfig, logr.Discard())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert clone source %s: %w", sourceName, err)
+	}
+	sourceSpec, err := extractUserSpec(hubSource, serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract spec of clone source %s: %w", sourceName, err)
+	}
+	instance, err := getInstanceName(source, serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance name of clone source %s: %w", sourceName, err)
+	}
+
+	excluded, err := getCloneExcludedFields(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clone config: %w", err)
+	}
+	spec := fieldpath.Pave(deepCopy(sourceSpec))
+	for _, field := range excluded {
+		if err := spec.DeleteField(field); err != nil {
+			return nil, fmt.Errorf("failed to exclude %s from clone: %w", field, err)
+		}
+	}
+	return map[string]any{"source": sourceName, "instance": instance, "spec": spec.UnstructuredContent()}, nil
+}
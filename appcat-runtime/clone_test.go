@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestApplyCloneFromExcludesFields(t *testing.T) {
+	toResource := func(object map[string]any) *fnv1.Resource {
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	source := toResource(map[string]any{
+		"apiVersion": "vshn.appcat.io/v1",
+		"kind":       "XRedis",
+		"metadata":   map[string]any{"name": "redis-prod", "namespace": "team"},
+		"spec": map[string]any{
+			"size":                       map[string]any{"memory": "2Gi", "cpu": "1"},
+			"security":                   map[string]any{"allowedIPs": []any{"10.0.0.0/8"}, "password": "source-secret"},
+			"restore":                    map[string]any{"source": "redis-older"},
+			"crossplane":                 map[string]any{"compositionRef": map[string]any{"name": "redis"}},
+			"writeConnectionSecretToRef": map[string]any{"name": "redis-prod-creds"},
+		},
+	})
+	composite := toResource(map[string]any{
+		"apiVersion": "vshn.appcat.io/v1",
+		"kind":       "XRedis",
+		"metadata":   map[string]any{"name": "redis-copy", "namespace": "team"},
+	})
+	serviceConfig := map[string]any{"clone": map[string]any{"excludeFields": []any{"security.password"}}}
+	req := &fnv1.RunFunctionRequest{RequiredResources: map[string]*fnv1.Resources{requiredCloneSource: {Items: []*fnv1.Resource{source}}}}
+
+	userSpec := map[string]any{
+		"cloneFrom": map[string]any{"name": "redis-prod"},
+		"size":      map[string]any{"memory": "4Gi"},
+	}
+	decision, err := applyCloneFrom(req, composite, serviceConfig, userSpec, logr.Discard())
+	if err != nil {
+		t.Fatalf("applyCloneFrom() error = %v", err)
+	}
+	if decision.pending {
+		t.Fatal("applyCloneFrom() pending with the source fetched")
+	}
+
+	size := userSpec["size"].(map[string]any)
+	if size["memory"] != "4Gi" || size["cpu"] != "1" {
+		t.Errorf("size = %v, want the clone's memory and the source's cpu", size)
+	}
+	security := userSpec["security"].(map[string]any)
+	if _, ok := security["password"]; ok {
+		t.Errorf("security.password copied despite clone.excludeFields")
+	}
+	if security["allowedIPs"] == nil {
+		t.Errorf("security.allowedIPs not copied")
+	}
+	for _, field := range []string{"crossplane", "writeConnectionSecretToRef"} {
+		if _, ok := userSpec[field]; ok {
+			t.Errorf("Crossplane field %s copied from the source", field)
+		}
+	}
+	// The source's own restore isn't copied, the clone restores the source's backup
+	if restore := userSpec["restore"].(map[string]any); restore["source"] != "redis-prod" {
+		t.Errorf("restore = %v, want the source's backup", restore)
+	}
+	if _, ok := decision.status["spec"].(map[string]any)["restore"]; ok {
+		t.Errorf("status.clone.spec holds the source's restore")
+	}
+}
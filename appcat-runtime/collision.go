@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// requiredCollisionPrefix prefixes the required resources looked up before an object is first created
+const requiredCollisionPrefix = "collision-"
+
+// collisionCheck holds the lookups of existing objects that share a name with a resource about to be created
+type collisionCheck struct {
+	requirements *fnv1.Requirements
+}
+
+// guardNameCollisions keeps the composite from taking over objects of other tenants or instances that happen
+// to share a generated name, e.g., a claim named like another team's Helm release in a shared namespace
+// Resources not observed yet are held back until Crossplane fetched any existing object of the same name,
+// which must be absent or already belong to this composite
+func guardNameCollisions(
+	req *fnv1.RunFunctionRequest,
+	resources map[string]*fnv1.Resource,
+	composite *fnv1.Resource,
+	log logr.Logger,
+) (*collisionCheck, error) {
+	check := &collisionCheck{}
+	observedResources := req.GetObserved().GetResources()
+	for _, key := range slices.Sorted(maps.Keys(resources)) {
+		if observedResources[key] != nil {
+			continue
+		}
+		paved := fieldpath.Pave(resources[key].GetResource().AsMap())
+		apiVersion, _ := paved.GetString("apiVersion")
+		kind, _ := paved.GetString("kind")
+		name, _ := paved.GetString("metadata.name")
+		if name == "" {
+			continue
+		}
+
+		selector := &fnv1.ResourceSelector{
+			ApiVersion: apiVersion,
+			Kind:       kind,
+			Match:      &fnv1.ResourceSelector_MatchName{MatchName: name},
+		}
+		if namespace, _ := paved.GetString("metadata.namespace"); namespace != "" {
+			selector.Namespace = &namespace
+		}
+		if check.requirements == nil {
+			check.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}
+		}
+		requirement := requiredCollisionPrefix + key
+		check.requirements.Resources[requirement] = selector
+
+		found, fetched := getRequiredResources(req, requirement)
+		if !fetched {
+			delete(resources, key)
+			log.Info("Holding back resource until existing objects of its name are known", "resource", key, "name", name)
+			continue
+		}
+		if len(found) > 0 && !ownedByComposite(found[0], composite) {
+			return check, fmt.Errorf("%s %s already exists and doesn't belong to this instance, choose another name", kind, name)
+		}
+	}
+	return check, nil
+}
+
+// ownedByComposite reports whether an existing object belongs to the composite, by its ownership label or
+// Crossplane's controller reference
+func ownedByComposite(existing, composite *fnv1.Resource) bool {
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	compositeName, _ := paved.GetString("metadata.name")
+	compositeUID, _ := paved.GetString("metadata.uid")
+
+	object := existing.GetResource().AsMap()
+	labels, _ := fieldpath.Pave(object).GetStringObject("metadata.labels")
+	if labels[LabelInstance] == compositeName {
+		return true
+	}
+	ownerRefs, _ := fieldpath.Pave(object).GetValue("metadata.ownerReferences")
+	refs, _ := ownerRefs.([]any)
+	for _, refRaw := range refs {
+		ref, _ := refRaw.(map[string]any)
+		if controller, _ := ref["controller"].(bool); controller && compositeUID != "" && ref["uid"] == compositeUID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGuardNameCollisions(t *testing.T) {
+	toResource := func(object map[string]any) *fnv1.Resource {
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	existing := func(metadata map[string]any) *fnv1.Resource {
+		metadata["name"] = "redis-a"
+		metadata["namespace"] = "team"
+		return toResource(map[string]any{"apiVersion": "helm.m.crossplane.io/v1beta1", "kind": "Release", "metadata": metadata})
+	}
+	composite := toResource(map[string]any{"metadata": map[string]any{"name": "redis-a", "namespace": "team", "uid": "1234"}})
+	requirement := requiredCollisionPrefix + releaseKey
+
+	cases := map[string]struct {
+		observed map[string]*fnv1.Resource
+		required map[string]*fnv1.Resources
+		wantHeld bool
+		wantErr  bool
+	}{
+		"NotFetched": {wantHeld: true},
+		"NameFree":   {required: map[string]*fnv1.Resources{requirement: {}}},
+		"OwnedByLabel": {
+			required: map[string]*fnv1.Resources{requirement: {Items: []*fnv1.Resource{
+				existing(map[string]any{"labels": map[string]any{LabelInstance: "redis-a"}}),
+			}}},
+		},
+		"OwnedByControllerReference": {
+			required: map[string]*fnv1.Resources{requirement: {Items: []*fnv1.Resource{
+				existing(map[string]any{"ownerReferences": []any{map[string]any{"uid": "1234", "controller": true}}}),
+			}}},
+		},
+		"OtherOwner": {
+			required: map[string]*fnv1.Resources{requirement: {Items: []*fnv1.Resource{
+				existing(map[string]any{
+					"labels":          map[string]any{LabelInstance: "redis-b"},
+					"ownerReferences": []any{map[string]any{"uid": "5678", "controller": true}},
+				}),
+			}}},
+			wantErr: true,
+		},
+		"NonControllerReference": {
+			required: map[string]*fnv1.Resources{requirement: {Items: []*fnv1.Resource{
+				existing(map[string]any{"ownerReferences": []any{map[string]any{"uid": "1234", "controller": false}}}),
+			}}},
+			wantErr: true,
+		},
+		"AlreadyObserved": {
+			observed: map[string]*fnv1.Resource{releaseKey: existing(map[string]any{})},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resources := map[string]*fnv1.Resource{releaseKey: existing(map[string]any{})}
+			req := &fnv1.RunFunctionRequest{Observed: &fnv1.State{Composite: composite, Resources: tc.observed}, RequiredResources: tc.required}
+			check, err := guardNameCollisions(req, resources, composite, logr.Discard())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("guardNameCollisions() error = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if held := resources[releaseKey] == nil; held != tc.wantHeld {
+				t.Errorf("release held back = %v, want %v", held, tc.wantHeld)
+			}
+			if _, required := check.requirements.GetResources()[requirement]; required != (tc.observed == nil) {
+				t.Errorf("existing object required = %v, want %v", required, tc.observed == nil)
+			}
+		})
+	}
+}
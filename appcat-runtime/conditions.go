@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/vshn/appcat-poc/appcat-runtime/releasehealth"
+	"k8s.io/utils/ptr"
+)
+
+// Standard composite condition types, computed on every render
+// Together they form the per-instance health contract used by dashboards and runbooks
+const (
+	ConditionSpecValid        = "SpecValid"
+	ConditionValuesMerged     = "ValuesMerged"
+	ConditionReleaseSynced    = "ReleaseSynced"
+	ConditionReleaseHealthy   = "ReleaseHealthy"
+	ConditionCredentialsReady = "CredentialsReady"
+	ConditionBackupConfigured = "BackupConfigured"
+)
+
+// ConditionQuotaEnforced reports the organization quota decision, only set when a quota is configured
+const ConditionQuotaEnforced = "QuotaEnforced"
+
+// newCondition creates a condition targeting both the composite and its claim
+func newCondition(conditionType string, status fnv1.Status, reason, message string) *fnv1.Condition {
+	return &fnv1.Condition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: ptr.To(message),
+		Target:  fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+	}
+}
+
+// observedCondition is a status condition read from an observed resource
+type observedCondition struct {
+	Status  string
+	Reason  string
+	Message string
+	// ObservedGeneration is the generation of the resource the condition was set for, zero if not reported
+	ObservedGeneration int64
+}
+
+// getObservedCondition returns the status condition of the given type from an observed resource
+func getObservedCondition(res *fnv1.Resource, conditionType string) (*observedCondition, bool) {
+	if res == nil || res.GetResource() == nil {
+		return nil, false
+	}
+
+	paved := fieldpath.Pave(res.GetResource().AsMap())
+	conditionsRaw, err := paved.GetValue("status.conditions")
+	if err != nil {
+		return nil, false
+	}
+	conditions, ok := conditionsRaw.([]any)
+	if !ok {
+		return nil, false
+	}
+
+	for _, conditionRaw := range conditions {
+		condition, ok := conditionRaw.(map[string]any)
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		status, _ := condition["status"].(string)
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		observedGeneration, _ := condition["observedGeneration"].(float64)
+		return &observedCondition{Status: status, Reason: reason, Message: message, ObservedGeneration: int64(observedGeneration)}, true
+	}
+	return nil, false
+}
+
+// isObservedReadyAtGeneration reports whether an observed resource is ready for its current spec
+// A Ready condition set for an earlier generation predates the last applied change; conditions without
+// observedGeneration can't tell, and are taken as current
+func isObservedReadyAtGeneration(res *fnv1.Resource) bool {
+	ready, ok := getObservedCondition(res, "Ready")
+	if !ok || ready.Status != "True" {
+		return false
+	}
+	if ready.ObservedGeneration == 0 {
+		return true
+	}
+	generation, _ := lookupNumber(fieldpath.Pave(res.GetResource().AsMap()), "metadata.generation")
+	return int64(generation) == ready.ObservedGeneration
+}
+
+// computeConditions derives the standard condition set for a successful render
+// renderedKey is the key of the Release being rendered and servingKey the one serving the instance,
+// which differ while a blue/green upgrade deploys the next release
+func computeConditions(observedResources map[string]*fnv1.Resource, renderedKey, servingKey string, mergedConfig map[string]any) []*fnv1.Condition {
+	conditions := []*fnv1.Condition{
+		newCondition(ConditionSpecValid, fnv1.Status_STATUS_CONDITION_TRUE, "Valid", "User spec is valid"),
+		newCondition(ConditionValuesMerged, fnv1.Status_STATUS_CONDITION_TRUE, "Merged", "Helm values merged from defaults and user spec"),
+		releaseSyncedCondition(observedResources[renderedKey]),
+		releaseHealthyCondition(releasehealth.Interpret(observedResources[servingKey])),
+		credentialsReadyCondition(observedResources["secret"], mergedConfig),
+		backupConfiguredCondition(observedResources[backupScheduleKey], mergedConfig),
+	}
+	return conditions
+}
+
+// releaseHealthyCondition reports the Release's health as a composite condition
+func releaseHealthyCondition(health releasehealth.Status) *fnv1.Condition {
+	switch health.Health {
+	case releasehealth.Healthy:
+		return newCondition(ConditionReleaseHealthy, fnv1.Status_STATUS_CONDITION_TRUE, health.Reason, health.Message)
+	case releasehealth.Unknown:
+		return newCondition(ConditionReleaseHealthy, fnv1.Status_STATUS_CONDITION_UNKNOWN, health.Reason, health.Message)
+	default:
+		return newCondition(ConditionReleaseHealthy, fnv1.Status_STATUS_CONDITION_FALSE, health.Reason, health.Message)
+	}
+}
+
+// reportReleaseHealth emits a warning for failed and degraded Releases
+func reportReleaseHealth(health releasehealth.Status, releaseName string, results *Results) {
+	switch health.Health {
+	case releasehealth.Failed, releasehealth.Degraded:
+		results.Warning("Release"+string(health.Health), "HelmRelease %s is %s: %s", releaseName, strings.ToLower(string(health.Health)), health.Message)
+	}
+}
+
+// releaseSyncedCondition mirrors the Synced condition of the observed HelmRelease
+func releaseSyncedCondition(release *fnv1.Resource) *fnv1.Condition {
+	synced, ok := getObservedCondition(release, "Synced")
+	if !ok {
+		return newCondition(ConditionReleaseSynced, fnv1.Status_STATUS_CONDITION_UNKNOWN, "NotObserved", "HelmRelease has not been observed yet")
+	}
+
+	reason := synced.Reason
+	if reason == "" {
+		reason = "NotSynced"
+	}
+
+	switch synced.Status {
+	case "True":
+		return newCondition(ConditionReleaseSynced, fnv1.Status_STATUS_CONDITION_TRUE, "Synced", "HelmRelease is synced")
+	case "False":
+		return newCondition(ConditionReleaseSynced, fnv1.Status_STATUS_CONDITION_FALSE, reason, synced.Message)
+	default:
+		return newCondition(ConditionReleaseSynced, fnv1.Status_STATUS_CONDITION_UNKNOWN, reason, synced.Message)
+	}
+}
+
+// credentialsReadyCondition reports whether the connection secret exists (if the service needs one)
+func credentialsReadyCondition(secret *fnv1.Resource, mergedConfig map[string]any) *fnv1.Condition {
+	if _, ok := mergedConfig["connectionSecret"]; !ok {
+		return newCondition(ConditionCredentialsReady, fnv1.Status_STATUS_CONDITION_TRUE, "NotRequired", "Service has no connection secret")
+	}
+	if secret == nil {
+		return newCondition(ConditionCredentialsReady, fnv1.Status_STATUS_CONDITION_FALSE, "Pending", "Connection secret has not been created yet")
+	}
+	return newCondition(ConditionCredentialsReady, fnv1.Status_STATUS_CONDITION_TRUE, "Available", "Connection secret is available")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ConversionStep migrates fields between two adjacent XRD versions
+// Fields map old object paths to new ones using the mapping syntax
+type ConversionStep struct {
+	From   string
+	To     string
+	Fields map[string]*MappingTarget
+}
+
+// ConversionConfig defines how composites are translated between XRD versions
+// Mapping paths are written against HubVersion, other versions are converted before mapping
+type ConversionConfig struct {
+	HubVersion string
+	Steps      []ConversionStep
+}
+
+// conversionEdge is one traversal of a step, forwards or in reverse
+type conversionEdge struct {
+	step    ConversionStep
+	reverse bool
+}
+
+// getConversionConfig extracts conversion configuration from service config
+// Returns nil without error if no conversion is configured
+func getConversionConfig(serviceConfig map[string]any) (*ConversionConfig, error) {
+	conversion, ok := serviceConfig["conversion"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	hubVersion, _ := conversion["hubVersion"].(string)
+	if hubVersion == "" {
+		return nil, fmt.Errorf("conversion requires a hubVersion")
+	}
+
+	stepsRaw, _ := conversion["steps"].([]any)
+	steps := make([]ConversionStep, 0, len(stepsRaw))
+	for i, stepRaw := range stepsRaw {
+		stepMap, ok := stepRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("steps[%d] is not a map", i)
+		}
+		from, _ := stepMap["from"].(string)
+		to, _ := stepMap["to"].(string)
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("steps[%d] requires from and to", i)
+		}
+
+		fieldsRaw, _ := stepMap["fields"].(map[string]any)
+		fields := make(map[string]*MappingTarget, len(fieldsRaw))
+		for oldPath, targetRaw := range fieldsRaw {
+			target, err := parseMappingTarget(targetRaw)
+			if err != nil {
+				return nil, fmt.Errorf("steps[%d] field %s: %w", i, oldPath, err)
+			}
+			fields[oldPath] = target
+		}
+
+		steps = append(steps, ConversionStep{From: from, To: to, Fields: fields})
+	}
+
+	return &ConversionConfig{HubVersion: hubVersion, Steps: steps}, nil
+}
+
+// path finds the chain of steps converting from one version to another
+// Steps can be walked backwards, so a single list of upgrades also covers downgrades
+func (c *ConversionConfig) path(from, to string) ([]conversionEdge, error) {
+	if from == to {
+		return nil, nil
+	}
+
+	// Breadth-first search over versions
+	previous := map[string]conversionEdge{}
+	visited := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		version := queue[0]
+		queue = queue[1:]
+
+		for _, step := range c.Steps {
+			for _, edge := range []conversionEdge{{step: step}, {step: step, reverse: true}} {
+				src, dst := edge.versions()
+				if src != version || visited[dst] {
+					continue
+				}
+				visited[dst] = true
+				previous[dst] = edge
+				queue = append(queue, dst)
+			}
+		}
+	}
+
+	if !visited[to] {
+		return nil, fmt.Errorf("no conversion path from %s to %s", from, to)
+	}
+
+	var edges []conversionEdge
+	for version := to; version != from; {
+		edge := previous[version]
+		edges = append([]conversionEdge{edge}, edges...)
+		version, _ = edge.versions()
+	}
+	return edges, nil
+}
+
+// versions returns the source and destination version of the edge
+func (e conversionEdge) versions() (string, string) {
+	if e.reverse {
+		return e.step.To, e.step.From
+	}
+	return e.step.From, e.step.To
+}
+
+// apply moves the edge's fields within obj
+// Values are collected before anything is written so fields can swap places
+func (e conversionEdge) apply(obj map[string]any) error {
+	type move struct {
+		src, dst string
+		value    any
+	}
+
+	var moves []move
+	for oldPath, target := range e.step.Fields {
+		src, dst := oldPath, target.HelmPath
+		if e.reverse {
+			src, dst = dst, src
+		}
+
+		value, err := lookupValueByPath(obj, src)
+		if err != nil {
+			// Field not set on this object
+			continue
+		}
+		// Transforms only work one way, objects without the field still convert back
+		if e.reverse && target.Transform != nil {
+			return fmt.Errorf("field %s uses a transform and can't be converted back to %s", oldPath, e.step.From)
+		}
+		if target.Transform != nil {
+			value, err = target.Transform(value)
+			if err != nil {
+				return fmt.Errorf("failed to transform %s for %s: %w", src, dst, err)
+			}
+		}
+		moves = append(moves, move{src: src, dst: dst, value: value})
+	}
+
+	for _, m := range moves {
+		if err := deleteValueByPath(obj, m.src); err != nil {
+			return err
+		}
+	}
+	for _, m := range moves {
+		if err := setValueByPath(obj, m.dst, m.value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", m.dst, err)
+		}
+	}
+	return nil
+}
+
+// convertObject converts obj in place to the given API version
+// Only fields listed in the conversion steps move, everything else is carried over unchanged
+func (c *ConversionConfig) convertObject(obj map[string]any, toVersion string) error {
+	apiVersion, _ := obj["apiVersion"].(string)
+	group, fromVersion := splitAPIVersion(apiVersion)
+
+	edges, err := c.path(fromVersion, toVersion)
+	if err != nil {
+		return err
+	}
+	for _, edge := range edges {
+		if err := edge.apply(obj); err != nil {
+			src, dst := edge.versions()
+			return fmt.Errorf("failed to convert %s to %s: %w", src, dst, err)
+		}
+	}
+
+	if group != "" {
+		obj["apiVersion"] = group + "/" + toVersion
+	} else {
+		obj["apiVersion"] = toVersion
+	}
+	return nil
+}
+
+// splitAPIVersion splits "group/version" into its parts
+func splitAPIVersion(apiVersion string) (group, version string) {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i], apiVersion[i+1:]
+	}
+	return "", apiVersion
+}
+
+// convertCompositeToHub returns the composite converted to the conversion hub version
+// The original composite is returned unchanged if no conversion is configured or it already is at the hub version
+func convertCompositeToHub(composite *fnv1.Resource, serviceConfig map[string]any, log logr.Logger) (*fnv1.Resource, error) {
+	conversion, err := getConversionConfig(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid conversion config: %w", err)
+	}
+	if conversion == nil {
+		return composite, nil
+	}
+
+	obj := composite.Resource.AsMap()
+	apiVersion, _ := obj["apiVersion"].(string)
+	if _, version := splitAPIVersion(apiVersion); version == conversion.HubVersion {
+		return composite, nil
+	}
+
+	if err := conversion.convertObject(obj, conversion.HubVersion); err != nil {
+		return nil, err
+	}
+	converted, err := structpb.NewStruct(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert composite to structpb: %w", err)
+	}
+
+	log.Info("Converted composite to hub version", "from", apiVersion, "to", conversion.HubVersion)
+	return &fnv1.Resource{Resource: converted, ConnectionDetails: composite.ConnectionDetails, Ready: composite.Ready}, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertObject(t *testing.T) {
+	config, err := getConversionConfig(map[string]any{"conversion": map[string]any{
+		"hubVersion": "v2",
+		"steps": []any{
+			map[string]any{"from": "v1", "to": "v1beta1", "fields": map[string]any{"spec.memory": map[string]any{"path": "spec.resources.memory"}}},
+			map[string]any{"from": "v1beta1", "to": "v2", "fields": map[string]any{
+				"spec.size":  "spec.plan",
+				"spec.cpu":   map[string]any{"path": "spec.resources.millicores", "transform": "toMillicores"},
+				"spec.left":  "spec.right",
+				"spec.right": "spec.left",
+			}},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		obj     map[string]any
+		to      string
+		want    map[string]any
+		wantErr bool
+	}{
+		"Forward": {
+			obj:  map[string]any{"apiVersion": "vshn.io/v1beta1", "spec": map[string]any{"size": "small", "cpu": "1.5", "other": "kept"}},
+			to:   "v2",
+			want: map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"plan": "small", "resources": map[string]any{"millicores": int64(1500)}, "other": "kept"}},
+		},
+		"MultipleSteps": {
+			obj:  map[string]any{"apiVersion": "vshn.io/v1", "spec": map[string]any{"memory": "1Gi", "size": "small"}},
+			to:   "v2",
+			want: map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"plan": "small", "resources": map[string]any{"memory": "1Gi"}}},
+		},
+		"Reverse": {
+			obj:  map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"plan": "large", "resources": map[string]any{"memory": "2Gi"}}},
+			to:   "v1",
+			want: map[string]any{"apiVersion": "vshn.io/v1", "spec": map[string]any{"size": "large", "memory": "2Gi"}},
+		},
+		"Swap": {
+			obj:  map[string]any{"apiVersion": "vshn.io/v1beta1", "spec": map[string]any{"left": "a", "right": "b"}},
+			to:   "v2",
+			want: map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"left": "b", "right": "a"}},
+		},
+		"SameVersion": {
+			obj:  map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"plan": "small"}},
+			to:   "v2",
+			want: map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"plan": "small"}},
+		},
+		"ReverseTransform": {
+			obj:     map[string]any{"apiVersion": "vshn.io/v2", "spec": map[string]any{"resources": map[string]any{"millicores": float64(1500)}}},
+			to:      "v1beta1",
+			wantErr: true,
+		},
+		"NoPath": {
+			obj:     map[string]any{"apiVersion": "vshn.io/v3", "spec": map[string]any{}},
+			to:      "v2",
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := config.convertObject(tc.obj, tc.to)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("convertObject() error = %v, want error %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(tc.obj, tc.want) {
+				t.Errorf("convertObject() = %v, want %v", tc.obj, tc.want)
+			}
+		})
+	}
+}
+
+func TestGetConversionConfig(t *testing.T) {
+	cases := map[string]struct {
+		conversion any
+		wantNil    bool
+		wantErr    bool
+	}{
+		"Unset":           {wantNil: true},
+		"MissingHub":      {conversion: map[string]any{"steps": []any{}}, wantErr: true},
+		"StepNotAMap":     {conversion: map[string]any{"hubVersion": "v2", "steps": []any{"v1"}}, wantErr: true},
+		"StepWithoutTo":   {conversion: map[string]any{"hubVersion": "v2", "steps": []any{map[string]any{"from": "v1"}}}, wantErr: true},
+		"InvalidField":    {conversion: map[string]any{"hubVersion": "v2", "steps": []any{map[string]any{"from": "v1", "to": "v2", "fields": map[string]any{"spec.a": float64(1)}}}}, wantErr: true},
+		"HubWithoutSteps": {conversion: map[string]any{"hubVersion": "v2"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			serviceConfig := map[string]any{}
+			if tc.conversion != nil {
+				serviceConfig["conversion"] = tc.conversion
+			}
+			config, err := getConversionConfig(serviceConfig)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("getConversionConfig() error = %v, want error %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && (config == nil) != tc.wantNil {
+				t.Errorf("getConversionConfig() = %v, want nil %v", config, tc.wantNil)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AnnotationDebug requests a debug bundle for every render of a composite, not only for failed ones
+const AnnotationDebug = "appcat.vshn.io/debug"
+
+// ContextKeyDebugBundle is the pipeline context key holding the full debug bundle
+const ContextKeyDebugBundle = "appcat.vshn.io/debug-bundle"
+
+// debugBundleResultLimit caps the bundle in the DebugBundle event, which Kubernetes truncates anyway
+const debugBundleResultLimit = 1024
+
+// redactedValue replaces redacted values in debug bundles
+const redactedValue = "<redacted>"
+
+// sensitiveKeyPattern matches keys whose values are redacted wherever they appear
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|apikey|api_key|privatekey|private_key)`)
+
+// debugBundle collects a sanitized snapshot of a render for support, so a single instance can be
+// diagnosed from its events (or the pipeline context in crossplane render) without reading pod logs
+type debugBundle struct {
+	serviceConfig map[string]any
+	userSpec      map[string]any
+	mergedConfig  map[string]any
+}
+
+// capture records the render's inputs; unset inputs stay empty in the bundle
+func (b *debugBundle) capture(serviceConfig, userSpec, mergedConfig map[string]any) {
+	b.serviceConfig = serviceConfig
+	b.userSpec = userSpec
+	b.mergedConfig = mergedConfig
+}
+
+// requested reports whether the composite asks for a bundle on successful renders
+func (b *debugBundle) requested(req *fnv1.RunFunctionRequest) bool {
+	value, _ := fieldpath.Pave(req.GetObserved().GetComposite().GetResource().AsMap()).GetString(fmt.Sprintf("metadata.annotations[%s]", AnnotationDebug))
+	return value == "true"
+}
+
+// build assembles the bundle with secrets redacted
+func (b *debugBundle) build(renderErr error) map[string]any {
+	bundle := map[string]any{"functionVersion": version}
+	if b.serviceConfig != nil {
+		if hash, err := hashConfig(b.serviceConfig); err == nil {
+			bundle["configHash"] = hash
+		}
+	}
+	if b.userSpec != nil {
+		bundle["spec"] = redactSensitive(deepCopy(b.userSpec))
+	}
+	if helmValues, ok := b.mergedConfig["helmValues"].(map[string]any); ok {
+		values := deepCopy(helmValues)
+		redactSecretPaths(values, b.mergedConfig)
+		bundle["helmValues"] = redactSensitive(values)
+	}
+	if renderErr != nil {
+		bundle["errors"] = errorChain(renderErr)
+	}
+	return bundle
+}
+
+// attach adds the bundle to the response's pipeline context and as a DebugBundle result
+func (b *debugBundle) attach(req *fnv1.RunFunctionRequest, resp *fnv1.RunFunctionResponse, renderErr error) error {
+	bundle := b.build(renderErr)
+
+	value, err := structpb.NewValue(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to convert debug bundle: %w", err)
+	}
+	// Context the render already set on the response is kept; without any, the request's context is passed on
+	if resp.Context == nil {
+		pipelineContext := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		for key, field := range req.GetContext().GetFields() {
+			pipelineContext.Fields[key] = field
+		}
+		resp.Context = pipelineContext
+	}
+	if resp.Context.Fields == nil {
+		resp.Context.Fields = map[string]*structpb.Value{}
+	}
+	resp.Context.Fields[ContextKeyDebugBundle] = value
+
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to marshal debug bundle: %w", err)
+	}
+	message := string(raw)
+	if len(message) > debugBundleResultLimit {
+		message = message[:debugBundleResultLimit] + "... (truncated, see " + ContextKeyDebugBundle + " in the pipeline context)"
+	}
+	resp.Results = append(resp.Results, newResult(fnv1.Severity_SEVERITY_NORMAL, "DebugBundle", message))
+	return nil
+}
+
+// errorChain returns what each error in a wrap chain adds to its cause, outermost first
+func errorChain(err error) []any {
+	var chain []any
+	for ; err != nil; err = errors.Unwrap(err) {
+		message := err.Error()
+		if cause := errors.Unwrap(err); cause != nil {
+			if message == cause.Error() {
+				continue
+			}
+			message = strings.TrimSuffix(message, ": "+cause.Error())
+		}
+		chain = append(chain, message)
+	}
+	return chain
+}
+
+// redactSecretPaths redacts the helm values the function injects credentials into and the values configured to
+// come from Secrets, in case they are inlined too
+func redactSecretPaths(values, mergedConfig map[string]any) {
+	paved := fieldpath.Pave(values)
+	var paths []string
+	if connectionSecret, ok := mergedConfig["connectionSecret"].(map[string]any); ok {
+		if path, _ := connectionSecret["passwordPath"].(string); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if mapping, ok := mergedConfig["secretMapping"].(map[string]any); ok {
+		for path := range mapping {
+			paths = append(paths, path)
+		}
+	}
+	if entries, ok := mergedConfig["valuesFrom"].([]any); ok {
+		for _, entry := range entries {
+			if ref, ok := entry.(map[string]any); ok {
+				if path, _ := ref["path"].(string); path != "" {
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	for _, path := range paths {
+		if _, err := paved.GetValue(path); err == nil {
+			_ = paved.SetValue(path, redactedValue)
+		}
+	}
+}
+
+// redactSensitive replaces the values of sensitive-looking keys, whole maps and lists included, recursing into
+// the others
+func redactSensitive(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if sensitiveKeyPattern.MatchString(key) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactSensitive(field)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactSensitive(item)
+		}
+	}
+	return value
+}
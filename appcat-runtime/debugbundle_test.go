@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestDebugBundleRedaction(t *testing.T) {
+	cases := map[string]struct {
+		helmValues   map[string]any
+		mergedConfig map[string]any
+		want         map[string]any
+	}{
+		"SensitiveString": {
+			helmValues: map[string]any{"auth": map[string]any{"password": "hunter2", "username": "admin"}},
+			want:       map[string]any{"auth": map[string]any{"password": redactedValue, "username": "admin"}},
+		},
+		"SensitiveSubtree": {
+			helmValues: map[string]any{"credentials": map[string]any{"user": "admin", "key": "abc"}, "tokens": []any{"a", "b"}},
+			want:       map[string]any{"credentials": redactedValue, "tokens": redactedValue},
+		},
+		"NestedInList": {
+			helmValues: map[string]any{"users": []any{map[string]any{"name": "app", "apiKey": "abc"}}},
+			want:       map[string]any{"users": []any{map[string]any{"name": "app", "apiKey": redactedValue}}},
+		},
+		"PasswordPath": {
+			helmValues:   map[string]any{"auth": map[string]any{"pass": "hunter2"}},
+			mergedConfig: map[string]any{"connectionSecret": map[string]any{"passwordPath": "auth.pass"}},
+			want:         map[string]any{"auth": map[string]any{"pass": redactedValue}},
+		},
+		"SecretMappingPath": {
+			helmValues:   map[string]any{"auth": map[string]any{"user": "admin", "pass": "hunter2"}},
+			mergedConfig: map[string]any{"secretMapping": map[string]any{"auth.pass": "${password}"}},
+			want:         map[string]any{"auth": map[string]any{"user": "admin", "pass": redactedValue}},
+		},
+		"ValuesFromPath": {
+			helmValues: map[string]any{"license": map[string]any{"key": "abc"}},
+			mergedConfig: map[string]any{"valuesFrom": []any{
+				map[string]any{"path": "license.key", "secretKeyRef": map[string]any{"name": "license", "key": "key"}},
+			}},
+			want: map[string]any{"license": map[string]any{"key": redactedValue}},
+		},
+		"MissingPathsUntouched": {
+			helmValues:   map[string]any{"replicas": float64(1)},
+			mergedConfig: map[string]any{"secretMapping": map[string]any{"auth.pass": "${password}"}},
+			want:         map[string]any{"replicas": float64(1)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mergedConfig := map[string]any{"helmValues": tc.helmValues}
+			for key, value := range tc.mergedConfig {
+				mergedConfig[key] = value
+			}
+			bundle := &debugBundle{mergedConfig: mergedConfig}
+			if got := bundle.build(nil)["helmValues"]; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("helmValues = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDebugBundleAttachKeepsContext(t *testing.T) {
+	req := &fnv1.RunFunctionRequest{Context: &structpb.Struct{Fields: map[string]*structpb.Value{
+		"from-request": structpb.NewStringValue("request"),
+	}}}
+	resp := &fnv1.RunFunctionResponse{Context: &structpb.Struct{Fields: map[string]*structpb.Value{
+		"from-request": structpb.NewStringValue("request"),
+		"from-render":  structpb.NewStringValue("render"),
+	}}}
+	if err := (&debugBundle{}).attach(req, resp, nil); err != nil {
+		t.Fatalf("attach() error = %v", err)
+	}
+	for _, key := range []string{"from-request", "from-render", ContextKeyDebugBundle} {
+		if _, ok := resp.GetContext().GetFields()[key]; !ok {
+			t.Errorf("response context lacks %s", key)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// defaultWarningDedupWindow is how long a repeated warning is suppressed after it was emitted
+const defaultWarningDedupWindow = 10 * time.Minute
+
+// warningRecord tracks the last emission of a warning of one composite
+type warningRecord struct {
+	message     string
+	lastEmitted time.Time
+	lastSeen    time.Time
+	suppressed  int
+}
+
+// warningDeduplicator suppresses warnings a composite repeats on every re-render, so a persistent
+// misconfiguration produces one event per window instead of one per reconcile
+// State is kept in memory; after a restart every warning is emitted once more
+type warningDeduplicator struct {
+	mu      sync.Mutex
+	window  time.Duration
+	records map[string]*warningRecord
+}
+
+// newWarningDeduplicator creates a deduplicator; a window of zero disables deduplication
+func newWarningDeduplicator(window time.Duration) *warningDeduplicator {
+	return &warningDeduplicator{window: window, records: map[string]*warningRecord{}}
+}
+
+// filter drops warnings the composite already emitted with the same reason and message within the window
+// A warning emitted again after the window reports how often it was suppressed in between
+func (d *warningDeduplicator) filter(composite *fnv1.Resource, results []*fnv1.Result, log logr.Logger) []*fnv1.Result {
+	if d == nil || d.window <= 0 || composite == nil {
+		return results
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := now()
+	identity := resourceIdentity(composite)
+	filtered := make([]*fnv1.Result, 0, len(results))
+	for _, result := range results {
+		if result.GetSeverity() != fnv1.Severity_SEVERITY_WARNING {
+			filtered = append(filtered, result)
+			continue
+		}
+
+		key := identity + "/" + result.GetReason()
+		record, ok := d.records[key]
+		if ok && record.message == result.GetMessage() && current.Sub(record.lastEmitted) < d.window {
+			record.suppressed++
+			record.lastSeen = current
+			warningsSuppressed.WithLabelValues(result.GetReason()).Inc()
+			continue
+		}
+
+		// Records keep the original message, not the one annotated with the repeat count
+		d.records[key] = &warningRecord{message: result.GetMessage(), lastEmitted: current, lastSeen: current}
+		if ok && record.message == result.GetMessage() && record.suppressed > 0 {
+			result.Message = fmt.Sprintf("%s (repeated %d times since %s)", result.GetMessage(), record.suppressed, record.lastEmitted.UTC().Format(time.RFC3339))
+			log.Info("Re-emitting repeated warning", "reason", result.GetReason(), "suppressed", record.suppressed)
+		}
+		filtered = append(filtered, result)
+	}
+
+	d.prune(current)
+	return filtered
+}
+
+// forget drops the warning records of a composite, so its next render emits every warning again
+func (d *warningDeduplicator) forget(composite *fnv1.Resource) {
+	if d == nil || composite == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prefix := resourceIdentity(composite) + "/"
+	for key := range d.records {
+		if strings.HasPrefix(key, prefix) {
+			delete(d.records, key)
+		}
+	}
+}
+
+// prune forgets warnings that haven't been seen for two windows, e.g. of fixed or deleted composites
+func (d *warningDeduplicator) prune(current time.Time) {
+	for key, record := range d.records {
+		if current.Sub(record.lastSeen) > 2*d.window {
+			delete(d.records, key)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// dependencyWildcard declares dependencies of every other generated resource, e.g. on a namespace
+const dependencyWildcard = "*"
+
+// usageKeyPrefix prefixes the desired resource keys of generated Usages
+const usageKeyPrefix = "usage-"
+
+// DependencyConfig declares which generated resources need others to exist first
+type DependencyConfig struct {
+	// Resources maps a desired resource key (or "*") to the keys it depends on
+	Resources map[string][]string
+	// Usages additionally emits Crossplane Usages, so dependencies outlive their dependents on deletion
+	Usages bool
+}
+
+// getDependencyConfig extracts dependencies configuration from service config
+// The chart order of multi-chart services is added to the declared dependencies
+// Returns nil without error if no dependencies are declared
+func getDependencyConfig(serviceConfig map[string]any) (*DependencyConfig, error) {
+	chartDependencies, err := getChartDependencies(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid charts config: %w", err)
+	}
+	config := &DependencyConfig{Resources: map[string][]string{}}
+	maps.Copy(config.Resources, chartDependencies)
+	dependencies, ok := serviceConfig["dependencies"].(map[string]any)
+	if !ok {
+		if len(chartDependencies) == 0 {
+			return nil, nil
+		}
+		return config, nil
+	}
+
+	config.Usages, _ = dependencies["usages"].(bool)
+	resources, ok := dependencies["resources"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("resources is required")
+	}
+	for key, keysRaw := range resources {
+		keyList, ok := keysRaw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("resources.%s must be a list of resource keys", key)
+		}
+		for _, dependencyRaw := range keyList {
+			dependency, _ := dependencyRaw.(string)
+			if dependency == "" || dependency == dependencyWildcard {
+				return nil, fmt.Errorf("resources.%s must be a list of resource keys", key)
+			}
+			config.Resources[key] = append(config.Resources[key], dependency)
+		}
+	}
+	return config, nil
+}
+
+// dependencyGraph returns the dependencies of each desired resource, expanding the wildcard
+// Keys missing from the desired state (e.g. an optional Secret) are dropped
+func (c *DependencyConfig) dependencyGraph(resources map[string]*fnv1.Resource) (map[string][]string, error) {
+	graph := map[string][]string{}
+	for _, key := range slices.Sorted(maps.Keys(resources)) {
+		declared := slices.Clone(c.Resources[key])
+		// A wildcard dependency applies to every resource it doesn't itself depend on
+		for _, dependency := range c.Resources[dependencyWildcard] {
+			if dependency != key && !slices.Contains(c.Resources[dependency], key) {
+				declared = append(declared, dependency)
+			}
+		}
+		for _, dependency := range declared {
+			if _, ok := resources[dependency]; ok && !slices.Contains(graph[key], dependency) {
+				graph[key] = append(graph[key], dependency)
+			}
+		}
+	}
+
+	// Cycles would withhold their resources forever
+	state := map[string]int{}
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case 1:
+			return fmt.Errorf("dependency cycle %s", strings.Join(append(path, key), " -> "))
+		case 2:
+			return nil
+		}
+		state[key] = 1
+		for _, dependency := range graph[key] {
+			if err := visit(dependency, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = 2
+		return nil
+	}
+	for _, key := range slices.Sorted(maps.Keys(graph)) {
+		if err := visit(key, nil); err != nil {
+			return nil, err
+		}
+	}
+	return graph, nil
+}
+
+// applyDependencies withholds resources whose dependencies aren't observed and ready yet, so e.g. the
+// Release isn't installed before its credentials Secret exists
+// Resources that already exist are never withheld: dropping them would delete them
+// With usages enabled every dependency also gets a Usage blocking its deletion while its dependent exists
+func applyDependencies(
+	resources map[string]*fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	serviceConfig map[string]any,
+	composite *fnv1.Resource,
+	results *Results,
+	log logr.Logger,
+) error {
+	config, err := getDependencyConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid dependencies config: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+	graph, err := config.dependencyGraph(resources)
+	if err != nil {
+		return fmt.Errorf("invalid dependencies config: %w", err)
+	}
+
+	var withheld []string
+	for _, key := range slices.Sorted(maps.Keys(graph)) {
+		if observedResources[key] != nil {
+			continue
+		}
+		var waiting []string
+		for _, dependency := range graph[key] {
+			if !isObservedReady(observedResources[dependency]) {
+				waiting = append(waiting, dependency)
+			}
+		}
+		if len(waiting) > 0 {
+			withheld = append(withheld, key)
+			log.Info("Withholding resource until its dependencies are ready", "resource", key, "waitingFor", waiting)
+		}
+	}
+	for _, key := range withheld {
+		delete(resources, key)
+	}
+	if len(withheld) > 0 {
+		results.Normal("WaitingForDependencies", "Waiting for dependencies before creating %s", strings.Join(withheld, ", "))
+	}
+
+	if !config.Usages {
+		return nil
+	}
+	compositeName, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.name")
+	for _, key := range slices.Sorted(maps.Keys(graph)) {
+		if resources[key] == nil {
+			continue
+		}
+		for _, dependency := range graph[key] {
+			usage, err := newDependencyUsage(compositeName, key, resources[key], dependency, resources[dependency])
+			if err != nil {
+				return err
+			}
+			resources[usageKeyPrefix+key+"-"+dependency] = usage
+		}
+	}
+	return nil
+}
+
+// newDependencyUsage creates the Usage of dependency by the resource under key
+// Usages are named after the composite and both keys, since generated objects of different kinds may share names
+func newDependencyUsage(compositeName, key string, res *fnv1.Resource, dependencyKey string, dependency *fnv1.Resource) (*fnv1.Resource, error) {
+	by := fieldpath.Pave(res.GetResource().AsMap())
+	of := fieldpath.Pave(dependency.GetResource().AsMap())
+	byAPIVersion, _ := by.GetString("apiVersion")
+	byKind, _ := by.GetString("kind")
+	byName, _ := by.GetString("metadata.name")
+	ofAPIVersion, _ := of.GetString("apiVersion")
+	ofKind, _ := of.GetString("kind")
+	ofName, _ := of.GetString("metadata.name")
+	namespace, _ := by.GetString("metadata.namespace")
+
+	usage := NewUsageBuilder(truncateName(fmt.Sprintf("%s-%s-uses-%s", compositeName, key, dependencyKey), 253), namespace).
+		Of(ofAPIVersion, ofKind, ofName).
+		By(byAPIVersion, byKind, byName).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		Build()
+	usageResource, err := toFunctionResource(usage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert usage of %s by %s: %w", dependencyKey, key, err)
+	}
+	return usageResource, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"k8s.io/utils/ptr"
+)
+
+// getDocLinks extracts the docs section from service config, mapping result and condition reasons to the
+// documentation users should read, e.g., {links: {ReplicasClamped: https://docs.example.com/redis/scaling}}
+// Returns nil without error if the service doesn't link its docs
+func getDocLinks(serviceConfig map[string]any) (map[string]string, error) {
+	docs, ok := serviceConfig["docs"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	linksRaw, _ := docs["links"].(map[string]any)
+	links := make(map[string]string, len(linksRaw))
+	for reason, linkRaw := range linksRaw {
+		link, _ := linkRaw.(string)
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("docs.links.%s must be an http(s) URL", reason)
+		}
+		links[reason] = link
+	}
+	return links, nil
+}
+
+// withDocLink appends the documentation link to a message
+func withDocLink(message, link string) string {
+	return message + " — see " + link
+}
+
+// linkDocs appends the documentation links of the service to the results and conditions of a response, by reason
+// Fatal results carry the generic RenderFailed reason, so they're linked by the reason of the failed condition
+func (r *Results) linkDocs(rsp *fnv1.RunFunctionResponse) {
+	if len(r.docs) == 0 {
+		return
+	}
+	failedLink := ""
+	for _, condition := range rsp.GetConditions() {
+		link, ok := r.docs[condition.GetReason()]
+		if !ok {
+			continue
+		}
+		condition.Message = ptr.To(withDocLink(condition.GetMessage(), link))
+		if failedLink == "" && condition.GetStatus() == fnv1.Status_STATUS_CONDITION_FALSE {
+			failedLink = link
+		}
+	}
+	for _, result := range rsp.GetResults() {
+		link, ok := r.docs[result.GetReason()]
+		if !ok && result.GetSeverity() == fnv1.Severity_SEVERITY_FATAL {
+			link, ok = failedLink, failedLink != ""
+		}
+		if ok {
+			result.Message = withDocLink(result.GetMessage(), link)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// IgnoreDifference declares fields of a composed resource whose drift is tolerated
+// Paths use fieldpath notation relative to the resource (e.g., spec.forProvider.values.replicaCount)
+type IgnoreDifference struct {
+	Resource string
+	Paths    []string
+}
+
+// getIgnoreDifferences extracts ignoreDifferences from service config
+func getIgnoreDifferences(serviceConfig map[string]any) ([]IgnoreDifference, error) {
+	ignoresRaw, ok := serviceConfig["ignoreDifferences"].([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	ignores := make([]IgnoreDifference, 0, len(ignoresRaw))
+	for i, ignoreRaw := range ignoresRaw {
+		ignoreMap, ok := ignoreRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("ignoreDifferences[%d] is not a map", i)
+		}
+		resource, _ := ignoreMap["resource"].(string)
+		if resource == "" {
+			return nil, fmt.Errorf("ignoreDifferences[%d]: resource must be set", i)
+		}
+		pathsRaw, _ := ignoreMap["paths"].([]any)
+		if len(pathsRaw) == 0 {
+			return nil, fmt.Errorf("ignoreDifferences[%d]: paths must not be empty", i)
+		}
+
+		paths := make([]string, 0, len(pathsRaw))
+		for j, pathRaw := range pathsRaw {
+			path, ok := pathRaw.(string)
+			if !ok || path == "" {
+				return nil, fmt.Errorf("ignoreDifferences[%d].paths[%d] must be a non-empty string", i, j)
+			}
+			if _, err := fieldpath.Parse(path); err != nil {
+				return nil, fmt.Errorf("ignoreDifferences[%d].paths[%d]: %w", i, j, err)
+			}
+			paths = append(paths, path)
+		}
+		ignores = append(ignores, IgnoreDifference{Resource: resource, Paths: paths})
+	}
+	return ignores, nil
+}
+
+// applyIgnoreDifferences re-emits observed values for fields whose drift is tolerated
+// This keeps runtime changes (e.g., replicas scaled by an HPA) from being reverted on every reconcile
+// Fields the observed resource doesn't have yet keep their rendered value
+func applyIgnoreDifferences(
+	resources map[string]*fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	ignores []IgnoreDifference,
+	log logr.Logger,
+) error {
+	for _, ignore := range ignores {
+		desired, ok := resources[ignore.Resource]
+		if !ok {
+			continue
+		}
+		observed, ok := observedResources[ignore.Resource]
+		if !ok {
+			continue
+		}
+
+		observedPaved := fieldpath.Pave(observed.GetResource().AsMap())
+		desiredPaved := fieldpath.Pave(desired.GetResource().AsMap())
+		kept := 0
+		for _, path := range ignore.Paths {
+			value, err := observedPaved.GetValue(path)
+			if err != nil {
+				continue
+			}
+			if err := desiredPaved.SetValue(path, value); err != nil {
+				return fmt.Errorf("failed to keep observed %s of %s: %w", path, ignore.Resource, err)
+			}
+			kept++
+		}
+		if kept == 0 {
+			continue
+		}
+
+		desiredStruct, err := structpb.NewStruct(desiredPaved.UnstructuredContent())
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", ignore.Resource, err)
+		}
+		desired.Resource = desiredStruct
+		log.Info("Kept observed values for ignored differences", "resource", ignore.Resource, "paths", kept)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+// defaultNoProxy keeps loopback and in-cluster traffic off the egress proxy
+var defaultNoProxy = []string{"localhost", "127.0.0.1", ".svc", ".cluster.local"}
+
+// EgressProxyConfig is the HTTP(S) proxy of clusters that only reach the internet through it
+// The proxy itself usually comes platform-wide from an EnvironmentConfig (see environment), the env paths
+// from the service profile, as only the service knows where its chart takes environment variables
+type EgressProxyConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	// NoProxy are hosts, domains and CIDRs reached directly, e.g., the cluster's pod and service CIDRs
+	NoProxy []string
+	// EnvPaths are helm value paths of env var lists (name/value entries), e.g., master.extraEnvVars
+	EnvPaths []string
+}
+
+// getEgressProxyConfig extracts egressProxy configuration from service config
+// Returns nil without error if the cluster reaches the internet directly
+func getEgressProxyConfig(serviceConfig map[string]any) (*EgressProxyConfig, error) {
+	proxyConfig, ok := serviceConfig["egressProxy"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &EgressProxyConfig{}
+	config.HTTPProxy, _ = proxyConfig["httpProxy"].(string)
+	config.HTTPSProxy, _ = proxyConfig["httpsProxy"].(string)
+	if config.HTTPProxy == "" && config.HTTPSProxy == "" {
+		return nil, fmt.Errorf("egressProxy requires httpProxy or httpsProxy")
+	}
+	for _, proxy := range []string{config.HTTPProxy, config.HTTPSProxy} {
+		if proxy == "" {
+			continue
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Hostname() == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		// Credentials would end up in plain helm values
+		if proxyURL.User != nil {
+			return nil, fmt.Errorf("proxy URL %q must not contain credentials", proxy)
+		}
+	}
+
+	config.NoProxy = slices.Clone(defaultNoProxy)
+	noProxyRaw, _ := proxyConfig["noProxy"].([]any)
+	for i, entryRaw := range noProxyRaw {
+		entry, _ := entryRaw.(string)
+		if entry == "" {
+			return nil, fmt.Errorf("noProxy[%d] must be a host, domain or CIDR", i)
+		}
+		if !slices.Contains(config.NoProxy, entry) {
+			config.NoProxy = append(config.NoProxy, entry)
+		}
+	}
+	pathsRaw, _ := proxyConfig["envPaths"].([]any)
+	for i, pathRaw := range pathsRaw {
+		path, _ := pathRaw.(string)
+		if path == "" {
+			return nil, fmt.Errorf("envPaths[%d] must be a helm value path", i)
+		}
+		config.EnvPaths = append(config.EnvPaths, path)
+	}
+	return config, nil
+}
+
+// env returns the proxy environment variables, upper- and lowercase as tools disagree on which they read
+func (c *EgressProxyConfig) env() []map[string]any {
+	var env []map[string]any
+	for _, variable := range []struct{ name, value string }{
+		{"HTTP_PROXY", c.HTTPProxy},
+		{"HTTPS_PROXY", c.HTTPSProxy},
+		{"NO_PROXY", strings.Join(c.NoProxy, ",")},
+	} {
+		if variable.value == "" {
+			continue
+		}
+		env = append(env,
+			map[string]any{"name": variable.name, "value": variable.value},
+			map[string]any{"name": strings.ToLower(variable.name), "value": variable.value},
+		)
+	}
+	return env
+}
+
+// applyEgressProxy appends the proxy environment variables to the env var lists of the chart values
+// Variables the values already set, e.g., through spec.helmValues, are left alone
+func applyEgressProxy(mergedConfig, serviceConfig map[string]any, log logr.Logger) error {
+	config, err := getEgressProxyConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid egressProxy config: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+	if len(config.EnvPaths) == 0 {
+		log.Info("Service declares no egressProxy.envPaths, not injecting the egress proxy")
+		return nil
+	}
+
+	helmValues, ok := mergedConfig["helmValues"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("helmValues not found in merged config")
+	}
+	for _, path := range config.EnvPaths {
+		existing, _ := lookupValueByPath(helmValues, path)
+		env, ok := existing.([]any)
+		if existing != nil && !ok {
+			return fmt.Errorf("%s must be a list of environment variables", path)
+		}
+		env = slices.Clone(env)
+		for _, variable := range config.env() {
+			if !slices.ContainsFunc(env, func(entry any) bool {
+				set, _ := entry.(map[string]any)
+				return set["name"] == variable["name"]
+			}) {
+				env = append(env, variable)
+			}
+		}
+		if err := setValueByPath(helmValues, path, env); err != nil {
+			return fmt.Errorf("failed to set proxy environment at %s: %w", path, err)
+		}
+	}
+	log.Info("Injected egress proxy environment", "paths", config.EnvPaths)
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/yaml"
+)
+
+// requiredEnvironmentPrefix prefixes the required resource names of environment sources
+const requiredEnvironmentPrefix = "environment-"
+
+// Defaults of environment sources
+const (
+	defaultEnvironmentAPIVersion = "apiextensions.crossplane.io/v1beta1"
+	defaultEnvironmentKind       = "EnvironmentConfig"
+	defaultEnvironmentKey        = "config.yaml"
+)
+
+// EnvironmentSource selects cluster-level platform defaults merged beneath the service config
+// EnvironmentConfigs provide service config sections in data, ConfigMaps as YAML in data[Key]
+type EnvironmentSource struct {
+	APIVersion string
+	Kind       string
+	// Name or MatchLabels select the resources; matches by label are merged in name order
+	Name        string
+	MatchLabels map[string]string
+	// Namespace of namespaced sources such as ConfigMaps
+	Namespace string
+	Key       string
+	// Optional sources may be missing, otherwise a missing source fails the render
+	Optional bool
+}
+
+// environmentDecision is the outcome of resolving the environment sources
+type environmentDecision struct {
+	requirements *fnv1.Requirements
+	// pending is true until Crossplane has fetched the required resources
+	pending bool
+}
+
+// getEnvironmentSources extracts environment sources from service config
+// Returns nil without error if no environment is configured
+func getEnvironmentSources(serviceConfig map[string]any) ([]EnvironmentSource, error) {
+	sourcesRaw, ok := serviceConfig["environment"]
+	if !ok {
+		return nil, nil
+	}
+	sourceList, ok := sourcesRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("environment must be a list")
+	}
+
+	sources := make([]EnvironmentSource, 0, len(sourceList))
+	for i, sourceRaw := range sourceList {
+		source, ok := sourceRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("environment[%d] must be a map", i)
+		}
+
+		s := EnvironmentSource{APIVersion: defaultEnvironmentAPIVersion, Kind: defaultEnvironmentKind, Key: defaultEnvironmentKey}
+		if apiVersion, _ := source["apiVersion"].(string); apiVersion != "" {
+			s.APIVersion = apiVersion
+		}
+		if kind, _ := source["kind"].(string); kind != "" {
+			s.Kind = kind
+		}
+		if key, _ := source["key"].(string); key != "" {
+			s.Key = key
+		}
+		s.Name, _ = source["name"].(string)
+		s.Namespace, _ = source["namespace"].(string)
+		s.Optional, _ = source["optional"].(bool)
+		if labels, ok := source["matchLabels"].(map[string]any); ok {
+			s.MatchLabels = map[string]string{}
+			for key, value := range labels {
+				s.MatchLabels[key], _ = value.(string)
+			}
+		}
+		if (s.Name == "") == (len(s.MatchLabels) == 0) {
+			return nil, fmt.Errorf("environment[%d] requires either name or matchLabels", i)
+		}
+		sources = append(sources, s)
+	}
+	return sources, nil
+}
+
+// selector returns the resource selector requiring the source from Crossplane
+func (s EnvironmentSource) selector() *fnv1.ResourceSelector {
+	selector := &fnv1.ResourceSelector{ApiVersion: s.APIVersion, Kind: s.Kind}
+	if s.Name != "" {
+		selector.Match = &fnv1.ResourceSelector_MatchName{MatchName: s.Name}
+	} else {
+		selector.Match = &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: s.MatchLabels}}
+	}
+	if s.Namespace != "" {
+		selector.Namespace = &s.Namespace
+	}
+	return selector
+}
+
+// config returns the service config sections a fetched source provides
+func (s EnvironmentSource) config(res *fnv1.Resource) (map[string]any, error) {
+	paved := fieldpath.Pave(res.GetResource().AsMap())
+	if s.Kind != "ConfigMap" {
+		dataRaw, err := paved.GetValue("data")
+		if err != nil {
+			return map[string]any{}, nil
+		}
+		data, ok := dataRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("data is not a map")
+		}
+		return data, nil
+	}
+
+	raw, err := paved.GetString(fmt.Sprintf("data[%s]", s.Key))
+	if err != nil {
+		return nil, fmt.Errorf("ConfigMap has no %s", s.Key)
+	}
+	data := map[string]any{}
+	if err := yaml.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.Key, err)
+	}
+	return data, nil
+}
+
+// applyEnvironment merges cluster-level platform defaults (e.g. storage classes, image registries)
+// from EnvironmentConfigs or ConfigMaps beneath the service config
+// The first invocation only requires the sources; Crossplane calls again once it has fetched them
+// The returned decision is never nil, so its requirements can be passed on unconditionally
+func applyEnvironment(req *fnv1.RunFunctionRequest, serviceConfig map[string]any, log logr.Logger) (map[string]any, *environmentDecision, error) {
+	sources, err := getEnvironmentSources(serviceConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid environment config: %w", err)
+	}
+	if len(sources) == 0 {
+		return serviceConfig, &environmentDecision{}, nil
+	}
+
+	decision := &environmentDecision{requirements: &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}}
+	for i, source := range sources {
+		decision.requirements.Resources[fmt.Sprintf("%s%d", requiredEnvironmentPrefix, i)] = source.selector()
+	}
+
+	environment := map[string]any{}
+	for i, source := range sources {
+		found, ok := getRequiredResources(req, fmt.Sprintf("%s%d", requiredEnvironmentPrefix, i))
+		if !ok {
+			decision.pending = true
+			return serviceConfig, decision, nil
+		}
+		if len(found) == 0 {
+			if !source.Optional {
+				return nil, nil, fmt.Errorf("environment[%d]: no %s found", i, source.Kind)
+			}
+			log.Info("Optional environment source not found", "index", i, "kind", source.Kind, "name", source.Name)
+			continue
+		}
+
+		byName := map[string]*fnv1.Resource{}
+		for _, res := range found {
+			name, _ := fieldpath.Pave(res.GetResource().AsMap()).GetString("metadata.name")
+			byName[name] = res
+		}
+		for _, name := range slices.Sorted(maps.Keys(byName)) {
+			config, err := source.config(byName[name])
+			if err != nil {
+				return nil, nil, fmt.Errorf("environment[%d] %s %s: %w", i, source.Kind, name, err)
+			}
+			environment = deepMerge(environment, config, replaceLists)
+		}
+	}
+
+	log.Info("Applied environment defaults", "sources", len(sources), "sections", slices.Sorted(maps.Keys(environment)))
+	return deepMerge(environment, serviceConfig, replaceLists), decision, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultExternalizeThresholdBytes is the inline values size above which sections move to a Secret
+// Well below the etcd object limit (1.5MiB) to leave room for the rest of the Release
+const defaultExternalizeThresholdBytes = 256 * 1024
+
+// externalValuesKey is the Secret key holding the externalized values document
+const externalValuesKey = "values.yaml"
+
+// externalValuesPath is the path of the externalized values document in the values Secret
+var externalValuesPath = fmt.Sprintf("data[%s]", externalValuesKey)
+
+// getExternalizeThreshold extracts valuesExternalization.thresholdBytes from merged config
+func getExternalizeThreshold(mergedConfig map[string]any) (int, error) {
+	externalization, ok := mergedConfig["valuesExternalization"].(map[string]any)
+	if !ok {
+		return defaultExternalizeThresholdBytes, nil
+	}
+
+	thresholdRaw, ok := externalization["thresholdBytes"]
+	if !ok {
+		return defaultExternalizeThresholdBytes, nil
+	}
+	threshold, ok := thresholdRaw.(float64)
+	if !ok || threshold <= 0 {
+		return 0, fmt.Errorf("thresholdBytes must be a positive number, got %v", thresholdRaw)
+	}
+	return int(threshold), nil
+}
+
+// externalizeValues splits helm values into inline and externalized parts
+// If the serialized values exceed threshold, the largest top-level sections are moved out until
+// the inline part fits. Returns nil external values when nothing needs to move.
+// Sections may hold injected credentials, so the external part must only be stored in a Secret
+func externalizeValues(helmValues map[string]any, threshold int) (inline, external map[string]any, err error) {
+	raw, err := json.Marshal(helmValues)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal helm values: %w", err)
+	}
+	if len(raw) <= threshold {
+		return helmValues, nil, nil
+	}
+
+	// Measure each top-level section
+	type section struct {
+		key  string
+		size int
+	}
+	sections := make([]section, 0, len(helmValues))
+	for key, value := range helmValues {
+		sectionRaw, err := json.Marshal(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal helm values section %s: %w", key, err)
+		}
+		sections = append(sections, section{key: key, size: len(sectionRaw)})
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		if sections[i].size != sections[j].size {
+			return sections[i].size > sections[j].size
+		}
+		return sections[i].key < sections[j].key
+	})
+
+	inline = make(map[string]any, len(helmValues))
+	for key, value := range helmValues {
+		inline[key] = value
+	}
+	external = make(map[string]any)
+
+	// Move the largest sections first
+	size := len(raw)
+	for _, s := range sections {
+		if size <= threshold {
+			break
+		}
+		external[s.key] = inline[s.key]
+		delete(inline, s.key)
+		size -= s.size
+	}
+
+	return inline, external, nil
+}
+
+// readExternalValues decodes the externalized values document of a values Secret, also when it's wrapped in an Object
+// Returns false if the resource holds no document
+func readExternalValues(resource *fnv1.Resource) (map[string]any, bool, error) {
+	encoded, err := fieldpath.Pave(resource.GetResource().AsMap()).GetString(observedPath(resource, externalValuesPath))
+	if err != nil || encoded == "" {
+		return nil, false, nil
+	}
+	document, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decode externalized values: %w", err)
+	}
+	values := map[string]any{}
+	if err := json.Unmarshal(document, &values); err != nil {
+		return nil, false, fmt.Errorf("failed to parse externalized values: %w", err)
+	}
+	return values, true, nil
+}
+
+// writeExternalValues replaces the externalized values document of a values Secret, also when it's wrapped in an Object
+func writeExternalValues(resource *fnv1.Resource, values map[string]any) error {
+	document, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal externalized values: %w", err)
+	}
+	object := resource.GetResource().AsMap()
+	if err := fieldpath.Pave(object).SetValue(observedPath(resource, externalValuesPath), base64.StdEncoding.EncodeToString(document)); err != nil {
+		return fmt.Errorf("failed to set externalized values: %w", err)
+	}
+	updated, err := structpb.NewStruct(object)
+	if err != nil {
+		return fmt.Errorf("failed to convert externalized values: %w", err)
+	}
+	resource.Resource = updated
+	return nil
+}
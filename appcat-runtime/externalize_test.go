@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestExternalizedValuesKeepPasswordInSecret(t *testing.T) {
+	composite, err := structpb.NewStruct(map[string]any{
+		"apiVersion": "vshn.appcat.io/v1",
+		"kind":       "XRedis",
+		"metadata":   map[string]any{"name": "redis-a", "namespace": "team"},
+		"spec":       map[string]any{"writeConnectionSecretToRef": map[string]any{"name": "redis-a-creds"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mergedConfig := map[string]any{
+		"chart": map[string]any{"repository": "https://charts.bitnami.com/bitnami", "name": "redis", "defaultVersion": "19.0.0"},
+		"helmValues": map[string]any{
+			// The password lands in the largest section, which is moved out first
+			"auth":    map[string]any{"enabled": true, "padding": strings.Repeat("x", 2048)},
+			"replica": map[string]any{"count": float64(1)},
+		},
+		"connectionSecret": map[string]any{
+			"passwordPath": "auth.password",
+			"fields":       []any{map[string]any{"key": "password", "value": "${password}"}},
+		},
+		"valuesExternalization": map[string]any{"thresholdBytes": float64(512)},
+	}
+
+	resources, connDetails, _, err := generateResources(context.Background(), &fnv1.Resource{Resource: composite}, nil, mergedConfig, ReleaseTarget{}, &Results{}, logr.Discard())
+	if err != nil {
+		t.Fatalf("generateResources() error = %v", err)
+	}
+	password := string(connDetails["password"])
+	if password == "" {
+		t.Fatal("no password in connection details")
+	}
+
+	valuesSecret, ok := resources[valuesSecretKey(releaseKey)]
+	if !ok {
+		t.Fatalf("no %s resource, got %v", valuesSecretKey(releaseKey), resources)
+	}
+	if kind := valuesSecret.GetResource().AsMap()["kind"]; kind != "Secret" {
+		t.Errorf("externalized values kind = %v, want Secret", kind)
+	}
+	values, ok, err := readExternalValues(valuesSecret)
+	if err != nil || !ok {
+		t.Fatalf("readExternalValues() = %v, %v", ok, err)
+	}
+	if got := values["auth"].(map[string]any)["password"]; got != password {
+		t.Errorf("externalized auth.password = %v, want the generated password", got)
+	}
+
+	for key, resource := range resources {
+		if kind := resource.GetResource().AsMap()["kind"]; kind == "Secret" {
+			continue
+		}
+		encoded, err := resource.GetResource().MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(encoded), password) {
+			t.Errorf("%s holds the password outside a Secret", key)
+		}
+	}
+}
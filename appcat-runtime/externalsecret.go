@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Resource key of the ExternalSecret and required resource name of the Secret ESO syncs it into
+const (
+	externalSecretKey        = "secret-external"
+	requiredExternalPassword = "external-password"
+	// externalPasswordKey is the key of the synced Secret holding the password
+	externalPasswordKey = "password"
+)
+
+// externalSecretSource reads the password from an external store (e.g. Vault) through an ESO ExternalSecret
+// The function never talks to the store: it composes the ExternalSecret and reads the Secret ESO syncs,
+// so the password is rotated in the store and follows within refreshInterval
+type externalSecretSource struct {
+	storeName       string
+	storeKind       string
+	remoteKey       string
+	property        string
+	refreshInterval string
+}
+
+// newExternalSecretSource creates an externalSecret source from its config, e.g.
+// {type: externalSecret, storeRef: {name: vault, kind: ClusterSecretStore}, remoteKey: "appcat/${namespace}/${instanceName}", property: password}
+func newExternalSecretSource(config map[string]any) (SecretSource, error) {
+	paved := fieldpath.Pave(config)
+	source := externalSecretSource{storeKind: "ClusterSecretStore", refreshInterval: "1h"}
+	source.storeName, _ = paved.GetString("storeRef.name")
+	if source.storeName == "" {
+		return nil, fmt.Errorf("storeRef.name is required")
+	}
+	if kind, _ := paved.GetString("storeRef.kind"); kind != "" {
+		if !slices.Contains([]string{"SecretStore", "ClusterSecretStore"}, kind) {
+			return nil, fmt.Errorf("storeRef.kind must be SecretStore or ClusterSecretStore")
+		}
+		source.storeKind = kind
+	}
+	source.remoteKey, _ = paved.GetString("remoteKey")
+	if source.remoteKey == "" {
+		return nil, fmt.Errorf("remoteKey is required")
+	}
+	source.property, _ = paved.GetString("property")
+	if interval, _ := paved.GetString("refreshInterval"); interval != "" {
+		source.refreshInterval = interval
+	}
+	return source, nil
+}
+
+// Password implements SecretSource
+func (externalSecretSource) Password(lookup SecretLookup) (string, bool, error) {
+	if lookup.ExternalPassword == "" {
+		return "", false, nil
+	}
+	lookup.Log.Info("Using password synced from the external store", "instance", lookup.InstanceName)
+	return lookup.ExternalPassword, true, nil
+}
+
+// externalPasswordSecretName returns the name of the ExternalSecret and the Secret ESO syncs the password into
+func externalPasswordSecretName(instanceName string) string {
+	return instanceName + "-external-password"
+}
+
+// externalSecretDecision is the outcome of fetching the password an externalSecret source syncs
+type externalSecretDecision struct {
+	requirements *fnv1.Requirements
+	// resources holds the ExternalSecret, composed on every render
+	resources map[string]*fnv1.Resource
+	// pending is set until ESO has synced the password
+	pending bool
+}
+
+// applyExternalSecret composes the ExternalSecret of the service's externalSecret source and fetches the Secret
+// ESO syncs, recording its password as mergedConfig["externalPassword"] for generateResources
+// Returns an empty decision if the secret store has no externalSecret source
+func applyExternalSecret(req *fnv1.RunFunctionRequest, composite *fnv1.Resource, mergedConfig map[string]any, results *Results, log logr.Logger) (*externalSecretDecision, error) {
+	decision := &externalSecretDecision{}
+	store, err := getSecretStore(mergedConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid secret store config: %w", err)
+	}
+	var source *externalSecretSource
+	for _, candidate := range store.Sources {
+		if external, ok := candidate.(externalSecretSource); ok {
+			source = &external
+			break
+		}
+	}
+	if source == nil {
+		return decision, nil
+	}
+
+	instanceName, err := getInstanceName(composite, mergedConfig)
+	if err != nil {
+		return decision, err
+	}
+	namespace, err := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.namespace")
+	if err != nil {
+		return decision, fmt.Errorf("failed to get composite namespace: %w", err)
+	}
+	name := externalPasswordSecretName(instanceName)
+
+	remoteKey := substituteVariables(source.remoteKey, map[string]string{"instanceName": instanceName, "namespace": namespace})
+	externalSecret, err := toFunctionResource(NewExternalSecretBuilder(name, namespace).
+		WithStore(source.storeName, source.storeKind).
+		WithRefreshInterval(source.refreshInterval).
+		WithData(externalPasswordKey, remoteKey, source.property).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel("app.kubernetes.io/component", "secret-external").
+		Build())
+	if err != nil {
+		return decision, fmt.Errorf("failed to convert external secret: %w", err)
+	}
+	decision.resources = map[string]*fnv1.Resource{externalSecretKey: externalSecret}
+	decision.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+		requiredExternalPassword: {
+			ApiVersion: "v1",
+			Kind:       "Secret",
+			Match:      &fnv1.ResourceSelector_MatchName{MatchName: name},
+			Namespace:  &namespace,
+		},
+	}}
+
+	found, _ := getRequiredResources(req, requiredExternalPassword)
+	var encoded string
+	if len(found) > 0 {
+		encoded, _ = fieldpath.Pave(found[0].GetResource().AsMap()).GetString(fmt.Sprintf("data[%s]", externalPasswordKey))
+	}
+	if encoded == "" {
+		decision.pending = true
+		log.Info("Waiting for the password to be synced from the external store", "secret", name)
+		results.Normal("WaitingForExternalSecret", "Waiting for ESO to sync the password of %s from %s %s", instanceName, source.storeKind, source.storeName)
+		return decision, nil
+	}
+	password, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return decision, fmt.Errorf("synced password in Secret %s is not valid base64: %w", name, err)
+	}
+	mergedConfig["externalPassword"] = string(password)
+	return decision, nil
+}
+
+// getExternalPassword returns the password fetched by applyExternalSecret, "" without an externalSecret source
+func getExternalPassword(mergedConfig map[string]any) string {
+	password, _ := mergedConfig["externalPassword"].(string)
+	return password
+}
+
+// hold returns the response of a render waiting for the synced password
+// Observed resources are kept with their observed spec and data and the ExternalSecret is added, so a new
+// instance only gets the ExternalSecret and an existing one keeps running as it is until the password is back
+func (d *externalSecretDecision) hold(req *fnv1.RunFunctionRequest, requirements *fnv1.Requirements, results *Results) (*fnv1.RunFunctionResponse, error) {
+	resources := map[string]*fnv1.Resource{}
+	for key, observed := range req.GetObserved().GetResources() {
+		kept, err := observedDesired(observed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to keep %s while waiting for the external secret: %w", key, err)
+		}
+		resources[key] = kept
+	}
+	for key, resource := range d.resources {
+		resources[key] = resource
+	}
+
+	compositeStatus, err := structpb.NewStruct(map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build composite: %w", err)
+	}
+	return &fnv1.RunFunctionResponse{
+		Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)},
+		Desired: &fnv1.State{
+			Composite: &fnv1.Resource{
+				Resource:          compositeStatus,
+				ConnectionDetails: req.GetObserved().GetComposite().GetConnectionDetails(),
+				Ready:             fnv1.Ready_READY_FALSE,
+			},
+			Resources: resources,
+		},
+		Requirements: requirements,
+		Results:      results.List(),
+		Conditions: []*fnv1.Condition{newCondition(ConditionRenderSynced, fnv1.Status_STATUS_CONDITION_FALSE,
+			"WaitingForExternalSecret", "Waiting for the password to be synced from the external store")},
+	}, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestApplyExternalSecret(t *testing.T) {
+	toResource := func(object map[string]any) *fnv1.Resource {
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	composite := toResource(map[string]any{"metadata": map[string]any{"name": "redis-a", "namespace": "team"}})
+	synced := toResource(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"data":       map[string]any{externalPasswordKey: base64.StdEncoding.EncodeToString([]byte("from-vault"))},
+	})
+	observedRelease := toResource(map[string]any{"apiVersion": "helm.m.crossplane.io/v1beta1", "kind": "Release", "spec": map[string]any{}})
+
+	cases := map[string]struct {
+		required map[string]*fnv1.Resources
+		observed map[string]*fnv1.Resource
+		pending  bool
+	}{
+		"RequirementsPending": {pending: true},
+		"NotSynced":           {required: map[string]*fnv1.Resources{requiredExternalPassword: {}}, observed: map[string]*fnv1.Resource{releaseKey: observedRelease}, pending: true},
+		"Synced":              {required: map[string]*fnv1.Resources{requiredExternalPassword: {Items: []*fnv1.Resource{synced}}}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mergedConfig := map[string]any{
+				"secretStore": map[string]any{"sources": []any{map[string]any{
+					"type":      SecretSourceExternalSecret,
+					"storeRef":  map[string]any{"name": "vault"},
+					"remoteKey": "appcat/${namespace}/${instanceName}",
+					"property":  "password",
+				}}},
+			}
+			req := &fnv1.RunFunctionRequest{
+				Observed:          &fnv1.State{Composite: composite, Resources: tc.observed},
+				RequiredResources: tc.required,
+			}
+			decision, err := applyExternalSecret(req, composite, mergedConfig, &Results{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("applyExternalSecret() error = %v", err)
+			}
+			if decision.pending != tc.pending {
+				t.Errorf("pending = %v, want %v", decision.pending, tc.pending)
+			}
+			externalSecret, ok := decision.resources[externalSecretKey]
+			if !ok {
+				t.Fatalf("no %s resource", externalSecretKey)
+			}
+			remoteKey := externalSecret.GetResource().AsMap()["spec"].(map[string]any)["data"].([]any)[0].(map[string]any)["remoteRef"].(map[string]any)["key"]
+			if remoteKey != "appcat/team/redis-a" {
+				t.Errorf("remoteRef.key = %v, want appcat/team/redis-a", remoteKey)
+			}
+
+			if !tc.pending {
+				if got := getExternalPassword(mergedConfig); got != "from-vault" {
+					t.Errorf("external password = %q, want from-vault", got)
+				}
+				return
+			}
+			resp, err := decision.hold(req, decision.requirements, &Results{})
+			if err != nil {
+				t.Fatalf("hold() error = %v", err)
+			}
+			resources := resp.GetDesired().GetResources()
+			if len(resources) != len(tc.observed)+1 || resources[externalSecretKey] == nil {
+				t.Errorf("held resources = %v, want the observed ones and the ExternalSecret", resources)
+			}
+			if _, ok := resp.GetRequirements().GetResources()[requiredExternalPassword]; !ok {
+				t.Errorf("held response doesn't require the synced Secret")
+			}
+		})
+	}
+}
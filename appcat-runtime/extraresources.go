@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ExtraResource is a resource the service config adds to the generated ones, e.g. a ServiceMonitor
+type ExtraResource struct {
+	// Condition is a CEL expression over spec, metadata and config; the resource is only rendered if it's true
+	Condition string
+	Object    map[string]any
+	// Remote deploys the object to another cluster, wrapped in a provider-kubernetes Object; nil renders it as is
+	Remote *RemoteTarget
+}
+
+// RemoteTarget is where and how provider-kubernetes applies a wrapped object
+type RemoteTarget struct {
+	// ProviderConfigRef defaults to the instance's placement, see applyPlacement
+	ProviderConfigRef  string
+	ManagementPolicies []string
+	// ReadinessPolicy is one of SuccessfulCreate, DeriveFromObject, AllTrue or DeriveFromCelQuery
+	ReadinessPolicy string
+	ReadinessQuery  string
+}
+
+// readinessPolicies are the readiness policies of provider-kubernetes Objects
+var readinessPolicies = []string{"SuccessfulCreate", "DeriveFromObject", "AllTrue", "DeriveFromCelQuery"}
+
+// getRemoteTarget reads the remote section of a resources entry, e.g.
+// {providerConfigRef: workload-cluster, managementPolicies: [Observe], readiness: {policy: DeriveFromObject}}
+// Returns nil without error if the entry has no remote section
+func getRemoteTarget(entry map[string]any) (*RemoteTarget, error) {
+	remoteRaw, ok := entry["remote"]
+	if !ok {
+		return nil, nil
+	}
+	remote, ok := remoteRaw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("remote must be a map")
+	}
+	target := &RemoteTarget{}
+	target.ProviderConfigRef, _ = remote["providerConfigRef"].(string)
+	if policiesRaw, ok := remote["managementPolicies"]; ok {
+		policies, ok := policiesRaw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("remote.managementPolicies must be a list of strings")
+		}
+		for _, policyRaw := range policies {
+			policy, _ := policyRaw.(string)
+			if policy == "" {
+				return nil, fmt.Errorf("remote.managementPolicies must be a list of strings")
+			}
+			target.ManagementPolicies = append(target.ManagementPolicies, policy)
+		}
+	}
+	if readinessRaw, ok := remote["readiness"]; ok {
+		readiness, ok := readinessRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("remote.readiness must be a map")
+		}
+		target.ReadinessPolicy, _ = readiness["policy"].(string)
+		target.ReadinessQuery, _ = readiness["celQuery"].(string)
+		if target.ReadinessPolicy == "" && target.ReadinessQuery != "" {
+			target.ReadinessPolicy = "DeriveFromCelQuery"
+		}
+		if !slices.Contains(readinessPolicies, target.ReadinessPolicy) {
+			return nil, fmt.Errorf("remote.readiness.policy %q is invalid, valid are %s", target.ReadinessPolicy, strings.Join(readinessPolicies, ", "))
+		}
+		if (target.ReadinessPolicy == "DeriveFromCelQuery") != (target.ReadinessQuery != "") {
+			return nil, fmt.Errorf("remote.readiness.celQuery is required by, and only allowed with, policy DeriveFromCelQuery")
+		}
+	}
+	return target, nil
+}
+
+// getExtraResources extracts the resources section from service config, by resource key, e.g.
+// {servicemonitor: {condition: "has(spec.monitoring) && spec.monitoring.enabled", object: {...}}}
+// Returns nil without error if the service only renders generated resources
+func getExtraResources(serviceConfig map[string]any) (map[string]ExtraResource, error) {
+	resourcesRaw, ok := serviceConfig["resources"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	extras := make(map[string]ExtraResource, len(resourcesRaw))
+	for key, entryRaw := range resourcesRaw {
+		entry, ok := entryRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("resources.%s must be a map", key)
+		}
+		object, ok := entry["object"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("resources.%s requires object", key)
+		}
+		for _, field := range []string{"apiVersion", "kind", "metadata.name"} {
+			if value, _ := fieldpath.Pave(object).GetString(field); value == "" {
+				return nil, fmt.Errorf("resources.%s: object requires %s", key, field)
+			}
+		}
+		remote, err := getRemoteTarget(entry)
+		if err != nil {
+			return nil, fmt.Errorf("resources.%s: %w", key, err)
+		}
+		extra := ExtraResource{Object: object, Remote: remote}
+		if condition, ok := entry["condition"]; ok {
+			extra.Condition, _ = condition.(string)
+			if extra.Condition == "" {
+				return nil, fmt.Errorf("resources.%s: condition must be a string", key)
+			}
+			if _, err := compileExpression(extra.Condition); err != nil {
+				return nil, fmt.Errorf("resources.%s: %w", key, err)
+			}
+		}
+		extras[key] = extra
+	}
+	return extras, nil
+}
+
+// generateExtraResources adds the service config's resources whose condition holds to the desired resources
+// Objects take the same templates as defaultHelmValues and default to the composite's namespace; remote objects
+// are wrapped in a provider-kubernetes Object named <instance>-<key> instead, on the instance's cluster by default
+func generateExtraResources(
+	resources map[string]*fnv1.Resource,
+	composite *fnv1.Resource,
+	serviceConfig, userSpec map[string]any,
+	placement *ProviderConfigRef,
+	log logr.Logger,
+) error {
+	extras, err := getExtraResources(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid resources config: %w", err)
+	}
+	if len(extras) == 0 {
+		return nil
+	}
+
+	metadata, _ := composite.GetResource().AsMap()["metadata"].(map[string]any)
+	namespace, _ := metadata["namespace"].(string)
+	var instanceName string
+	vars := map[string]any{"spec": userSpec, "metadata": metadata, "config": serviceConfig, "self": nil}
+	var rendered []string
+	for _, key := range slices.Sorted(maps.Keys(extras)) {
+		extra := extras[key]
+		if _, ok := resources[key]; ok {
+			return fmt.Errorf("resources.%s has the key of a generated resource", key)
+		}
+		if extra.Condition != "" {
+			result, err := evaluateExpression(extra.Condition, vars)
+			if err != nil {
+				return fmt.Errorf("resources.%s: %w", key, err)
+			}
+			enabled, ok := result.(bool)
+			if !ok {
+				return fmt.Errorf("resources.%s: condition must return a bool, got %T", key, result)
+			}
+			if !enabled {
+				continue
+			}
+		}
+
+		object := deepCopy(extra.Object)
+		if err := renderValueTemplates(object, metadata, userSpec, serviceConfig, log); err != nil {
+			return fmt.Errorf("resources.%s: %w", key, err)
+		}
+		if extra.Remote != nil {
+			// The remote cluster's namespaces are unrelated to the composite's, so the object keeps its own
+			if instanceName == "" {
+				if instanceName, err = getInstanceName(composite, serviceConfig); err != nil {
+					return err
+				}
+			}
+			builder := NewObjectBuilder(truncateName(instanceName+"-"+key, defaultNamingMaxLength), object).
+				WithManagementPolicies(extra.Remote.ManagementPolicies...)
+			switch {
+			case extra.Remote.ProviderConfigRef != "":
+				builder = builder.WithProviderConfigRef("", extra.Remote.ProviderConfigRef)
+			case placement != nil:
+				// provider-kubernetes configs are named like the provider-helm configs of the same cluster
+				builder = builder.WithProviderConfigRef(placement.Kind, placement.Name)
+			}
+			switch {
+			case extra.Remote.ReadinessQuery != "":
+				builder = builder.WithReadinessQuery(extra.Remote.ReadinessQuery)
+			case extra.Remote.ReadinessPolicy != "":
+				builder = builder.WithReadiness(extra.Remote.ReadinessPolicy)
+			}
+			object = builder.Build().Object
+		} else {
+			// Namespaced composites can only compose namespaced resources, which live next to the composite
+			paved := fieldpath.Pave(object)
+			if objectNamespace, _ := paved.GetString("metadata.namespace"); objectNamespace == "" && namespace != "" {
+				if err := paved.SetValue("metadata.namespace", namespace); err != nil {
+					return fmt.Errorf("resources.%s: failed to set namespace: %w", key, err)
+				}
+			}
+		}
+		resource, err := structpb.NewStruct(object)
+		if err != nil {
+			return fmt.Errorf("resources.%s: failed to convert object: %w", key, err)
+		}
+		resources[key] = &fnv1.Resource{Resource: resource}
+		rendered = append(rendered, key)
+	}
+	log.Info("Generated resources of the service config", "rendered", rendered, "configured", len(extras))
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	helmv1 "github.com/crossplane-contrib/provider-helm/apis/namespaced/release/v1beta1"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// fluxHelmReleaseKind is the kind of Flux's releases, telling them apart from provider-helm Releases when observed
+const fluxHelmReleaseKind = "HelmRelease"
+
+// fluxRepositoryKey returns the desired resource key of a Flux release's HelmRepository
+func fluxRepositoryKey(key string) string {
+	return key + "-repository"
+}
+
+// fluxReleaseResources translates a Release into a Flux HelmRelease and the HelmRepository it pulls the chart
+// from, each wrapped into an Object, so Flux on the placement's cluster owns the helm release
+// The HelmRelease takes the Release's key, the HelmRepository is keyed by fluxRepositoryKey
+func fluxReleaseResources(key string, release *helmv1.Release, output *OutputConfig) (map[string]*fnv1.Resource, error) {
+	forProvider := release.Spec.ForProvider
+	interval := output.Interval.String()
+
+	repository := map[string]any{
+		"url":      forProvider.Chart.Repository,
+		"interval": interval,
+	}
+	if isOCIRepository(forProvider.Chart.Repository) {
+		repository["type"] = "oci"
+	}
+	// Flux reads the same username and password keys as provider-helm
+	if forProvider.Chart.PullSecretRef.Name != "" {
+		repository["secretRef"] = map[string]any{"name": forProvider.Chart.PullSecretRef.Name}
+	}
+	repositoryName := release.Name + "-repository"
+
+	values := map[string]any{}
+	if len(forProvider.Values.Raw) > 0 {
+		if err := json.Unmarshal(forProvider.Values.Raw, &values); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal release values: %w", err)
+		}
+	}
+	valuesFrom := []any{}
+	for _, source := range forProvider.ValuesFrom {
+		valuesFrom = append(valuesFrom, fluxValuesReference(source, ""))
+	}
+	for _, set := range forProvider.Set {
+		if set.ValueFrom == nil {
+			return nil, fmt.Errorf("set %s: Flux only sets values from Secrets and ConfigMaps", set.Name)
+		}
+		valuesFrom = append(valuesFrom, fluxValuesReference(*set.ValueFrom, set.Name))
+	}
+
+	// Flux waits by default and creates CRDs on install only, provider-helm's defaults are kept
+	install := map[string]any{
+		"createNamespace": !forProvider.SkipCreateNamespace,
+		"disableWait":     !forProvider.Wait,
+	}
+	upgrade := map[string]any{
+		"disableWait": !forProvider.Wait,
+	}
+	if forProvider.SkipCRDs {
+		install["crds"] = "Skip"
+		upgrade["crds"] = "Skip"
+	}
+	if limit := release.Spec.RollbackRetriesLimit; limit != nil {
+		install["remediation"] = map[string]any{"retries": int64(*limit)}
+		upgrade["remediation"] = map[string]any{"retries": int64(*limit)}
+	}
+	spec := map[string]any{
+		"interval":    interval,
+		"releaseName": release.Name,
+		"chart": map[string]any{
+			"spec": map[string]any{
+				"chart":     forProvider.Chart.Name,
+				"version":   forProvider.Chart.Version,
+				"sourceRef": map[string]any{"kind": "HelmRepository", "name": repositoryName},
+				"interval":  interval,
+			},
+		},
+		"install": install,
+		"upgrade": upgrade,
+	}
+	if len(values) > 0 {
+		spec["values"] = values
+	}
+	if len(valuesFrom) > 0 {
+		spec["valuesFrom"] = valuesFrom
+	}
+	if forProvider.WaitTimeout != nil {
+		spec["timeout"] = forProvider.WaitTimeout.Duration.String()
+	}
+
+	labels := make(map[string]any, len(release.Labels))
+	for label, value := range release.Labels {
+		labels[label] = value
+	}
+	helmRelease := map[string]any{
+		"apiVersion": "helm.toolkit.fluxcd.io/v2",
+		"kind":       fluxHelmReleaseKind,
+		"metadata":   map[string]any{"name": release.Name, "namespace": release.Namespace, "labels": labels},
+		"spec":       spec,
+	}
+	helmRepository := map[string]any{
+		"apiVersion": "source.toolkit.fluxcd.io/v1",
+		"kind":       "HelmRepository",
+		"metadata":   map[string]any{"name": repositoryName, "namespace": release.Namespace, "labels": labels},
+		"spec":       repository,
+	}
+
+	releaseResource, err := toFunctionResource(newReleaseObject(release.Name, helmRelease, release).Build())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert flux helm release: %w", err)
+	}
+	repositoryResource, err := toFunctionResource(newReleaseObject(repositoryName, helmRepository, release).Build())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert flux helm repository: %w", err)
+	}
+	return map[string]*fnv1.Resource{key: releaseResource, fluxRepositoryKey(key): repositoryResource}, nil
+}
+
+// fluxValuesReference translates a Release's values source into an entry of Flux's valuesFrom
+// targetPath sets the key's value at a single helm value, empty merges the key as a values document
+func fluxValuesReference(source helmv1.ValueFromSource, targetPath string) map[string]any {
+	kind, selector := "ConfigMap", source.ConfigMapKeyRef
+	if source.SecretKeyRef != nil {
+		kind, selector = "Secret", source.SecretKeyRef
+	}
+	reference := map[string]any{"kind": kind, "name": selector.Name}
+	if selector.Key != "" {
+		reference["valuesKey"] = selector.Key
+	}
+	if targetPath != "" {
+		reference["targetPath"] = targetPath
+	}
+	if selector.Optional {
+		reference["optional"] = true
+	}
+	return reference
+}
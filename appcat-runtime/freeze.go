@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// FreezeWindow is a change freeze (e.g., the year-end freeze) during which chart versions and plans stay as deployed
+type FreezeWindow struct {
+	Start  time.Time
+	End    time.Time
+	Reason string
+	// Namespaces and Claims ("namespace/name") limit the freeze to some claims; empty freezes all instances
+	Namespaces []string
+	Claims     []string
+}
+
+// freezeDecision is the change freeze applying to the composite, if any
+type freezeDecision struct {
+	window *FreezeWindow
+	// status holds status.freeze, nil outside a freeze
+	status map[string]any
+}
+
+// getFreezeWindows extracts freezeWindows from service config, typically provided platform-wide by an
+// EnvironmentConfig (see environment)
+// Returns nil without error if no freeze is configured
+func getFreezeWindows(serviceConfig map[string]any) ([]FreezeWindow, error) {
+	windowsRaw, ok := serviceConfig["freezeWindows"].([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	windows := make([]FreezeWindow, 0, len(windowsRaw))
+	for i, windowRaw := range windowsRaw {
+		window, ok := windowRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("freezeWindows[%d] is not a map", i)
+		}
+		startRaw, _ := window["start"].(string)
+		start, err := time.Parse(time.RFC3339, startRaw)
+		if err != nil {
+			return nil, fmt.Errorf("freezeWindows[%d]: invalid start %q, expected RFC 3339", i, startRaw)
+		}
+		endRaw, _ := window["end"].(string)
+		end, err := time.Parse(time.RFC3339, endRaw)
+		if err != nil || !end.After(start) {
+			return nil, fmt.Errorf("freezeWindows[%d]: invalid end %q, expected RFC 3339 after start", i, endRaw)
+		}
+		freeze := FreezeWindow{Start: start, End: end}
+		freeze.Reason, _ = window["reason"].(string)
+		for _, field := range []struct {
+			name   string
+			target *[]string
+		}{{"namespaces", &freeze.Namespaces}, {"claims", &freeze.Claims}} {
+			valuesRaw, _ := window[field.name].([]any)
+			for j, valueRaw := range valuesRaw {
+				value, _ := valueRaw.(string)
+				if value == "" {
+					return nil, fmt.Errorf("freezeWindows[%d].%s[%d] must be a string", i, field.name, j)
+				}
+				*field.target = append(*field.target, value)
+			}
+		}
+		windows = append(windows, freeze)
+	}
+	return windows, nil
+}
+
+// matches reports whether the freeze applies to the claim
+func (w *FreezeWindow) matches(claim ClaimReference) bool {
+	if len(w.Namespaces) == 0 && len(w.Claims) == 0 {
+		return true
+	}
+	return slices.Contains(w.Namespaces, claim.Namespace) || slices.Contains(w.Claims, claim.Namespace+"/"+claim.Name)
+}
+
+// checkFreeze finds the change freeze the composite is in; of overlapping freezes the longest lasting wins
+func checkFreeze(composite *fnv1.Resource, serviceConfig map[string]any) (*freezeDecision, error) {
+	decision := &freezeDecision{}
+	windows, err := getFreezeWindows(serviceConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid freezeWindows: %w", err)
+	}
+
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	name, _ := paved.GetString("metadata.name")
+	namespace, _ := paved.GetString("metadata.namespace")
+	claim := getClaimReference(composite, name, namespace)
+	current := now()
+	for i := range windows {
+		window := &windows[i]
+		if current.Before(window.Start) || !current.Before(window.End) || !window.matches(claim) {
+			continue
+		}
+		if decision.window == nil || window.End.After(decision.window.End) {
+			decision.window = window
+		}
+	}
+	if decision.window != nil {
+		decision.status = map[string]any{"until": decision.window.End.UTC().Format(time.RFC3339)}
+		if decision.window.Reason != "" {
+			decision.status["reason"] = decision.window.Reason
+		}
+	}
+	return decision, nil
+}
+
+// describe names the freeze for warnings, e.g. "the change freeze until 2027-01-04T00:00:00Z (year-end)"
+func (d *freezeDecision) describe() string {
+	description := "the change freeze until " + d.window.End.UTC().Format(time.RFC3339)
+	if d.window.Reason != "" {
+		description += " (" + d.window.Reason + ")"
+	}
+	return description
+}
+
+// holdPlan keeps the deployed plan during a freeze by resetting spec.plan to status.plan
+// Plan transitions that already started are finished, so the instance isn't left between plans
+func (d *freezeDecision) holdPlan(composite *fnv1.Resource, userSpec map[string]any, results *Results, log logr.Logger) {
+	if d.window == nil {
+		return
+	}
+	target, _ := userSpec["plan"].(string)
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	current, _ := paved.GetString("status.plan")
+	if target == "" || current == "" || target == current {
+		return
+	}
+	transitionTo, _ := paved.GetString("status.planTransition.to")
+	transitionPhase, _ := paved.GetString("status.planTransition.phase")
+	if transitionTo == target && (transitionPhase == PlanPhaseScalingUp || transitionPhase == PlanPhaseApplying) {
+		return
+	}
+
+	userSpec["plan"] = current
+	log.Info("Holding back plan change during change freeze", "plan", current, "requested", target, "until", d.window.End)
+	results.Warning("ChangeFrozen", "Plan %s is applied after %s, staying on %s", target, d.describe(), current)
+}
+
+// holdChartVersion pins the chart to the observed release's version during a freeze, in either direction
+// A blue/green upgrade that already started is finished
+func (d *freezeDecision) holdChartVersion(
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	results *Results,
+	log logr.Logger,
+) error {
+	if d.window == nil {
+		return nil
+	}
+	observedVersion := observedChartVersion(observedResources[activeReleaseKey(composite)])
+	if observedVersion == "" {
+		return nil
+	}
+	chart, ok := mergedConfig["chart"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("chart not found in merged config")
+	}
+	targetVersion, _ := chart["defaultVersion"].(string)
+	phase, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("status.upgrade.phase")
+	if targetVersion == observedVersion || phase == UpgradePhaseDeploying {
+		return nil
+	}
+
+	chart["defaultVersion"] = observedVersion
+	log.Info("Holding back chart version change during change freeze", "version", observedVersion, "requested", targetVersion, "until", d.window.End)
+	results.Warning("ChangeFrozen", "Chart version %s is applied after %s, staying on %s", targetVersion, d.describe(), observedVersion)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import "fmt"
+
+// GitOps controllers the function can coexist with
+const (
+	GitOpsModeArgoCD = "argocd"
+	GitOpsModeFlux   = "flux"
+)
+
+// gitOpsAnnotations keeps GitOps controllers observing the same namespaces from pruning or reverting our resources
+var gitOpsAnnotations = map[string]map[string]string{
+	GitOpsModeArgoCD: {
+		"argocd.argoproj.io/compare-options": "IgnoreExtraneous",
+		"argocd.argoproj.io/sync-options":    "Prune=false",
+	},
+	GitOpsModeFlux: {
+		"kustomize.toolkit.fluxcd.io/prune":     "disabled",
+		"kustomize.toolkit.fluxcd.io/reconcile": "disabled",
+	},
+}
+
+// getGitOpsAnnotations returns the annotations for the gitops.mode in merged config
+// Returns nil if no gitops section is configured
+func getGitOpsAnnotations(mergedConfig map[string]any) (map[string]string, error) {
+	gitops, ok := mergedConfig["gitops"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	mode, _ := gitops["mode"].(string)
+	annotations, ok := gitOpsAnnotations[mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown gitops mode %q", mode)
+	}
+	return annotations, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthServiceName is the service name reported by the health server besides the overall ("") status
+const healthServiceName = "function-appcat-poc"
+
+// Proxy health probing cadence
+const (
+	proxyProbeInterval = 10 * time.Second
+	proxyProbeTimeout  = 5 * time.Second
+)
+
+// readiness wires the gRPC health server to the function's internal state
+// The server reports NOT_SERVING until startup completes and, in proxy mode, while the proxy target is unhealthy
+type readiness struct {
+	srv *health.Server
+	log logr.Logger
+}
+
+// newReadiness creates a health server that starts out NOT_SERVING
+func newReadiness(log logr.Logger) *readiness {
+	r := &readiness{srv: health.NewServer(), log: log}
+	r.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	return r
+}
+
+// set updates the overall and the named service status
+func (r *readiness) set(status healthpb.HealthCheckResponse_ServingStatus) {
+	r.srv.SetServingStatus(healthServiceName, status)
+	r.srv.SetServingStatus("", status)
+}
+
+// markReady reports SERVING once startup (flags, TLS config) has completed
+func (r *readiness) markReady() {
+	r.set(healthpb.HealthCheckResponse_SERVING)
+}
+
+// watchProxy probes the proxy endpoint's own health service until ctx is done
+// Readiness follows the proxy, since requests fail while it's unreachable
+func (r *readiness) watchProxy(ctx context.Context, endpoint string, dial ...grpc.DialOption) {
+	go func() {
+		ticker := time.NewTicker(proxyProbeInterval)
+		defer ticker.Stop()
+
+		healthy := true
+		for {
+			err := probeProxy(ctx, endpoint, dial...)
+			switch {
+			case err != nil && healthy:
+				r.log.Error(err, "Proxy endpoint unhealthy", "endpoint", endpoint)
+				r.set(healthpb.HealthCheckResponse_NOT_SERVING)
+				healthy = false
+			case err == nil && !healthy:
+				r.log.Info("Proxy endpoint healthy again", "endpoint", endpoint)
+				r.set(healthpb.HealthCheckResponse_SERVING)
+				healthy = true
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probeProxy runs a single health check against the proxy endpoint
+// The connection is dialed in plaintext if no dial options are given
+func probeProxy(ctx context.Context, endpoint string, dial ...grpc.DialOption) error {
+	if len(dial) == 0 {
+		dial = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(endpoint, dial...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, proxyProbeTimeout)
+	defer cancel()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return errNotServing(resp.GetStatus())
+	}
+	return nil
+}
+
+// errNotServing reports a proxy that answered health checks with a non-serving status
+type errNotServing healthpb.HealthCheckResponse_ServingStatus
+
+func (e errNotServing) Error() string {
+	return "proxy reports " + healthpb.HealthCheckResponse_ServingStatus(e).String()
+}
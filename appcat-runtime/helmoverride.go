@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+// HelmValuesOverridePolicy restricts which chart values spec.helmValuesOverride may set
+type HelmValuesOverridePolicy struct {
+	// AllowedPaths are dot-separated helm value prefixes; "*" matches any single key
+	AllowedPaths [][]string
+}
+
+// getHelmValuesOverridePolicy extracts the helmValuesOverride policy from service config
+// Returns nil without error if the service doesn't allow overrides
+func getHelmValuesOverridePolicy(serviceConfig map[string]any) (*HelmValuesOverridePolicy, error) {
+	override, ok := serviceConfig["helmValuesOverride"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	pathsRaw, ok := override["allowedPaths"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("allowedPaths must be a list")
+	}
+	policy := &HelmValuesOverridePolicy{}
+	for i, pathRaw := range pathsRaw {
+		path, _ := pathRaw.(string)
+		segments, err := splitKeyPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("allowedPaths[%d] must be a dot-separated helm value path: %w", i, err)
+		}
+		policy.AllowedPaths = append(policy.AllowedPaths, segments)
+	}
+	return policy, nil
+}
+
+// allows reports whether the helm value at path lies at or below an allowed prefix,
+// and whether it is a parent of one, so map values below it may still be allowed
+func (p *HelmValuesOverridePolicy) allows(path []string) (allowed, parent bool) {
+	if p == nil {
+		return false, false
+	}
+	for _, prefix := range p.AllowedPaths {
+		n := min(len(path), len(prefix))
+		matched := true
+		for i := range n {
+			if prefix[i] != "*" && prefix[i] != path[i] {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if len(path) >= len(prefix) {
+			return true, false
+		}
+		parent = true
+	}
+	return false, parent
+}
+
+// filterHelmValuesOverride returns the parts of spec.helmValuesOverride the policy allows
+// and the paths of the dropped values, sorted
+func filterHelmValuesOverride(override map[string]any, policy *HelmValuesOverridePolicy) (map[string]any, []string) {
+	var dropped []string
+	var filter func(values map[string]any, path []string) map[string]any
+	filter = func(values map[string]any, path []string) map[string]any {
+		filtered := map[string]any{}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			keyPath := append(slices.Clone(path), key)
+			allowed, parent := policy.allows(keyPath)
+			if allowed {
+				filtered[key] = values[key]
+				continue
+			}
+			if nested, ok := values[key].(map[string]any); ok && parent {
+				if kept := filter(nested, keyPath); len(kept) > 0 {
+					filtered[key] = kept
+				}
+				continue
+			}
+			dropped = append(dropped, joinKeyPath(keyPath))
+		}
+		return filtered
+	}
+	return filter(override, nil), dropped
+}
+
+// applyHelmValuesOverride deep-merges the allowed parts of spec.helmValuesOverride over the helm values,
+// after all other sources, so power users can reach chart knobs without a dedicated spec field
+// Values outside the service's allowlist are dropped with a warning
+func applyHelmValuesOverride(helmValues, serviceConfig, userSpec map[string]any, listPolicy ListMergePolicy, results *Results, log logr.Logger) error {
+	override, ok := userSpec["helmValuesOverride"].(map[string]any)
+	if !ok || len(override) == 0 {
+		return nil
+	}
+	policy, err := getHelmValuesOverridePolicy(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid helmValuesOverride config: %w", err)
+	}
+
+	filtered, dropped := filterHelmValuesOverride(override, policy)
+	if len(dropped) > 0 {
+		log.Info("Dropped helm values override outside the allowlist", "paths", dropped)
+		results.Warning("HelmValuesOverrideFiltered", "Ignored spec.helmValuesOverride values not allowed by this service: %s", strings.Join(dropped, ", "))
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	deepMerge(helmValues, deepCopy(filtered), listPolicy)
+	log.Info("Applied helm values override", "keys", slices.Sorted(maps.Keys(filtered)))
+	return nil
+}
+
+// applyCustomHelmValues deep-merges spec.helmValues over the mapped helm values, for services that trust their
+// users with any chart option (allowCustomValues: true); protectedHelmPaths still apply
+// Other services ignore spec.helmValues with a warning, spec.helmValuesOverride offers them an allowlist instead
+func applyCustomHelmValues(helmValues, serviceConfig, userSpec map[string]any, listPolicy ListMergePolicy, results *Results, log logr.Logger) {
+	custom, ok := userSpec["helmValues"].(map[string]any)
+	if !ok || len(custom) == 0 {
+		return
+	}
+	if allowed, _ := serviceConfig["allowCustomValues"].(bool); !allowed {
+		log.Info("Service doesn't allow custom helm values, ignoring spec.helmValues")
+		results.Warning("CustomValuesUnsupported", "This service doesn't allow custom helm values, spec.helmValues is ignored")
+		return
+	}
+	deepMerge(helmValues, deepCopy(custom), listPolicy)
+	log.Info("Applied custom helm values", "keys", slices.Sorted(maps.Keys(custom)))
+}
+
+// getProtectedHelmPaths extracts protectedHelmPaths from service config, e.g., ["auth.enabled", "image", "*.image"]
+// Paths are dot-separated helm value paths; "*" matches any single key
+// Returns nil without error if user input may set every value
+func getProtectedHelmPaths(serviceConfig map[string]any) ([][]string, error) {
+	pathsRaw, ok := serviceConfig["protectedHelmPaths"]
+	if !ok {
+		return nil, nil
+	}
+	pathList, ok := pathsRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("protectedHelmPaths must be a list")
+	}
+	var paths [][]string
+	for i, pathRaw := range pathList {
+		path, _ := pathRaw.(string)
+		segments, err := splitKeyPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("protectedHelmPaths[%d] must be a dot-separated helm value path: %w", i, err)
+		}
+		paths = append(paths, segments)
+	}
+	return paths, nil
+}
+
+// protectedValues are the platform's values at the protected helm paths, before user input is merged
+type protectedValues struct {
+	paths [][]string
+	// values by concrete path; paths without a value are absent
+	values map[string]any
+}
+
+// snapshotProtectedValues records the values at the protected paths, see restore
+func snapshotProtectedValues(helmValues map[string]any, paths [][]string) *protectedValues {
+	snapshot := &protectedValues{paths: paths, values: map[string]any{}}
+	for _, pattern := range paths {
+		for _, path := range expandHelmPath(helmValues, pattern) {
+			if value, ok := lookupHelmSegments(helmValues, path); ok {
+				snapshot.values[joinKeyPath(path)] = deepCopySlice([]any{value})[0]
+			}
+		}
+	}
+	return snapshot
+}
+
+// restore resets protected values user input changed, added or removed, returning the reset paths sorted
+func (p *protectedValues) restore(helmValues map[string]any) []string {
+	var reset []string
+	seen := map[string]bool{}
+	for _, pattern := range p.paths {
+		candidates := expandHelmPath(helmValues, pattern)
+		for key := range p.values {
+			path, _ := splitKeyPath(key)
+			candidates = append(candidates, path)
+		}
+		for _, path := range candidates {
+			key := joinKeyPath(path)
+			if seen[key] || !matchesHelmPath(path, pattern) {
+				continue
+			}
+			seen[key] = true
+			current, set := lookupHelmSegments(helmValues, path)
+			original, protected := p.values[key]
+			switch {
+			case protected && (!set || !reflect.DeepEqual(current, original)):
+				setHelmSegments(helmValues, path, deepCopySlice([]any{original})[0])
+			case !protected && set:
+				deleteHelmSegments(helmValues, path)
+			default:
+				continue
+			}
+			reset = append(reset, key)
+		}
+	}
+	slices.Sort(reset)
+	return reset
+}
+
+// expandHelmPath resolves the wildcards of a protected path against the keys present in the values
+func expandHelmPath(values map[string]any, pattern []string) [][]string {
+	if len(pattern) == 0 {
+		return [][]string{nil}
+	}
+	keys := []string{pattern[0]}
+	if pattern[0] == "*" {
+		keys = slices.Sorted(maps.Keys(values))
+	}
+	var paths [][]string
+	for _, key := range keys {
+		if len(pattern) == 1 {
+			paths = append(paths, []string{key})
+			continue
+		}
+		nested, ok := values[key].(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, rest := range expandHelmPath(nested, pattern[1:]) {
+			paths = append(paths, append([]string{key}, rest...))
+		}
+	}
+	return paths
+}
+
+// matchesHelmPath reports whether a concrete path matches a protected path
+func matchesHelmPath(path, pattern []string) bool {
+	if len(path) != len(pattern) {
+		return false
+	}
+	for i := range path {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupHelmSegments returns the value at path, keys containing dots included
+func lookupHelmSegments(values map[string]any, path []string) (any, bool) {
+	current := any(values)
+	for _, key := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setHelmSegments sets the value at path, replacing non-map values on the way by maps
+func setHelmSegments(values map[string]any, path []string, value any) {
+	current := values
+	for _, key := range path[:len(path)-1] {
+		nested, ok := current[key].(map[string]any)
+		if !ok {
+			nested = map[string]any{}
+			current[key] = nested
+		}
+		current = nested
+	}
+	current[path[len(path)-1]] = value
+}
+
+// deleteHelmSegments removes the value at path
+func deleteHelmSegments(values map[string]any, path []string) {
+	parent, ok := lookupHelmSegments(values, path[:len(path)-1])
+	if m, isMap := parent.(map[string]any); ok && isMap {
+		delete(m, path[len(path)-1])
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterHelmValuesOverride(t *testing.T) {
+	policy, err := getHelmValuesOverridePolicy(map[string]any{"helmValuesOverride": map[string]any{
+		"allowedPaths": []any{"master.podAnnotations", "*.nodeSelector", "metrics.enabled", "commonLabels[team.io/name]"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		override    map[string]any
+		want        map[string]any
+		wantDropped []string
+	}{
+		"AllowedSubtree": {
+			override: map[string]any{"master": map[string]any{"podAnnotations": map[string]any{"a": "1"}}},
+			want:     map[string]any{"master": map[string]any{"podAnnotations": map[string]any{"a": "1"}}},
+		},
+		"Wildcard": {
+			override: map[string]any{"replica": map[string]any{"nodeSelector": map[string]any{"pool": "db"}}},
+			want:     map[string]any{"replica": map[string]any{"nodeSelector": map[string]any{"pool": "db"}}},
+		},
+		"SiblingsDropped": {
+			override: map[string]any{
+				"master":  map[string]any{"podAnnotations": map[string]any{"a": "1"}, "image": "evil"},
+				"metrics": map[string]any{"enabled": true, "port": float64(1)},
+			},
+			want: map[string]any{
+				"master":  map[string]any{"podAnnotations": map[string]any{"a": "1"}},
+				"metrics": map[string]any{"enabled": true},
+			},
+			wantDropped: []string{"master.image", "metrics.port"},
+		},
+		"ScalarAtParentDropped": {
+			override:    map[string]any{"master": "evil"},
+			want:        map[string]any{},
+			wantDropped: []string{"master"},
+		},
+		"DottedKey": {
+			override:    map[string]any{"commonLabels": map[string]any{"team.io/name": "a", "other.io/name": "b"}},
+			want:        map[string]any{"commonLabels": map[string]any{"team.io/name": "a"}},
+			wantDropped: []string{"commonLabels[other.io/name]"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, dropped := filterHelmValuesOverride(tc.override, policy)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("filterHelmValuesOverride() = %v, want %v", got, tc.want)
+			}
+			if !reflect.DeepEqual(dropped, tc.wantDropped) {
+				t.Errorf("dropped = %v, want %v", dropped, tc.wantDropped)
+			}
+		})
+	}
+
+	t.Run("NoPolicyDropsEverything", func(t *testing.T) {
+		got, dropped := filterHelmValuesOverride(map[string]any{"a": "1"}, nil)
+		if len(got) != 0 || !reflect.DeepEqual(dropped, []string{"a"}) {
+			t.Errorf("filterHelmValuesOverride() = %v, dropped %v, want nothing kept and a dropped", got, dropped)
+		}
+	})
+}
+
+func TestProtectedValuesRestore(t *testing.T) {
+	paths, err := getProtectedHelmPaths(map[string]any{"protectedHelmPaths": []any{"auth.enabled", "*.image"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform := func() map[string]any {
+		return map[string]any{
+			"auth":    map[string]any{"enabled": true},
+			"master":  map[string]any{"image": map[string]any{"tag": "7.2"}, "count": float64(1)},
+			"replica": map[string]any{"count": float64(1)},
+		}
+	}
+
+	cases := map[string]struct {
+		user      map[string]any
+		wantReset []string
+	}{
+		"UntouchedKept": {
+			user: map[string]any{"master": map[string]any{"count": float64(3)}},
+		},
+		"ChangedRestored": {
+			user:      map[string]any{"auth": map[string]any{"enabled": false}, "master": map[string]any{"image": map[string]any{"tag": "latest"}}},
+			wantReset: []string{"auth.enabled", "master.image"},
+		},
+		"AddedRemoved": {
+			user:      map[string]any{"replica": map[string]any{"image": map[string]any{"repository": "evil"}}},
+			wantReset: []string{"replica.image"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			values := platform()
+			snapshot := snapshotProtectedValues(values, paths)
+			merged := deepMerge(values, tc.user, replaceLists)
+			reset := snapshot.restore(merged)
+			if !reflect.DeepEqual(reset, tc.wantReset) {
+				t.Errorf("restore() = %v, want %v", reset, tc.wantReset)
+			}
+
+			want := deepMerge(platform(), tc.user, replaceLists)
+			want["auth"] = map[string]any{"enabled": true}
+			want["master"].(map[string]any)["image"] = map[string]any{"tag": "7.2"}
+			delete(want["replica"].(map[string]any), "image")
+			if !reflect.DeepEqual(merged, want) {
+				t.Errorf("restored values = %v, want %v", merged, want)
+			}
+		})
+	}
+
+	t.Run("RemovedRestored", func(t *testing.T) {
+		values := platform()
+		snapshot := snapshotProtectedValues(values, paths)
+		delete(values["master"].(map[string]any), "image")
+		if reset := snapshot.restore(values); !reflect.DeepEqual(reset, []string{"master.image"}) {
+			t.Errorf("restore() = %v, want [master.image]", reset)
+		}
+		if _, ok := values["master"].(map[string]any)["image"]; !ok {
+			t.Errorf("master.image not restored")
+		}
+	})
+}
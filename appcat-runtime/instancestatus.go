@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// buildInstanceStatus collects the facts claim users need to connect to the instance,
+// so they can read them from status.instance instead of looking up the generated resources
+// The chart version is the one of the observed serving release, i.e. what is actually deployed
+func buildInstanceStatus(
+	desired map[string]*fnv1.Resource,
+	observed map[string]*fnv1.Resource,
+	servingKey string,
+	connDetails map[string][]byte,
+) map[string]any {
+	status := map[string]any{}
+
+	if release, ok := desired[servingKey]; ok {
+		paved := fieldpath.Pave(release.GetResource().AsMap())
+		if namespace, _ := paved.GetString("metadata.namespace"); namespace != "" {
+			status["namespace"] = namespace
+		}
+		if name, _ := paved.GetString("metadata.name"); name != "" {
+			status["releaseName"] = name
+		}
+	}
+	if release, ok := observed[servingKey]; ok && release.GetResource() != nil {
+		if chartVersion, _ := fieldpath.Pave(release.GetResource().AsMap()).GetString(releaseChartVersionPath(release)); chartVersion != "" {
+			status["chartVersion"] = chartVersion
+		}
+	}
+	if secret, ok := desired["secret"]; ok {
+		if name, _ := fieldpath.Pave(secret.GetResource().AsMap()).GetString("metadata.name"); name != "" {
+			status["secretName"] = name
+		}
+	}
+	if endpoint := connectionEndpoint(connDetails); endpoint != "" {
+		status["endpoint"] = endpoint
+	}
+	return status
+}
+
+// connectionEndpoint returns host:port from the host and port connection details, or just the host without a port
+func connectionEndpoint(connDetails map[string][]byte) string {
+	host := string(connDetails["host"])
+	if host == "" {
+		return ""
+	}
+	if port := string(connDetails["port"]); port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	return host
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Desired resource keys of an instance's TLS certificate chain
+const (
+	tlsSelfSignedIssuerKey = "tls-selfsigned-issuer"
+	tlsCAKey               = "tls-ca"
+	tlsIssuerKey           = "tls-issuer"
+	tlsCertificateKey      = "tls-certificate"
+)
+
+// Default validity of instance certificates, renewed a third before they expire
+const (
+	defaultTLSDuration    = "2160h"
+	defaultTLSRenewBefore = "720h"
+)
+
+// defaultTLSDNSNames are the certificate's DNS names unless the service config lists the chart's Services
+var defaultTLSDNSNames = []string{
+	"${instanceName}.${namespace}.svc",
+	"${instanceName}.${namespace}.svc.cluster.local",
+	"*.${instanceName}.${namespace}.svc.cluster.local",
+}
+
+// TLSConfig defines the certificates of instances with spec.tls.enabled
+type TLSConfig struct {
+	// DNSNames support ${instanceName} and ${namespace}
+	DNSNames    []string
+	Duration    string
+	RenewBefore string
+}
+
+// getTLSConfig extracts tls configuration from service config
+// Returns nil without error if the service doesn't support TLS
+func getTLSConfig(serviceConfig map[string]any) (*TLSConfig, error) {
+	tls, ok := serviceConfig["tls"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &TLSConfig{DNSNames: defaultTLSDNSNames, Duration: defaultTLSDuration, RenewBefore: defaultTLSRenewBefore}
+	if dnsNamesRaw, ok := tls["dnsNames"].([]any); ok {
+		config.DNSNames = nil
+		for i, dnsNameRaw := range dnsNamesRaw {
+			dnsName, _ := dnsNameRaw.(string)
+			if dnsName == "" {
+				return nil, fmt.Errorf("dnsNames[%d] must be a DNS name", i)
+			}
+			config.DNSNames = append(config.DNSNames, dnsName)
+		}
+	}
+	if duration, _ := tls["duration"].(string); duration != "" {
+		config.Duration = duration
+	}
+	if renewBefore, _ := tls["renewBefore"].(string); renewBefore != "" {
+		config.RenewBefore = renewBefore
+	}
+	return config, nil
+}
+
+// applyTLS resolves spec.tls and records the certificate settings as mergedConfig["tls"] for generateTLS
+// The certificate's Secret name is added as spec.tls.secretName before merging, so the service's mapping
+// wires it into the chart's TLS values like any other spec field (e.g., "spec.tls.secretName": "tls.existingSecret")
+func applyTLS(composite *fnv1.Resource, serviceConfig, userSpec map[string]any, results *Results, log logr.Logger) (map[string]any, error) {
+	tls, ok := userSpec["tls"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	if enabled, _ := tls["enabled"].(bool); !enabled {
+		return nil, nil
+	}
+	config, err := getTLSConfig(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls config: %w", err)
+	}
+	if config == nil {
+		log.Info("Service doesn't support TLS, ignoring spec.tls")
+		results.Warning("TLSUnsupported", "This service doesn't support TLS, spec.tls is ignored")
+		return nil, nil
+	}
+
+	instanceName, err := getInstanceName(composite, serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+	namespace, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.namespace")
+	variables := map[string]string{"instanceName": instanceName, "namespace": namespace}
+	dnsNames := make([]any, 0, len(config.DNSNames))
+	for _, dnsName := range config.DNSNames {
+		dnsNames = append(dnsNames, substituteVariables(dnsName, variables))
+	}
+
+	secretName := instanceName + "-tls"
+	tls["secretName"] = secretName
+	return map[string]any{
+		"secretName":  secretName,
+		"dnsNames":    dnsNames,
+		"duration":    config.Duration,
+		"renewBefore": config.RenewBefore,
+	}, nil
+}
+
+// generateTLS creates the instance's certificate chain: a self-signed Issuer bootstraps a per-instance CA,
+// whose Issuer signs the serving certificate. The serving Secret carries the CA as ca.crt, so clients can
+// trust the instance without trusting other instances, and serving certificates rotate without changing the CA
+func generateTLS(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	tls, ok := mergedConfig["tls"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	secretName, _ := tls["secretName"].(string)
+	duration, _ := tls["duration"].(string)
+	renewBefore, _ := tls["renewBefore"].(string)
+	var dnsNames []string
+	for _, dnsName := range tls["dnsNames"].([]any) {
+		dnsNames = append(dnsNames, dnsName.(string))
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": "crossplane",
+		"app.kubernetes.io/instance":   instanceName,
+		"app.kubernetes.io/component":  "tls",
+		LabelClaimName:                 claim.Name,
+		LabelClaimNamespace:            claim.Namespace,
+	}
+	selfSigned := NewIssuerBuilder(instanceName+"-selfsigned", instanceNamespace)
+	ca := NewCertificateBuilder(instanceName+"-ca", instanceNamespace, instanceName+"-ca").
+		WithIssuer(instanceName + "-selfsigned").
+		WithCommonName(truncateName(instanceName+"-ca", 64)).
+		AsCA()
+	issuer := NewIssuerBuilder(instanceName+"-ca", instanceNamespace).
+		WithCA(instanceName + "-ca")
+	certificate := NewCertificateBuilder(instanceName+"-tls", instanceNamespace, secretName).
+		WithIssuer(instanceName+"-ca").
+		WithDNSNames(dnsNames...).
+		WithValidity(duration, renewBefore)
+	for key, value := range labels {
+		selfSigned.WithLabel(key, value)
+		ca.WithLabel(key, value)
+		issuer.WithLabel(key, value)
+		certificate.WithLabel(key, value)
+	}
+
+	for key, obj := range map[string]*unstructured.Unstructured{
+		tlsSelfSignedIssuerKey: selfSigned.Build(),
+		tlsCAKey:               ca.Build(),
+		tlsIssuerKey:           issuer.Build(),
+		tlsCertificateKey:      certificate.Build(),
+	} {
+		resource, err := toFunctionResource(obj)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", key, err)
+		}
+		resources[key] = resource
+	}
+	log.Info("Generated TLS certificate", "secret", secretName, "dnsNames", dnsNames)
+	return nil
+}
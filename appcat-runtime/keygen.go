@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// minKeySeedLength is the shortest seed keys are derived from
+const minKeySeedLength = 16
+
+// Validity of generated certificates; fixed, so re-renders produce identical certificates
+// 9999-12-31T23:59:59Z means "no well-defined expiration" (RFC 5280, 4.1.2.5), rotation happens by changing the seed
+var (
+	generatedCertNotBefore = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	generatedCertNotAfter  = time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC)
+)
+
+// deriveKeyMaterial derives 32 bytes for the given purpose from a secret seed
+func deriveKeyMaterial(seed []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte("appcat/" + purpose))
+	return mac.Sum(nil)
+}
+
+// deriveEd25519Key derives an Ed25519 key from a secret seed
+// Ed25519 keys and signatures are deterministic, unlike ECDSA and RSA in Go, so the same seed always
+// yields the same key and certificate
+func deriveEd25519Key(seed []byte, purpose string) (ed25519.PrivateKey, error) {
+	if len(seed) < minKeySeedLength {
+		return nil, fmt.Errorf("key seed must be at least %d bytes", minKeySeedLength)
+	}
+	return ed25519.NewKeyFromSeed(deriveKeyMaterial(seed, purpose)), nil
+}
+
+// generateSelfSignedTLS creates a self-signed certificate and its key for the given SANs (DNS names or IPs)
+// Returns the PEM encoded certificate and PKCS#8 key
+func generateSelfSignedTLS(seed []byte, commonName string, sans []string) (certPEM, keyPEM []byte, err error) {
+	key, err := deriveEd25519Key(seed, "tls")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetBytes(deriveKeyMaterial(seed, "tls-serial")[:16]),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             generatedCertNotBefore,
+		NotAfter:              generatedCertNotAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	// The random source is unused for Ed25519
+	der, err := x509.CreateCertificate(nil, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), nil
+}
+
+// generateSSHKeypair creates an Ed25519 SSH keypair
+// Returns the private key in OpenSSH format and the public key as an authorized_keys line
+func generateSSHKeypair(seed []byte, comment string) (privateKey, publicKey []byte, err error) {
+	key, err := deriveEd25519Key(seed, "ssh")
+	if err != nil {
+		return nil, nil, err
+	}
+	public := key.Public().(ed25519.PublicKey)
+
+	var publicBlob bytes.Buffer
+	writeSSHString(&publicBlob, []byte("ssh-ed25519"))
+	writeSSHString(&publicBlob, public)
+
+	// openssh-key-v1 without encryption; the check integers are derived instead of random,
+	// so the encoded key is stable across renders
+	var private bytes.Buffer
+	check := binary.BigEndian.Uint32(deriveKeyMaterial(seed, "ssh-check"))
+	binary.Write(&private, binary.BigEndian, check)
+	binary.Write(&private, binary.BigEndian, check)
+	writeSSHString(&private, []byte("ssh-ed25519"))
+	writeSSHString(&private, public)
+	writeSSHString(&private, key)
+	writeSSHString(&private, []byte(comment))
+	for i := byte(1); private.Len()%8 != 0; i++ {
+		private.WriteByte(i)
+	}
+
+	var encoded bytes.Buffer
+	encoded.WriteString("openssh-key-v1\x00")
+	writeSSHString(&encoded, []byte("none"))
+	writeSSHString(&encoded, []byte("none"))
+	writeSSHString(&encoded, nil)
+	binary.Write(&encoded, binary.BigEndian, uint32(1))
+	writeSSHString(&encoded, publicBlob.Bytes())
+	writeSSHString(&encoded, private.Bytes())
+
+	privateKey = pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: encoded.Bytes()})
+	publicKey = []byte("ssh-ed25519 " + base64.StdEncoding.EncodeToString(publicBlob.Bytes()))
+	if comment != "" {
+		publicKey = append(publicKey, " "+comment...)
+	}
+	return privateKey, append(publicKey, '\n'), nil
+}
+
+// writeSSHString writes a length-prefixed string as used in the SSH wire format
+func writeSSHString(buf *bytes.Buffer, value []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(value)))
+	buf.Write(value)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerateSelfSignedTLS(t *testing.T) {
+	seed := []byte("0123456789abcdef")
+	sans := []string{"redis-a.team.svc", "10.0.0.1", "::1"}
+	certPEM, keyPEM, err := generateSelfSignedTLS(seed, "redis-a", sans)
+	if err != nil {
+		t.Fatalf("generateSelfSignedTLS() error = %v", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("certificate is not a PEM CERTIFICATE block: %q", certPEM)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	if cert.Subject.CommonName != "redis-a" {
+		t.Errorf("CommonName = %q, want redis-a", cert.Subject.CommonName)
+	}
+	if !slices.Equal(cert.DNSNames, []string{"redis-a.team.svc"}) {
+		t.Errorf("DNSNames = %v, want [redis-a.team.svc]", cert.DNSNames)
+	}
+	if len(cert.IPAddresses) != 2 || !cert.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")) || !cert.IPAddresses[1].Equal(net.ParseIP("::1")) {
+		t.Errorf("IPAddresses = %v, want [10.0.0.1 ::1]", cert.IPAddresses)
+	}
+	if err := cert.CheckSignatureFrom(cert); err != nil {
+		t.Errorf("certificate isn't self-signed: %v", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil || keyBlock.Type != "PRIVATE KEY" {
+		t.Fatalf("key is not a PEM PRIVATE KEY block: %q", keyPEM)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+	if !key.(ed25519.PrivateKey).Public().(ed25519.PublicKey).Equal(cert.PublicKey) {
+		t.Errorf("key doesn't match the certificate")
+	}
+
+	cases := map[string]struct {
+		seed []byte
+		same bool
+	}{
+		"SameSeed":    {seed: seed, same: true},
+		"RotatedSeed": {seed: []byte("fedcba9876543210")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			otherCert, otherKey, err := generateSelfSignedTLS(tc.seed, "redis-a", sans)
+			if err != nil {
+				t.Fatalf("generateSelfSignedTLS() error = %v", err)
+			}
+			if same := bytes.Equal(otherCert, certPEM); same != tc.same {
+				t.Errorf("certificate unchanged = %v, want %v", same, tc.same)
+			}
+			if same := bytes.Equal(otherKey, keyPEM); same != tc.same {
+				t.Errorf("key unchanged = %v, want %v", same, tc.same)
+			}
+		})
+	}
+
+	if _, _, err := generateSelfSignedTLS([]byte("short"), "redis-a", sans); err == nil {
+		t.Errorf("generateSelfSignedTLS() with a short seed succeeded, want error")
+	}
+}
+
+func TestGenerateSSHKeypair(t *testing.T) {
+	seed := []byte("0123456789abcdef")
+	privateKey, publicKey, err := generateSSHKeypair(seed, "redis-a@team")
+	if err != nil {
+		t.Fatalf("generateSSHKeypair() error = %v", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(privateKey)
+	if err != nil {
+		t.Fatalf("ssh.ParsePrivateKey() error = %v", err)
+	}
+	authorized, comment, _, rest, err := ssh.ParseAuthorizedKey(publicKey)
+	if err != nil {
+		t.Fatalf("ssh.ParseAuthorizedKey() error = %v", err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("public key has trailing data %q", rest)
+	}
+	if comment != "redis-a@team" {
+		t.Errorf("comment = %q, want redis-a@team", comment)
+	}
+	if !bytes.Equal(signer.PublicKey().Marshal(), authorized.Marshal()) {
+		t.Errorf("private key doesn't match the public key")
+	}
+	signature, err := signer.Sign(nil, []byte("challenge"))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if err := authorized.Verify([]byte("challenge"), signature); err != nil {
+		t.Errorf("signature doesn't verify with the public key: %v", err)
+	}
+
+	cases := map[string]struct {
+		seed []byte
+		same bool
+	}{
+		"SameSeed":    {seed: seed, same: true},
+		"RotatedSeed": {seed: []byte("fedcba9876543210")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			otherPrivate, otherPublic, err := generateSSHKeypair(tc.seed, "redis-a@team")
+			if err != nil {
+				t.Fatalf("generateSSHKeypair() error = %v", err)
+			}
+			if same := bytes.Equal(otherPrivate, privateKey); same != tc.same {
+				t.Errorf("private key unchanged = %v, want %v", same, tc.same)
+			}
+			if same := bytes.Equal(otherPublic, publicKey); same != tc.same {
+				t.Errorf("public key unchanged = %v, want %v", same, tc.same)
+			}
+		})
+	}
+}
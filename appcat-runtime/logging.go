@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// Log forwarding modes
+const (
+	// LoggingModeOutput generates a fluent-operator Output shipping the instance's container logs
+	LoggingModeOutput = "output"
+	// LoggingModeHelmValues hands destination and format to the chart, for charts with their own log shipper
+	LoggingModeHelmValues = "helmValues"
+)
+
+// defaultLogMatch selects the container logs of the instance's pods as tagged by fluent-bit's tail input
+const defaultLogMatch = "kube.var.log.containers.${instanceName}*_${namespace}_*"
+
+// Log formats accepted in spec.logging.format
+const (
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+// LoggingConfig controls how spec.logging is rendered
+type LoggingConfig struct {
+	Mode string
+	// DestinationPath and FormatPath are the helm value paths used in helmValues mode
+	DestinationPath string
+	FormatPath      string
+	// Match is the Output's tag pattern in output mode; supports ${instanceName} and ${namespace}
+	Match string
+}
+
+// LoggingSpec is the tenant's log forwarding request from spec.logging
+type LoggingSpec struct {
+	// Destination is the sink URL (loki://, http://, https://, syslog+tcp:// or syslog+udp://)
+	Destination *url.URL
+	Format      string
+}
+
+// getLoggingConfig extracts logging configuration from service config
+// Returns nil without error if the service doesn't support log forwarding
+func getLoggingConfig(serviceConfig map[string]any) (*LoggingConfig, error) {
+	loggingConfig, ok := serviceConfig["logging"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &LoggingConfig{Mode: LoggingModeOutput, Match: defaultLogMatch}
+	if mode, _ := loggingConfig["mode"].(string); mode != "" {
+		config.Mode = mode
+	}
+	if match, _ := loggingConfig["match"].(string); match != "" {
+		config.Match = match
+	}
+	config.DestinationPath, _ = loggingConfig["destinationPath"].(string)
+	config.FormatPath, _ = loggingConfig["formatPath"].(string)
+
+	switch config.Mode {
+	case LoggingModeOutput:
+	case LoggingModeHelmValues:
+		if config.DestinationPath == "" {
+			return nil, fmt.Errorf("destinationPath is required in helmValues mode")
+		}
+	default:
+		return nil, fmt.Errorf("unknown logging mode %q", config.Mode)
+	}
+	return config, nil
+}
+
+// getLoggingSpec extracts spec.logging from the user spec
+// Returns nil without error if the tenant didn't request log forwarding
+func getLoggingSpec(userSpec map[string]any) (*LoggingSpec, error) {
+	logging, ok := userSpec["logging"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	destination, _ := logging["destination"].(string)
+	if destination == "" {
+		return nil, fmt.Errorf("logging.destination is required")
+	}
+	destinationURL, err := url.Parse(destination)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logging.destination")
+	}
+	if destinationURL.Hostname() == "" {
+		return nil, fmt.Errorf("logging.destination %q has no host", destination)
+	}
+	// Credentials would end up in plain helm values or Output specs
+	if destinationURL.User != nil {
+		return nil, fmt.Errorf("logging.destination must not contain credentials")
+	}
+
+	format, _ := logging["format"].(string)
+	switch format {
+	case "":
+		format = LogFormatJSON
+	case LogFormatJSON, LogFormatLogfmt:
+	default:
+		return nil, fmt.Errorf("unknown logging.format %q", format)
+	}
+	return &LoggingSpec{Destination: destinationURL, Format: format}, nil
+}
+
+// applyLogging renders spec.logging into the merged config
+// In helmValues mode destination and format are set in the helm values, in output mode the request is
+// recorded as mergedConfig["logging"] for generateLogOutput
+func applyLogging(mergedConfig, serviceConfig, userSpec map[string]any, results *Results, log logr.Logger) error {
+	spec, err := getLoggingSpec(userSpec)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		return nil
+	}
+	config, err := getLoggingConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid logging config: %w", err)
+	}
+	if config == nil {
+		log.Info("Service doesn't support log forwarding, ignoring spec.logging")
+		results.Warning("LoggingUnsupported", "This service doesn't support log forwarding, spec.logging is ignored")
+		return nil
+	}
+
+	if config.Mode == LoggingModeHelmValues {
+		helmValues, ok := mergedConfig["helmValues"].(map[string]any)
+		if !ok {
+			return fmt.Errorf("helmValues not found in merged config")
+		}
+		if err := setValueByPath(helmValues, config.DestinationPath, spec.Destination.String()); err != nil {
+			return fmt.Errorf("failed to set log destination: %w", err)
+		}
+		if config.FormatPath != "" {
+			if err := setValueByPath(helmValues, config.FormatPath, spec.Format); err != nil {
+				return fmt.Errorf("failed to set log format: %w", err)
+			}
+		}
+		return nil
+	}
+
+	// Fail the render before generating an Output the sink can't accept
+	if _, _, err := logOutputPlugin(spec); err != nil {
+		return err
+	}
+	mergedConfig["logging"] = map[string]any{
+		"destination": spec.Destination.String(),
+		"format":      spec.Format,
+		"match":       config.Match,
+	}
+	return nil
+}
+
+// generateLogOutput creates a fluent-operator Output shipping the instance's logs to the tenant's sink
+func generateLogOutput(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	logging, ok := mergedConfig["logging"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	spec, err := getLoggingSpec(map[string]any{"logging": logging})
+	if err != nil {
+		return err
+	}
+	plugin, settings, err := logOutputPlugin(spec)
+	if err != nil {
+		return err
+	}
+	if plugin == "loki" {
+		settings["labels"] = []any{"instance=" + instanceName, "namespace=" + instanceNamespace}
+	}
+
+	match, _ := logging["match"].(string)
+	builder := NewLogOutputBuilder(instanceName+"-logs", instanceNamespace).
+		WithMatch(substituteVariables(match, map[string]string{
+			"instanceName": instanceName,
+			"namespace":    instanceNamespace,
+		})).
+		WithPlugin(plugin, settings).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel("app.kubernetes.io/component", "log-output").
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace)
+	if spec.Destination.Scheme == "https" {
+		builder = builder.WithTLS()
+	}
+
+	outputResource, err := toFunctionResource(builder.Build())
+	if err != nil {
+		return fmt.Errorf("failed to convert log output: %w", err)
+	}
+	resources["log-output"] = outputResource
+
+	log.Info("Created log output", "plugin", plugin, "host", spec.Destination.Hostname())
+	return nil
+}
+
+// logOutputPlugin maps a destination URL and format to a fluent-bit output plugin and its settings
+func logOutputPlugin(spec *LoggingSpec) (string, map[string]any, error) {
+	destination := spec.Destination
+	settings := map[string]any{"host": destination.Hostname()}
+
+	var plugin string
+	var defaultPort int64
+	switch destination.Scheme {
+	case "loki":
+		plugin, defaultPort = "loki", 3100
+		settings["lineFormat"] = "json"
+		if spec.Format == LogFormatLogfmt {
+			settings["lineFormat"] = "key_value"
+		}
+	case "http", "https":
+		if spec.Format != LogFormatJSON {
+			return "", nil, fmt.Errorf("logging.format %s is not supported by %s destinations", spec.Format, destination.Scheme)
+		}
+		plugin, defaultPort = "http", 80
+		if destination.Scheme == "https" {
+			defaultPort = 443
+		}
+		settings["format"] = "json_lines"
+		if destination.Path != "" {
+			settings["uri"] = destination.Path
+		}
+	case "syslog+tcp", "syslog+udp":
+		if spec.Format != LogFormatJSON {
+			return "", nil, fmt.Errorf("logging.format %s is not supported by syslog destinations", spec.Format)
+		}
+		plugin, defaultPort = "syslog", 514
+		settings["mode"] = destination.Scheme[len("syslog+"):]
+		settings["syslogFormat"] = "rfc5424"
+		settings["syslogMessageKey"] = "log"
+	default:
+		return "", nil, fmt.Errorf("unsupported logging.destination scheme %q", destination.Scheme)
+	}
+
+	settings["port"] = defaultPort
+	if port := destination.Port(); port != "" {
+		number, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid logging.destination port %q", port)
+		}
+		settings["port"] = number
+	}
+	return plugin, settings, nil
+}
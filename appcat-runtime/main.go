@@ -1,50 +1,171 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 
 	function "github.com/crossplane/function-sdk-go"
-	"google.golang.org/grpc/health"
-	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 func main() {
+	// The render subcommand runs a single request offline instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(runRender(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	// The orphan-scan subcommand lists generated objects whose composite is gone
+	if len(os.Args) > 1 && os.Args[1] == "orphan-scan" {
+		os.Exit(runOrphanScan(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	addr := flag.String("addr", ":9443", "gRPC listen address")
-	tlsDirFlag := flag.String("tls-dir", "", "Directory containing tls.crt, tls.key, ca.crt (defaults to TLS_SERVER_CERTS_DIR)")
+	tlsCertDir := flag.String("tls-cert-dir", "", "Directory containing tls.crt, tls.key, ca.crt (defaults to TLS_SERVER_CERTS_DIR)")
+	tlsDirFlag := flag.String("tls-dir", "", "Deprecated: use --tls-cert-dir")
 	proxyEndpoint := flag.String("proxy", "", "Proxy endpoint for debugging (e.g., '127.0.0.1:9443'). If set, all requests are forwarded to this endpoint.")
+	proxyFallback := flag.Bool("proxy-fallback", false, "Render locally with the built-in logic while the proxy endpoint is unreachable")
+	proxyTLS := flag.Bool("proxy-tls", false, "Connect to the proxy endpoint over TLS verified against the system roots")
+	proxyCACert := flag.String("proxy-ca-cert", "", "CA certificate verifying the proxy endpoint (implies --proxy-tls)")
+	proxyClientCert := flag.String("proxy-client-cert", "", "Client certificate presented to the proxy endpoint (implies --proxy-tls)")
+	proxyClientKey := flag.String("proxy-client-key", "", "Client key for --proxy-client-cert")
+	proxyTokenFile := flag.String("proxy-token-file", "", "File containing a bearer token sent to the proxy endpoint (requires TLS)")
+	pluginDir := flag.String("plugin-dir", "", "Directory containing generation plugin executables, loaded at startup")
+	serviceRegistryDir := flag.String("service-registry-dir", "", "Directory containing shared service profiles named <service>.yaml")
+	warningDedupWindow := flag.Duration("warning-dedup-window", defaultWarningDedupWindow, "Suppress warnings a composite repeats with the same reason and message for this long (0 disables)")
+	recordDir := flag.String("record-dir", "", "Directory to dump every RunFunctionRequest and its response to, for replaying them locally. Recordings contain credentials.")
 	insecure := flag.Bool("insecure", false, "Run in insecure mode without TLS (for local debugging only)")
+	randomSeed := flag.String("random-seed", "", "Derive generated passwords from this seed, e.g. for stable crossplane render output (requires --insecure)")
+	adminAddr := flag.String("admin-addr", "", "Admin endpoint listen address for forced re-renders (e.g., '127.0.0.1:9445'), unauthenticated and loopback only. Disabled if empty.")
+	webhookAddr := flag.String("webhook-addr", "", "Admission webhook listen address (e.g., ':9444'). Webhook is disabled if empty.")
+	webhookCertDir := flag.String("webhook-cert-dir", "", "Directory containing the webhook's tls.crt and tls.key")
+	webhookConfigDir := flag.String("webhook-config-dir", "", "Directory containing service configs named <plural>.<group>.yaml")
 	flag.Parse()
 
 	// Get TLS directory from flag or environment
-	tlsDir := *tlsDirFlag
+	tlsDir := *tlsCertDir
+	if tlsDir == "" {
+		tlsDir = *tlsDirFlag
+	}
 	if tlsDir == "" {
 		tlsDir = os.Getenv("TLS_SERVER_CERTS_DIR")
 	}
 
 	// Validate TLS configuration unless in insecure mode
 	if !*insecure && tlsDir == "" {
-		panic("TLS server cert directory not set; set --tls-dir or TLS_SERVER_CERTS_DIR, or use --insecure for local debugging")
+		panic("TLS server cert directory not set; set --tls-cert-dir or TLS_SERVER_CERTS_DIR, or use --insecure for local debugging")
+	}
+
+	// Deterministic passwords are only acceptable for local renders
+	if *randomSeed != "" {
+		if !*insecure {
+			panic("--random-seed requires --insecure; seeded passwords are predictable")
+		}
+		seedRandomSource(*randomSeed)
+	}
+
+	log := zap.New()
+
+	// Tracing is configured through the standard OTEL_EXPORTER_OTLP_* env vars
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		panic(fmt.Errorf("setup tracing: %w", err))
+	}
+	if shutdownTracing != nil {
+		defer func() { _ = shutdownTracing(context.Background()) }()
 	}
 
-	// Health server allows Crossplane to probe readiness
-	healthSrv := health.NewServer()
-	healthSrv.SetServingStatus("function-appcat-poc", healthpb.HealthCheckResponse_SERVING)
-	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	// Health server allows Crossplane and Kubernetes to probe readiness
+	ready := newReadiness(log)
+
+	// Proxy mode may forward over an ingress or tunnel, so the connection supports TLS and a bearer token
+	proxyConfig := ProxyConfig{
+		TLS:            *proxyTLS,
+		CACertFile:     *proxyCACert,
+		ClientCertFile: *proxyClientCert,
+		ClientKeyFile:  *proxyClientKey,
+		TokenFile:      *proxyTokenFile,
+	}
+	proxyDial, err := proxyConfig.dialOptions()
+	if err != nil {
+		panic(fmt.Errorf("configure proxy connection: %w", err))
+	}
 
 	// Create and register manager with proxy endpoint
-	mgr := NewManager(zap.New(), *proxyEndpoint)
+	mgr := NewManager(log, *proxyEndpoint, proxyDial...).WithProxyFallback(*proxyFallback).
+		WithWarningDedupWindow(*warningDedupWindow)
+	if *serviceRegistryDir != "" {
+		mgr = mgr.WithServiceRegistry(newServiceRegistry(*serviceRegistryDir))
+	}
+	if *pluginDir != "" {
+		plugins, err := loadPlugins(*pluginDir)
+		if err != nil {
+			panic(fmt.Errorf("load plugins: %w", err))
+		}
+		mgr = mgr.WithPlugins(plugins)
+		fmt.Printf("Loaded plugins %v from %s\n", plugins.names(), *pluginDir)
+	}
+	if *recordDir != "" {
+		rec, err := newRecorder(*recordDir, log)
+		if err != nil {
+			panic(fmt.Errorf("setup recording: %w", err))
+		}
+		mgr = mgr.WithRecorder(rec)
+		fmt.Printf("Recording requests and responses to %s\n", *recordDir)
+	}
 
 	// Build server options
 	opts := []function.ServeOption{
 		function.Listen("tcp", *addr),
 		function.Insecure(*insecure),
-		function.WithHealthServer(healthSrv),
+		function.WithHealthServer(ready.srv),
+		withTracing(),
 	}
 	if !*insecure {
-		opts = append(opts, function.MTLSCertificates(tlsDir))
+		// Certificates are reloaded on rotation without restarting the server
+		reloader, err := newCertReloader(tlsDir, log)
+		if err != nil {
+			panic(fmt.Errorf("load TLS config from %s: %w", tlsDir, err))
+		}
+		if err := reloader.watch(context.Background()); err != nil {
+			panic(fmt.Errorf("watch TLS config in %s: %w", tlsDir, err))
+		}
+		opts = append(opts, withTLSConfig(reloader.tlsConfig()))
+	}
+
+	// Optional admission webhook validating specs before Crossplane creates the composite
+	if *webhookAddr != "" {
+		if *webhookCertDir == "" || *webhookConfigDir == "" {
+			panic("--webhook-addr requires --webhook-cert-dir and --webhook-config-dir")
+		}
+		webhookReloader, err := newCertReloaderWith(*webhookCertDir, log, loadWebhookTLSConfig)
+		if err != nil {
+			panic(fmt.Errorf("load webhook TLS config from %s: %w", *webhookCertDir, err))
+		}
+		if err := webhookReloader.watch(context.Background()); err != nil {
+			panic(fmt.Errorf("watch webhook TLS config in %s: %w", *webhookCertDir, err))
+		}
+		webhook := NewWebhookServer(log, *webhookConfigDir, mgr.schemas, mgr.values, mgr.services)
+		go func() {
+			if err := serveWebhook(context.Background(), *webhookAddr, webhookReloader, webhook); err != nil {
+				panic(fmt.Errorf("serve webhook: %w", err))
+			}
+		}()
+		fmt.Printf("Starting admission webhook on %s (configs: %s)\n", *webhookAddr, *webhookConfigDir)
+	}
+
+	// Optional admin endpoint forcing re-renders, e.g., after urgent config fixes
+	if *adminAddr != "" {
+		if err := checkAdminAddr(*adminAddr); err != nil {
+			panic(fmt.Errorf("invalid --admin-addr: %w", err))
+		}
+		admin := NewAdminServer(log, mgr)
+		go func() {
+			if err := serveAdmin(context.Background(), *adminAddr, admin); err != nil {
+				panic(fmt.Errorf("serve admin endpoint: %w", err))
+			}
+		}()
+		fmt.Printf("Starting admin endpoint on %s\n", *adminAddr)
 	}
 
 	// Log startup configuration
@@ -55,6 +176,15 @@ func main() {
 	}
 	if *proxyEndpoint != "" {
 		fmt.Printf("PROXY MODE: Forwarding to %s\n", *proxyEndpoint)
+		if *proxyFallback {
+			fmt.Println("PROXY MODE: Falling back to built-in logic while the endpoint is unreachable")
+		}
+	}
+
+	// Startup complete; in proxy mode readiness follows the proxy endpoint unless requests fall back to local rendering
+	ready.markReady()
+	if *proxyEndpoint != "" && !*proxyFallback {
+		ready.watchProxy(context.Background(), *proxyEndpoint, proxyDial...)
 	}
 
 	if err := function.Serve(mgr, opts...); err != nil {
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// defaultMaintenanceWindowDuration is how long a maintenance window stays open after it starts
+const defaultMaintenanceWindowDuration = 4 * time.Hour
+
+// MaintenanceWindow is the weekly window from spec.maintenance in which chart upgrades are rolled out
+type MaintenanceWindow struct {
+	DayOfWeek time.Weekday
+	// TimeOfDay is the window's start in UTC, as an offset from midnight
+	TimeOfDay time.Duration
+	Duration  time.Duration
+}
+
+// maintenanceDecision is the outcome of gating a chart upgrade on the maintenance window
+type maintenanceDecision struct {
+	// status holds status.maintenance, nil without spec.maintenance
+	status map[string]any
+}
+
+// getMaintenanceWindowDuration extracts maintenance.windowDuration from service config
+func getMaintenanceWindowDuration(serviceConfig map[string]any) (time.Duration, error) {
+	maintenance, ok := serviceConfig["maintenance"].(map[string]any)
+	if !ok {
+		return defaultMaintenanceWindowDuration, nil
+	}
+	durationRaw, _ := maintenance["windowDuration"].(string)
+	if durationRaw == "" {
+		return defaultMaintenanceWindowDuration, nil
+	}
+	duration, err := time.ParseDuration(durationRaw)
+	if err != nil || duration <= 0 || duration > 7*24*time.Hour {
+		return 0, fmt.Errorf("invalid maintenance.windowDuration %q", durationRaw)
+	}
+	return duration, nil
+}
+
+// getMaintenanceWindow extracts spec.maintenance from the user spec
+// Returns nil without error if the tenant didn't set a maintenance window
+func getMaintenanceWindow(userSpec, serviceConfig map[string]any) (*MaintenanceWindow, error) {
+	maintenance, ok := userSpec["maintenance"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	dayRaw, _ := maintenance["dayOfWeek"].(string)
+	day, ok := parseWeekday(dayRaw)
+	if !ok {
+		return nil, fmt.Errorf("invalid maintenance.dayOfWeek %q", dayRaw)
+	}
+	timeRaw, _ := maintenance["timeOfDay"].(string)
+	start, err := time.Parse(time.TimeOnly, timeRaw)
+	if err != nil {
+		if start, err = time.Parse("15:04", timeRaw); err != nil {
+			return nil, fmt.Errorf("invalid maintenance.timeOfDay %q, expected HH:MM[:SS] in UTC", timeRaw)
+		}
+	}
+	duration, err := getMaintenanceWindowDuration(serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &MaintenanceWindow{
+		DayOfWeek: day,
+		TimeOfDay: time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute + time.Duration(start.Second())*time.Second,
+		Duration:  duration,
+	}, nil
+}
+
+// parseWeekday parses a day name such as "Tuesday" or "tue", case-insensitively
+func parseWeekday(day string) (time.Weekday, bool) {
+	day = strings.ToLower(day)
+	if len(day) < 3 {
+		return 0, false
+	}
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.HasPrefix(strings.ToLower(weekday.String()), day) {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+// lastStart returns the latest start of the window at or before t
+func (w *MaintenanceWindow) lastStart(t time.Time) time.Time {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	start := midnight.AddDate(0, 0, int(w.DayOfWeek)-int(t.Weekday())).Add(w.TimeOfDay)
+	if start.After(t) {
+		start = start.AddDate(0, 0, -7)
+	}
+	return start
+}
+
+// open reports whether t lies in the window and when the current or next window starts
+func (w *MaintenanceWindow) open(t time.Time) (bool, time.Time) {
+	start := w.lastStart(t)
+	if t.Before(start.Add(w.Duration)) {
+		return true, start
+	}
+	return false, start.AddDate(0, 0, 7)
+}
+
+// applyMaintenanceWindow pins the chart to the observed release's version until the tenant's maintenance window,
+// so a newer defaultVersion in the Composition only rolls out when the tenant expects disruption
+// Downgrades (e.g., a platform rollback), exact spec.version pins and new instances aren't gated
+func applyMaintenanceWindow(
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	serviceConfig, mergedConfig, userSpec map[string]any,
+	results *Results,
+	log logr.Logger,
+) (*maintenanceDecision, error) {
+	decision := &maintenanceDecision{}
+	window, err := getMaintenanceWindow(userSpec, serviceConfig)
+	if err != nil {
+		return decision, err
+	}
+	if window == nil {
+		return decision, nil
+	}
+
+	current := now()
+	inWindow, windowStart := window.open(current)
+	decision.status = map[string]any{"windowOpen": inWindow}
+	if !inWindow {
+		decision.status["nextWindow"] = windowStart.Format(time.RFC3339)
+	}
+
+	// An exact spec.version is the tenant's own choice and applies right away
+	observedVersion := observedChartVersion(observedResources[activeReleaseKey(composite)])
+	if observedVersion == "" || isPinnedVersion(userSpec) {
+		return decision, nil
+	}
+	chart, ok := mergedConfig["chart"].(map[string]any)
+	if !ok {
+		return decision, fmt.Errorf("chart not found in merged config")
+	}
+	targetVersion, _ := chart["defaultVersion"].(string)
+	newer, err := isNewerChartVersion(targetVersion, observedVersion)
+	if err != nil {
+		return decision, err
+	}
+	if !newer {
+		return decision, nil
+	}
+	// A blue/green upgrade started in the window finishes even if the window closes meanwhile
+	phase, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("status.upgrade.phase")
+	if inWindow || phase == UpgradePhaseDeploying {
+		log.Info("Upgrading chart in maintenance window", "from", observedVersion, "to", targetVersion)
+		results.Normal("MaintenanceUpgrade", "Upgrading chart from %s to %s in the maintenance window", observedVersion, targetVersion)
+		return decision, nil
+	}
+
+	chart["defaultVersion"] = observedVersion
+	decision.status["pendingVersion"] = targetVersion
+	log.Info("Pinning chart version until the maintenance window", "version", observedVersion, "pending", targetVersion, "nextWindow", windowStart)
+	results.Normal("UpgradePending", "Chart version %s will be rolled out in the maintenance window starting %s", targetVersion, windowStart.Format(time.RFC3339))
+	return decision, nil
+}
+
+// isNewerChartVersion reports whether target is a higher semantic version than current
+func isNewerChartVersion(target, current string) (bool, error) {
+	targetVersion, err := semver.NewVersion(target)
+	if err != nil {
+		return false, fmt.Errorf("invalid chart version %q: %w", target, err)
+	}
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		// An unparsable observed version can't be compared, so the configured one isn't held back
+		return false, nil
+	}
+	return targetVersion.GreaterThan(currentVersion), nil
+}
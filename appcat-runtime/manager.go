@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 
 	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
 	"github.com/go-logr/logr"
+	"github.com/vshn/appcat-poc/appcat-runtime/releasehealth"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // Manager handles composition function requests
@@ -17,93 +26,676 @@ type Manager struct {
 	fnv1.UnimplementedFunctionRunnerServiceServer
 	log           logr.Logger
 	proxyEndpoint string
+	proxyDial     []grpc.DialOption
+	proxyFallback bool
+	recorder      *recorder
+	services      *serviceRegistry
+	plugins       *pluginRegistry
+	schemas       *schemaCache
+	appVersions   *appVersionResolver
+	values        *valuesFetcher
+	warnings      *warningDeduplicator
+	rerenders     *rerenderQueue
+	offline       bool
 }
 
 // NewManager creates a new Manager instance
-func NewManager(log logr.Logger, proxyEndpoint string) *Manager {
+// proxyDial configures the connection to the proxy endpoint, which is dialed in plaintext if empty
+func NewManager(log logr.Logger, proxyEndpoint string, proxyDial ...grpc.DialOption) *Manager {
+	if len(proxyDial) == 0 {
+		proxyDial = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
 	return &Manager{
 		log:           log,
 		proxyEndpoint: proxyEndpoint,
+		proxyDial:     proxyDial,
+		schemas:       newSchemaCache(),
+		appVersions:   newAppVersionResolver(),
+		values:        newValuesFetcher(),
+		warnings:      newWarningDeduplicator(defaultWarningDedupWindow),
+		rerenders:     newRerenderQueue(),
 	}
 }
 
+// WithWarningDedupWindow suppresses repeated warnings of a composite for the window, 0 disables deduplication
+func (m *Manager) WithWarningDedupWindow(window time.Duration) *Manager {
+	m.warnings = newWarningDeduplicator(window)
+	return m
+}
+
+// WithProxyFallback renders requests locally while the proxy endpoint is unreachable
+func (m *Manager) WithProxyFallback(enabled bool) *Manager {
+	m.proxyFallback = enabled
+	return m
+}
+
+// WithOffline marks renders as offline, e.g. the render subcommand, which skips calls with side effects such as
+// creating one-time links
+func (m *Manager) WithOffline(offline bool) *Manager {
+	m.offline = offline
+	return m
+}
+
+// WithServiceRegistry builds service configs on the shared profiles of a service registry
+func (m *Manager) WithServiceRegistry(r *serviceRegistry) *Manager {
+	m.services = r
+	return m
+}
+
+// WithPlugins runs out-of-tree generation plugins selected by service configs
+func (m *Manager) WithPlugins(r *pluginRegistry) *Manager {
+	m.plugins = r
+	return m
+}
+
+// WithRecorder dumps every request and its response to disk
+func (m *Manager) WithRecorder(r *recorder) *Manager {
+	m.recorder = r
+	return m
+}
+
 // RunFunction implements the FunctionRunnerServiceServer interface
 // Merges service config (defaultHelmValues + mapping) with user runtime parameters
 func (m *Manager) RunFunction(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	resp, err := m.runFunction(ctx, req)
+	if m.recorder != nil {
+		m.recorder.record(req, resp, err)
+	}
+	return resp, err
+}
+
+// runFunction forwards the request in proxy mode or renders it
+func (m *Manager) runFunction(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
 	log := m.log.WithValues("function", "appcat-poc")
 
+	// Render failures are reported on the composite and claim instead of as gRPC errors,
+	// which would only show up in Crossplane's logs
+	results := &Results{}
+
 	// If proxy endpoint is set, forward request to local endpoint
 	if m.proxyEndpoint != "" {
 		log.Info("Proxy mode enabled - forwarding request", "endpoint", m.proxyEndpoint)
-		return m.proxyFunction(ctx, req)
+		resp, err := m.proxyFunction(ctx, req)
+		if err == nil || !m.proxyFallback || !isProxyUnreachable(err) {
+			return resp, err
+		}
+		log.Info("Proxy endpoint unreachable, rendering with built-in logic", "endpoint", m.proxyEndpoint, "error", err.Error())
+		results.Warning("ProxyFallback", "Proxy endpoint %s is unreachable, rendered with the function's built-in logic", m.proxyEndpoint)
 	}
 
 	log.Info("RunFunction called")
+	bundle := &debugBundle{}
+	resp, err := m.render(ctx, req, results, bundle, log)
+	if err != nil {
+		log.Error(err, "Render failed")
+		resp = renderFailure(ctx, req, results, err)
+		results.linkDocs(resp)
+		resp.Results = m.warnings.filter(req.GetObserved().GetComposite(), resp.GetResults(), log)
+		if err := bundle.attach(req, resp, err); err != nil {
+			log.Error(err, "Failed to attach debug bundle")
+		}
+		return resp, nil
+	}
+	results.linkDocs(resp)
+	resp.Results = m.warnings.filter(req.GetObserved().GetComposite(), resp.GetResults(), log)
+	if bundle.requested(req) {
+		if err := bundle.attach(req, resp, nil); err != nil {
+			log.Error(err, "Failed to attach debug bundle")
+		}
+	}
 
+	log.Info("Function execution complete", "resourceCount", len(resp.GetDesired().GetResources()))
+	return resp, nil
+}
+
+// render produces the desired state for a request
+// Results collects warnings and summaries, which render adds to the response
+// The debug bundle captures the render's inputs as far as it got
+func (m *Manager) render(ctx context.Context, req *fnv1.RunFunctionRequest, results *Results, bundle *debugBundle, log logr.Logger) (*fnv1.RunFunctionResponse, error) {
 	// STEP 1: Extract composite (contains user runtime parameters from XRD spec)
 	composite := req.GetObserved().GetComposite()
 	if composite == nil {
 		return nil, fmt.Errorf("composite is nil")
 	}
+	traceComposite(ctx, composite)
+
+	// STEP 1a: Deleted composites only report teardown progress
+	if isDeleting(composite) {
+		return teardownResponse(req, composite, m.services, log)
+	}
+
+	// Each phase gets its own span so slow compositions can be narrowed down in traces
+	var (
+		serviceConfig, userSpec, mergedConfig map[string]any
+		resources                             map[string]*fnv1.Resource
+		connDetails                           map[string][]byte
+		linkRequest                           *oneTimeLinkRequest
+		patches                               []UserPatch
+		upgrade                               *blueGreenRender
+		environment                           *environmentDecision
+		clone                                 *cloneDecision
+		capabilities                          *capabilityDetection
+		collisions                            *collisionCheck
+		backup                                *backupDecision
+		pullSecret                            *pullSecretDecision
+		releaseConnection                     *releaseConnectionDecision
+		restore                               *restoreDecision
+		maintenance                           *maintenanceDecision
+		autoUpgrade                           *autoUpgradeDecision
+		rotation                              *rotationDecision
+		external                              *externalSecretDecision
+		values                                *valueResolution
+		err                                   error
+	)
+	defer func() { bundle.capture(serviceConfig, userSpec, mergedConfig) }()
+
+	err = tracePhase(ctx, "extract", func(ctx context.Context) error {
+		var err error
+
+		// STEP 2: Extract service config from Composition input
+		input := req.GetInput()
+		if input == nil {
+			return fmt.Errorf("input is nil")
+		}
+
+		serviceConfig, err = extractServiceConfig(input, m.services)
+		if err != nil {
+			return fmt.Errorf("failed to extract service config: %w", err)
+		}
+		log.Info("Extracted service config")
+
+		// STEP 2a: Merge cluster-level platform defaults (EnvironmentConfigs, ConfigMaps) beneath the service config
+		serviceConfig, environment, err = applyEnvironment(req, serviceConfig, log)
+		if err != nil {
+			return err
+		}
+		if environment.pending {
+			return nil
+		}
+		results.docs, err = getDocLinks(serviceConfig)
+		if err != nil {
+			return fmt.Errorf("invalid docs config: %w", err)
+		}
+
+		// STEP 2b: Extract user spec using the spec convention of the composite's API group
+		// Composites of older XRD versions are converted first, since the mapping targets the hub version
+		hubComposite, err := convertCompositeToHub(composite, serviceConfig, log)
+		if err != nil {
+			return fmt.Errorf("failed to convert composite: %w", err)
+		}
+		userSpec, err = extractUserSpec(hubComposite, serviceConfig)
+		if err != nil {
+			return fmt.Errorf("failed to extract user spec: %w", err)
+		}
+		log.Info("Extracted user spec", "spec", userSpec)
+
+		// Fill the spec from spec.cloneFrom's source instance, so copied fields are pruned and validated like the user's own
+		clone, err = applyCloneFrom(req, composite, serviceConfig, userSpec, log)
+		if err != nil {
+			return fmt.Errorf("failed to clone instance: %w", err)
+		}
+		if clone.pending {
+			return nil
+		}
+
+		// STEP 2c: Drop fields the spec schema doesn't declare and validate the rest before they reach any mapping
+		if err := pruneUserSpec(serviceConfig, userSpec, results, log); err != nil {
+			return fmt.Errorf("invalid user spec: %w", err)
+		}
+		if err := validateUserSpec(serviceConfig, userSpec); err != nil {
+			return fmt.Errorf("invalid user spec: %w", err)
+		}
+
+		// STEP 2d: Validate spec.patches against the service's allowlist before rendering anything
+		patchPolicy, err := getPatchPolicy(serviceConfig)
+		if err != nil {
+			return fmt.Errorf("invalid patches config: %w", err)
+		}
+		patches, err = getUserPatches(userSpec, patchPolicy)
+		if err != nil {
+			return fmt.Errorf("invalid spec.patches: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, failedPhase(ConditionSpecValid, "InvalidSpec", err)
+	}
+	if environment.pending {
+		// Ask Crossplane for the platform defaults before rendering
+		return &fnv1.RunFunctionResponse{Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)}, Requirements: environment.requirements}, nil
+	}
+	if clone.pending {
+		// Ask Crossplane for the source instance before rendering the clone
+		return &fnv1.RunFunctionResponse{Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)}, Requirements: mergeRequirements(environment.requirements, clone.requirements)}, nil
+	}
 
-	userSpec, err := extractUserSpec(composite)
+	// Changes of plan and chart version are held back during a change freeze
+	freeze, err := checkFreeze(composite, serviceConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract user spec: %w", err)
+		return nil, failedPhase(ConditionSpecValid, "InvalidSpec", err)
 	}
-	log.Info("Extracted user spec", "spec", userSpec)
+	freeze.holdPlan(composite, userSpec, results, log)
 
-	// STEP 2: Extract service config from Composition input
-	input := req.GetInput()
-	if input == nil {
-		return nil, fmt.Errorf("input is nil")
+	// STEP 2e: Resolve spec.plan, staging plan changes over several renders
+	// The plan's spec presets fill the fields the user didn't set, so the quota counts and clamps them
+	plan, err := orchestratePlan(composite, req.GetObserved().GetResources(), serviceConfig, userSpec, results, log)
+	if errors.Is(err, errPlanConfig) {
+		return nil, failedPhase(ConditionValuesMerged, "InvalidServiceConfig", fmt.Errorf("failed to resolve plan: %w", err))
+	}
+	if err != nil {
+		return nil, failedPhase(ConditionSpecValid, "InvalidSpec", fmt.Errorf("failed to resolve plan: %w", err))
+	}
+	if plan != nil {
+		applyPlanSpec(userSpec, plan.spec)
+		serviceConfig, err = withPlanValues(serviceConfig, plan.values)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply plan values: %w", err)
+		}
 	}
 
-	serviceConfig, err := extractServiceConfig(input)
+	// STEP 2f: Check the spec, including plan presets, against the organization's quota (may clamp userSpec)
+	quota, err := enforceQuota(req, composite, serviceConfig, userSpec, results, log)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract service config: %w", err)
+		return nil, failedPhase(ConditionSpecValid, "InvalidSpec", fmt.Errorf("failed to enforce quota: %w", err))
+	}
+	if quota != nil && quota.pending {
+		// Ask Crossplane for the quota and sibling instances before deciding
+		return &fnv1.RunFunctionResponse{Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)}, Requirements: mergeRequirements(environment.requirements, clone.requirements, quota.requirements)}, nil
+	}
+	if quota != nil && quota.rejected {
+		log.Info("Spec rejected by quota", "reason", quota.result.GetMessage())
+		return &fnv1.RunFunctionResponse{
+			Meta:         &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)},
+			Requirements: mergeRequirements(environment.requirements, clone.requirements, quota.requirements),
+			Results:      append(results.List(), quota.result),
+			Conditions:   []*fnv1.Condition{quota.condition},
+		}, nil
 	}
-	log.Info("Extracted service config")
 
-	// STEP 3: Merge configs (defaultHelmValues + user parameters)
-	mergedConfig, err := mergeConfigs(serviceConfig, userSpec, log)
+	// STEP 2g: Detect no-op updates (e.g., only Crossplane's own spec fields changed)
+	change, err := detectSpecChange(composite, serviceConfig, userSpec)
 	if err != nil {
-		return nil, fmt.Errorf("failed to merge configs: %w", err)
+		return nil, err
+	}
+	// Composites selected by an admin re-render request are recomputed from scratch and emit all warnings again
+	if m.rerenders.claim(composite) {
+		log.Info("Re-rendering on admin request")
+		change.unchanged = false
+		m.warnings.forget(composite)
+	}
+	if change.unchanged {
+		log.Info("Spec unchanged since the previous render", "specHash", change.hash)
 	}
 
-	// STEP 4: Generate desired resources
-	resources, connDetails, err := generateResources(ctx, composite, req.GetObserved().GetResources(), mergedConfig, log)
+	// STEP 2h: Resolve spec.version against the service's version policy
+	chartVersion, err := resolveChartVersion(ctx, m.appVersions, serviceConfig, userSpec, log)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate resources: %w", err)
+		return nil, failedPhase(ConditionSpecValid, "UnsupportedVersion", err)
+	}
+
+	err = tracePhase(ctx, "merge", func(ctx context.Context) error {
+		var err error
+
+		// STEP 3: Resolve the value sources, then merge configs (defaultHelmValues + value sources + user parameters)
+		values, err = resolveValueSources(ctx, req, serviceConfig, m.values, results, log)
+		if err != nil {
+			return err
+		}
+		if values.pending {
+			return nil
+		}
+		// The certificate Secret of spec.tls is added to the spec before merging, so the mapping wires it into the chart
+		tls, err := applyTLS(composite, serviceConfig, userSpec, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		compositeMetadata, _ := composite.GetResource().AsMap()["metadata"].(map[string]any)
+		mergedConfig, err = mergeConfigs(serviceConfig, values.layers, userSpec, compositeMetadata, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to merge configs: %w", err)
+		}
+		if tls != nil {
+			mergedConfig["tls"] = tls
+		}
+		if chartVersion != "" {
+			chart, ok := mergedConfig["chart"].(map[string]any)
+			if !ok {
+				return fmt.Errorf("chart not found in merged config")
+			}
+			chart["defaultVersion"] = chartVersion
+		}
+
+		// STEP 3a: Render spec.logging into helm values or a log Output
+		if err := applyLogging(mergedConfig, serviceConfig, userSpec, results, log); err != nil {
+			return fmt.Errorf("failed to configure log forwarding: %w", err)
+		}
+
+		// STEP 3b: Render spec.monitoring.externalScrape and the service's monitors into helm values
+		if err := applyExternalScrape(mergedConfig, serviceConfig, userSpec, results, log); err != nil {
+			return fmt.Errorf("failed to configure external scraping: %w", err)
+		}
+		if err := applyMonitors(mergedConfig, serviceConfig); err != nil {
+			return fmt.Errorf("failed to configure monitors: %w", err)
+		}
+		// Route the instance's outbound HTTP(S) through the platform's egress proxy
+		if err := applyEgressProxy(mergedConfig, serviceConfig, log); err != nil {
+			return fmt.Errorf("failed to configure egress proxy: %w", err)
+		}
+
+		// STEP 3c: Move instances to the newest chart version the auto-upgrade policy allows in their maintenance window,
+		// holding back other newer chart versions until then
+		autoUpgrade, err = applyAutoUpgrade(ctx, m.appVersions, composite, req.GetObserved().GetResources(), serviceConfig, mergedConfig, userSpec, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to apply auto upgrade policy: %w", err)
+		}
+		maintenance, err = applyMaintenanceWindow(composite, req.GetObserved().GetResources(), serviceConfig, mergedConfig, userSpec, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to apply maintenance window: %w", err)
+		}
+		if err := freeze.holdChartVersion(composite, req.GetObserved().GetResources(), mergedConfig, results, log); err != nil {
+			return fmt.Errorf("failed to apply change freeze: %w", err)
+		}
+
+		// STEP 3d: Resolve the chart's application version for status, labels and metrics
+		if err := resolveChartAppVersion(ctx, m.appVersions, mergedConfig, results, log); err != nil {
+			return fmt.Errorf("failed to resolve chart app version: %w", err)
+		}
+		// Fail instead of deploying an OCI chart whose tag no longer points to its pinned digest
+		if err := verifyChartDigest(ctx, m.values, mergedConfig, log); err != nil {
+			return err
+		}
+
+		// STEP 3e: Resolve spec.backup against the service's backup configuration
+		backup, err = applyBackup(req, mergedConfig, serviceConfig, userSpec, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to configure backups: %w", err)
+		}
+
+		// Fetch the platform's credentials of authenticated chart repositories
+		pullSecret, err = applyChartPullSecret(req, mergedConfig, serviceConfig, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to resolve chart pull secret: %w", err)
+		}
+
+		// Fetch the connection details the Releases read from the objects they deployed
+		releaseConnection, err = applyReleaseConnection(req, composite, mergedConfig, log)
+		if err != nil {
+			return fmt.Errorf("failed to fetch release connection details: %w", err)
+		}
+
+		// STEP 3f: Regenerate the instance password once spec.security.passwordRotationDays have passed
+		rotation, err = applyPasswordRotation(req.GetObserved().GetResources(), mergedConfig, userSpec, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to apply password rotation: %w", err)
+		}
+
+		// STEP 3g: Fetch the password an externalSecret source syncs from an external store (e.g. Vault)
+		external, err = applyExternalSecret(req, composite, mergedConfig, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to fetch external secret: %w", err)
+		}
+
+		// STEP 3h: Select the cluster the instance is deployed to, keeping existing instances where they are
+		if err := applyPlacement(composite, req.GetObserved().GetResources(), serviceConfig, mergedConfig, userSpec, results, log); err != nil {
+			return fmt.Errorf("failed to place instance: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, failedPhase(ConditionValuesMerged, "MergeFailed", err)
+	}
+	if values.pending {
+		// Ask Crossplane for the ConfigMaps and EnvironmentConfigs holding helm values before merging
+		requirements := mergeRequirements(environment.requirements, clone.requirements, values.requirements)
+		if quota != nil {
+			requirements = mergeRequirements(requirements, quota.requirements)
+		}
+		return &fnv1.RunFunctionResponse{Meta: &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)}, Requirements: requirements}, nil
+	}
+	if external.pending {
+		// Compose the ExternalSecret and keep everything else as observed until ESO synced the password
+		requirements := mergeRequirements(environment.requirements, clone.requirements, values.requirements, backup.requirements, pullSecret.requirements, releaseConnection.requirements, external.requirements)
+		if quota != nil {
+			requirements = mergeRequirements(requirements, quota.requirements)
+		}
+		return external.hold(req, requirements, results)
+	}
+
+	err = tracePhase(ctx, "generate", func(ctx context.Context) error {
+		var err error
+
+		// STEP 4: Pick the release to render; blue/green upgrades deploy a new version alongside the old one
+		upgrade, err = planBlueGreen(composite, req.GetObserved().GetResources(), serviceConfig, mergedConfig, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to plan upgrade: %w", err)
+		}
+
+		// STEP 4a: Generate desired resources, keeping the previous release while it's still needed
+		resources, connDetails, linkRequest, err = generateResources(ctx, composite, req.GetObserved().GetResources(), mergedConfig, upgrade.release, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to generate resources: %w", err)
+		}
+		maps.Copy(resources, upgrade.retained)
+		maps.Copy(resources, external.resources)
+
+		// Seed new instances from spec.restore, holding back the Release until the data is restored
+		restore, err = generateRestore(req, resources, serviceConfig, mergedConfig, userSpec, composite, upgrade.release, results, log)
+		if err != nil {
+			return fmt.Errorf("failed to restore from backup: %w", err)
+		}
+
+		// Add the service config's own resources whose condition holds (e.g., a ServiceMonitor if monitoring is enabled)
+		if err := generateExtraResources(resources, composite, serviceConfig, userSpec, getProviderConfigRef(mergedConfig), log); err != nil {
+			return err
+		}
+
+		// Run the service's out-of-tree generation plugins over the built-in resources
+		if err := runPlugins(ctx, m.plugins, composite, req.GetObserved().GetResources(), resources, serviceConfig, mergedConfig, results, log); err != nil {
+			return err
+		}
+
+		// Skip resources of optional APIs (e.g. K8up, cert-manager) the cluster doesn't provide
+		capabilities, err = skipUnsupportedResources(req, resources, serviceConfig, results, log)
+		if err != nil {
+			return err
+		}
+
+		// STEP 4b: Keep renamed resource keys from orphaning or duplicating existing objects
+		renames, err := getResourceRenames(serviceConfig)
+		if err != nil {
+			return fmt.Errorf("invalid resource renames: %w", err)
+		}
+		if err := applyResourceRenames(resources, req.GetObserved().GetResources(), renames, log); err != nil {
+			return fmt.Errorf("failed to apply resource renames: %w", err)
+		}
+
+		// STEP 4c: Keep observed values of fields whose drift is tolerated (e.g., HPA-managed replicas)
+		ignores, err := getIgnoreDifferences(serviceConfig)
+		if err != nil {
+			return fmt.Errorf("invalid ignoreDifferences: %w", err)
+		}
+		if err := applyIgnoreDifferences(resources, req.GetObserved().GetResources(), ignores, log); err != nil {
+			return fmt.Errorf("failed to apply ignoreDifferences: %w", err)
+		}
+
+		// STEP 4d: Apply the user's allowlisted spec.patches
+		if err := applyUserPatches(resources, patches, results, log); err != nil {
+			return fmt.Errorf("failed to apply spec.patches: %w", err)
+		}
+
+		// STEP 4e: Validate final helm values (including injected credentials and patches) against the chart schema
+		// on every render: value sources and the chart version can change without the spec hash changing
+		if err := validateHelmValues(ctx, m.schemas, mergedConfig, resources, upgrade.release.Key, log); err != nil {
+			return fmt.Errorf("helm values failed chart schema validation: %w", err)
+		}
+
+		// STEP 4f: Hold back resources until the resources they depend on are ready (e.g., Secret before Release)
+		if err := applyDependencies(resources, req.GetObserved().GetResources(), serviceConfig, composite, results, log); err != nil {
+			return fmt.Errorf("failed to order resources: %w", err)
+		}
+
+		// STEP 4g: Stamp provenance annotations so cluster-side debugging can trace inputs
+		renderedAt := change.renderedAt(req.GetObserved().GetResources()[upgrade.release.Key])
+		provenance, err := buildProvenance(serviceConfig, composite, renderedAt)
+		if err != nil {
+			return fmt.Errorf("failed to build provenance: %w", err)
+		}
+		if err := setResourceAnnotations(resources, provenance); err != nil {
+			return fmt.Errorf("failed to stamp provenance: %w", err)
+		}
+
+		// STEP 4h: Mark resources as externally managed for GitOps controllers watching the same namespaces
+		gitOpsAnnotations, err := getGitOpsAnnotations(mergedConfig)
+		if err != nil {
+			return fmt.Errorf("invalid gitops config: %w", err)
+		}
+		if err := setResourceAnnotations(resources, gitOpsAnnotations); err != nil {
+			return fmt.Errorf("failed to stamp gitops annotations: %w", err)
+		}
+
+		// STEP 4i: Propagate allowlisted composite labels and annotations (e.g., billing IDs)
+		propagation, err := getPropagationConfig(serviceConfig)
+		if err != nil {
+			return fmt.Errorf("invalid propagation config: %w", err)
+		}
+		if err := propagateCompositeMetadata(resources, composite, propagation, log); err != nil {
+			return fmt.Errorf("failed to propagate composite metadata: %w", err)
+		}
+
+		// STEP 4j: Stamp the ownership label set, so cleanup tooling finds objects of deleted instances
+		ownership, err := ownershipLabels(composite, mergedConfig)
+		if err != nil {
+			return err
+		}
+		if err := setResourceLabels(resources, ownership); err != nil {
+			return fmt.Errorf("failed to stamp ownership labels: %w", err)
+		}
+
+		// STEP 4k: Hold back new resources until it's known no other tenant's object has the same name
+		collisions, err = guardNameCollisions(req, resources, composite, log)
+		if err != nil {
+			return failedPhase(ConditionSpecValid, "NameCollision", err)
+		}
+
+		// STEP 4l: Scrub forbidden fields (e.g., privileged containers) from everything rendered, as the last line of defense
+		sanitizers, err := getSanitizers(serviceConfig)
+		if err != nil {
+			return fmt.Errorf("invalid sanitizers: %w", err)
+		}
+		if err := sanitizeResources(resources, sanitizers, results, log); err != nil {
+			return fmt.Errorf("failed to sanitize resources: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// STEP 5: Build and return response
+	reconcileConfig, err := getReconcileConfig(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reconcile config: %w", err)
+	}
+
+	// Expose the deployed application version in status
+	statusFields := map[string]any{"specHash": change.hash}
+	if appVersion := getChartAppVersion(mergedConfig); appVersion != "" {
+		statusFields["appVersion"] = appVersion
+	}
+	if plan != nil {
+		maps.Copy(statusFields, plan.status)
+	}
+	if upgrade.status != nil {
+		statusFields["upgrade"] = upgrade.status
+	}
+	if maintenance.status != nil {
+		statusFields["maintenance"] = maintenance.status
+	}
+	if autoUpgrade.status != nil {
+		statusFields["autoUpgrade"] = autoUpgrade.status
+	}
+	if freeze.status != nil {
+		statusFields["freeze"] = freeze.status
+	}
+	if rotation.status != nil {
+		statusFields["credentials"] = rotation.status
+	}
+	if clone.status != nil {
+		statusFields["clone"] = clone.status
+	}
+	if restore.status != nil {
+		statusFields["restore"] = restore.status
+	}
+	if quota != nil && quota.status != nil {
+		statusFields["quota"] = quota.status
+	}
+	// Expose where the instance runs and how to reach it
+	if instance := buildInstanceStatus(resources, req.GetObserved().GetResources(), upgrade.servingKey, connDetails); len(instance) > 0 {
+		statusFields["instance"] = instance
+	}
+	// Copy the observed fields the service exposes, e.g., the deployed Helm revision
+	if err := applyStatusMapping(statusFields, req.GetObserved().GetResources(), upgrade.servingKey, serviceConfig, results, log); err != nil {
+		return nil, err
+	}
+	compositeStatus, err := structpb.NewStruct(map[string]any{"status": statusFields})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build composite status: %w", err)
+	}
+
+	// The instance is ready once the release serving it is deployed; ReleaseHealthy explains why not
+	ready := fnv1.Ready_READY_FALSE
+	if health := releasehealth.Interpret(req.GetObserved().GetResources()[upgrade.servingKey]); health.Ready() {
+		ready = fnv1.Ready_READY_TRUE
+	} else {
+		log.Info("Instance not ready", "health", health.Health, "reason", health.Reason)
+	}
+
 	resp := &fnv1.RunFunctionResponse{
 		Meta: &fnv1.ResponseMeta{
-			Ttl: durationpb.New(60 * time.Second),
+			Ttl: durationpb.New(reconcileConfig.responseTTL()),
 		},
 		Desired: &fnv1.State{
 			Composite: &fnv1.Resource{
+				Resource:          compositeStatus,
 				ConnectionDetails: connDetails,
-				Ready:             fnv1.Ready_READY_TRUE,
+				Ready:             ready,
 			},
 			Resources: resources,
 		},
+		Conditions: append(computeConditions(req.GetObserved().GetResources(), upgrade.release.Key, upgrade.servingKey, mergedConfig),
+			newCondition(ConditionRenderSynced, fnv1.Status_STATUS_CONDITION_TRUE, "Rendered", "Desired resources rendered")),
 	}
-
-	log.Info("Function execution complete", "resourceCount", len(resources))
+	// Every response repeats all requirements, otherwise Crossplane stops fetching them
+	resp.Requirements = mergeRequirements(environment.requirements, clone.requirements, values.requirements, backup.requirements, pullSecret.requirements, releaseConnection.requirements, restore.requirements, capabilities.requirements, collisions.requirements, external.requirements)
+	if quota != nil {
+		resp.Requirements = mergeRequirements(resp.Requirements, quota.requirements)
+		resp.Conditions = append(resp.Conditions, quota.condition)
+		if quota.result != nil {
+			results.Add(quota.result)
+		}
+	}
+	// A new password's one-time link is created last, once it's known this response is the one Crossplane keeps
+	if linkRequest != nil {
+		if err := issueOneTimeLink(ctx, linkRequest, req, resp, m.offline, results, log); err != nil {
+			return nil, fmt.Errorf("failed to deliver password link: %w", err)
+		}
+	}
+	results.Normal("Rendered", "Rendered %d resources: %s", len(resources), strings.Join(slices.Sorted(maps.Keys(resources)), ", "))
+	resp.Results = results.List()
 	return resp, nil
 }
 
 // proxyFunction forwards requests to a local development endpoint for debugging
 func (m *Manager) proxyFunction(ctx context.Context, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
-	// Create insecure gRPC connection to local endpoint
-	conn, err := grpc.NewClient(m.proxyEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Connect to the development endpoint with the configured TLS and token credentials
+	conn, err := grpc.NewClient(m.proxyEndpoint, m.proxyDial...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to proxy %s: %w", m.proxyEndpoint, err)
 	}
 	defer conn.Close()
 
-	// Forward the request
+	// Forward the request, continuing the trace on the development endpoint
+	ctx = metadata.NewOutgoingContext(ctx, outgoingTraceMetadata(ctx))
 	client := fnv1.NewFunctionRunnerServiceClient(conn)
 	resp, err := client.RunFunction(ctx, req)
 	if err != nil {
@@ -112,3 +704,13 @@ func (m *Manager) proxyFunction(ctx context.Context, req *fnv1.RunFunctionReques
 
 	return resp, nil
 }
+
+// isProxyUnreachable reports whether a proxy error means the endpoint couldn't be reached,
+// as opposed to the endpoint failing the request
+func isProxyUnreachable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
@@ -10,28 +12,102 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
+// defaultSpecPath is where user parameters live unless a spec convention says otherwise
+const defaultSpecPath = "spec"
+
+// SpecConvention describes where user parameters live for composites of an API group
+type SpecConvention struct {
+	// APIGroup the convention applies to (e.g. "exoscale.appcat.io")
+	APIGroup string
+	// Version restricts the convention to one API version; empty matches all versions
+	Version string
+	// SpecPath is the composite field holding the user parameters (e.g. "spec.parameters")
+	SpecPath string
+}
+
+// getSpecConventions extracts specConventions from service config
+func getSpecConventions(serviceConfig map[string]any) ([]SpecConvention, error) {
+	conventionsRaw, ok := serviceConfig["specConventions"].([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	conventions := make([]SpecConvention, 0, len(conventionsRaw))
+	for i, conventionRaw := range conventionsRaw {
+		conventionMap, ok := conventionRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("specConventions[%d] is not a map", i)
+		}
+		apiGroup, _ := conventionMap["apiGroup"].(string)
+		version, _ := conventionMap["version"].(string)
+		specPath, _ := conventionMap["specPath"].(string)
+
+		if apiGroup == "" {
+			return nil, fmt.Errorf("specConventions[%d]: apiGroup is required", i)
+		}
+		if specPath == "" {
+			specPath = defaultSpecPath
+		}
+		conventions = append(conventions, SpecConvention{APIGroup: apiGroup, Version: version, SpecPath: specPath})
+	}
+	return conventions, nil
+}
+
+// resolveSpecPath returns the spec path for the given apiVersion
+// Version-specific conventions win over group-wide ones; falls back to defaultSpecPath
+func resolveSpecPath(conventions []SpecConvention, apiVersion string) string {
+	group, version, found := strings.Cut(apiVersion, "/")
+	if !found {
+		// Core group, e.g. "v1"
+		group, version = "", apiVersion
+	}
+
+	specPath := defaultSpecPath
+	for _, c := range conventions {
+		if c.APIGroup != group {
+			continue
+		}
+		if c.Version == version {
+			return c.SpecPath
+		}
+		if c.Version == "" {
+			specPath = c.SpecPath
+		}
+	}
+	return specPath
+}
+
 // extractUserSpec extracts user-provided spec from the composite resource
-// Returns a map with the full spec (e.g., {size: {cpu: "1000m"}, replicas: 3})
-func extractUserSpec(composite *fnv1.Resource) (map[string]any, error) {
+// The location of the parameters depends on the composite's API group (see SpecConvention)
+// Returns a map with the parameters (e.g., {size: {cpu: "1000m"}, replicas: 3})
+func extractUserSpec(composite *fnv1.Resource, serviceConfig map[string]any) (map[string]any, error) {
 	compositeMap := composite.Resource.AsMap()
 	paved := fieldpath.Pave(compositeMap)
 
-	specRaw, err := paved.GetValue("spec")
+	conventions, err := getSpecConventions(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec conventions: %w", err)
+	}
+	apiVersion, _ := paved.GetString("apiVersion")
+	specPath := resolveSpecPath(conventions, apiVersion)
+
+	specRaw, err := paved.GetValue(specPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get spec from composite: %w", err)
+		return nil, fmt.Errorf("failed to get %s from composite: %w", specPath, err)
 	}
 
 	spec, ok := specRaw.(map[string]any)
 	if !ok {
-		return nil, fmt.Errorf("spec is not a map")
+		return nil, fmt.Errorf("%s is not a map", specPath)
 	}
 
 	return spec, nil
 }
 
 // extractServiceConfig extracts service configuration from Composition input
-// Returns a map with: chart, defaultHelmValues, mapping, connectionSecret
-func extractServiceConfig(input *structpb.Struct) (map[string]any, error) {
+// The input is layered over the service's registry profile, if any; registry may be nil
+// Returns a map with: service, chart, defaultHelmValues, mapping, connectionSecret
+func extractServiceConfig(input *structpb.Struct, registry *serviceRegistry) (map[string]any, error) {
 	inputMap := input.AsMap()
 	paved := fieldpath.Pave(inputMap)
 
@@ -45,6 +121,16 @@ func extractServiceConfig(input *structpb.Struct) (map[string]any, error) {
 		return nil, fmt.Errorf("data is not a map")
 	}
 
+	service := getServiceName(input)
+	profile, err := registry.profile(service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load profile of service %s: %w", service, err)
+	}
+	data = withServiceProfile(profile, data)
+	if service != "" {
+		data["service"] = service
+	}
+
 	// Validate required fields
 	if _, ok := data["chart"]; !ok {
 		return nil, fmt.Errorf("chart not found in service config")
@@ -57,18 +143,164 @@ func extractServiceConfig(input *structpb.Struct) (map[string]any, error) {
 	}
 	// Note: connectionSecret is optional - not all services need it
 
+	// Multi-chart services list their charts; the first one is the main chart
+	if err := normalizeCharts(data); err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
 
+// optionalConfigSections lists service config sections passed through to the merged config unchanged
+var optionalConfigSections = []string{
+	"service",
+	"charts",
+	"naming",
+	"connectionSecret",
+	"secretStore",
+	"secretMapping",
+	"valuesSchema",
+	"gitops",
+	"networkPolicy",
+	"valuesExternalization",
+	"valuesFrom",
+	"releaseOptions",
+	"output",
+	"highAvailability",
+	"rbac",
+	"monitoring",
+	"alerts",
+}
+
+// ListMergeStrategy controls how lists are combined when a user value is merged over a default
+type ListMergeStrategy string
+
+const (
+	// ListMergeReplace replaces the default list with the user list
+	ListMergeReplace ListMergeStrategy = "replace"
+	// ListMergeAppend appends the user list to the default list
+	ListMergeAppend ListMergeStrategy = "append"
+	// ListMergeByKey merges user elements into the default elements with the same key, appending the others
+	ListMergeByKey ListMergeStrategy = "merge"
+)
+
+// ListMergePolicy picks the strategy for each list merged into helm values
+type ListMergePolicy struct {
+	// Default applies to the lists no rule matches
+	Default ListMergeStrategy
+	Rules   []ListMergeRule
+}
+
+// ListMergeRule sets the strategy of the lists at a helm value path; "*" matches any single key
+type ListMergeRule struct {
+	Path     []string
+	Strategy ListMergeStrategy
+	// Key is the element field lists merged by key are matched on, e.g. "name"
+	Key string
+}
+
+// replaceLists is the policy of merges where user lists always replace the defaults, e.g. service profiles
+var replaceLists = ListMergePolicy{Default: ListMergeReplace}
+
+// ruleAt returns the rule for the list at path, falling back to the default strategy
+// Rules are sorted so exact paths win over wildcards
+func (p ListMergePolicy) ruleAt(path []string) ListMergeRule {
+	for _, rule := range p.Rules {
+		if matchesHelmPath(path, rule.Path) {
+			return rule
+		}
+	}
+	return ListMergeRule{Path: path, Strategy: p.Default}
+}
+
+// parseListMergeStrategy checks a strategy name, defaulting to replace
+func parseListMergeStrategy(name string) (ListMergeStrategy, error) {
+	switch ListMergeStrategy(name) {
+	case "":
+		return ListMergeReplace, nil
+	case ListMergeReplace, ListMergeAppend, ListMergeByKey:
+		return ListMergeStrategy(name), nil
+	default:
+		return "", fmt.Errorf("unknown list merge strategy %q", name)
+	}
+}
+
+// getListMergePolicy extracts mergeStrategy from service config, defaulting to replace
+// mergeStrategy.lists is the default strategy, mergeStrategy.paths overrides it by helm value path, e.g.
+// {"master.extraEnvVars": {strategy: merge, key: name}, tolerations: append}
+func getListMergePolicy(serviceConfig map[string]any) (ListMergePolicy, error) {
+	mergeStrategy, ok := serviceConfig["mergeStrategy"].(map[string]any)
+	if !ok {
+		return replaceLists, nil
+	}
+
+	lists, _ := mergeStrategy["lists"].(string)
+	if lists == string(ListMergeByKey) {
+		return ListMergePolicy{}, fmt.Errorf("list merge strategy %q requires a key, set it per path", lists)
+	}
+	strategy, err := parseListMergeStrategy(lists)
+	if err != nil {
+		return ListMergePolicy{}, err
+	}
+	policy := ListMergePolicy{Default: strategy}
+
+	pathsRaw, _ := mergeStrategy["paths"].(map[string]any)
+	for _, path := range slices.Sorted(maps.Keys(pathsRaw)) {
+		segments, err := splitKeyPath(path)
+		if err != nil {
+			return ListMergePolicy{}, fmt.Errorf("paths[%s]: %w", path, err)
+		}
+		rule := ListMergeRule{Path: segments}
+		var name string
+		switch raw := pathsRaw[path].(type) {
+		case string:
+			name = raw
+		case map[string]any:
+			name, _ = raw["strategy"].(string)
+			rule.Key, _ = raw["key"].(string)
+		default:
+			return ListMergePolicy{}, fmt.Errorf("paths[%s] must be a strategy or {strategy, key}", path)
+		}
+		if rule.Strategy, err = parseListMergeStrategy(name); err != nil {
+			return ListMergePolicy{}, fmt.Errorf("paths[%s]: %w", path, err)
+		}
+		if rule.Strategy == ListMergeByKey && rule.Key == "" {
+			return ListMergePolicy{}, fmt.Errorf("paths[%s]: strategy merge requires a key", path)
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	wildcards := func(rule ListMergeRule) int {
+		n := 0
+		for _, key := range rule.Path {
+			if key == "*" {
+				n++
+			}
+		}
+		return n
+	}
+	slices.SortStableFunc(policy.Rules, func(a, b ListMergeRule) int {
+		return wildcards(a) - wildcards(b)
+	})
+	return policy, nil
+}
+
 // mergeConfigs merges service config with user spec using the provided mapping
-// Returns a merged config with: chart, helmValues (merged), connectionSecret
-func mergeConfigs(serviceConfig map[string]any, userSpec map[string]any, log logr.Logger) (map[string]any, error) {
+// Value source layers are merged over defaultHelmValues in their declared order, user values over all of them
+// spec.helmValues (if allowCustomValues) and spec.helmValuesOverride (filtered by the service's allowlist) are merged
+// last; protectedHelmPaths are kept as before user input either way
+// Returns a merged config with: chart, helmValues (merged) and any optionalConfigSections
+// metadata is the composite's metadata, which mapping expressions and defaultHelmValues templates may refer to
+func mergeConfigs(serviceConfig map[string]any, layers []valueLayer, userSpec, metadata map[string]any, results *Results, log logr.Logger) (map[string]any, error) {
 	// Start with service's defaultHelmValues (deep copy)
 	defaultHelmValues, ok := serviceConfig["defaultHelmValues"].(map[string]any)
 	if !ok {
 		return nil, fmt.Errorf("defaultHelmValues is not a map")
 	}
 	helmValues := deepCopy(defaultHelmValues)
+	// Templates let defaults refer to generated names, e.g. "{{ .InstanceName }}-headless"
+	if err := renderValueTemplates(helmValues, metadata, userSpec, serviceConfig, log); err != nil {
+		return nil, fmt.Errorf("invalid defaultHelmValues: %w", err)
+	}
 
 	// Get mapping
 	mapping, ok := serviceConfig["mapping"].(map[string]any)
@@ -76,119 +308,226 @@ func mergeConfigs(serviceConfig map[string]any, userSpec map[string]any, log log
 		return nil, fmt.Errorf("mapping is not a map")
 	}
 
+	listPolicy, err := getListMergePolicy(serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Layer the value sources, e.g. cluster-wide image registries from a ConfigMap
+	for _, layer := range layers {
+		helmValues = deepMerge(helmValues, deepCopy(layer.values), listPolicy)
+		log.Info("Merged value source", "source", layer.name)
+	}
+
+	// Values at protected paths (e.g., auth, image) are the platform's, whatever the user spec says
+	protectedPaths, err := getProtectedHelmPaths(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protectedHelmPaths: %w", err)
+	}
+	protected := snapshotProtectedValues(helmValues, protectedPaths)
+
 	// Apply mappings: inject user spec values into helm values
-	for xrdPath, helmPathRaw := range mapping {
-		helmPath, ok := helmPathRaw.(string)
-		if !ok {
-			log.Info("Skipping non-string helm path", "xrdPath", xrdPath, "helmPath", helmPathRaw)
-			continue
-		}
+	expressionVars := map[string]any{"metadata": metadata, "config": serviceConfig}
+	if err := applyMapping(helmValues, mapping, userSpec, expressionVars, listPolicy, log); err != nil {
+		return nil, err
+	}
+	service, _ := serviceConfig["service"].(string)
+	recordMappingUsage(service, mapping, userSpec)
 
-		// Get value from user spec using XRD path
-		value, err := getValueByPath(userSpec, xrdPath)
-		if err != nil {
-			// User didn't provide this field - skip it
-			log.Info("User spec doesn't have value for path", "xrdPath", xrdPath)
-			continue
-		}
+	// Custom values of services that allow them, then the user's raw overrides limited to the service's allowlist
+	applyCustomHelmValues(helmValues, serviceConfig, userSpec, listPolicy, results, log)
+	if err := applyHelmValuesOverride(helmValues, serviceConfig, userSpec, listPolicy, results, log); err != nil {
+		return nil, err
+	}
+	if reset := protected.restore(helmValues); len(reset) > 0 {
+		log.Info("Reset protected helm values set by user input", "paths", reset)
+		results.Warning("HelmValuesProtected", "Ignored user input for helm values protected by this service: %s", strings.Join(reset, ", "))
+	}
 
-		// Set value in helm values using helm path
-		if err := setValueByPath(helmValues, helmPath, value); err != nil {
-			return nil, fmt.Errorf("failed to set helm value at %s: %w", helmPath, err)
-		}
+	chart, ok := serviceConfig["chart"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("chart is not a map")
 	}
 
 	// Return merged config
+	// chart is copied since later steps record resolved details (e.g. appVersion) in it
 	result := map[string]any{
-		"chart":      serviceConfig["chart"],
+		"chart":      deepCopy(chart),
 		"helmValues": helmValues,
 	}
 
-	// Include connectionSecret if present in service config
-	if connectionSecret, ok := serviceConfig["connectionSecret"]; ok {
-		result["connectionSecret"] = connectionSecret
+	// Include optional sections if present in service config
+	for _, key := range optionalConfigSections {
+		if section, ok := serviceConfig[key]; ok {
+			result[key] = section
+		}
 	}
 
 	return result, nil
 }
 
-// getValueByPath retrieves a value from a nested map using a dot-separated path
-// Example: "spec.size.cpu" -> userSpec["size"]["cpu"]
-func getValueByPath(data map[string]any, path string) (any, error) {
-	parts := strings.Split(path, ".")
-	current := any(data)
-
-	for i, part := range parts {
-		if i == 0 && part == "spec" {
+// applyMapping merges the values of a spec into helm values through the service's mapping
+// Spec fields without a value are skipped, so defaults the spec doesn't override are kept
+// Entries with an expression are evaluated whether the field is set or not, with expressionVars (metadata and
+// config), spec and self, the field's value; expressions returning null are skipped
+// Spec fields set to null remove the helm value, as do remove entries whose value is true; removed values are
+// null in the Release values, which Helm takes as removing the key, including the chart's own default
+func applyMapping(helmValues, mapping, spec, expressionVars map[string]any, listPolicy ListMergePolicy, log logr.Logger) error {
+	for xrdPath, targetRaw := range mapping {
+		// A broken service config fails the render instead of deploying without the mapped value
+		target, err := parseMappingTarget(targetRaw)
+		if err != nil {
+			return fmt.Errorf("invalid mapping for %s: %w", xrdPath, err)
+		}
+		helmPath := target.HelmPath
+
+		// Get value from the spec using XRD path
+		value, err := getValueByPath(spec, xrdPath)
+		if target.Expression != "" {
+			vars := maps.Clone(expressionVars)
+			vars["spec"] = spec
+			vars["self"] = nil
+			if err == nil {
+				vars["self"] = value
+			}
+			value, err = evaluateExpression(target.Expression, vars)
+			if err != nil {
+				return fmt.Errorf("mapping for %s: %w", xrdPath, err)
+			}
+			if value == nil {
+				log.Info("Mapping expression returned null", "xrdPath", xrdPath)
+				continue
+			}
+		} else if err != nil {
+			// The spec doesn't set this field - skip it
+			log.Info("Spec doesn't have value for path", "xrdPath", xrdPath)
 			continue
 		}
 
-		m, ok := current.(map[string]any)
-		if !ok {
-			return nil, fmt.Errorf("path %s: expected map at part %s, got %T", path, part, current)
+		if target.Remove {
+			remove, ok := value.(bool)
+			if !ok {
+				return fmt.Errorf("mapping for %s: remove entries take a bool, got %T", xrdPath, value)
+			}
+			if !remove {
+				continue
+			}
+			value = nil
+		}
+		if value == nil {
+			if err := setValueByPath(helmValues, helmPath, nil); err != nil {
+				return fmt.Errorf("failed to remove helm value at %s: %w", helmPath, err)
+			}
+			log.Info("Removed helm value", "xrdPath", xrdPath, "helmPath", helmPath)
+			continue
 		}
 
-		value, ok := m[part]
-		if !ok {
-			return nil, fmt.Errorf("path %s: key %s not found", path, part)
+		// Convert the value into the representation the chart expects
+		if target.Transform != nil {
+			value, err = target.Transform(value)
+			if err != nil {
+				return fmt.Errorf("failed to transform %s for %s: %w", xrdPath, helmPath, err)
+			}
 		}
 
-		current = value
+		// Merge value into helm values using helm path, keeping defaults the user didn't override
+		if err := mergeValueByPath(helmValues, helmPath, value, listPolicy); err != nil {
+			return fmt.Errorf("failed to set helm value at %s: %w", helmPath, err)
+		}
 	}
-
-	return current, nil
+	return nil
 }
 
-// setValueByPath sets a value in a nested map using a dot-separated path
-// Creates intermediate maps if they don't exist
-// Example: "master.resources.requests.cpu" with value "1000m"
-func setValueByPath(data map[string]any, path string, value any) error {
-	parts := strings.Split(path, ".")
-	if len(parts) == 0 {
-		return fmt.Errorf("empty path")
+// recordMappingUsage counts which mapped spec fields the user spec sets, so unused fields can be deprecated
+func recordMappingUsage(service string, mapping, userSpec map[string]any) {
+	used := map[string]bool{}
+	for xrdPath := range mapping {
+		if _, err := getValueByPath(userSpec, xrdPath); err == nil {
+			used[xrdPath] = true
+		}
 	}
+	recordUsage(mappingFieldRenders, service, slices.Sorted(maps.Keys(mapping)), used)
+}
 
-	// Navigate to the parent of the final key, creating maps as needed
-	current := data
-	for i := 0; i < len(parts)-1; i++ {
-		part := parts[i]
-
-		// Get or create the next level
-		next, ok := current[part]
+// mergeValues merges src over dst at path and returns the result
+// Maps are merged key-by-key, lists are combined according to the policy's rule for path, anything else is
+// replaced by a copy of src, so later merges and patches never write through to the user spec
+func mergeValues(dst, src any, lists ListMergePolicy, path []string) any {
+	switch srcVal := src.(type) {
+	case map[string]any:
+		if dstMap, ok := dst.(map[string]any); ok {
+			return deepMergeAt(dstMap, srcVal, lists, path)
+		}
+	case []any:
+		dstList, ok := dst.([]any)
 		if !ok {
-			// Create new map
-			next = make(map[string]any)
-			current[part] = next
+			break
 		}
+		switch rule := lists.ruleAt(path); rule.Strategy {
+		case ListMergeAppend:
+			merged := make([]any, 0, len(dstList)+len(srcVal))
+			merged = append(merged, dstList...)
+			return append(merged, deepCopySlice(srcVal)...)
+		case ListMergeByKey:
+			return mergeListByKey(dstList, srcVal, rule.Key, ListMergePolicy{Default: lists.Default})
+		}
+	}
+	return deepCopyValue(src)
+}
 
-		// Ensure it's a map
-		nextMap, ok := next.(map[string]any)
-		if !ok {
-			return fmt.Errorf("path %s: expected map at part %s, got %T", path, part, next)
+// mergeListByKey deep-merges the elements of src into the elements of dst with the same value at key
+// Elements without a match, or without a scalar key, are appended; nested lists follow elements, the policy
+// without rules, as paths below list elements aren't addressable
+func mergeListByKey(dst, src []any, key string, elements ListMergePolicy) []any {
+	merged := deepCopySlice(dst)
+	for _, srcElement := range src {
+		if id, ok := listElementKey(srcElement, key); ok {
+			index := slices.IndexFunc(merged, func(element any) bool {
+				elementID, ok := listElementKey(element, key)
+				return ok && elementID == id
+			})
+			if index >= 0 {
+				merged[index] = mergeValues(merged[index], srcElement, elements, nil)
+				continue
+			}
 		}
+		merged = append(merged, deepCopyValue(srcElement))
+	}
+	return merged
+}
 
-		current = nextMap
+// listElementKey returns the value at key of a list element, if it is a map with a scalar there
+func listElementKey(element any, key string) (any, bool) {
+	m, ok := element.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	switch id := m[key].(type) {
+	case string, float64, bool:
+		return id, true
+	default:
+		return nil, false
 	}
+}
 
-	// Set the final value
-	finalKey := parts[len(parts)-1]
-	current[finalKey] = value
+// deepMerge recursively merges src into dst and returns dst
+func deepMerge(dst, src map[string]any, lists ListMergePolicy) map[string]any {
+	return deepMergeAt(dst, src, lists, nil)
+}
 
-	return nil
+// deepMergeAt merges src into dst, which is at path below the root of the merge, and returns dst
+func deepMergeAt(dst, src map[string]any, lists ListMergePolicy, path []string) map[string]any {
+	for k, v := range src {
+		dst[k] = mergeValues(dst[k], v, lists, append(slices.Clip(path), k))
+	}
+	return dst
 }
 
 // deepCopy creates a deep copy of a map[string]any
 func deepCopy(src map[string]any) map[string]any {
 	dst := make(map[string]any)
 	for k, v := range src {
-		switch val := v.(type) {
-		case map[string]any:
-			dst[k] = deepCopy(val)
-		case []any:
-			dst[k] = deepCopySlice(val)
-		default:
-			dst[k] = v
-		}
+		dst[k] = deepCopyValue(v)
 	}
 	return dst
 }
@@ -197,14 +536,19 @@ func deepCopy(src map[string]any) map[string]any {
 func deepCopySlice(src []any) []any {
 	dst := make([]any, len(src))
 	for i, v := range src {
-		switch val := v.(type) {
-		case map[string]any:
-			dst[i] = deepCopy(val)
-		case []any:
-			dst[i] = deepCopySlice(val)
-		default:
-			dst[i] = v
-		}
+		dst[i] = deepCopyValue(v)
 	}
 	return dst
 }
+
+// deepCopyValue creates a deep copy of maps and lists, other values are returned as they are
+func deepCopyValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		return deepCopy(val)
+	case []any:
+		return deepCopySlice(val)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeepMerge(t *testing.T) {
+	cases := map[string]struct {
+		dst  map[string]any
+		src  map[string]any
+		want map[string]any
+	}{
+		"KeepsDefaults": {
+			dst:  map[string]any{"master": map[string]any{"count": float64(1), "image": "redis"}},
+			src:  map[string]any{"master": map[string]any{"count": float64(3)}},
+			want: map[string]any{"master": map[string]any{"count": float64(3), "image": "redis"}},
+		},
+		"AddsKeys": {
+			dst:  map[string]any{"a": "1"},
+			src:  map[string]any{"b": map[string]any{"c": "2"}},
+			want: map[string]any{"a": "1", "b": map[string]any{"c": "2"}},
+		},
+		"ReplacesScalarWithMap": {
+			dst:  map[string]any{"resources": "small"},
+			src:  map[string]any{"resources": map[string]any{"cpu": "1"}},
+			want: map[string]any{"resources": map[string]any{"cpu": "1"}},
+		},
+		"ReplacesMapWithScalar": {
+			dst:  map[string]any{"resources": map[string]any{"cpu": "1"}},
+			src:  map[string]any{"resources": "small"},
+			want: map[string]any{"resources": "small"},
+		},
+		"ReplacesLists": {
+			dst:  map[string]any{"tolerations": []any{"a", "b"}},
+			src:  map[string]any{"tolerations": []any{"c"}},
+			want: map[string]any{"tolerations": []any{"c"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := deepMerge(tc.dst, tc.src, replaceLists); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("deepMerge() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeepMergeCopiesSource(t *testing.T) {
+	src := map[string]any{
+		"resources":   map[string]any{"cpu": "1"},
+		"tolerations": []any{map[string]any{"key": "a"}},
+	}
+	lists := map[string]ListMergePolicy{
+		"Replace": replaceLists,
+		"Append":  {Default: ListMergeAppend},
+		"ByKey":   {Rules: []ListMergeRule{{Path: []string{"tolerations"}, Strategy: ListMergeByKey, Key: "key"}}},
+	}
+	for name, policy := range lists {
+		t.Run(name, func(t *testing.T) {
+			merged := deepMerge(map[string]any{"tolerations": []any{}}, src, policy)
+			merged["resources"].(map[string]any)["cpu"] = "2"
+			merged["tolerations"].([]any)[0].(map[string]any)["key"] = "b"
+			if src["resources"].(map[string]any)["cpu"] != "1" || src["tolerations"].([]any)[0].(map[string]any)["key"] != "a" {
+				t.Errorf("changing the merged values changed the source: %v", src)
+			}
+		})
+	}
+}
+
+func TestGetListMergePolicy(t *testing.T) {
+	cases := map[string]struct {
+		mergeStrategy any
+		want          ListMergePolicy
+		wantErr       bool
+	}{
+		"Unset": {want: replaceLists},
+		"Default": {
+			mergeStrategy: map[string]any{"lists": "append"},
+			want:          ListMergePolicy{Default: ListMergeAppend},
+		},
+		"ExactPathsBeforeWildcards": {
+			mergeStrategy: map[string]any{"paths": map[string]any{
+				"*.extraEnvVars":      "append",
+				"master.extraEnvVars": map[string]any{"strategy": "merge", "key": "name"},
+			}},
+			want: ListMergePolicy{Default: ListMergeReplace, Rules: []ListMergeRule{
+				{Path: []string{"master", "extraEnvVars"}, Strategy: ListMergeByKey, Key: "name"},
+				{Path: []string{"*", "extraEnvVars"}, Strategy: ListMergeAppend},
+			}},
+		},
+		"DefaultByKey":    {mergeStrategy: map[string]any{"lists": "merge"}, wantErr: true},
+		"UnknownStrategy": {mergeStrategy: map[string]any{"lists": "union"}, wantErr: true},
+		"ByKeyWithoutKey": {mergeStrategy: map[string]any{"paths": map[string]any{"tolerations": "merge"}}, wantErr: true},
+		"InvalidRule":     {mergeStrategy: map[string]any{"paths": map[string]any{"tolerations": float64(1)}}, wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			serviceConfig := map[string]any{}
+			if tc.mergeStrategy != nil {
+				serviceConfig["mergeStrategy"] = tc.mergeStrategy
+			}
+			got, err := getListMergePolicy(serviceConfig)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("getListMergePolicy() error = %v, want error %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("getListMergePolicy() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDeepMergeLists(t *testing.T) {
+	env := func(name, value string) map[string]any { return map[string]any{"name": name, "value": value} }
+	dst := func() map[string]any {
+		return map[string]any{
+			"master":  map[string]any{"extraEnvVars": []any{env("A", "1"), env("B", "2")}},
+			"replica": map[string]any{"extraEnvVars": []any{env("A", "1")}},
+		}
+	}
+	src := map[string]any{
+		"master":  map[string]any{"extraEnvVars": []any{env("B", "3"), env("C", "4")}},
+		"replica": map[string]any{"extraEnvVars": []any{env("C", "4")}},
+	}
+	byKey := ListMergeRule{Path: []string{"master", "extraEnvVars"}, Strategy: ListMergeByKey, Key: "name"}
+
+	cases := map[string]struct {
+		policy      ListMergePolicy
+		wantMaster  []any
+		wantReplica []any
+	}{
+		"Replace": {
+			policy:      replaceLists,
+			wantMaster:  []any{env("B", "3"), env("C", "4")},
+			wantReplica: []any{env("C", "4")},
+		},
+		"Append": {
+			policy:      ListMergePolicy{Default: ListMergeAppend},
+			wantMaster:  []any{env("A", "1"), env("B", "2"), env("B", "3"), env("C", "4")},
+			wantReplica: []any{env("A", "1"), env("C", "4")},
+		},
+		"ByKeyAtPath": {
+			policy:      ListMergePolicy{Default: ListMergeReplace, Rules: []ListMergeRule{byKey}},
+			wantMaster:  []any{env("A", "1"), env("B", "3"), env("C", "4")},
+			wantReplica: []any{env("C", "4")},
+		},
+		"Wildcard": {
+			policy:      ListMergePolicy{Default: ListMergeReplace, Rules: []ListMergeRule{{Path: []string{"*", "extraEnvVars"}, Strategy: ListMergeAppend}}},
+			wantMaster:  []any{env("A", "1"), env("B", "2"), env("B", "3"), env("C", "4")},
+			wantReplica: []any{env("A", "1"), env("C", "4")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			merged := deepMerge(dst(), src, tc.policy)
+			if got := merged["master"].(map[string]any)["extraEnvVars"]; !reflect.DeepEqual(got, tc.wantMaster) {
+				t.Errorf("master.extraEnvVars = %v, want %v", got, tc.wantMaster)
+			}
+			if got := merged["replica"].(map[string]any)["extraEnvVars"]; !reflect.DeepEqual(got, tc.wantReplica) {
+				t.Errorf("replica.extraEnvVars = %v, want %v", got, tc.wantReplica)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vshn/appcat-poc/appcat-runtime/releasehealth"
+)
+
+// instanceInfo exposes one series per instance with its deployed chart and app version
+// Served by the function SDK's metrics endpoint from the default registry
+var instanceInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "appcat_instance_info",
+		Help: "Deployed chart and application version per instance (always 1).",
+	},
+	[]string{"namespace", "instance", "chart", "chart_version", "app_version"},
+)
+
+// releaseHealth exposes the normalized health of each instance's HelmRelease, 1 for the current health
+var releaseHealth = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "appcat_release_health",
+		Help: "Health of the instance's HelmRelease (1 for the current health, 0 otherwise).",
+	},
+	[]string{"namespace", "instance", "health"},
+)
+
+// warningsSuppressed counts repeated warnings the deduplicator didn't emit
+var warningsSuppressed = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "appcat_warnings_suppressed_total",
+		Help: "Repeated warning results suppressed by the deduplication window, by reason.",
+	},
+	[]string{"reason"},
+)
+
+// mappingFieldRenders counts renders per mapped spec field, and whether the user spec set it
+// Every field of the mapping gets a series, so fields no instance sets show up with used="true" staying at 0
+var mappingFieldRenders = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "appcat_mapping_field_renders_total",
+		Help: "Renders per service and mapped spec field, by whether the user spec set the field.",
+	},
+	[]string{"service", "field", "used"},
+)
+
+// planRenders counts renders per plan of the service's plan table, and whether spec.plan selected it
+var planRenders = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "appcat_plan_renders_total",
+		Help: "Renders per service and plan, by whether spec.plan selected the plan.",
+	},
+	[]string{"service", "plan", "used"},
+)
+
+func init() {
+	prometheus.MustRegister(instanceInfo, releaseHealth, warningsSuppressed, mappingFieldRenders, planRenders)
+}
+
+// recordInstanceInfo updates the info series of an instance, dropping series for previous versions
+func recordInstanceInfo(namespace, instance, chart, chartVersion, appVersion string) {
+	instanceInfo.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "instance": instance})
+	instanceInfo.WithLabelValues(namespace, instance, chart, chartVersion, appVersion).Set(1)
+}
+
+// forgetInstanceMetrics drops the series of an instance being deleted, so deleted instances don't linger
+func forgetInstanceMetrics(namespace, instance string) {
+	labels := prometheus.Labels{"namespace": namespace, "instance": instance}
+	instanceInfo.DeletePartialMatch(labels)
+	releaseHealth.DeletePartialMatch(labels)
+}
+
+// recordReleaseHealth updates the health series of an instance
+func recordReleaseHealth(namespace, instance string, health releasehealth.Health) {
+	for _, value := range releasehealth.Values {
+		gauge := releaseHealth.WithLabelValues(namespace, instance, string(value))
+		if value == health {
+			gauge.Set(1)
+		} else {
+			gauge.Set(0)
+		}
+	}
+}
+
+// recordUsage counts a render for each of the names, as used if in use
+// Names the render didn't use are still touched, so their series exist at 0 until somebody uses them
+func recordUsage(counter *prometheus.CounterVec, service string, names []string, used map[string]bool) {
+	for _, name := range names {
+		counter.WithLabelValues(service, name, strconv.FormatBool(used[name])).Inc()
+		counter.WithLabelValues(service, name, strconv.FormatBool(!used[name]))
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// MonitoringConfig describes how a service exposes metrics
+type MonitoringConfig struct {
+	// MetricsPort is the port of the instance pods serving metrics
+	MetricsPort int
+	// MetricsPath is the HTTP path of the metrics endpoint
+	MetricsPath string
+	// MetricsEnabledPath is the helm value path enabling the chart's metrics exporter
+	MetricsEnabledPath string
+	// ServiceAnnotationsPath is the helm value path of the metrics Service's annotations
+	ServiceAnnotationsPath string
+	// PodSelector selects the pods serving metrics; defaults to the Helm release instance label
+	PodSelector map[string]string
+	// ServiceMonitor and PodMonitor are emitted for the platform's Prometheus if configured
+	ServiceMonitor *MonitorConfig
+	PodMonitor     *MonitorConfig
+}
+
+// MonitorConfig controls a ServiceMonitor or PodMonitor scraping the instance
+type MonitorConfig struct {
+	// Selector selects the scraped Services or Pods; defaults to the Helm release instance label
+	Selector map[string]string
+	// Port is the name of the metrics port; the metricsPort number is used if empty
+	Port     string
+	Interval string
+	// Labels are set on the monitor, e.g. the label the platform's Prometheus selects monitors by
+	Labels            map[string]string
+	Relabelings       []any
+	MetricRelabelings []any
+}
+
+// ExternalScrapeSpec is the tenant's request from spec.monitoring.externalScrape
+type ExternalScrapeSpec struct {
+	// CIDRs are the source ranges of the tenant's Prometheus allowed to scrape the instance
+	CIDRs []string
+}
+
+// getMonitoringConfig extracts monitoring configuration from service config
+// Returns nil without error if the service doesn't expose metrics
+func getMonitoringConfig(serviceConfig map[string]any) (*MonitoringConfig, error) {
+	monitoringConfig, ok := serviceConfig["monitoring"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &MonitoringConfig{MetricsPath: "/metrics", PodSelector: map[string]string{}}
+	port, ok := lookupNumber(fieldpath.Pave(monitoringConfig), "metricsPort")
+	if !ok || port != float64(int(port)) || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("metricsPort must be a port number")
+	}
+	config.MetricsPort = int(port)
+	if path, _ := monitoringConfig["metricsPath"].(string); path != "" {
+		config.MetricsPath = path
+	}
+	config.MetricsEnabledPath, _ = monitoringConfig["metricsEnabledPath"].(string)
+	config.ServiceAnnotationsPath, _ = monitoringConfig["serviceAnnotationsPath"].(string)
+	if selectorRaw, ok := monitoringConfig["podSelector"].(map[string]any); ok {
+		for key, valueRaw := range selectorRaw {
+			value, ok := valueRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("podSelector %s must be a string", key)
+			}
+			config.PodSelector[key] = value
+		}
+	}
+	for field, target := range map[string]**MonitorConfig{"serviceMonitor": &config.ServiceMonitor, "podMonitor": &config.PodMonitor} {
+		monitor, err := getMonitorConfig(monitoringConfig, field)
+		if err != nil {
+			return nil, err
+		}
+		*target = monitor
+	}
+	return config, nil
+}
+
+// getMonitorConfig extracts a serviceMonitor or podMonitor section of the monitoring config
+// Returns nil without error if the section is absent or disabled
+func getMonitorConfig(monitoringConfig map[string]any, field string) (*MonitorConfig, error) {
+	monitorRaw, ok := monitoringConfig[field].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	if enabled, set := monitorRaw["enabled"].(bool); set && !enabled {
+		return nil, nil
+	}
+
+	monitor := &MonitorConfig{Selector: map[string]string{}, Labels: map[string]string{}}
+	for name, target := range map[string]map[string]string{"selector": monitor.Selector, "labels": monitor.Labels} {
+		valuesRaw, _ := monitorRaw[name].(map[string]any)
+		for key, valueRaw := range valuesRaw {
+			value, ok := valueRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s.%s %s must be a string", field, name, key)
+			}
+			target[key] = value
+		}
+	}
+	monitor.Port, _ = monitorRaw["port"].(string)
+	monitor.Interval, _ = monitorRaw["interval"].(string)
+	for name, target := range map[string]*[]any{"relabelings": &monitor.Relabelings, "metricRelabelings": &monitor.MetricRelabelings} {
+		if raw, ok := monitorRaw[name]; ok {
+			list, ok := raw.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s.%s must be a list", field, name)
+			}
+			*target = list
+		}
+	}
+	return monitor, nil
+}
+
+// enableMetricsExporter turns on the chart's metrics exporter, if the service has a switch for it
+func enableMetricsExporter(helmValues map[string]any, config *MonitoringConfig) error {
+	if config.MetricsEnabledPath == "" {
+		return nil
+	}
+	if err := setValueByPath(helmValues, config.MetricsEnabledPath, true); err != nil {
+		return fmt.Errorf("failed to enable metrics: %w", err)
+	}
+	return nil
+}
+
+// getExternalScrapeSpec extracts spec.monitoring.externalScrape from the user spec
+// Returns nil without error if the tenant didn't request external scraping
+func getExternalScrapeSpec(userSpec map[string]any) (*ExternalScrapeSpec, error) {
+	externalScrape, err := fieldpath.Pave(userSpec).GetValue("monitoring.externalScrape")
+	if err != nil {
+		return nil, nil
+	}
+	scrapeMap, ok := externalScrape.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("monitoring.externalScrape must be an object")
+	}
+	if enabled, set := scrapeMap["enabled"].(bool); set && !enabled {
+		return nil, nil
+	}
+
+	cidrsRaw, _ := scrapeMap["cidrs"].([]any)
+	if len(cidrsRaw) == 0 {
+		return nil, fmt.Errorf("monitoring.externalScrape.cidrs requires at least one CIDR")
+	}
+	spec := &ExternalScrapeSpec{}
+	for i, cidrRaw := range cidrsRaw {
+		cidr, _ := cidrRaw.(string)
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("monitoring.externalScrape.cidrs[%d]: invalid CIDR %q", i, cidr)
+		}
+		// Metrics reveal instance internals, so scraping is never opened to everyone
+		if ones, _ := network.Mask.Size(); ones == 0 {
+			return nil, fmt.Errorf("monitoring.externalScrape.cidrs[%d]: %s allows any source", i, cidr)
+		}
+		spec.CIDRs = append(spec.CIDRs, network.String())
+	}
+	return spec, nil
+}
+
+// applyExternalScrape renders spec.monitoring.externalScrape into the merged config
+// The chart's exporter and Service annotations are set in the helm values, and the request is recorded as
+// mergedConfig["externalScrape"] for generateExternalScrapePolicy
+func applyExternalScrape(mergedConfig, serviceConfig, userSpec map[string]any, results *Results, log logr.Logger) error {
+	spec, err := getExternalScrapeSpec(userSpec)
+	if err != nil {
+		return err
+	}
+	if spec == nil {
+		return nil
+	}
+	config, err := getMonitoringConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid monitoring config: %w", err)
+	}
+	if config == nil {
+		log.Info("Service doesn't expose metrics, ignoring spec.monitoring.externalScrape")
+		results.Warning("ExternalScrapeUnsupported", "This service doesn't expose metrics, spec.monitoring.externalScrape is ignored")
+		return nil
+	}
+
+	helmValues, ok := mergedConfig["helmValues"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("helmValues not found in merged config")
+	}
+	if err := enableMetricsExporter(helmValues, config); err != nil {
+		return err
+	}
+	if config.ServiceAnnotationsPath != "" {
+		paved := fieldpath.Pave(helmValues)
+		for key, value := range map[string]string{
+			"prometheus.io/scrape": "true",
+			"prometheus.io/port":   strconv.Itoa(config.MetricsPort),
+			"prometheus.io/path":   config.MetricsPath,
+		} {
+			if err := paved.SetValue(fmt.Sprintf("%s[%s]", config.ServiceAnnotationsPath, key), value); err != nil {
+				return fmt.Errorf("failed to set metrics service annotation %s: %w", key, err)
+			}
+		}
+	}
+
+	cidrs := make([]any, 0, len(spec.CIDRs))
+	for _, cidr := range spec.CIDRs {
+		cidrs = append(cidrs, cidr)
+	}
+	podSelector := make(map[string]any, len(config.PodSelector))
+	for key, value := range config.PodSelector {
+		podSelector[key] = value
+	}
+	mergedConfig["externalScrape"] = map[string]any{
+		"cidrs":       cidrs,
+		"port":        float64(config.MetricsPort),
+		"podSelector": podSelector,
+	}
+	return nil
+}
+
+// generateExternalScrapePolicy creates a NetworkPolicy admitting the tenant's Prometheus to the metrics port
+// Only the listed CIDRs reach only the metrics port, on top of any other policies selecting the pods
+func generateExternalScrapePolicy(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	externalScrape, ok := mergedConfig["externalScrape"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	paved := fieldpath.Pave(externalScrape)
+	port, _ := lookupNumber(paved, "port")
+	var cidrs []string
+	if err := paved.GetValueInto("cidrs", &cidrs); err != nil {
+		return fmt.Errorf("invalid external scrape config: %w", err)
+	}
+	podSelector := map[string]string{}
+	if err := paved.GetValueInto("podSelector", &podSelector); err != nil {
+		return fmt.Errorf("invalid external scrape config: %w", err)
+	}
+	if len(podSelector) == 0 {
+		podSelector = map[string]string{"app.kubernetes.io/instance": instanceName}
+	}
+
+	policy := NewNetworkPolicyBuilder(instanceName+"-allow-scrape", instanceNamespace).
+		WithPodSelector(podSelector).
+		AllowFromCIDRs(cidrs, int32(port)).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace).
+		Build()
+
+	policyResource, err := toFunctionResource(policy)
+	if err != nil {
+		return fmt.Errorf("failed to convert allow-scrape network policy: %w", err)
+	}
+	resources["networkpolicy-allow-scrape"] = policyResource
+
+	log.Info("Created external scrape network policy", "cidrs", cidrs, "port", int(port))
+	return nil
+}
+
+// applyMonitors enables the chart's metrics exporter when the service emits a ServiceMonitor or PodMonitor
+func applyMonitors(mergedConfig, serviceConfig map[string]any) error {
+	config, err := getMonitoringConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid monitoring config: %w", err)
+	}
+	if config == nil || (config.ServiceMonitor == nil && config.PodMonitor == nil) {
+		return nil
+	}
+	helmValues, ok := mergedConfig["helmValues"].(map[string]any)
+	if !ok {
+		return fmt.Errorf("helmValues not found in merged config")
+	}
+	return enableMetricsExporter(helmValues, config)
+}
+
+// generateMonitors creates the ServiceMonitor and PodMonitor the service config asks for,
+// so the platform's Prometheus scrapes the chart's exporter
+// Both are skipped on clusters without Prometheus Operator (see capabilities)
+func generateMonitors(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	config, err := getMonitoringConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid monitoring config: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	for key, monitor := range map[string]*MonitorConfig{"servicemonitor": config.ServiceMonitor, "podmonitor": config.PodMonitor} {
+		if monitor == nil {
+			continue
+		}
+		builder := NewServiceMonitorBuilder(instanceName, instanceNamespace)
+		if key == "podmonitor" {
+			builder = NewPodMonitorBuilder(instanceName, instanceNamespace)
+		}
+
+		selector := monitor.Selector
+		if len(selector) == 0 {
+			selector = map[string]string{"app.kubernetes.io/instance": instanceName}
+		}
+		builder = builder.
+			WithSelector(selector).
+			WithEndpoint(MonitorEndpoint{
+				Port:              monitor.Port,
+				TargetPort:        config.MetricsPort,
+				Path:              config.MetricsPath,
+				Interval:          monitor.Interval,
+				Relabelings:       monitor.Relabelings,
+				MetricRelabelings: monitor.MetricRelabelings,
+			})
+		for label, value := range monitor.Labels {
+			builder = builder.WithLabel(label, value)
+		}
+		built := builder.
+			WithLabel("app.kubernetes.io/managed-by", "crossplane").
+			WithLabel("app.kubernetes.io/instance", instanceName).
+			WithLabel(LabelClaimName, claim.Name).
+			WithLabel(LabelClaimNamespace, claim.Namespace).
+			Build()
+
+		monitorResource, err := toFunctionResource(built)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", key, err)
+		}
+		resources[key] = monitorResource
+		log.Info("Created monitor", "kind", built.GetKind(), "selector", selector)
+	}
+	return nil
+}
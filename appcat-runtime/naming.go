@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// defaultNamingMaxLength keeps Release names below Helm's 53 character limit, even with the blue/green "-next" suffix
+const defaultNamingMaxLength = 53 - len(nextSuffix)
+
+// namingHashLength is the number of hex digits of the hash suffix of truncated names
+const namingHashLength = 8
+
+// NamingConfig defines how the instance name is derived from the composite
+type NamingConfig struct {
+	// Template supports ${prefix}, ${name}, ${namespace}, ${claimName} and ${claimNamespace}
+	Template  string
+	Prefix    string
+	MaxLength int
+}
+
+// getNamingConfig extracts naming configuration from merged config
+// Returns nil without error if no naming is configured
+func getNamingConfig(mergedConfig map[string]any) (*NamingConfig, error) {
+	namingConfig, ok := mergedConfig["naming"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &NamingConfig{Template: "${prefix}${name}", MaxLength: defaultNamingMaxLength}
+	config.Prefix, _ = namingConfig["prefix"].(string)
+	if template, _ := namingConfig["template"].(string); template != "" {
+		config.Template = template
+	}
+	if maxLength, ok := lookupNumber(fieldpath.Pave(namingConfig), "maxLength"); ok {
+		config.MaxLength = int(maxLength)
+		if float64(config.MaxLength) != maxLength || config.MaxLength <= namingHashLength+1 || config.MaxLength > validation.DNS1123LabelMaxLength {
+			return nil, fmt.Errorf("maxLength must be an integer between %d and %d", namingHashLength+2, validation.DNS1123LabelMaxLength)
+		}
+	}
+	return config, nil
+}
+
+// getInstanceName returns the name the instance's Release and derived resources are named after
+// Without a naming config this is the composite name, so existing instances keep their names
+func getInstanceName(composite *fnv1.Resource, mergedConfig map[string]any) (string, error) {
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+	name, err := paved.GetString("metadata.name")
+	if err != nil {
+		return "", fmt.Errorf("failed to get instance name: %w", err)
+	}
+
+	config, err := getNamingConfig(mergedConfig)
+	if err != nil {
+		return "", fmt.Errorf("invalid naming config: %w", err)
+	}
+	if config == nil {
+		return name, nil
+	}
+
+	namespace, _ := paved.GetString("metadata.namespace")
+	claim := getClaimReference(composite, name, namespace)
+	instanceName := truncateName(substituteVariables(config.Template, map[string]string{
+		"prefix":         config.Prefix,
+		"name":           name,
+		"namespace":      namespace,
+		"claimName":      claim.Name,
+		"claimNamespace": claim.Namespace,
+	}), config.MaxLength)
+
+	if errs := validation.IsDNS1123Label(instanceName); len(errs) > 0 {
+		return "", fmt.Errorf("instance name %q is not a valid RFC 1123 label: %s", instanceName, strings.Join(errs, "; "))
+	}
+	return instanceName, nil
+}
+
+// truncateName shortens name to maxLength, replacing the cut-off part with a hash of the full name
+// so distinct long names stay distinct
+func truncateName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	head := strings.TrimRight(name[:maxLength-namingHashLength-1], "-.")
+	return head + "-" + hex.EncodeToString(sum[:])[:namingHashLength]
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// NetworkPolicyConfig controls default network isolation of instances
+type NetworkPolicyConfig struct {
+	Enabled bool
+	// PodSelector selects the instance's pods; defaults to the Helm release instance label
+	PodSelector map[string]string
+}
+
+// getNetworkPolicyConfig extracts networkPolicy configuration from merged config
+// Returns nil without error if no networkPolicy is configured
+func getNetworkPolicyConfig(mergedConfig map[string]any) (*NetworkPolicyConfig, error) {
+	policyConfig, ok := mergedConfig["networkPolicy"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	enabled, _ := policyConfig["enabled"].(bool)
+	podSelector := map[string]string{}
+	if selectorRaw, ok := policyConfig["podSelector"].(map[string]any); ok {
+		for key, valueRaw := range selectorRaw {
+			value, ok := valueRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("podSelector %s must be a string", key)
+			}
+			podSelector[key] = value
+		}
+	}
+
+	return &NetworkPolicyConfig{Enabled: enabled, PodSelector: podSelector}, nil
+}
+
+// generateNetworkPolicies creates a deny-all plus allow-from-claim-namespace policy pair for the instance pods
+// Policies only select the instance's pods, so other workloads sharing the namespace are unaffected
+func generateNetworkPolicies(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	log logr.Logger,
+) error {
+	policyConfig, err := getNetworkPolicyConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid network policy config: %w", err)
+	}
+	if policyConfig == nil || !policyConfig.Enabled {
+		return nil
+	}
+
+	podSelector := policyConfig.PodSelector
+	if len(podSelector) == 0 {
+		podSelector = map[string]string{"app.kubernetes.io/instance": instanceName}
+	}
+
+	denyAll := NewNetworkPolicyBuilder(instanceName+"-deny-all", instanceNamespace).
+		WithPodSelector(podSelector).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace).
+		Build()
+
+	// Instance pods must still reach each other (e.g. replication), so the instance namespace is always allowed
+	allowBuilder := NewNetworkPolicyBuilder(instanceName+"-allow-claim", instanceNamespace).
+		WithPodSelector(podSelector).
+		AllowFromNamespace(instanceNamespace)
+	if claim.Namespace != instanceNamespace {
+		allowBuilder = allowBuilder.AllowFromNamespace(claim.Namespace)
+	}
+	allowClaim := allowBuilder.
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace).
+		Build()
+
+	denyAllResource, err := toFunctionResource(denyAll)
+	if err != nil {
+		return fmt.Errorf("failed to convert deny-all network policy: %w", err)
+	}
+	allowClaimResource, err := toFunctionResource(allowClaim)
+	if err != nil {
+		return fmt.Errorf("failed to convert allow-claim network policy: %w", err)
+	}
+	resources["networkpolicy-deny-all"] = denyAllResource
+	resources["networkpolicy-allow-claim"] = allowClaimResource
+
+	log.Info("Created network policies",
+		"instanceNamespace", instanceNamespace,
+		"claimNamespace", claim.Namespace)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+)
+
+// ociChartManifestType is the media type of the manifests helm pushes charts with
+const ociChartManifestType = "application/vnd.oci.image.manifest.v1+json"
+
+// chartDigestPattern matches pinned manifest digests
+var chartDigestPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// isOCIRepository reports whether a chart repository is an OCI registry, e.g. oci://ghcr.io/vshn/charts
+func isOCIRepository(repo string) bool {
+	return strings.HasPrefix(repo, "oci://")
+}
+
+// ociChartURL returns the URL form of an OCI chart, e.g. oci://ghcr.io/vshn/charts/redis:18.0.0
+// OCI registries have no repository index, the URL names the chart's tag directly
+func ociChartURL(repo, name, version string) string {
+	return strings.TrimSuffix(repo, "/") + "/" + name + ":" + version
+}
+
+// getChartDigests extracts chart.digests, the manifest digests OCI chart versions are pinned to, by version, e.g.
+// {"18.0.0": "sha256:..."}
+// Returns nil without error if no versions are pinned
+func getChartDigests(chart map[string]any) (map[string]string, error) {
+	digestsRaw, ok := chart["digests"]
+	if !ok {
+		return nil, nil
+	}
+	entries, ok := digestsRaw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("chart.digests must be a map of versions to digests")
+	}
+	if repo, _ := chart["repository"].(string); !isOCIRepository(repo) {
+		return nil, fmt.Errorf("chart.digests requires an oci:// repository")
+	}
+	digests := make(map[string]string, len(entries))
+	for version, digestRaw := range entries {
+		digest, _ := digestRaw.(string)
+		if !chartDigestPattern.MatchString(digest) {
+			return nil, fmt.Errorf("chart.digests.%s must be a sha256:<hex> digest", version)
+		}
+		digests[version] = digest
+	}
+	return digests, nil
+}
+
+// verifyChartDigest checks that the tag of the chart version to deploy still points to its pinned manifest digest
+// Tags can be pushed again, so a moved tag fails the render instead of silently changing deployed instances;
+// provider-helm pulls by tag, so a tag moved between the check and the pull isn't detected
+func verifyChartDigest(ctx context.Context, fetcher *valuesFetcher, mergedConfig map[string]any, log logr.Logger) error {
+	chart, _ := mergedConfig["chart"].(map[string]any)
+	digests, err := getChartDigests(chart)
+	if err != nil || digests == nil {
+		return err
+	}
+	repo, name, version, err := extractChartConfig(mergedConfig)
+	if err != nil {
+		return err
+	}
+	pinned, ok := digests[version]
+	if !ok {
+		return fmt.Errorf("chart %s version %s has no pinned digest in chart.digests", name, version)
+	}
+
+	ref := ociChartURL(repo, name, version)
+	host, repository, tag, err := parseOCIReference(ref)
+	if err != nil {
+		return err
+	}
+	manifestURL := "https://" + host + "/v2/" + repository + "/manifests/" + tag
+	manifest, err := fetcher.get(ctx, "chart-manifest|"+ref, indexRefreshInterval, func(ctx context.Context) ([]byte, error) {
+		return fetcher.fetchRegistry(ctx, manifestURL, repository, ociChartManifestType)
+	})
+	if err != nil {
+		if manifest == nil {
+			return fmt.Errorf("failed to verify digest of chart %s: %w", ref, err)
+		}
+		log.Error(err, "Failed to refresh chart manifest, verifying against the cached copy", "chart", ref)
+	}
+	sum := sha256.Sum256(manifest)
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != pinned {
+		return fmt.Errorf("chart %s is %s in the registry, but pinned to %s", ref, actual, pinned)
+	}
+	log.Info("Verified chart digest", "chart", ref, "digest", pinned)
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaultOneTimeLinkKey is the connection detail holding the retrieval link
+const defaultOneTimeLinkKey = "passwordLink"
+
+// oneTimeLinkTimeout bounds a call to the one-time secret service
+const oneTimeLinkTimeout = 10 * time.Second
+
+// oneTimeLinkClient calls the one-time secret service
+var oneTimeLinkClient = &http.Client{Timeout: oneTimeLinkTimeout}
+
+// OneTimeLinkConfig delivers the password through a one-time secret service instead of the connection Secret
+// The service receives POST {"secret": <password>, "ttl": <seconds>} and answers with the link in LinkField
+type OneTimeLinkConfig struct {
+	Endpoint string
+	// TokenFile holds a bearer token for the service, re-read on every call
+	TokenFile string
+	TTL       time.Duration
+	// Key is the connection detail the link is published under
+	Key string
+	// LinkField is the field of the service's JSON response holding the link
+	LinkField string
+}
+
+// parseOneTimeLinkConfig parses connectionSecret.oneTimeLink
+func parseOneTimeLinkConfig(linkConfig map[string]any) (*OneTimeLinkConfig, error) {
+	config := &OneTimeLinkConfig{TTL: 7 * 24 * time.Hour, Key: defaultOneTimeLinkKey, LinkField: "link"}
+
+	config.Endpoint, _ = linkConfig["endpoint"].(string)
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("oneTimeLink.endpoint must be an https URL")
+	}
+	config.TokenFile, _ = linkConfig["tokenFile"].(string)
+	if ttl, _ := linkConfig["ttl"].(string); ttl != "" {
+		config.TTL, err = time.ParseDuration(ttl)
+		if err != nil || config.TTL <= 0 {
+			return nil, fmt.Errorf("oneTimeLink.ttl must be a positive duration")
+		}
+	}
+	if key, _ := linkConfig["key"].(string); key != "" {
+		config.Key = key
+	}
+	if field, _ := linkConfig["linkField"].(string); field != "" {
+		config.LinkField = field
+	}
+	return config, nil
+}
+
+// oneTimeLinkRequest is a one-time link generateResources needs for a new or rotated password
+// The link is created by issueOneTimeLink once the render is final
+type oneTimeLinkRequest struct {
+	config   *OneTimeLinkConfig
+	password string
+}
+
+// publishedOneTimeLink returns the retrieval link of the instance password a previous render published, or requests
+// a new one on first render and whenever the password was rotated, as the previous link points at the old, likely
+// consumed, password
+// The password must be derived, since neither the connection Secret nor anything else in the cluster it could be
+// read back from is supposed to hold it
+func publishedOneTimeLink(
+	config *OneTimeLinkConfig,
+	store *SecretStore,
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	password string,
+	rotated bool,
+) (string, *oneTimeLinkRequest, error) {
+	derived := false
+	for _, source := range store.Sources {
+		if _, ok := source.(derivedSecretSource); ok {
+			derived = true
+		}
+	}
+	if !derived {
+		return "", nil, fmt.Errorf("oneTimeLink requires a %s secret source, otherwise every render generates a new password", SecretSourceDerived)
+	}
+
+	if link := observedOneTimeLink(composite, observedResources, config.Key); link != "" && !rotated {
+		return link, nil, nil
+	}
+	return "", &oneTimeLinkRequest{config: config, password: password}, nil
+}
+
+// issueOneTimeLink creates the requested one-time link and adds it to the connection details and connection Secret
+// of the response
+// Crossplane calls the function again as long as it fetches new requirements and only keeps the last response, so the
+// link is only created once all requirements of the response are fetched. Offline renders never create one, since
+// nobody would receive it
+func issueOneTimeLink(
+	ctx context.Context,
+	link *oneTimeLinkRequest,
+	req *fnv1.RunFunctionRequest,
+	resp *fnv1.RunFunctionResponse,
+	offline bool,
+	results *Results,
+	log logr.Logger,
+) error {
+	if offline {
+		results.Warning("PasswordLinkSkipped", "Offline renders don't create one-time links, connection detail %s is left out", link.config.Key)
+		return nil
+	}
+	if !requirementsFetched(req, resp.GetRequirements()) {
+		log.Info("Deferring one-time password link until all required resources are fetched")
+		return nil
+	}
+
+	url, err := createOneTimeLink(ctx, link.config, link.password)
+	if err != nil {
+		return err
+	}
+	resp.Desired.Composite.ConnectionDetails[link.config.Key] = []byte(url)
+	if secret, ok := resp.GetDesired().GetResources()["secret"]; ok {
+		object := secret.GetResource().AsMap()
+		if err := fieldpath.Pave(object).SetValue(fmt.Sprintf("data[%s]", link.config.Key), base64.StdEncoding.EncodeToString([]byte(url))); err != nil {
+			return fmt.Errorf("failed to add link to connection secret: %w", err)
+		}
+		if secret.Resource, err = structpb.NewStruct(object); err != nil {
+			return fmt.Errorf("failed to convert connection secret: %w", err)
+		}
+	}
+	log.Info("Created one-time password link", "endpoint", link.config.Endpoint, "ttl", link.config.TTL.String())
+	results.Normal("PasswordLinkCreated", "Password is available once through the link in connection detail %s, valid for %s", link.config.Key, link.config.TTL)
+	return nil
+}
+
+// observedOneTimeLink returns the link published by a previous render, if any
+// A link is only created once per instance; the service invalidates it after the first retrieval anyway
+func observedOneTimeLink(composite *fnv1.Resource, observedResources map[string]*fnv1.Resource, key string) string {
+	if secret, ok := observedResources["secret"]; ok && secret != nil {
+		encoded, _ := fieldpath.Pave(secret.GetResource().AsMap()).GetString(fmt.Sprintf("data[%s]", key))
+		if link, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(link) > 0 {
+			return string(link)
+		}
+	}
+	return string(composite.GetConnectionDetails()[key])
+}
+
+// createOneTimeLink stores the password in the one-time secret service and returns its retrieval link
+func createOneTimeLink(ctx context.Context, config *OneTimeLinkConfig, password string) (string, error) {
+	body, err := json.Marshal(map[string]any{"secret": password, "ttl": int64(config.TTL.Seconds())})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to build request for %s: %w", config.Endpoint, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if config.TokenFile != "" {
+		raw, err := os.ReadFile(config.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read one-time link token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(raw)))
+	}
+
+	resp, err := oneTimeLinkClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call %s: %w", config.Endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to call %s: unexpected status %s", config.Endpoint, resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response of %s: %w", config.Endpoint, err)
+	}
+
+	response := map[string]any{}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return "", fmt.Errorf("failed to parse response of %s: %w", config.Endpoint, err)
+	}
+	link, _ := fieldpath.Pave(response).GetString(config.LinkField)
+	if link == "" {
+		return "", fmt.Errorf("response of %s has no %s", config.Endpoint, config.LinkField)
+	}
+	return link, nil
+}
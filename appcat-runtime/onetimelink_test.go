@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPublishedOneTimeLink(t *testing.T) {
+	config := &OneTimeLinkConfig{Endpoint: "https://links.example.com", TTL: oneTimeLinkTimeout, Key: defaultOneTimeLinkKey, LinkField: "link"}
+	derived := &SecretStore{Sources: []SecretSource{derivedSecretSource{keyFile: "key", policy: defaultPasswordPolicy}}}
+
+	observedSecret, err := structpb.NewStruct(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"data": map[string]any{
+			defaultOneTimeLinkKey: base64.StdEncoding.EncodeToString([]byte("https://links.example.com/old")),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withLink := map[string]*fnv1.Resource{"secret": {Resource: observedSecret}}
+
+	cases := map[string]struct {
+		store     *SecretStore
+		observed  map[string]*fnv1.Resource
+		rotated   bool
+		want      string
+		requested bool
+		wantErr   bool
+	}{
+		"FirstRender":          {store: derived, observed: map[string]*fnv1.Resource{}, requested: true},
+		"KeepsPublishedLink":   {store: derived, observed: withLink, want: "https://links.example.com/old"},
+		"RotationRequestsLink": {store: derived, observed: withLink, rotated: true, requested: true},
+		"StoredPassword": {
+			store:   &SecretStore{Sources: []SecretSource{observedSecretSource{}, randomSecretSource{policy: defaultPasswordPolicy}}},
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			link, request, err := publishedOneTimeLink(config, tc.store, &fnv1.Resource{}, tc.observed, "new", tc.rotated)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("publishedOneTimeLink() error = %v, want error %v", err, tc.wantErr)
+			}
+			if link != tc.want {
+				t.Errorf("publishedOneTimeLink() link = %q, want %q", link, tc.want)
+			}
+			if requested := request != nil; requested != tc.requested {
+				t.Errorf("link requested = %v, want %v", requested, tc.requested)
+			}
+		})
+	}
+}
+
+func TestIssueOneTimeLink(t *testing.T) {
+	var received []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]any{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		secret, _ := body["secret"].(string)
+		received = append(received, secret)
+		_ = json.NewEncoder(w).Encode(map[string]any{"link": "https://links.example.com/" + secret})
+	}))
+	defer server.Close()
+	previousClient := oneTimeLinkClient
+	oneTimeLinkClient = server.Client()
+	defer func() { oneTimeLinkClient = previousClient }()
+
+	link := &oneTimeLinkRequest{
+		config:   &OneTimeLinkConfig{Endpoint: server.URL, TTL: oneTimeLinkTimeout, Key: defaultOneTimeLinkKey, LinkField: "link"},
+		password: "new",
+	}
+	requirements := &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+		requiredQuota: {ApiVersion: "appuio.io/v1", Kind: "OrganizationQuota"},
+	}}
+
+	cases := map[string]struct {
+		required map[string]*fnv1.Resources
+		offline  bool
+		created  bool
+	}{
+		"FinalPass":           {required: map[string]*fnv1.Resources{requiredQuota: {}}, created: true},
+		"RequirementsPending": {},
+		"Offline":             {required: map[string]*fnv1.Resources{requiredQuota: {}}, offline: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			received = nil
+			secret, err := structpb.NewStruct(map[string]any{"apiVersion": "v1", "kind": "Secret", "data": map[string]any{}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &fnv1.RunFunctionRequest{RequiredResources: tc.required}
+			resp := &fnv1.RunFunctionResponse{
+				Requirements: requirements,
+				Desired: &fnv1.State{
+					Composite: &fnv1.Resource{ConnectionDetails: map[string][]byte{}},
+					Resources: map[string]*fnv1.Resource{"secret": {Resource: secret}},
+				},
+			}
+			if err := issueOneTimeLink(context.Background(), link, req, resp, tc.offline, &Results{}, logr.Discard()); err != nil {
+				t.Fatalf("issueOneTimeLink() error = %v", err)
+			}
+			if created := len(received) > 0; created != tc.created {
+				t.Errorf("link created = %v, want %v", created, tc.created)
+			}
+
+			want := ""
+			if tc.created {
+				want = "https://links.example.com/new"
+			}
+			if got := string(resp.GetDesired().GetComposite().GetConnectionDetails()[defaultOneTimeLinkKey]); got != want {
+				t.Errorf("connection detail = %q, want %q", got, want)
+			}
+			if got := observedOneTimeLink(&fnv1.Resource{}, resp.GetDesired().GetResources(), defaultOneTimeLinkKey); got != want {
+				t.Errorf("connection secret link = %q, want %q", got, want)
+			}
+		})
+	}
+}
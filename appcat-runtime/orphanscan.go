@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// defaultOrphanScanKinds are the kinds the function generates, scanned unless --kinds is set
+var defaultOrphanScanKinds = []string{
+	"helm.m.crossplane.io/v1beta1/Release",
+	"v1/Secret",
+	"v1/ConfigMap",
+	"networking.k8s.io/v1/NetworkPolicy",
+	"policy/v1/PodDisruptionBudget",
+	"external-secrets.io/v1alpha1/PushSecret",
+	"fluentbit.fluent.io/v1alpha2/Output",
+}
+
+// orphan is a generated object whose owning composite no longer exists
+type orphan struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Instance  string
+}
+
+// runOrphanScan implements the orphan-scan subcommand: it lists objects carrying the ownership labels
+// whose composite doesn't exist anymore, e.g. left behind by a deletion that didn't complete
+// Returns the process exit code, 1 if orphans were found so it can gate cleanup jobs
+func runOrphanScan(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("orphan-scan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	composite := flags.String("composite", "", "Composite type owning the objects as <group>/<version>/<Kind> (e.g., appcat.vshn.io/v1alpha1/XVSHNRedis)")
+	kinds := flags.String("kinds", strings.Join(defaultOrphanScanKinds, ","), "Comma-separated <group>/<version>/<Kind>s to scan (core kinds as <version>/<Kind>)")
+	service := flags.String("service", "", "Only scan objects of this service (appcat.vshn.io/service label)")
+	namespace := flags.String("namespace", "", "Only scan this namespace")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: appcat-runtime orphan-scan --composite <group>/<version>/<Kind> [flags]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *composite == "" || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	compositeGVK, err := parseGVK(*composite)
+	if err != nil {
+		fmt.Fprintf(stderr, "orphan-scan: invalid --composite: %v\n", err)
+		return 2
+	}
+	var scanKinds []schema.GroupVersionKind
+	for _, kind := range strings.Split(*kinds, ",") {
+		gvk, err := parseGVK(strings.TrimSpace(kind))
+		if err != nil {
+			fmt.Fprintf(stderr, "orphan-scan: invalid --kinds: %v\n", err)
+			return 2
+		}
+		scanKinds = append(scanKinds, gvk)
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		fmt.Fprintf(stderr, "orphan-scan: failed to load kubeconfig: %v\n", err)
+		return 1
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		fmt.Fprintf(stderr, "orphan-scan: failed to create client: %v\n", err)
+		return 1
+	}
+
+	orphans, err := scanOrphans(context.Background(), c, compositeGVK, scanKinds, *service, *namespace, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "orphan-scan: %v\n", err)
+		return 1
+	}
+	if len(orphans) == 0 {
+		fmt.Fprintln(stdout, "No orphaned objects found")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tINSTANCE")
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", o.Kind, o.Namespace, o.Name, o.Instance)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "orphan-scan: %v\n", err)
+	}
+	return 1
+}
+
+// scanOrphans lists objects of the given kinds carrying the ownership labels whose composite is gone
+// Kinds whose API isn't installed are skipped with a note on stderr
+func scanOrphans(
+	ctx context.Context,
+	c client.Reader,
+	compositeGVK schema.GroupVersionKind,
+	kinds []schema.GroupVersionKind,
+	service, namespace string,
+	stderr io.Writer,
+) ([]orphan, error) {
+	selector := client.HasLabels{LabelInstance}
+	opts := []client.ListOption{selector}
+	if service != "" {
+		opts = append(opts, client.MatchingLabels{LabelService: service})
+	}
+	if namespace != "" {
+		opts = append(opts, client.InNamespace(namespace))
+	}
+
+	// Composites are looked up once per instance, most instances own several objects
+	alive := map[string]bool{}
+	var orphans []orphan
+	for _, gvk := range kinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.List(ctx, list, opts...); err != nil {
+			if meta.IsNoMatchError(err) {
+				fmt.Fprintf(stderr, "Skipping %s: API not installed\n", gvk.Kind)
+				continue
+			}
+			return nil, fmt.Errorf("failed to list %s: %w", gvk.Kind, err)
+		}
+
+		for _, obj := range list.Items {
+			instance := obj.GetLabels()[LabelInstance]
+			key := obj.GetNamespace() + "/" + instance
+			exists, checked := alive[key]
+			if !checked {
+				composite := &unstructured.Unstructured{}
+				composite.SetGroupVersionKind(compositeGVK)
+				err := c.Get(ctx, client.ObjectKey{Namespace: obj.GetNamespace(), Name: instance}, composite)
+				if client.IgnoreNotFound(err) != nil {
+					return nil, fmt.Errorf("failed to get composite %s: %w", key, err)
+				}
+				exists = err == nil
+				alive[key] = exists
+			}
+			if !exists {
+				orphans = append(orphans, orphan{Kind: gvk.Kind, Namespace: obj.GetNamespace(), Name: obj.GetName(), Instance: instance})
+			}
+		}
+	}
+
+	slices.SortFunc(orphans, func(a, b orphan) int {
+		return strings.Compare(a.Namespace+"/"+a.Instance+"/"+a.Kind+"/"+a.Name, b.Namespace+"/"+b.Instance+"/"+b.Kind+"/"+b.Name)
+	})
+	return orphans, nil
+}
+
+// parseGVK parses <group>/<version>/<Kind>, or <version>/<Kind> for the core group
+func parseGVK(value string) (schema.GroupVersionKind, error) {
+	idx := strings.LastIndex(value, "/")
+	if idx <= 0 || idx == len(value)-1 {
+		return schema.GroupVersionKind{}, fmt.Errorf("%q is not <group>/<version>/<Kind>", value)
+	}
+	gv, err := schema.ParseGroupVersion(value[:idx])
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("%q: %w", value, err)
+	}
+	return gv.WithKind(value[idx+1:]), nil
+}
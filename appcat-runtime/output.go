@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	helmv1 "github.com/crossplane-contrib/provider-helm/apis/namespaced/release/v1beta1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// Output modes, the controllers deploying the rendered charts
+const (
+	OutputModeProviderHelm = "provider-helm"
+	OutputModeFlux         = "flux"
+	OutputModeArgoCD       = "argocd"
+)
+
+// defaultOutputInterval is how often GitOps controllers reconcile the releases they were handed
+const defaultOutputInterval = 10 * time.Minute
+
+// objectManifestPath is where provider-kubernetes Objects keep the manifest they wrap
+const objectManifestPath = "spec.forProvider.manifest"
+
+// OutputConfig selects what the function emits for a release
+type OutputConfig struct {
+	Mode string
+	// Interval is how often Flux reconciles the release
+	Interval time.Duration
+	// ArgoCD configures the Applications of the argocd mode
+	ArgoCD *ArgoCDOutput
+}
+
+// getOutputConfig extracts output configuration from merged config, e.g. {mode: flux, interval: 5m}
+// Returns nil without error if the charts are deployed with provider-helm Releases
+func getOutputConfig(mergedConfig map[string]any) (*OutputConfig, error) {
+	output, ok := mergedConfig["output"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &OutputConfig{Interval: defaultOutputInterval}
+	config.Mode, _ = output["mode"].(string)
+	switch config.Mode {
+	case OutputModeProviderHelm:
+		return nil, nil
+	case OutputModeFlux:
+	case OutputModeArgoCD:
+		argocd, err := getArgoCDOutput(output)
+		if err != nil {
+			return nil, err
+		}
+		config.ArgoCD = argocd
+	default:
+		return nil, fmt.Errorf("unknown output mode %q", config.Mode)
+	}
+	if raw, ok := output["interval"]; ok {
+		interval, _ := raw.(string)
+		duration, err := time.ParseDuration(interval)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("interval must be a positive duration, e.g. 5m")
+		}
+		config.Interval = duration
+	}
+	return config, nil
+}
+
+// releaseOutputResources returns the desired resources deploying a Release in the output mode, by resource key
+// The Release is kept for provider-helm; other modes translate it, adding their resources next to the key
+func releaseOutputResources(key string, release *helmv1.Release, output *OutputConfig) (map[string]*fnv1.Resource, error) {
+	if output == nil {
+		resource, err := toFunctionResource(release)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert helm release: %w", err)
+		}
+		return map[string]*fnv1.Resource{key: resource}, nil
+	}
+	if output.Mode == OutputModeArgoCD {
+		return argoCDReleaseResources(key, release, output.ArgoCD)
+	}
+	return fluxReleaseResources(key, release, output)
+}
+
+// wrapOutputResource wraps a resource of a release, e.g. its values ConfigMap, into an Object applied next to
+// the release by the GitOps controller's cluster
+// Resources are kept as they are for provider-helm
+func wrapOutputResource(resource *fnv1.Resource, release *helmv1.Release, output *OutputConfig) (*fnv1.Resource, error) {
+	if output == nil {
+		return resource, nil
+	}
+	manifest := resource.GetResource().AsMap()
+	name, _ := fieldpath.Pave(manifest).GetString("metadata.name")
+	return toFunctionResource(newReleaseObject(name, manifest, release).Build())
+}
+
+// newReleaseObject wraps a manifest of a release into a namespaced Object next to the Release, applied to the
+// cluster of the release's placement with the release's labels
+func newReleaseObject(name string, manifest map[string]any, release *helmv1.Release) *ObjectBuilder {
+	builder := NewObjectBuilder(name, manifest).
+		WithNamespace(release.Namespace).
+		WithReadiness("DeriveFromObject")
+	// provider-kubernetes configs are named like the provider-helm configs of the same cluster
+	if ref := release.Spec.ProviderConfigReference; ref != nil {
+		builder = builder.WithProviderConfigRef(ref.Kind, ref.Name)
+	}
+	for key, value := range release.Labels {
+		builder = builder.WithLabel(key, value)
+	}
+	return builder
+}
+
+// observedPath returns a path of an observed resource a release output emitted, looking into the manifest of
+// Objects, e.g. data[values.yaml] of a wrapped values ConfigMap
+func observedPath(resource *fnv1.Resource, path string) string {
+	if kind, _ := resource.GetResource().AsMap()["kind"].(string); kind == "Object" {
+		return objectManifestPath + "." + path
+	}
+	return path
+}
+
+// releaseManifestKind returns the kind of an observed release, looking into the manifest of Objects
+func releaseManifestKind(release *fnv1.Resource) string {
+	kind, _ := fieldpath.Pave(release.GetResource().AsMap()).GetString(observedPath(release, "kind"))
+	return kind
+}
+
+// releaseValuesPath returns the path of the inline helm values of an observed release of any output mode
+func releaseValuesPath(release *fnv1.Resource) string {
+	switch releaseManifestKind(release) {
+	case fluxHelmReleaseKind:
+		return observedPath(release, "spec.values")
+	case argoCDApplicationKind:
+		return observedPath(release, "spec.source.helm.valuesObject")
+	}
+	return "spec.forProvider.values"
+}
+
+// releaseChartVersionPath returns the path of the chart version of an observed release of any output mode
+func releaseChartVersionPath(release *fnv1.Resource) string {
+	switch releaseManifestKind(release) {
+	case fluxHelmReleaseKind:
+		return observedPath(release, "spec.chart.spec.version")
+	case argoCDApplicationKind:
+		return observedPath(release, "spec.source.targetRevision")
+	}
+	return "spec.forProvider.chart.version"
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// LabelInstance names the composite owning a generated object, in the object's namespace
+// Together with LabelService and the claim labels it forms the ownership label set on every generated object;
+// select all objects of an instance with -l appcat.vshn.io/instance=<composite>
+const LabelInstance = "appcat.vshn.io/instance"
+
+// ownershipLabels returns the ownership label set of a composite's generated objects
+func ownershipLabels(composite *fnv1.Resource, mergedConfig map[string]any) (map[string]string, error) {
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	compositeName, err := paved.GetString("metadata.name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get composite name: %w", err)
+	}
+	compositeNamespace, _ := paved.GetString("metadata.namespace")
+	claim := getClaimReference(composite, compositeName, compositeNamespace)
+
+	labels := map[string]string{
+		LabelInstance:       compositeName,
+		LabelClaimName:      claim.Name,
+		LabelClaimNamespace: claim.Namespace,
+	}
+	if service, _ := mergedConfig["service"].(string); service != "" {
+		labels[LabelService] = service
+	}
+	return labels, nil
+}
+
+// setResourceLabels sets labels on all resources, keeping values a builder already set
+func setResourceLabels(resources map[string]*fnv1.Resource, labels map[string]string) error {
+	for name, res := range resources {
+		paved := fieldpath.Pave(res.Resource.AsMap())
+
+		for key, value := range labels {
+			path := fmt.Sprintf("metadata.labels[%s]", key)
+			if _, err := paved.GetValue(path); err == nil {
+				continue
+			}
+			if err := paved.SetValue(path, value); err != nil {
+				return fmt.Errorf("failed to set label %s on %s: %w", key, name, err)
+			}
+		}
+
+		updated, err := structpb.NewStruct(paved.UnstructuredContent())
+		if err != nil {
+			return fmt.Errorf("failed to convert %s to structpb: %w", name, err)
+		}
+		res.Resource = updated
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+)
+
+// Character classes generated passwords are composed of
+const (
+	PasswordClassLowercase = "lowercase"
+	PasswordClassUppercase = "uppercase"
+	PasswordClassDigits    = "digits"
+	PasswordClassSymbols   = "symbols"
+)
+
+// passwordClassCharacters are the characters of each class, symbols come from the policy
+var passwordClassCharacters = map[string]string{
+	PasswordClassLowercase: "abcdefghijklmnopqrstuvwxyz",
+	PasswordClassUppercase: "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	PasswordClassDigits:    "0123456789",
+}
+
+// defaultPasswordSymbols are URL- and shell-safe, so the default alphabet is base64url's
+const defaultPasswordSymbols = "-_"
+
+// ambiguousPasswordCharacters are easily confused when passwords are read or typed
+const ambiguousPasswordCharacters = "0O1lI|"
+
+// PasswordPolicy defines how random passwords are generated
+type PasswordPolicy struct {
+	Length int
+	// Classes maps each enabled class to its characters
+	Classes map[string]string
+	// MinPerClass is the minimal number of characters of a class
+	MinPerClass map[string]int
+}
+
+// defaultPasswordPolicy generates passwords from the base64url alphabet
+var defaultPasswordPolicy = PasswordPolicy{
+	Length: defaultPasswordLength,
+	Classes: map[string]string{
+		PasswordClassLowercase: passwordClassCharacters[PasswordClassLowercase],
+		PasswordClassUppercase: passwordClassCharacters[PasswordClassUppercase],
+		PasswordClassDigits:    passwordClassCharacters[PasswordClassDigits],
+		PasswordClassSymbols:   defaultPasswordSymbols,
+	},
+}
+
+// newPasswordPolicy creates a policy from a random source's config, e.g.
+// {length: 24, classes: [lowercase, uppercase, digits, symbols], symbols: "!#%+", excludeAmbiguous: true, minPerClass: {digits: 2}}
+func newPasswordPolicy(config map[string]any) (PasswordPolicy, error) {
+	policy := PasswordPolicy{Length: defaultPasswordLength, Classes: maps.Clone(defaultPasswordPolicy.Classes), MinPerClass: map[string]int{}}
+	paved := fieldpath.Pave(config)
+	if lengthRaw, ok := lookupNumber(paved, "length"); ok {
+		policy.Length = int(lengthRaw)
+		if float64(policy.Length) != lengthRaw || policy.Length < 8 || policy.Length > 128 {
+			return PasswordPolicy{}, fmt.Errorf("length must be an integer between 8 and 128")
+		}
+	}
+
+	symbols := defaultPasswordSymbols
+	if symbolsRaw, ok := config["symbols"].(string); ok {
+		for _, c := range symbolsRaw {
+			if c <= ' ' || c > '~' || strings.ContainsRune(passwordClassCharacters[PasswordClassLowercase]+passwordClassCharacters[PasswordClassUppercase]+passwordClassCharacters[PasswordClassDigits], c) {
+				return PasswordPolicy{}, fmt.Errorf("symbols must be printable ASCII characters other than letters and digits")
+			}
+		}
+		symbols = symbolsRaw
+	}
+	if classesRaw, ok := config["classes"].([]any); ok {
+		policy.Classes = map[string]string{}
+		for i, classRaw := range classesRaw {
+			class, _ := classRaw.(string)
+			switch class {
+			case PasswordClassLowercase, PasswordClassUppercase, PasswordClassDigits:
+				policy.Classes[class] = passwordClassCharacters[class]
+			case PasswordClassSymbols:
+				policy.Classes[class] = symbols
+			default:
+				return PasswordPolicy{}, fmt.Errorf("classes[%d]: unknown class %q", i, class)
+			}
+		}
+	} else {
+		policy.Classes[PasswordClassSymbols] = symbols
+	}
+
+	if excludeAmbiguous, _ := config["excludeAmbiguous"].(bool); excludeAmbiguous {
+		for class, characters := range policy.Classes {
+			policy.Classes[class] = strings.Map(func(c rune) rune {
+				if strings.ContainsRune(ambiguousPasswordCharacters, c) {
+					return -1
+				}
+				return c
+			}, characters)
+		}
+	}
+	for class, characters := range policy.Classes {
+		if characters == "" {
+			delete(policy.Classes, class)
+		}
+	}
+	if len(policy.Classes) == 0 {
+		return PasswordPolicy{}, fmt.Errorf("at least one character class is required")
+	}
+
+	minPerClass, _ := config["minPerClass"].(map[string]any)
+	total := 0
+	for class := range minPerClass {
+		if _, ok := policy.Classes[class]; !ok {
+			return PasswordPolicy{}, fmt.Errorf("minPerClass.%s requires the class to be enabled", class)
+		}
+		count, ok := lookupNumber(fieldpath.Pave(minPerClass), class)
+		if !ok || count < 0 || float64(int(count)) != count {
+			return PasswordPolicy{}, fmt.Errorf("minPerClass.%s must be a non-negative integer", class)
+		}
+		policy.MinPerClass[class] = int(count)
+		total += int(count)
+	}
+	if total > policy.Length {
+		return PasswordPolicy{}, fmt.Errorf("minPerClass requires %d characters, more than the length of %d", total, policy.Length)
+	}
+	return policy, nil
+}
+
+// generateRandomPassword generates a random password following the policy
+// identity names what the password is for, e.g. namespace/instance/password, keying seeded sources
+func generateRandomPassword(policy PasswordPolicy, identity string) (string, error) {
+	return generatePassword(policy, passwordRandomSource(identity))
+}
+
+// generatePassword draws a password following the policy from source
+// Every character is drawn uniformly: first the minimal characters of each class, then the rest from all
+// enabled classes, shuffled so the required characters don't sit at fixed positions
+func generatePassword(policy PasswordPolicy, source io.Reader) (string, error) {
+	password := make([]byte, 0, policy.Length)
+	all := ""
+	for _, class := range slices.Sorted(maps.Keys(policy.Classes)) {
+		characters := policy.Classes[class]
+		all += characters
+		for range policy.MinPerClass[class] {
+			c, err := randomCharacter(source, characters)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+	for len(password) < policy.Length {
+		c, err := randomCharacter(source, all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(source, i+1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// randomCharacter draws a character of the set uniformly
+func randomCharacter(source io.Reader, characters string) (byte, error) {
+	i, err := randomIndex(source, len(characters))
+	if err != nil {
+		return 0, err
+	}
+	return characters[i], nil
+}
+
+// randomIndex draws an integer in [0, n) uniformly for n <= 256, rejecting bytes that would bias it
+func randomIndex(source io.Reader, n int) (int, error) {
+	limit := 256 - 256%n
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(source, b); err != nil {
+			return 0, fmt.Errorf("failed to generate password: %w", err)
+		}
+		if int(b[0]) < limit {
+			return int(b[0]) % n, nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestGenerateRandomPasswordSeeded(t *testing.T) {
+	previousSeed := randomSeed
+	seedRandomSource("golden")
+	defer func() { randomSeed = previousSeed }()
+
+	generate := func(identity string) string {
+		password, err := generateRandomPassword(defaultPasswordPolicy, identity)
+		if err != nil {
+			t.Fatalf("generateRandomPassword() error = %v", err)
+		}
+		return password
+	}
+	first := generate("default/redis-a/password")
+
+	// Concurrent renders of other instances neither race nor shift the instance's password
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = generateRandomPassword(defaultPasswordPolicy, "default/redis-b/password")
+		}()
+	}
+	wg.Wait()
+
+	cases := map[string]struct {
+		identity string
+		same     bool
+	}{
+		"SameIdentity":   {identity: "default/redis-a/password", same: true},
+		"OtherInstance":  {identity: "default/redis-b/password"},
+		"OtherNamespace": {identity: "other/redis-a/password"},
+		"OtherPurpose":   {identity: "default/redis-a/backup-repository"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if same := generate(tc.identity) == first; same != tc.same {
+				t.Errorf("password equal to default/redis-a/password = %v, want %v", same, tc.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PatchPolicy restricts which fields of generated resources spec.patches may change
+type PatchPolicy struct {
+	// AllowedPaths maps desired resource keys to JSON pointer prefixes; "*" matches any single segment
+	AllowedPaths map[string][]string
+}
+
+// UserPatch is a single JSON6902 operation from spec.patches against a generated resource
+type UserPatch struct {
+	Resource string
+	Op       string
+	Path     string
+	Value    any
+}
+
+// getPatchPolicy extracts the patches policy from service config
+// Returns nil without error if the service doesn't allow user patches
+func getPatchPolicy(serviceConfig map[string]any) (*PatchPolicy, error) {
+	patches, ok := serviceConfig["patches"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	allowedRaw, _ := patches["allowedPaths"].(map[string]any)
+	policy := &PatchPolicy{AllowedPaths: make(map[string][]string, len(allowedRaw))}
+	for resource, pathsRaw := range allowedRaw {
+		paths, ok := pathsRaw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("allowedPaths.%s must be a list", resource)
+		}
+		for i, pathRaw := range paths {
+			path, ok := pathRaw.(string)
+			if !ok || !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("allowedPaths.%s[%d] must be a JSON pointer", resource, i)
+			}
+			policy.AllowedPaths[resource] = append(policy.AllowedPaths[resource], path)
+		}
+	}
+	return policy, nil
+}
+
+// getUserPatches extracts spec.patches and validates every operation against the policy
+// Only add, replace and remove are supported, since move and copy would read from outside the allowlist
+func getUserPatches(userSpec map[string]any, policy *PatchPolicy) ([]UserPatch, error) {
+	patchesRaw, ok := userSpec["patches"].([]any)
+	if !ok || len(patchesRaw) == 0 {
+		return nil, nil
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("this service doesn't support spec.patches")
+	}
+
+	patches := make([]UserPatch, 0, len(patchesRaw))
+	for i, patchRaw := range patchesRaw {
+		patchMap, ok := patchRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("patches[%d] is not a map", i)
+		}
+		patch := UserPatch{}
+		patch.Resource, _ = patchMap["resource"].(string)
+		patch.Op, _ = patchMap["op"].(string)
+		patch.Path, _ = patchMap["path"].(string)
+		value, hasValue := patchMap["value"]
+		patch.Value = value
+
+		switch patch.Op {
+		case "add", "replace":
+			if !hasValue {
+				return nil, fmt.Errorf("patches[%d]: %s requires a value", i, patch.Op)
+			}
+		case "remove":
+			if hasValue {
+				return nil, fmt.Errorf("patches[%d]: remove doesn't take a value", i)
+			}
+		default:
+			return nil, fmt.Errorf("patches[%d]: unsupported op %q, use add, replace or remove", i, patch.Op)
+		}
+		if !isPatchPathAllowed(patch.Path, policy.AllowedPaths[patch.Resource]) {
+			return nil, fmt.Errorf("patches[%d]: %s of %s is not allowed", i, patch.Path, patch.Resource)
+		}
+		patches = append(patches, patch)
+	}
+	return patches, nil
+}
+
+// isPatchPathAllowed reports whether a JSON pointer lies at or below one of the allowed prefixes
+func isPatchPathAllowed(path string, allowed []string) bool {
+	if !strings.HasPrefix(path, "/") {
+		return false
+	}
+	segments := strings.Split(path[1:], "/")
+	for _, prefix := range allowed {
+		prefixSegments := strings.Split(prefix[1:], "/")
+		if len(segments) < len(prefixSegments) {
+			continue
+		}
+		matched := true
+		for i, segment := range prefixSegments {
+			if segment != "*" && segment != segments[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUserPatches applies spec.patches to the generated resources
+// Every applied patch is reported as a warning, since it bypasses the service's own spec fields
+func applyUserPatches(resources map[string]*fnv1.Resource, patches []UserPatch, results *Results, log logr.Logger) error {
+	for i, patch := range patches {
+		desired, ok := resources[patch.Resource]
+		if !ok {
+			return fmt.Errorf("patches[%d]: resource %s is not generated", i, patch.Resource)
+		}
+
+		operation := map[string]any{"op": patch.Op, "path": patch.Path}
+		if patch.Op != "remove" {
+			operation["value"] = patch.Value
+		}
+		operationJSON, err := json.Marshal([]any{operation})
+		if err != nil {
+			return fmt.Errorf("patches[%d]: %w", i, err)
+		}
+		decoded, err := jsonpatch.DecodePatch(operationJSON)
+		if err != nil {
+			return fmt.Errorf("patches[%d]: %w", i, err)
+		}
+
+		resourceJSON, err := desired.GetResource().MarshalJSON()
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", patch.Resource, err)
+		}
+		options := jsonpatch.NewApplyOptions()
+		options.EnsurePathExistsOnAdd = true
+		patchedJSON, err := decoded.ApplyWithOptions(resourceJSON, options)
+		if err != nil {
+			return fmt.Errorf("patches[%d]: failed to %s %s of %s: %w", i, patch.Op, patch.Path, patch.Resource, err)
+		}
+
+		patched := &structpb.Struct{}
+		if err := patched.UnmarshalJSON(patchedJSON); err != nil {
+			return fmt.Errorf("failed to unmarshal patched %s: %w", patch.Resource, err)
+		}
+		desired.Resource = patched
+
+		log.Info("Applied user patch", "resource", patch.Resource, "op", patch.Op, "path", patch.Path)
+		results.Warning("UserPatchApplied", "Applied spec.patches[%d]: %s %s of %s", i, patch.Op, patch.Path, patch.Resource)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestIsPatchPathAllowed(t *testing.T) {
+	allowed := []string{"/spec/forProvider/values/master/podAnnotations", "/metadata/labels", "/spec/template/spec/containers/*/resources"}
+	cases := map[string]struct {
+		path string
+		want bool
+	}{
+		"Prefix":            {path: "/metadata/labels", want: true},
+		"BelowPrefix":       {path: "/spec/forProvider/values/master/podAnnotations/team", want: true},
+		"EscapedKey":        {path: "/metadata/labels/team.io~1name", want: true},
+		"WildcardSegment":   {path: "/spec/template/spec/containers/0/resources/limits", want: true},
+		"ParentOfPrefix":    {path: "/spec/forProvider/values/master", want: false},
+		"Sibling":           {path: "/metadata/annotations", want: false},
+		"SharedStringStart": {path: "/metadata/labelsExtra", want: false},
+		"WildcardMismatch":  {path: "/spec/template/spec/initContainers/0/resources", want: false},
+		"Root":              {path: "/", want: false},
+		"NotAPointer":       {path: "metadata/labels", want: false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := isPatchPathAllowed(tc.path, allowed); got != tc.want {
+				t.Errorf("isPatchPathAllowed(%q) = %v, want %v", tc.path, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("NothingAllowed", func(t *testing.T) {
+		if isPatchPathAllowed("/metadata/labels", nil) {
+			t.Errorf("isPatchPathAllowed() without allowed paths = true, want false")
+		}
+	})
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is a single step in a value path: either a map key or a list index
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+	// isAppend marks a "[-]" segment, which appends a new list element
+	isAppend bool
+}
+
+// String renders the segment the way it appears in a path
+func (s pathSegment) String() string {
+	switch {
+	case s.isAppend:
+		return "[-]"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	default:
+		return escapeKey(s.key)
+	}
+}
+
+// escapeKey renders a map key so parsePath reads it back as one key
+// Keys with dots are bracketed, e.g. "[prometheus.io/scrape]"; keys that can't be bracketed are backslash-escaped
+func escapeKey(key string) string {
+	if !strings.ContainsAny(key, ".[]\\") {
+		return key
+	}
+	if !strings.ContainsRune(key, ']') {
+		return "[" + key + "]"
+	}
+	var escaped strings.Builder
+	for _, c := range key {
+		if strings.ContainsRune(".[]\\", c) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
+
+// parsePath splits a value path into segments
+// Supports dot-separated keys, list indices and appends, e.g. "master.extraEnvVars[0].value" or "tolerations[-]"
+// Keys containing dots are bracketed or escaped with a backslash, e.g. "podAnnotations[prometheus.io/scrape]" or
+// "podAnnotations.prometheus\\.io/scrape"
+func parsePath(path string) ([]pathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var segments []pathSegment
+	var key strings.Builder
+	// expectKey is true at the start of the path and after a dot, where a key must follow
+	expectKey := true
+
+	flushKey := func() error {
+		if key.Len() == 0 {
+			if expectKey {
+				return fmt.Errorf("path %s: empty key", path)
+			}
+			return nil
+		}
+		segments = append(segments, pathSegment{key: key.String()})
+		key.Reset()
+		expectKey = false
+		return nil
+	}
+
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '.':
+			if err := flushKey(); err != nil {
+				return nil, err
+			}
+			expectKey = true
+		case '\\':
+			if key.Len() == 0 && !expectKey {
+				return nil, fmt.Errorf("path %s: key after ] must follow a dot", path)
+			}
+			if i+1 == len(path) {
+				return nil, fmt.Errorf("path %s: trailing backslash", path)
+			}
+			i++
+			key.WriteByte(path[i])
+		case '[':
+			// A bracket directly after a dot holds the key, e.g. "annotations.[prometheus.io/scrape]"
+			if key.Len() > 0 || !expectKey {
+				if err := flushKey(); err != nil {
+					return nil, err
+				}
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %s: unterminated index", path)
+			}
+			inner := path[i+1 : i+end]
+			i += end
+
+			if inner == "-" {
+				segments = append(segments, pathSegment{isIndex: true, isAppend: true})
+				expectKey = false
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			switch {
+			case err == nil && index >= 0:
+				segments = append(segments, pathSegment{isIndex: true, index: index})
+			case err == nil, inner == "":
+				return nil, fmt.Errorf("path %s: invalid index [%s]", path, inner)
+			default:
+				// Anything but an index is a key, so keys with dots can be addressed
+				segments = append(segments, pathSegment{key: inner})
+			}
+			expectKey = false
+		default:
+			// A key can't directly follow a bracket, e.g. "a[0]b" is a typo of "a[0].b"
+			if key.Len() == 0 && !expectKey {
+				return nil, fmt.Errorf("path %s: key after ] must follow a dot", path)
+			}
+			key.WriteByte(c)
+		}
+	}
+	if err := flushKey(); err != nil {
+		return nil, err
+	}
+
+	if segments[0].isIndex {
+		return nil, fmt.Errorf("path %s: must start with a key", path)
+	}
+	return segments, nil
+}
+
+// splitKeyPath splits a path of map keys, such as an allowlisted helm value prefix, into its keys
+func splitKeyPath(path string) ([]string, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg.isIndex {
+			return nil, fmt.Errorf("path %s: list index %s where a key is expected", path, seg)
+		}
+		keys = append(keys, seg.key)
+	}
+	return keys, nil
+}
+
+// joinKeyPath renders map keys as a path splitKeyPath reads back, e.g. "podAnnotations[prometheus.io/scrape]"
+func joinKeyPath(keys []string) string {
+	var path strings.Builder
+	for i, key := range keys {
+		escaped := escapeKey(key)
+		if i > 0 && !strings.HasPrefix(escaped, "[") {
+			path.WriteByte('.')
+		}
+		path.WriteString(escaped)
+	}
+	return path.String()
+}
+
+// getValueByPath retrieves a value from a nested map using a dot-separated path
+// A leading "spec" segment is skipped since data is the spec itself
+// Example: "spec.size.cpu" -> userSpec["size"]["cpu"], "spec.users[0].name" -> userSpec["users"][0]["name"]
+func getValueByPath(data map[string]any, path string) (any, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if segments[0].key == "spec" {
+		segments = segments[1:]
+	}
+	return lookupSegments(data, segments, path)
+}
+
+// lookupValueByPath retrieves a value from a nested map using a dot-separated path taken literally
+// Used for helm value paths, where a leading "spec" key is an ordinary key
+func lookupValueByPath(data map[string]any, path string) (any, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return lookupSegments(data, segments, path)
+}
+
+// lookupSegments walks segments below data and returns the value at the end
+func lookupSegments(data map[string]any, segments []pathSegment, path string) (any, error) {
+	current := any(data)
+	for _, seg := range segments {
+		switch {
+		case seg.isAppend:
+			return nil, fmt.Errorf("path %s: append segment [-] is not readable", path)
+		case seg.isIndex:
+			list, ok := current.([]any)
+			if !ok {
+				return nil, fmt.Errorf("path %s: expected list at %s, got %T", path, seg, current)
+			}
+			if seg.index >= len(list) {
+				return nil, fmt.Errorf("path %s: index %s out of range", path, seg)
+			}
+			current = list[seg.index]
+		default:
+			m, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("path %s: expected map at part %s, got %T", path, seg, current)
+			}
+			value, ok := m[seg.key]
+			if !ok {
+				return nil, fmt.Errorf("path %s: key %s not found", path, seg)
+			}
+			current = value
+		}
+	}
+
+	return current, nil
+}
+
+// setValueByPath sets a value in a nested map using a dot-separated path
+// Creates intermediate maps and lists if they don't exist
+// Example: "master.resources.requests.cpu" with value "1000m", "master.extraEnvVars[-]" appends
+func setValueByPath(data map[string]any, path string, value any) error {
+	return updateValueByPath(data, path, func(any) any { return value })
+}
+
+// mergeValueByPath merges a value into a nested map using a dot-separated path
+// Maps are deep-merged into the existing value, lists follow the policy, scalars are overridden
+func mergeValueByPath(data map[string]any, path string, value any, lists ListMergePolicy) error {
+	// Rules address lists by keys only, so values below list elements get the default strategy
+	keys, err := splitKeyPath(path)
+	if err != nil {
+		keys, lists = nil, ListMergePolicy{Default: lists.Default}
+	}
+	return updateValueByPath(data, path, func(existing any) any {
+		return mergeValues(existing, value, lists, keys)
+	})
+}
+
+// updateValueByPath replaces the value at path with update(existing)
+// existing is nil when the path doesn't exist yet
+func updateValueByPath(data map[string]any, path string, update func(existing any) any) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	_, err = updateSegments(data, segments, path, update)
+	return err
+}
+
+// updateSegments walks segments below current, creating containers as needed, and applies update at the end
+// Returns the (possibly new) container so list growth can be written back to the parent
+func updateSegments(current any, segments []pathSegment, path string, update func(existing any) any) (any, error) {
+	seg := segments[0]
+	rest := segments[1:]
+
+	apply := func(existing any) (any, error) {
+		if len(rest) == 0 {
+			return update(existing), nil
+		}
+		return updateSegments(existing, rest, path, update)
+	}
+
+	if !seg.isIndex {
+		if current == nil {
+			current = make(map[string]any)
+		}
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("path %s: expected map at part %s, got %T", path, seg, current)
+		}
+		value, err := apply(m[seg.key])
+		if err != nil {
+			return nil, err
+		}
+		m[seg.key] = value
+		return m, nil
+	}
+
+	if current == nil {
+		current = []any{}
+	}
+	list, ok := current.([]any)
+	if !ok {
+		return nil, fmt.Errorf("path %s: expected list at %s, got %T", path, seg, current)
+	}
+
+	index := seg.index
+	if seg.isAppend {
+		index = len(list)
+	}
+	switch {
+	case index == len(list):
+		list = append(list, nil)
+	case index > len(list):
+		return nil, fmt.Errorf("path %s: index %s out of range (length %d)", path, seg, len(list))
+	}
+
+	value, err := apply(list[index])
+	if err != nil {
+		return nil, err
+	}
+	list[index] = value
+	return list, nil
+}
+
+// deleteValueByPath removes the key at the end of a dot-separated path taken literally
+// Maps left empty by the removal are removed too; missing paths are ignored
+// List elements can't be deleted since that would shift indices
+func deleteValueByPath(data map[string]any, path string) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if last := segments[len(segments)-1]; last.isIndex {
+		return fmt.Errorf("path %s: cannot delete list element %s", path, last)
+	}
+	deleteSegments(data, segments)
+	return nil
+}
+
+// deleteSegments removes the key at the end of segments below current
+// Returns true if current is a map that is empty afterwards
+func deleteSegments(current any, segments []pathSegment) bool {
+	seg := segments[0]
+	switch {
+	case seg.isAppend:
+		return false
+	case seg.isIndex:
+		list, ok := current.([]any)
+		if !ok || seg.index >= len(list) {
+			return false
+		}
+		deleteSegments(list[seg.index], segments[1:])
+		return false
+	}
+
+	m, ok := current.(map[string]any)
+	if !ok {
+		return false
+	}
+	if len(segments) == 1 {
+		delete(m, seg.key)
+	} else if deleteSegments(m[seg.key], segments[1:]) {
+		delete(m, seg.key)
+	}
+	return len(m) == 0
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := map[string]struct {
+		path    string
+		want    []pathSegment
+		wantErr bool
+	}{
+		"Keys": {
+			path: "master.persistence.size",
+			want: []pathSegment{{key: "master"}, {key: "persistence"}, {key: "size"}},
+		},
+		"Index": {
+			path: "master.extraEnvVars[0].value",
+			want: []pathSegment{{key: "master"}, {key: "extraEnvVars"}, {isIndex: true, index: 0}, {key: "value"}},
+		},
+		"NestedIndices": {
+			path: "matrix[1][2]",
+			want: []pathSegment{{key: "matrix"}, {isIndex: true, index: 1}, {isIndex: true, index: 2}},
+		},
+		"Append": {
+			path: "tolerations[-]",
+			want: []pathSegment{{key: "tolerations"}, {isIndex: true, isAppend: true}},
+		},
+		"BracketedKey": {
+			path: "podAnnotations[prometheus.io/scrape]",
+			want: []pathSegment{{key: "podAnnotations"}, {key: "prometheus.io/scrape"}},
+		},
+		"BracketedKeyAfterDot": {
+			path: "podAnnotations.[prometheus.io/scrape]",
+			want: []pathSegment{{key: "podAnnotations"}, {key: "prometheus.io/scrape"}},
+		},
+		"EscapedDot": {
+			path: "podAnnotations.prometheus\\.io/scrape",
+			want: []pathSegment{{key: "podAnnotations"}, {key: "prometheus.io/scrape"}},
+		},
+		"EscapedBracket": {
+			path: "labels.a\\]b",
+			want: []pathSegment{{key: "labels"}, {key: "a]b"}},
+		},
+		"TrailingBackslash": {path: "labels.a\\", wantErr: true},
+		"EmptyPath":         {path: "", wantErr: true},
+		"EmptyKey":          {path: "master..size", wantErr: true},
+		"TrailingDot":       {path: "master.", wantErr: true},
+		"NegativeIndex":     {path: "users[-1]", wantErr: true},
+		"EmptyIndex":        {path: "users[]", wantErr: true},
+		"UnterminatedIdx":   {path: "users[0", wantErr: true},
+		"LeadingIndex":      {path: "[0].name", wantErr: true},
+		"KeyAfterIndex":     {path: "users[0]name", wantErr: true},
+		"EscapeAfterIndex":  {path: "users[0]\\.name", wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parsePath(tc.path)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parsePath(%q) error = %v, want error %v", tc.path, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parsePath(%q) = %v, want %v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJoinKeyPath(t *testing.T) {
+	cases := map[string]struct {
+		keys []string
+		want string
+	}{
+		"Plain":       {keys: []string{"master", "count"}, want: "master.count"},
+		"Dotted":      {keys: []string{"podAnnotations", "prometheus.io/scrape"}, want: "podAnnotations[prometheus.io/scrape]"},
+		"Bracket":     {keys: []string{"labels", "a]b"}, want: "labels.a\\]b"},
+		"DottedFirst": {keys: []string{"a.b", "c"}, want: "[a.b].c"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := joinKeyPath(tc.keys)
+			if got != tc.want {
+				t.Errorf("joinKeyPath(%q) = %q, want %q", tc.keys, got, tc.want)
+			}
+			keys, err := splitKeyPath(got)
+			if err != nil {
+				t.Fatalf("splitKeyPath(%q) error = %v", got, err)
+			}
+			if !reflect.DeepEqual(keys, tc.keys) {
+				t.Errorf("splitKeyPath(%q) = %q, want %q", got, keys, tc.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// HighAvailabilityConfig controls PodDisruptionBudgets for multi-replica instances
+type HighAvailabilityConfig struct {
+	// ReplicasPath is the helm value path holding the replica count (e.g. "master.count")
+	ReplicasPath string
+	// Selector selects the chart's workload pods; defaults to the Helm release instance label
+	Selector map[string]string
+	// MinAvailable is the number or percentage of pods that must stay available
+	MinAvailable intstr.IntOrString
+}
+
+// getHighAvailabilityConfig extracts highAvailability configuration from merged config
+// Returns nil without error if no highAvailability is configured
+func getHighAvailabilityConfig(mergedConfig map[string]any) (*HighAvailabilityConfig, error) {
+	haConfig, ok := mergedConfig["highAvailability"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	replicasPath, _ := haConfig["replicasPath"].(string)
+	if replicasPath == "" {
+		return nil, fmt.Errorf("replicasPath is required")
+	}
+
+	selector := map[string]string{}
+	if selectorRaw, ok := haConfig["selector"].(map[string]any); ok {
+		for key, valueRaw := range selectorRaw {
+			value, ok := valueRaw.(string)
+			if !ok {
+				return nil, fmt.Errorf("selector %s must be a string", key)
+			}
+			selector[key] = value
+		}
+	}
+
+	minAvailable := intstr.FromInt32(1)
+	switch v := haConfig["minAvailable"].(type) {
+	case nil:
+	case string:
+		minAvailable = intstr.FromString(v)
+	default:
+		number, ok := toNumber(v)
+		if !ok {
+			return nil, fmt.Errorf("minAvailable must be a number or percentage string, got %T", v)
+		}
+		minAvailable = intstr.FromInt32(int32(number))
+	}
+
+	return &HighAvailabilityConfig{
+		ReplicasPath: replicasPath,
+		Selector:     selector,
+		MinAvailable: minAvailable,
+	}, nil
+}
+
+// generatePodDisruptionBudget creates a PodDisruptionBudget when the instance runs more than one replica
+func generatePodDisruptionBudget(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	helmValues map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	results *Results,
+	log logr.Logger,
+) error {
+	haConfig, err := getHighAvailabilityConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid high availability config: %w", err)
+	}
+	if haConfig == nil {
+		return nil
+	}
+
+	replicasRaw, err := lookupValueByPath(helmValues, haConfig.ReplicasPath)
+	if err != nil {
+		log.Info("No replica count in helm values, skipping PodDisruptionBudget", "path", haConfig.ReplicasPath)
+		results.Warning("PodDisruptionBudgetSkipped", "No replica count at helm value %s, skipped PodDisruptionBudget", haConfig.ReplicasPath)
+		return nil
+	}
+	replicas, ok := toNumber(replicasRaw)
+	if !ok {
+		return fmt.Errorf("replica count at %s must be a number, got %T", haConfig.ReplicasPath, replicasRaw)
+	}
+	if replicas <= 1 {
+		return nil
+	}
+
+	selector := haConfig.Selector
+	if len(selector) == 0 {
+		selector = map[string]string{"app.kubernetes.io/instance": instanceName}
+	}
+
+	pdb := NewPodDisruptionBudgetBuilder(instanceName, instanceNamespace).
+		WithSelector(selector).
+		WithMinAvailable(haConfig.MinAvailable).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace).
+		Build()
+
+	pdbResource, err := toFunctionResource(pdb)
+	if err != nil {
+		return fmt.Errorf("failed to convert pod disruption budget: %w", err)
+	}
+	resources["pdb"] = pdbResource
+
+	log.Info("Created PodDisruptionBudget",
+		"replicas", replicas,
+		"minAvailable", haConfig.MinAvailable.String())
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+func TestGeneratePodDisruptionBudget(t *testing.T) {
+	mergedConfig := map[string]any{"highAvailability": map[string]any{"replicasPath": "replicas"}}
+	cases := map[string]struct {
+		replicas any
+		want     bool
+		wantErr  bool
+	}{
+		"Float":         {replicas: float64(3), want: true},
+		"Int":           {replicas: 3, want: true},
+		"Int64":         {replicas: int64(3), want: true},
+		"SingleReplica": {replicas: int64(1)},
+		"NotANumber":    {replicas: "3", wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resources := map[string]*fnv1.Resource{}
+			err := generatePodDisruptionBudget(resources, mergedConfig, map[string]any{"replicas": tc.replicas}, "redis", "default", ClaimReference{}, &Results{}, logr.Discard())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("generatePodDisruptionBudget() error = %v, want error %v", err, tc.wantErr)
+			}
+			if _, ok := resources["pdb"]; ok != tc.want {
+				t.Errorf("PodDisruptionBudget generated = %v, want %v", ok, tc.want)
+			}
+		})
+	}
+}
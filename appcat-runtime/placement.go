@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// defaultProviderConfigKind is the kind of provider config namespaced Releases refer to, shared by all namespaces
+const defaultProviderConfigKind = "ClusterProviderConfig"
+
+// ProviderConfigRef is the provider config, and so the target cluster, an instance is deployed with
+type ProviderConfigRef struct {
+	Kind string
+	Name string
+}
+
+// PlacementConfig selects the target cluster of an instance's Releases and remote objects
+// Platform-wide placements usually come from an EnvironmentConfig per cluster (see environment)
+type PlacementConfig struct {
+	// ProviderConfigRef is the default target; empty leaves it to the provider's default provider config
+	ProviderConfigRef string
+	Kind              string
+	// Rules are checked in order, the first whose condition holds selects the target
+	Rules []PlacementRule
+}
+
+// PlacementRule selects a target cluster for instances whose condition holds, e.g. by spec.region
+type PlacementRule struct {
+	// Condition is a CEL expression over spec, metadata and config
+	Condition         string
+	ProviderConfigRef string
+}
+
+// getPlacementConfig extracts placement configuration from service config
+// Returns nil without error if instances are deployed with the provider's default provider config
+func getPlacementConfig(serviceConfig map[string]any) (*PlacementConfig, error) {
+	placement, ok := serviceConfig["placement"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &PlacementConfig{Kind: defaultProviderConfigKind}
+	config.ProviderConfigRef, _ = placement["providerConfigRef"].(string)
+	if kind, _ := placement["kind"].(string); kind != "" {
+		config.Kind = kind
+	}
+	if config.Kind != "ProviderConfig" && config.Kind != "ClusterProviderConfig" {
+		return nil, fmt.Errorf("kind must be ProviderConfig or ClusterProviderConfig, got %q", config.Kind)
+	}
+	rulesRaw, _ := placement["rules"].([]any)
+	for i, ruleRaw := range rulesRaw {
+		rule, ok := ruleRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("rules[%d] must be a map", i)
+		}
+		r := PlacementRule{}
+		r.Condition, _ = rule["condition"].(string)
+		r.ProviderConfigRef, _ = rule["providerConfigRef"].(string)
+		if r.Condition == "" || r.ProviderConfigRef == "" {
+			return nil, fmt.Errorf("rules[%d] requires condition and providerConfigRef", i)
+		}
+		if _, err := compileExpression(r.Condition); err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		config.Rules = append(config.Rules, r)
+	}
+	if config.ProviderConfigRef == "" && len(config.Rules) == 0 {
+		return nil, fmt.Errorf("placement requires providerConfigRef or rules")
+	}
+	return config, nil
+}
+
+// selectProviderConfig returns the name of the provider config the placement selects for an instance
+// Empty if no rule matches and there's no default
+func (c *PlacementConfig) selectProviderConfig(vars map[string]any) (string, error) {
+	for i, rule := range c.Rules {
+		result, err := evaluateExpression(rule.Condition, vars)
+		if err != nil {
+			return "", fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		matched, ok := result.(bool)
+		if !ok {
+			return "", fmt.Errorf("rules[%d]: condition must return a bool, got %T", i, result)
+		}
+		if matched {
+			return rule.ProviderConfigRef, nil
+		}
+	}
+	return c.ProviderConfigRef, nil
+}
+
+// applyPlacement resolves the instance's target cluster into mergedConfig's providerConfigRef
+// Existing instances stay on the cluster they were deployed to, as moving a Release would orphan its workload
+// and data on the old cluster
+func applyPlacement(
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	serviceConfig, mergedConfig, userSpec map[string]any,
+	results *Results,
+	log logr.Logger,
+) error {
+	config, err := getPlacementConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid placement config: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	metadata, _ := composite.GetResource().AsMap()["metadata"].(map[string]any)
+	vars := map[string]any{"spec": userSpec, "metadata": metadata, "config": serviceConfig, "self": nil}
+	selected, err := config.selectProviderConfig(vars)
+	if err != nil {
+		return err
+	}
+
+	ref := ProviderConfigRef{Kind: config.Kind, Name: selected}
+	for _, key := range []string{releaseKey, nextReleaseKey} {
+		observed, ok := observedResources[key]
+		if !ok {
+			continue
+		}
+		paved := fieldpath.Pave(observed.GetResource().AsMap())
+		current, _ := paved.GetString("spec.providerConfigRef.name")
+		if current == "" || current == selected {
+			break
+		}
+		log.Info("Keeping instance on its cluster", "providerConfig", current, "selected", selected)
+		results.Warning("PlacementChanged", "Instance stays on provider config %s instead of the selected %s, as instances can't move between clusters", current, selected)
+		ref.Name = current
+		if kind, _ := paved.GetString("spec.providerConfigRef.kind"); kind != "" {
+			ref.Kind = kind
+		}
+		break
+	}
+	if ref.Name == "" {
+		return nil
+	}
+	mergedConfig["providerConfigRef"] = map[string]any{"kind": ref.Kind, "name": ref.Name}
+	log.Info("Placed instance", "providerConfig", ref.Name, "kind", ref.Kind)
+	return nil
+}
+
+// getProviderConfigRef returns the placement applyPlacement resolved into mergedConfig
+// Returns nil if the provider's default provider config is used
+func getProviderConfigRef(mergedConfig map[string]any) *ProviderConfigRef {
+	ref, ok := mergedConfig["providerConfigRef"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	kind, _ := ref["kind"].(string)
+	name, _ := ref["name"].(string)
+	return &ProviderConfigRef{Kind: kind, Name: name}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// Plan transition phases reported in status.planTransition.phase
+// Increases are rolled out before decreases, so the instance never runs below either plan mid-change
+const (
+	// PlanPhaseScalingUp renders the target plan's increases while keeping current values that would shrink
+	PlanPhaseScalingUp = "ScalingUp"
+	// PlanPhaseApplying renders the full target plan, including decreases
+	PlanPhaseApplying = "Applying"
+	// PlanPhaseCompleted means the target plan is deployed and recorded in status.plan
+	PlanPhaseCompleted = "Completed"
+	// PlanPhaseInvalid means the requested plan doesn't exist; the current plan stays deployed
+	PlanPhaseInvalid = "Invalid"
+	// PlanPhaseCancelled means spec.plan was reverted before a transition completed
+	PlanPhaseCancelled = "Cancelled"
+)
+
+// errPlanConfig marks errors in the service's plan table, which operators rather than users have to fix
+var errPlanConfig = errors.New("invalid plan config")
+
+// planRender is the plan's contribution to a render
+type planRender struct {
+	// spec presets the user spec fields the user didn't set, before the quota and the mapping see the spec
+	spec map[string]any
+	// values are layered over defaultHelmValues, below the user's mapped values
+	values map[string]any
+	// status holds status.plan and status.planTransition
+	status map[string]any
+}
+
+// newPlanRender splits a plan of the plan table, as staged by orchestratePlan, into its spec presets and helm values
+func newPlanRender(plan map[string]any, status map[string]any) *planRender {
+	spec, _ := plan["spec"].(map[string]any)
+	values, _ := plan["helmValues"].(map[string]any)
+	return &planRender{spec: spec, values: values, status: status}
+}
+
+// getPlans extracts the plan table from service config
+// Each plan is {spec: {...}, helmValues: {...}}: spec presets the user spec fields of sizes and replicas
+// (e.g., {size: {cpu: "1", memory: 4Gi}, replicas: 3}), which the user's own fields override and the mapping
+// translates like them; helmValues are layered over defaultHelmValues
+// Returns nil without error if no plans are configured
+func getPlans(serviceConfig map[string]any) (map[string]map[string]any, error) {
+	plansRaw, ok := serviceConfig["plans"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	plans := make(map[string]map[string]any, len(plansRaw))
+	for name, planRaw := range plansRaw {
+		plan, ok := planRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("plan %s is not a map", name)
+		}
+		preset := map[string]any{"spec": map[string]any{}, "helmValues": map[string]any{}}
+		for _, field := range []string{"spec", "helmValues"} {
+			value, ok := plan[field]
+			if !ok {
+				continue
+			}
+			valueMap, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("plan %s: %s is not a map", name, field)
+			}
+			preset[field] = deepCopy(valueMap)
+		}
+		plans[name] = preset
+	}
+	return plans, nil
+}
+
+// applyPlanSpec fills the user spec fields the user didn't set from the plan's spec presets, in place
+func applyPlanSpec(userSpec, spec map[string]any) {
+	for key, value := range spec {
+		current, ok := userSpec[key]
+		if !ok {
+			userSpec[key] = deepCopyValue(value)
+			continue
+		}
+		currentMap, currentIsMap := current.(map[string]any)
+		valueMap, valueIsMap := value.(map[string]any)
+		if currentIsMap && valueIsMap {
+			applyPlanSpec(currentMap, valueMap)
+		}
+	}
+}
+
+// orchestratePlan resolves the spec presets and helm values of spec.plan, staging a change of plan over several renders
+// The applied plan is tracked in status.plan; a change first rolls out increases, then once the
+// release is ready again the decreases, and finally records the new plan
+// Returns nil if the service has no plans or the spec selects none
+func orchestratePlan(
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	serviceConfig, userSpec map[string]any,
+	results *Results,
+	log logr.Logger,
+) (*planRender, error) {
+	plans, err := getPlans(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errPlanConfig, err)
+	}
+	target, _ := userSpec["plan"].(string)
+	if plans == nil {
+		return nil, nil
+	}
+	service, _ := serviceConfig["service"].(string)
+	recordUsage(planRenders, service, slices.Sorted(maps.Keys(plans)), map[string]bool{target: true})
+	if target == "" {
+		return nil, nil
+	}
+
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+	current, _ := paved.GetString("status.plan")
+	transition := map[string]any{}
+	if observed, err := paved.GetValue("status.planTransition"); err == nil {
+		transition, _ = observed.(map[string]any)
+	}
+	transitionTo, _ := transition["to"].(string)
+	transitionPhase, _ := transition["phase"].(string)
+
+	// New instances start on the requested plan right away
+	if current == "" {
+		values, ok := plans[target]
+		if !ok {
+			return nil, fmt.Errorf("unknown plan %q, available plans: %s", target, strings.Join(slices.Sorted(maps.Keys(plans)), ", "))
+		}
+		return newPlanRender(values, map[string]any{"plan": target}), nil
+	}
+
+	currentValues, ok := plans[current]
+	if !ok {
+		return nil, fmt.Errorf("%w: deployed plan %q no longer exists in the service config", errPlanConfig, current)
+	}
+
+	if target == current {
+		render := newPlanRender(currentValues, map[string]any{"plan": current})
+		if transitionTo != "" && transitionTo != current && transitionPhase != PlanPhaseInvalid && transitionPhase != PlanPhaseCancelled {
+			results.Normal("PlanTransition", "Cancelled plan change to %s, staying on %s", transitionTo, current)
+			render.status["planTransition"] = planTransitionStatus(transition, current, transitionTo, PlanPhaseCancelled, "spec.plan was reverted")
+		} else if len(transition) > 0 {
+			render.status["planTransition"] = transition
+		}
+		return render, nil
+	}
+
+	targetValues, ok := plans[target]
+	if !ok {
+		message := fmt.Sprintf("Plan %q does not exist (available plans: %s), staying on %s", target, strings.Join(slices.Sorted(maps.Keys(plans)), ", "), current)
+		results.Warning("InvalidPlan", "%s", message)
+		return newPlanRender(currentValues, map[string]any{
+			"plan":           current,
+			"planTransition": planTransitionStatus(transition, current, target, PlanPhaseInvalid, message),
+		}), nil
+	}
+
+	scaleUpValues, _ := stagePlanIncreases(currentValues, targetValues).(map[string]any)
+	hasDecreases := !reflect.DeepEqual(scaleUpValues, targetValues)
+	// Readiness left over from before the previous phase's values were applied doesn't count
+	released := isObservedReadyAtGeneration(observedResources[activeReleaseKey(composite)])
+
+	// A phase completes once the release reports ready for the spec it was rendered with
+	phase := PlanPhaseApplying
+	if hasDecreases {
+		phase = PlanPhaseScalingUp
+	}
+	if transitionTo == target {
+		switch {
+		case transitionPhase == PlanPhaseScalingUp && released:
+			phase = PlanPhaseApplying
+		case transitionPhase == PlanPhaseApplying && released, transitionPhase == PlanPhaseCompleted:
+			phase = PlanPhaseCompleted
+		case transitionPhase == PlanPhaseApplying:
+			phase = PlanPhaseApplying
+		}
+	}
+
+	staged := targetValues
+	if phase == PlanPhaseScalingUp {
+		staged = scaleUpValues
+	}
+	render := newPlanRender(staged, map[string]any{"plan": current})
+	var message string
+	switch phase {
+	case PlanPhaseScalingUp:
+		message = fmt.Sprintf("Changing plan from %s to %s: rolling out increases", current, target)
+	case PlanPhaseApplying:
+		message = fmt.Sprintf("Changing plan from %s to %s: applying target plan", current, target)
+	case PlanPhaseCompleted:
+		render.status["plan"] = target
+		message = fmt.Sprintf("Changed plan from %s to %s", current, target)
+	}
+
+	log.Info("Plan transition", "from", current, "to", target, "phase", phase)
+	results.Normal("PlanTransition", "%s", message)
+	render.status["planTransition"] = planTransitionStatus(transition, current, target, phase, message)
+	return render, nil
+}
+
+// stagePlanIncreases returns the target values with decreases held back
+// Maps are compared key by key; numbers and quantities keep the larger of both values, anything else takes the target
+func stagePlanIncreases(current, target any) any {
+	currentMap, currentIsMap := current.(map[string]any)
+	targetMap, targetIsMap := target.(map[string]any)
+	if currentIsMap && targetIsMap {
+		staged := make(map[string]any, len(targetMap))
+		for key, value := range targetMap {
+			staged[key] = stagePlanIncreases(currentMap[key], value)
+		}
+		return staged
+	}
+
+	if currentNum, ok := current.(float64); ok {
+		if targetNum, ok := target.(float64); ok {
+			return max(currentNum, targetNum)
+		}
+	}
+
+	if _, ok := current.(string); ok {
+		currentQ, err := toQuantity(current)
+		if err != nil {
+			return target
+		}
+		targetQ, err := toQuantity(target)
+		if err != nil {
+			return target
+		}
+		if currentQ.Cmp(targetQ) > 0 {
+			return current
+		}
+	}
+	return target
+}
+
+// planTransitionStatus builds status.planTransition
+// lastTransitionTime is kept from the observed status while the phase doesn't change
+func planTransitionStatus(observed map[string]any, from, to, phase, message string) map[string]any {
+	lastTransitionTime := now().UTC().Format(time.RFC3339)
+	if observed["to"] == to && observed["phase"] == phase {
+		if observedTime, ok := observed["lastTransitionTime"].(string); ok {
+			lastTransitionTime = observedTime
+		}
+	}
+	return map[string]any{
+		"from":               from,
+		"to":                 to,
+		"phase":              phase,
+		"message":            message,
+		"lastTransitionTime": lastTransitionTime,
+	}
+}
+
+// withPlanValues returns a shallow copy of serviceConfig with plan values layered over defaultHelmValues
+func withPlanValues(serviceConfig map[string]any, values map[string]any) (map[string]any, error) {
+	defaults, ok := serviceConfig["defaultHelmValues"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("defaultHelmValues is not a map")
+	}
+	listPolicy, err := getListMergePolicy(serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := make(map[string]any, len(serviceConfig))
+	for key, value := range serviceConfig {
+		effective[key] = value
+	}
+	effective["defaultHelmValues"] = deepMerge(deepCopy(defaults), deepCopy(values), listPolicy)
+	return effective, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestOrchestratePlan(t *testing.T) {
+	serviceConfig := map[string]any{
+		"service": "redis",
+		"plans": map[string]any{
+			"small": map[string]any{"helmValues": map[string]any{"cpu": float64(1), "disks": float64(4)}},
+			"large": map[string]any{"helmValues": map[string]any{"cpu": float64(2), "disks": float64(2)}},
+		},
+	}
+	composite := func(status map[string]any) *fnv1.Resource {
+		resource, err := structpb.NewStruct(map[string]any{"status": status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: resource}
+	}
+	transition := func(phase string) map[string]any {
+		return map[string]any{"plan": "small", "planTransition": map[string]any{"from": "small", "to": "large", "phase": phase}}
+	}
+	release := func(generation, observedGeneration float64) map[string]*fnv1.Resource {
+		resource, err := structpb.NewStruct(map[string]any{
+			"metadata": map[string]any{"generation": generation},
+			"status": map[string]any{"conditions": []any{
+				map[string]any{"type": "Ready", "status": "True", "observedGeneration": observedGeneration},
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]*fnv1.Resource{releaseKey: {Resource: resource}}
+	}
+
+	cases := map[string]struct {
+		status    map[string]any
+		observed  map[string]*fnv1.Resource
+		wantPhase string
+		wantCPU   float64
+		wantDisks float64
+	}{
+		"StartsWithIncreases": {
+			status:    map[string]any{"plan": "small"},
+			observed:  release(1, 1),
+			wantPhase: PlanPhaseScalingUp,
+			wantCPU:   2,
+			wantDisks: 4,
+		},
+		"WaitsForScaleUpRollout": {
+			status:    transition(PlanPhaseScalingUp),
+			observed:  release(2, 1),
+			wantPhase: PlanPhaseScalingUp,
+			wantCPU:   2,
+			wantDisks: 4,
+		},
+		"AppliesOnceScaledUp": {
+			status:    transition(PlanPhaseScalingUp),
+			observed:  release(2, 2),
+			wantPhase: PlanPhaseApplying,
+			wantCPU:   2,
+			wantDisks: 2,
+		},
+		"WaitsForApplyRollout": {
+			status:    transition(PlanPhaseApplying),
+			observed:  release(3, 2),
+			wantPhase: PlanPhaseApplying,
+			wantCPU:   2,
+			wantDisks: 2,
+		},
+		"CompletesOnceApplied": {
+			status:    transition(PlanPhaseApplying),
+			observed:  release(3, 3),
+			wantPhase: PlanPhaseCompleted,
+			wantCPU:   2,
+			wantDisks: 2,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			render, err := orchestratePlan(composite(tc.status), tc.observed, serviceConfig, map[string]any{"plan": "large"}, &Results{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("orchestratePlan() error = %v", err)
+			}
+			phase, _ := render.status["planTransition"].(map[string]any)["phase"].(string)
+			if phase != tc.wantPhase {
+				t.Errorf("phase = %q, want %q", phase, tc.wantPhase)
+			}
+			if render.values["cpu"] != tc.wantCPU || render.values["disks"] != tc.wantDisks {
+				t.Errorf("values = %v, want cpu %v and disks %v", render.values, tc.wantCPU, tc.wantDisks)
+			}
+		})
+	}
+
+	t.Run("RemovedPlanIsConfigError", func(t *testing.T) {
+		_, err := orchestratePlan(composite(map[string]any{"plan": "medium"}), nil, serviceConfig, map[string]any{"plan": "large"}, &Results{}, logr.Discard())
+		if !errors.Is(err, errPlanConfig) {
+			t.Errorf("orchestratePlan() error = %v, want %v", err, errPlanConfig)
+		}
+	})
+}
+
+func TestStagePlanIncreases(t *testing.T) {
+	cases := map[string]struct {
+		current any
+		target  any
+		want    any
+	}{
+		"NumberIncrease":   {current: float64(1), target: float64(2), want: float64(2)},
+		"NumberDecrease":   {current: float64(4), target: float64(2), want: float64(4)},
+		"QuantityIncrease": {current: "1Gi", target: "2Gi", want: "2Gi"},
+		"QuantityDecrease": {current: "2Gi", target: "1024Mi", want: "2Gi"},
+		"MixedUnits":       {current: "500m", target: float64(1), want: float64(1)},
+		"NonQuantity":      {current: "standalone", target: "replication", want: "replication"},
+		"NewValue":         {current: nil, target: float64(1), want: float64(1)},
+		"TypeChange":       {current: float64(1), target: "small", want: "small"},
+		"Nested": {
+			current: map[string]any{"resources": map[string]any{"cpu": "2", "memory": "1Gi"}, "disks": float64(4), "dropped": "a"},
+			target:  map[string]any{"resources": map[string]any{"cpu": "1", "memory": "2Gi"}, "disks": float64(2)},
+			want:    map[string]any{"resources": map[string]any{"cpu": "2", "memory": "2Gi"}, "disks": float64(4)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := stagePlanIncreases(tc.current, tc.target); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("stagePlanIncreases() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// pluginTimeout bounds a single plugin run, so a hanging plugin can't stall the render
+const pluginTimeout = 30 * time.Second
+
+// Kinds of the documents exchanged with plugins on stdin and stdout
+const (
+	pluginAPIVersion   = "appcat.vshn.io/v1alpha1"
+	pluginRequestKind  = "PluginRequest"
+	pluginResponseKind = "PluginResponse"
+)
+
+// pluginRegistry holds the generation plugins found at startup, executables in a mounted directory
+// Plugins run out of process, like Helm or kustomize plugins, so they can be written in any language and
+// a crashing plugin only fails the render of the services using it
+type pluginRegistry struct {
+	// plugins maps the plugin name, the file name without extension, to its executable
+	plugins map[string]string
+}
+
+// loadPlugins finds the executables in dir; subdirectories and non-executable files are ignored
+func loadPlugins(dir string) (*pluginRegistry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+	registry := &pluginRegistry{plugins: map[string]string{}}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		// Follow symlinks, as mounted ConfigMaps and Secrets are links to their data
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if other, ok := registry.plugins[name]; ok {
+			return nil, fmt.Errorf("plugins %s and %s share the name %s", other, path, name)
+		}
+		registry.plugins[name] = path
+	}
+	return registry, nil
+}
+
+// names returns the names of the loaded plugins, sorted
+func (r *pluginRegistry) names() []string {
+	if r == nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(r.plugins))
+}
+
+// PluginConfig selects a plugin for a service and passes it its settings
+type PluginConfig struct {
+	Name   string
+	Config map[string]any
+}
+
+// getPluginConfigs extracts the plugins section from service config, e.g., [{name: pgbouncer, config: {...}}]
+// Returns nil without error if the service only uses built-in generation
+func getPluginConfigs(serviceConfig map[string]any) ([]PluginConfig, error) {
+	entries, _ := serviceConfig["plugins"].([]any)
+	var configs []PluginConfig
+	for i, entryRaw := range entries {
+		entry, ok := entryRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("plugins[%d] must be a map", i)
+		}
+		name, _ := entry["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("plugins[%d] requires name", i)
+		}
+		config, _ := entry["config"].(map[string]any)
+		configs = append(configs, PluginConfig{Name: name, Config: config})
+	}
+	return configs, nil
+}
+
+// pluginResponse is what a plugin writes to stdout
+type pluginResponse struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// Resources are added to or replace desired resources by key; null removes a resource
+	Resources map[string]map[string]any `json:"resources"`
+	Results   []struct {
+		Severity string `json:"severity"`
+		Reason   string `json:"reason"`
+		Message  string `json:"message"`
+	} `json:"results"`
+}
+
+// runPlugins runs the service's plugins in order over the desired resources
+// Each plugin gets a PluginRequest with its config, the composite, the observed and desired resources and the
+// merged helm values on stdin, and answers with a PluginResponse on stdout; later plugins see earlier changes
+// Plugins run before labels are stamped and names are checked, so their resources are treated like built-in ones
+func runPlugins(
+	ctx context.Context,
+	registry *pluginRegistry,
+	composite *fnv1.Resource,
+	observedResources, resources map[string]*fnv1.Resource,
+	serviceConfig, mergedConfig map[string]any,
+	results *Results,
+	log logr.Logger,
+) error {
+	configs, err := getPluginConfigs(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid plugins config: %w", err)
+	}
+	for _, config := range configs {
+		path, ok := "", false
+		if registry != nil {
+			path, ok = registry.plugins[config.Name]
+		}
+		if !ok {
+			return fmt.Errorf("plugin %s is not installed, available plugins: %s", config.Name, strings.Join(registry.names(), ", "))
+		}
+
+		request := map[string]any{
+			"apiVersion": pluginAPIVersion,
+			"kind":       pluginRequestKind,
+			"config":     config.Config,
+			"composite":  composite.GetResource().AsMap(),
+			"observed":   resourceObjects(observedResources),
+			"desired":    resourceObjects(resources),
+			"helmValues": mergedConfig["helmValues"],
+		}
+		response, err := execPlugin(ctx, path, request)
+		if err != nil {
+			return fmt.Errorf("plugin %s failed: %w", config.Name, err)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(response.Resources)) {
+			object := response.Resources[key]
+			if object == nil {
+				delete(resources, key)
+				continue
+			}
+			resource, err := structpb.NewStruct(object)
+			if err != nil {
+				return fmt.Errorf("plugin %s returned invalid resource %s: %w", config.Name, key, err)
+			}
+			resources[key] = &fnv1.Resource{Resource: resource}
+		}
+		for _, result := range response.Results {
+			switch result.Severity {
+			case "Normal":
+				results.Normal(result.Reason, "%s", result.Message)
+			case "Warning":
+				results.Warning(result.Reason, "%s", result.Message)
+			default:
+				return fmt.Errorf("plugin %s returned a result of unknown severity %q", config.Name, result.Severity)
+			}
+		}
+		log.Info("Ran plugin", "plugin", config.Name, "resources", slices.Sorted(maps.Keys(response.Resources)))
+	}
+	return nil
+}
+
+// execPlugin runs a plugin executable with the request on stdin and decodes its response from stdout
+// Plugins get an empty environment, so the function's own credentials (e.g., tokens and proxy settings) don't leak
+// to them; the request is all they see
+func execPlugin(ctx context.Context, path string, request map[string]any) (*pluginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = []string{}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+
+	response := &pluginResponse{}
+	if err := json.Unmarshal(stdout.Bytes(), response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if response.APIVersion != pluginAPIVersion || response.Kind != pluginResponseKind {
+		return nil, fmt.Errorf("expected a %s %s, got %s %s", pluginAPIVersion, pluginResponseKind, response.APIVersion, response.Kind)
+	}
+	return response, nil
+}
+
+// resourceObjects returns the objects of resources by key
+func resourceObjects(resources map[string]*fnv1.Resource) map[string]any {
+	objects := make(map[string]any, len(resources))
+	for key, resource := range resources {
+		objects[key] = resource.GetResource().AsMap()
+	}
+	return objects
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecPluginEnvironment(t *testing.T) {
+	t.Setenv("APPCAT_PLUGIN_TEST_TOKEN", "secret")
+	path := filepath.Join(t.TempDir(), "envcheck.sh")
+	script := `#!/bin/sh
+if [ -n "$APPCAT_PLUGIN_TEST_TOKEN" ]; then
+  echo "environment leaked to plugin" >&2
+  exit 1
+fi
+while read -r _; do :; done
+echo '{"apiVersion": "` + pluginAPIVersion + `", "kind": "` + pluginResponseKind + `"}'
+`
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := execPlugin(context.Background(), path, map[string]any{"apiVersion": pluginAPIVersion, "kind": pluginRequestKind}); err != nil {
+		t.Fatalf("execPlugin() error = %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// reservedPropagationPrefixes are key prefixes the function manages itself and never copies from the composite
+var reservedPropagationPrefixes = []string{"app.kubernetes.io/", "appcat.vshn.io/"}
+
+// PropagationConfig lists the composite labels and annotations copied onto generated resources
+// Entries are exact keys or prefixes ending in "*" (e.g., "billing.vshn.io/*")
+type PropagationConfig struct {
+	Labels      []string
+	Annotations []string
+}
+
+// getPropagationConfig extracts propagation configuration from service config
+// Returns nil without error if nothing is propagated
+func getPropagationConfig(serviceConfig map[string]any) (*PropagationConfig, error) {
+	propagation, ok := serviceConfig["propagation"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &PropagationConfig{}
+	for field, target := range map[string]*[]string{"labels": &config.Labels, "annotations": &config.Annotations} {
+		keysRaw, ok := propagation[field]
+		if !ok {
+			continue
+		}
+		keys, ok := keysRaw.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list", field)
+		}
+		for i, keyRaw := range keys {
+			key, _ := keyRaw.(string)
+			if key == "" || strings.Contains(strings.TrimSuffix(key, "*"), "*") {
+				return nil, fmt.Errorf("%s[%d] must be a key or a prefix ending in *", field, i)
+			}
+			prefix, isPrefix := strings.CutSuffix(key, "*")
+			for _, reserved := range reservedPropagationPrefixes {
+				if strings.HasPrefix(key, reserved) || (isPrefix && strings.HasPrefix(reserved, prefix)) {
+					return nil, fmt.Errorf("%s[%d]: %s keys are managed by the function", field, i, reserved)
+				}
+			}
+			*target = append(*target, key)
+		}
+	}
+	return config, nil
+}
+
+// selectPropagated returns the entries of values whose key matches the allowlist
+func selectPropagated(values map[string]any, allowlist []string) map[string]string {
+	selected := map[string]string{}
+	for key, valueRaw := range values {
+		value, ok := valueRaw.(string)
+		if !ok {
+			continue
+		}
+		for _, allowed := range allowlist {
+			prefix, isPrefix := strings.CutSuffix(allowed, "*")
+			if key == allowed || (isPrefix && strings.HasPrefix(key, prefix)) {
+				selected[key] = value
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// propagateCompositeMetadata copies allowlisted composite labels and annotations onto every generated resource,
+// so billing and policy tooling can attribute them to the instance
+// Labels and annotations the function sets itself take precedence
+func propagateCompositeMetadata(resources map[string]*fnv1.Resource, composite *fnv1.Resource, config *PropagationConfig, log logr.Logger) error {
+	if config == nil {
+		return nil
+	}
+
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+	labelsRaw, _ := paved.GetValue("metadata.labels")
+	annotationsRaw, _ := paved.GetValue("metadata.annotations")
+	labels, _ := labelsRaw.(map[string]any)
+	annotations, _ := annotationsRaw.(map[string]any)
+	propagated := map[string]map[string]string{
+		"labels":      selectPropagated(labels, config.Labels),
+		"annotations": selectPropagated(annotations, config.Annotations),
+	}
+	if len(propagated["labels"]) == 0 && len(propagated["annotations"]) == 0 {
+		return nil
+	}
+
+	for name, res := range resources {
+		resourcePaved := fieldpath.Pave(res.Resource.AsMap())
+		for field, values := range propagated {
+			for key, value := range values {
+				path := fmt.Sprintf("metadata.%s[%s]", field, key)
+				if _, err := resourcePaved.GetValue(path); err == nil {
+					continue
+				}
+				if err := resourcePaved.SetValue(path, value); err != nil {
+					return fmt.Errorf("failed to propagate %s %s to %s: %w", field, key, name, err)
+				}
+			}
+		}
+
+		updated, err := structpb.NewStruct(resourcePaved.UnstructuredContent())
+		if err != nil {
+			return fmt.Errorf("failed to convert %s to structpb: %w", name, err)
+		}
+		res.Resource = updated
+	}
+
+	log.Info("Propagated composite metadata",
+		"labels", len(propagated["labels"]),
+		"annotations", len(propagated["annotations"]),
+		"resources", len(resources))
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Provenance annotation keys stamped on every generated resource
+const (
+	AnnotationFunctionVersion     = "appcat.vshn.io/function-version"
+	AnnotationConfigHash          = "appcat.vshn.io/config-hash"
+	AnnotationCompositionRevision = "appcat.vshn.io/composition-revision"
+	AnnotationRenderedAt          = "appcat.vshn.io/rendered-at"
+)
+
+// version is the function version, set at build time via -ldflags "-X main.version=..."
+var version = "dev"
+
+// now returns the current time for timestamps in rendered output
+// The render subcommand pins it so golden files stay stable
+var now = time.Now
+
+// buildProvenance computes the provenance annotations for a render
+// Records which function version, service config and composition revision produced the resources
+func buildProvenance(serviceConfig map[string]any, composite *fnv1.Resource, renderedAt time.Time) (map[string]string, error) {
+	configHash, err := hashConfig(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash service config: %w", err)
+	}
+
+	annotations := map[string]string{
+		AnnotationFunctionVersion: version,
+		AnnotationConfigHash:      configHash,
+		AnnotationRenderedAt:      renderedAt.UTC().Format(time.RFC3339),
+	}
+
+	if revision := getCompositionRevision(composite); revision != "" {
+		annotations[AnnotationCompositionRevision] = revision
+	}
+
+	return annotations, nil
+}
+
+// hashConfig returns a stable sha256 hex digest of a config map
+// json.Marshal sorts map keys, so equal configs always produce the same hash
+func hashConfig(config map[string]any) (string, error) {
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// getCompositionRevision extracts the composition revision name from the composite
+// Crossplane v2 uses spec.crossplane.compositionRevisionRef, v1 uses spec.compositionRevisionRef
+func getCompositionRevision(composite *fnv1.Resource) string {
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+
+	for _, path := range []string{
+		"spec.crossplane.compositionRevisionRef.name",
+		"spec.compositionRevisionRef.name",
+	} {
+		if revision, err := paved.GetString(path); err == nil && revision != "" {
+			return revision
+		}
+	}
+	return ""
+}
+
+// setResourceAnnotations merges annotations into metadata.annotations of every resource
+func setResourceAnnotations(resources map[string]*fnv1.Resource, annotations map[string]string) error {
+	for name, res := range resources {
+		resourceMap := res.Resource.AsMap()
+		paved := fieldpath.Pave(resourceMap)
+
+		for key, value := range annotations {
+			if err := paved.SetValue(fmt.Sprintf("metadata.annotations[%s]", key), value); err != nil {
+				return fmt.Errorf("failed to set annotation %s on %s: %w", key, name, err)
+			}
+		}
+
+		updated, err := structpb.NewStruct(paved.UnstructuredContent())
+		if err != nil {
+			return fmt.Errorf("failed to convert %s to structpb: %w", name, err)
+		}
+		res.Resource = updated
+	}
+	return nil
+}
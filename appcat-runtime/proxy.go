@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ProxyConfig configures how proxy mode connects to the development endpoint
+// Without TLS settings the endpoint is dialed in plaintext, which only suits port-forwards
+type ProxyConfig struct {
+	// TLS enables TLS verified against the system roots, implied by any of the file settings
+	TLS bool
+	// CACertFile verifies the endpoint's certificate instead of the system roots
+	CACertFile string
+	// ClientCertFile and ClientKeyFile present a client certificate to the endpoint
+	ClientCertFile string
+	ClientKeyFile  string
+	// TokenFile holds a bearer token sent as authorization metadata, re-read on every call
+	TokenFile string
+}
+
+// dialOptions builds the gRPC dial options for the proxy endpoint
+func (c ProxyConfig) dialOptions() ([]grpc.DialOption, error) {
+	if !c.TLS && c.CACertFile == "" && c.ClientCertFile == "" && c.ClientKeyFile == "" {
+		if c.TokenFile != "" {
+			return nil, fmt.Errorf("proxy token requires TLS; set --proxy-tls or --proxy-ca-cert")
+		}
+		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CACertFile != "" {
+		ca, err := os.ReadFile(c.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxy CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no valid CA certificate in %s", c.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return nil, fmt.Errorf("proxy client certificate requires both --proxy-client-cert and --proxy-client-key")
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load proxy client keypair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	if c.TokenFile != "" {
+		if _, err := readProxyToken(c.TokenFile); err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(proxyToken{file: c.TokenFile}))
+	}
+	return opts, nil
+}
+
+// proxyToken sends a bearer token from a file with every call
+// The file is re-read per call so rotated tokens are picked up without a restart
+type proxyToken struct {
+	file string
+}
+
+func (t proxyToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := readProxyToken(t.file)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+// RequireTransportSecurity keeps the token from being sent in plaintext
+func (t proxyToken) RequireTransportSecurity() bool {
+	return true
+}
+
+// readProxyToken reads a bearer token, ignoring surrounding whitespace
+func readProxyToken(file string) (string, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read proxy token: %w", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return "", fmt.Errorf("proxy token file %s is empty", file)
+	}
+	return token, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// chartPullSecretKey is the desired resource key of the instance's copy of the repository credentials
+const chartPullSecretKey = "chart-pull-secret"
+
+// requiredChartPullSecret names the required resource holding the platform's repository credentials
+const requiredChartPullSecret = "chart-pull-secret"
+
+// chartPullSecretKeys are the keys provider-helm reads repository credentials from
+var chartPullSecretKeys = []string{"username", "password"}
+
+// ChartPullSecretConfig selects the platform Secret holding the credentials of an authenticated chart repository
+// or OCI registry, which is copied into the instance namespace as Releases can only refer to Secrets next to them
+type ChartPullSecretConfig struct {
+	Name      string
+	Namespace string
+}
+
+// pullSecretDecision is the outcome of resolving the chart pull secret
+type pullSecretDecision struct {
+	requirements *fnv1.Requirements
+}
+
+// getChartPullSecretConfig extracts chartPullSecret configuration from service config
+// Returns nil without error if the service's charts are public
+func getChartPullSecretConfig(serviceConfig map[string]any) (*ChartPullSecretConfig, error) {
+	secret, ok := serviceConfig["chartPullSecret"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	config := &ChartPullSecretConfig{}
+	config.Name, _ = secret["name"].(string)
+	config.Namespace, _ = secret["namespace"].(string)
+	if config.Name == "" || config.Namespace == "" {
+		return nil, fmt.Errorf("chartPullSecret requires name and namespace")
+	}
+	return config, nil
+}
+
+// applyChartPullSecret fetches the platform's repository credentials and records them as
+// mergedConfig["chartPullSecret"] for generateChartPullSecret
+// A missing Secret is reported as a warning; the Releases then fail to pull the chart until it exists
+func applyChartPullSecret(req *fnv1.RunFunctionRequest, mergedConfig, serviceConfig map[string]any, results *Results, log logr.Logger) (*pullSecretDecision, error) {
+	decision := &pullSecretDecision{}
+	config, err := getChartPullSecretConfig(serviceConfig)
+	if err != nil || config == nil {
+		return decision, err
+	}
+
+	namespace := config.Namespace
+	decision.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+		requiredChartPullSecret: {
+			ApiVersion: "v1",
+			Kind:       "Secret",
+			Match:      &fnv1.ResourceSelector_MatchName{MatchName: config.Name},
+			Namespace:  &namespace,
+		},
+	}}
+	found, fetched := getRequiredResources(req, requiredChartPullSecret)
+	if !fetched {
+		return decision, nil
+	}
+	if len(found) == 0 {
+		log.Info("Chart pull secret not found", "secret", namespace+"/"+config.Name)
+		results.Warning("ChartPullSecretMissing", "Chart repository credentials Secret %s/%s not found, charts can't be pulled", namespace, config.Name)
+		return decision, nil
+	}
+
+	paved := fieldpath.Pave(found[0].GetResource().AsMap())
+	credentials := map[string]any{}
+	for _, key := range chartPullSecretKeys {
+		encoded, _ := paved.GetString(fmt.Sprintf("data[%s]", key))
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(value) == 0 {
+			return decision, fmt.Errorf("chart pull secret %s/%s has no valid %s", namespace, config.Name, key)
+		}
+		credentials[key] = string(value)
+	}
+	mergedConfig["chartPullSecret"] = map[string]any{"credentials": credentials}
+	return decision, nil
+}
+
+// generateChartPullSecret creates the instance's copy of the repository credentials
+// Returns the copy's name for the Releases' pullSecretRef, empty if no credentials were fetched
+func generateChartPullSecret(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+) (string, error) {
+	pullSecret, _ := mergedConfig["chartPullSecret"].(map[string]any)
+	credentials, ok := pullSecret["credentials"].(map[string]any)
+	if !ok {
+		return "", nil
+	}
+
+	name := truncateName(instanceName+"-chart-pull", defaultNamingMaxLength)
+	secret := NewSecretBuilder(name, instanceNamespace).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel("app.kubernetes.io/component", "chart-pull-secret").
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace)
+	for _, key := range chartPullSecretKeys {
+		value, _ := credentials[key].(string)
+		secret = secret.WithData(key, []byte(value))
+	}
+	resource, err := toFunctionResource(secret.Build())
+	if err != nil {
+		return "", fmt.Errorf("failed to convert chart pull secret: %w", err)
+	}
+	resources[chartPullSecretKey] = resource
+	return name, nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Required resource names requested from Crossplane for quota enforcement
+const (
+	requiredQuota          = "quota"
+	requiredQuotaInstances = "quota-instances"
+)
+
+// defaultOrganizationLabel is the composite label holding the tenant organization
+const defaultOrganizationLabel = "appuio.io/organization"
+
+// Quota modes
+const (
+	// QuotaModeReject refuses to render specs exceeding the quota
+	QuotaModeReject = "reject"
+	// QuotaModeClamp lowers resources to what is left of the quota
+	QuotaModeClamp = "clamp"
+)
+
+// quotaResources are the aggregated resource kinds, in the order they are checked
+var quotaResources = []string{"cpu", "memory", "storage"}
+
+// QuotaConfig defines how an instance is checked against its organization's quota
+type QuotaConfig struct {
+	// APIVersion and Kind of the cluster-scoped quota CR, selected by organization label
+	APIVersion string
+	Kind       string
+	// OrganizationLabel is the label on composites and quota CRs identifying the organization
+	OrganizationLabel string
+	Mode              string
+	// Usage maps cpu, memory and storage to user spec paths
+	Usage map[string]string
+	// ReplicasPath is an optional user spec path multiplying the usage
+	ReplicasPath string
+}
+
+// quotaDecision is the outcome of a quota check
+type quotaDecision struct {
+	requirements *fnv1.Requirements
+	// pending is true until Crossplane has fetched the required resources
+	pending   bool
+	rejected  bool
+	condition *fnv1.Condition
+	result    *fnv1.Result
+	// status is written to status.quota, recording the usage the instance is deployed with
+	status map[string]any
+}
+
+// getQuotaConfig extracts quota configuration from service config
+// Returns nil without error if no quota is configured
+func getQuotaConfig(serviceConfig map[string]any) (*QuotaConfig, error) {
+	quota, ok := serviceConfig["quota"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	apiVersion, _ := quota["apiVersion"].(string)
+	kind, _ := quota["kind"].(string)
+	if apiVersion == "" || kind == "" {
+		return nil, fmt.Errorf("quota requires apiVersion and kind")
+	}
+
+	organizationLabel, _ := quota["organizationLabel"].(string)
+	if organizationLabel == "" {
+		organizationLabel = defaultOrganizationLabel
+	}
+
+	mode, _ := quota["mode"].(string)
+	switch mode {
+	case "":
+		mode = QuotaModeReject
+	case QuotaModeReject, QuotaModeClamp:
+	default:
+		return nil, fmt.Errorf("unknown quota mode %q", mode)
+	}
+
+	usage := map[string]string{}
+	usageRaw, _ := quota["usage"].(map[string]any)
+	for key, pathRaw := range usageRaw {
+		path, ok := pathRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("usage.%s must be a path", key)
+		}
+		if key == "replicas" {
+			continue
+		}
+		usage[key] = path
+	}
+	replicasPath, _ := usageRaw["replicas"].(string)
+
+	return &QuotaConfig{
+		APIVersion:        apiVersion,
+		Kind:              kind,
+		OrganizationLabel: organizationLabel,
+		Mode:              mode,
+		Usage:             usage,
+		ReplicasPath:      replicasPath,
+	}, nil
+}
+
+// getRequiredResources returns the resources Crossplane fetched for a requirement
+// ok is false if Crossplane hasn't fetched them yet (the first invocation)
+func getRequiredResources(req *fnv1.RunFunctionRequest, name string) ([]*fnv1.Resource, bool) {
+	if resources, ok := req.GetRequiredResources()[name]; ok {
+		return resources.GetItems(), true
+	}
+	if resources, ok := req.GetExtraResources()[name]; ok {
+		return resources.GetItems(), true
+	}
+	return nil, false
+}
+
+// enforceQuota checks the instance's spec against its organization's aggregate limits
+// Usage of the organization's other instances of this service is summed from the usage they recorded in
+// status.quota.usage, which is what they are deployed with after clamping, falling back to their specs
+// In clamp mode the user spec is lowered in place; returns nil if no quota applies
+func enforceQuota(req *fnv1.RunFunctionRequest, composite *fnv1.Resource, serviceConfig, userSpec map[string]any, results *Results, log logr.Logger) (*quotaDecision, error) {
+	config, err := getQuotaConfig(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quota config: %w", err)
+	}
+	if config == nil {
+		return nil, nil
+	}
+
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+	organization, _ := paved.GetString(fmt.Sprintf("metadata.labels[%s]", config.OrganizationLabel))
+	if organization == "" {
+		log.Info("Composite has no organization label, skipping quota", "label", config.OrganizationLabel)
+		results.Warning("QuotaSkipped", "Composite has no %s label, organization quota not enforced", config.OrganizationLabel)
+		return nil, nil
+	}
+	apiVersion, _ := paved.GetString("apiVersion")
+	kind, _ := paved.GetString("kind")
+	self := resourceIdentity(composite)
+
+	selector := map[string]string{config.OrganizationLabel: organization}
+	decision := &quotaDecision{
+		requirements: &fnv1.Requirements{
+			Resources: map[string]*fnv1.ResourceSelector{
+				requiredQuota: {
+					ApiVersion: config.APIVersion,
+					Kind:       config.Kind,
+					Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: selector}},
+				},
+				requiredQuotaInstances: {
+					ApiVersion: apiVersion,
+					Kind:       kind,
+					Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: selector}},
+				},
+			},
+		},
+	}
+
+	quotas, ok := getRequiredResources(req, requiredQuota)
+	if !ok {
+		decision.pending = true
+		return decision, nil
+	}
+	instances, _ := getRequiredResources(req, requiredQuotaInstances)
+
+	if len(quotas) == 0 {
+		log.Info("No quota found for organization", "organization", organization)
+		decision.condition = newCondition(ConditionQuotaEnforced, fnv1.Status_STATUS_CONDITION_TRUE, "NoQuota",
+			fmt.Sprintf("No quota defined for organization %s", organization))
+		return decision, nil
+	}
+	limits := fieldpath.Pave(quotas[0].GetResource().AsMap())
+
+	// Existing instances count against the limits, the instance being rendered doesn't
+	others := 0
+	used := map[string]resource.Quantity{}
+	for _, instance := range instances {
+		if resourceIdentity(instance) == self {
+			continue
+		}
+		others++
+
+		// Skipping an instance would undercount the organization's usage, so the render fails instead
+		usage, err := config.instanceUsage(instance, serviceConfig, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read quota usage of %s: %w", resourceIdentity(instance), err)
+		}
+		for name, q := range usage {
+			total := used[name]
+			total.Add(q)
+			used[name] = total
+		}
+	}
+
+	if limit, ok := lookupNumber(limits, "spec.limits.instances"); ok && float64(others+1) > limit {
+		return decision.reject(fmt.Sprintf("organization %s already has %d of %d allowed instances", organization, others, int(limit))), nil
+	}
+
+	requested, err := config.usage(userSpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute requested usage: %w", err)
+	}
+	replicas, err := config.replicas(userSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	var clamped []string
+	for _, name := range quotaResources {
+		want, ok := requested[name]
+		if !ok {
+			continue
+		}
+		limitRaw, err := limits.GetValue("spec.limits." + name)
+		if err != nil {
+			continue
+		}
+		limit, err := toQuantity(limitRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota limit %s: %w", name, err)
+		}
+
+		remaining := limit.DeepCopy()
+		remaining.Sub(used[name])
+		if want.Cmp(remaining) <= 0 {
+			continue
+		}
+
+		message := fmt.Sprintf("%s request %s exceeds the %s left of organization %s's quota of %s",
+			name, want.String(), remaining.String(), organization, limit.String())
+		if config.Mode == QuotaModeReject || remaining.Sign() <= 0 {
+			return decision.reject(message), nil
+		}
+
+		// Clamp the per-replica value so the total fits into what's left
+		perReplica := clampPerReplica(name, remaining, replicas)
+		if err := setValueByPath(userSpec, strings.TrimPrefix(config.Usage[name], "spec."), perReplica); err != nil {
+			return nil, fmt.Errorf("failed to clamp %s: %w", name, err)
+		}
+		clamped = append(clamped, fmt.Sprintf("%s to %s", name, perReplica))
+	}
+
+	deployed, err := config.usage(userSpec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute deployed usage: %w", err)
+	}
+	recorded := map[string]any{}
+	for name, q := range deployed {
+		recorded[name] = q.String()
+	}
+	decision.status = map[string]any{"usage": recorded}
+
+	if len(clamped) > 0 {
+		message := fmt.Sprintf("Clamped %s to fit organization %s's quota", strings.Join(clamped, ", "), organization)
+		log.Info(message)
+		decision.condition = newCondition(ConditionQuotaEnforced, fnv1.Status_STATUS_CONDITION_TRUE, "Clamped", message)
+		decision.result = newResult(fnv1.Severity_SEVERITY_WARNING, "QuotaClamped", message)
+		return decision, nil
+	}
+
+	decision.condition = newCondition(ConditionQuotaEnforced, fnv1.Status_STATUS_CONDITION_TRUE, "WithinQuota",
+		fmt.Sprintf("Instance fits organization %s's quota", organization))
+	return decision, nil
+}
+
+// reject marks the decision as rejected with a fatal result and a failing condition
+// Crossplane doesn't apply the desired state of a FATAL render, so the instance keeps running as last rendered,
+// frozen until the spec fits the quota again; neither spec nor config changes reach it meanwhile
+func (d *quotaDecision) reject(message string) *quotaDecision {
+	d.rejected = true
+	d.condition = newCondition(ConditionQuotaEnforced, fnv1.Status_STATUS_CONDITION_FALSE, "QuotaExceeded", message)
+	d.result = newResult(fnv1.Severity_SEVERITY_FATAL, "QuotaExceeded", message)
+	return d
+}
+
+// instanceUsage returns the usage of another instance of the organization
+// The usage recorded in its status wins over its spec, which may ask for more than clamping left it
+func (c *QuotaConfig) instanceUsage(instance *fnv1.Resource, serviceConfig map[string]any, log logr.Logger) (map[string]resource.Quantity, error) {
+	if recorded, err := fieldpath.Pave(instance.GetResource().AsMap()).GetValue("status.quota.usage"); err == nil {
+		values, ok := recorded.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("status.quota.usage must be a map")
+		}
+		usage := map[string]resource.Quantity{}
+		for name, value := range values {
+			q, err := toQuantity(value)
+			if err != nil {
+				return nil, fmt.Errorf("status.quota.usage.%s: %w", name, err)
+			}
+			usage[name] = q
+		}
+		return usage, nil
+	}
+
+	hubInstance, err := convertCompositeToHub(instance, serviceConfig, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert to hub version: %w", err)
+	}
+	spec, err := extractUserSpec(hubInstance, serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract spec: %w", err)
+	}
+	return c.usage(spec)
+}
+
+// usage returns the total resources requested by a user spec, multiplied by its replicas
+func (c *QuotaConfig) usage(spec map[string]any) (map[string]resource.Quantity, error) {
+	replicas, err := c.replicas(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := map[string]resource.Quantity{}
+	for name, path := range c.Usage {
+		value, err := getValueByPath(spec, path)
+		if err != nil {
+			continue
+		}
+		q, err := toQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		total := resource.Quantity{}
+		for range replicas {
+			total.Add(q)
+		}
+		usage[name] = total
+	}
+	return usage, nil
+}
+
+// replicas returns the replica count of a user spec, defaulting to 1
+func (c *QuotaConfig) replicas(spec map[string]any) (int, error) {
+	if c.ReplicasPath == "" {
+		return 1, nil
+	}
+	value, err := getValueByPath(spec, c.ReplicasPath)
+	if err != nil {
+		return 1, nil
+	}
+	replicas, ok := value.(float64)
+	if !ok || replicas < 1 {
+		return 0, fmt.Errorf("%s must be a positive number", c.ReplicasPath)
+	}
+	return int(replicas), nil
+}
+
+// clampPerReplica splits the remaining quota across replicas
+// CPU keeps millicore precision, memory and storage are rounded down to whole Mi
+func clampPerReplica(name string, remaining resource.Quantity, replicas int) string {
+	if name == "cpu" {
+		return resource.NewMilliQuantity(remaining.MilliValue()/int64(replicas), resource.DecimalSI).String()
+	}
+	mi := remaining.Value() / int64(replicas) / (1024 * 1024)
+	return fmt.Sprintf("%dMi", mi)
+}
+
+// lookupNumber reads a number from a paved object
+func lookupNumber(paved *fieldpath.Paved, path string) (float64, bool) {
+	value, err := paved.GetValue(path)
+	if err != nil {
+		return 0, false
+	}
+	return toNumber(value)
+}
+
+// toNumber coerces a number to float64: JSON decodes numbers as float64, but values set by Go code and
+// YAML decoders may be integers
+func toNumber(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	}
+	return 0, false
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestEnforceQuotaClamp(t *testing.T) {
+	serviceConfig := map[string]any{
+		"service": "redis",
+		"quota": map[string]any{
+			"apiVersion": "appuio.io/v1",
+			"kind":       "OrganizationQuota",
+			"mode":       QuotaModeClamp,
+			"usage":      map[string]any{"cpu": "spec.cpu"},
+		},
+	}
+	resource := func(name string, fields map[string]any) *fnv1.Resource {
+		object := map[string]any{
+			"apiVersion": "vshn.appcat.io/v1",
+			"kind":       "XRedis",
+			"metadata":   map[string]any{"name": name, "namespace": "team", "labels": map[string]any{defaultOrganizationLabel: "acme"}},
+		}
+		for key, value := range fields {
+			object[key] = value
+		}
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	quota := resource("acme", map[string]any{"spec": map[string]any{"limits": map[string]any{"cpu": "4"}}})
+
+	cases := map[string]struct {
+		other   *fnv1.Resource
+		want    string
+		clamped bool
+		wantErr bool
+	}{
+		"FitsBesideClampedInstance": {
+			// The other instance asks for 3 CPUs but was clamped to 1
+			other: resource("other", map[string]any{
+				"spec":   map[string]any{"cpu": "3"},
+				"status": map[string]any{"quota": map[string]any{"usage": map[string]any{"cpu": "1"}}},
+			}),
+			want: "2",
+		},
+		"SpecWithoutRecordedUsage": {
+			other:   resource("other", map[string]any{"spec": map[string]any{"cpu": "3"}}),
+			want:    "1",
+			clamped: true,
+		},
+		"UnreadableUsage": {
+			other: resource("other", map[string]any{
+				"spec":   map[string]any{"cpu": "3"},
+				"status": map[string]any{"quota": map[string]any{"usage": map[string]any{"cpu": "lots"}}},
+			}),
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &fnv1.RunFunctionRequest{RequiredResources: map[string]*fnv1.Resources{
+				requiredQuota:          {Items: []*fnv1.Resource{quota}},
+				requiredQuotaInstances: {Items: []*fnv1.Resource{tc.other}},
+			}}
+			userSpec := map[string]any{"cpu": "2"}
+			decision, err := enforceQuota(req, resource("self", nil), serviceConfig, userSpec, &Results{}, logr.Discard())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("enforceQuota() error = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if decision.rejected {
+				t.Fatalf("enforceQuota() rejected: %s", decision.result.GetMessage())
+			}
+			if got := userSpec["cpu"]; got != tc.want {
+				t.Errorf("cpu = %v, want %v", got, tc.want)
+			}
+			if clamped := decision.result != nil; clamped != tc.clamped {
+				t.Errorf("clamped = %v, want %v", clamped, tc.clamped)
+			}
+			if got := decision.status["usage"].(map[string]any)["cpu"]; got != tc.want {
+				t.Errorf("status usage cpu = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnforceQuotaPlanPreset(t *testing.T) {
+	serviceConfig := map[string]any{
+		"service": "redis",
+		"plans": map[string]any{
+			"large": map[string]any{"spec": map[string]any{"cpu": "3"}},
+		},
+		"quota": map[string]any{
+			"apiVersion": "appuio.io/v1",
+			"kind":       "OrganizationQuota",
+			"mode":       QuotaModeClamp,
+			"usage":      map[string]any{"cpu": "spec.cpu"},
+		},
+	}
+	resource := func(name string, fields map[string]any) *fnv1.Resource {
+		object := map[string]any{
+			"apiVersion": "vshn.appcat.io/v1",
+			"kind":       "XRedis",
+			"metadata":   map[string]any{"name": name, "namespace": "team", "labels": map[string]any{defaultOrganizationLabel: "acme"}},
+		}
+		for key, value := range fields {
+			object[key] = value
+		}
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	req := &fnv1.RunFunctionRequest{RequiredResources: map[string]*fnv1.Resources{
+		requiredQuota:          {Items: []*fnv1.Resource{resource("acme", map[string]any{"spec": map[string]any{"limits": map[string]any{"cpu": "4"}}})}},
+		requiredQuotaInstances: {Items: []*fnv1.Resource{resource("other", map[string]any{"spec": map[string]any{"cpu": "2"}})}},
+	}}
+
+	cases := map[string]struct {
+		userSpec map[string]any
+		want     string
+		clamped  bool
+	}{
+		"PresetClamped":        {userSpec: map[string]any{"plan": "large"}, want: "2", clamped: true},
+		"ExplicitSizeOverride": {userSpec: map[string]any{"plan": "large", "cpu": "1"}, want: "1"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			self := resource("self", nil)
+			plan, err := orchestratePlan(self, nil, serviceConfig, tc.userSpec, &Results{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("orchestratePlan() error = %v", err)
+			}
+			applyPlanSpec(tc.userSpec, plan.spec)
+
+			decision, err := enforceQuota(req, self, serviceConfig, tc.userSpec, &Results{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("enforceQuota() error = %v", err)
+			}
+			if got := tc.userSpec["cpu"]; got != tc.want {
+				t.Errorf("cpu = %v, want %v", got, tc.want)
+			}
+			if clamped := decision.result != nil; clamped != tc.clamped {
+				t.Errorf("clamped = %v, want %v", clamped, tc.clamped)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// RBACConfig controls the per-instance ServiceAccount and the customer's admin binding
+type RBACConfig struct {
+	// ServiceAccountNamePath is the helm value receiving the instance's ServiceAccount name (e.g. "serviceAccount.name")
+	// Empty to let the chart manage its ServiceAccount
+	ServiceAccountNamePath string
+	// ServiceAccountCreatePath is an optional helm value set to false, so the chart doesn't create its own
+	ServiceAccountCreatePath string
+	// AdminGroupLabel is the composite label holding the customer's user group; empty disables the admin binding
+	AdminGroupLabel string
+	// AdminClusterRole is bound instead of the generated read-only Role if set (e.g. "view")
+	AdminClusterRole string
+}
+
+// getRBACConfig extracts rbac configuration from merged config
+// Returns nil without error if no rbac is configured
+func getRBACConfig(mergedConfig map[string]any) (*RBACConfig, error) {
+	rbac, ok := mergedConfig["rbac"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &RBACConfig{}
+	if serviceAccount, ok := rbac["serviceAccount"].(map[string]any); ok {
+		config.ServiceAccountNamePath, _ = serviceAccount["namePath"].(string)
+		if config.ServiceAccountNamePath == "" {
+			return nil, fmt.Errorf("serviceAccount.namePath is required")
+		}
+		config.ServiceAccountCreatePath, _ = serviceAccount["createPath"].(string)
+	}
+	if admin, ok := rbac["admin"].(map[string]any); ok {
+		config.AdminGroupLabel, _ = admin["groupLabel"].(string)
+		if config.AdminGroupLabel == "" {
+			config.AdminGroupLabel = defaultOrganizationLabel
+		}
+		config.AdminClusterRole, _ = admin["clusterRole"].(string)
+	}
+	return config, nil
+}
+
+// applyServiceAccount points the chart at the instance's own ServiceAccount
+func applyServiceAccount(helmValues map[string]any, config *RBACConfig, instanceName string) error {
+	if config == nil || config.ServiceAccountNamePath == "" {
+		return nil
+	}
+
+	paved := fieldpath.Pave(helmValues)
+	if err := paved.SetValue(config.ServiceAccountNamePath, instanceName); err != nil {
+		return fmt.Errorf("failed to inject service account name: %w", err)
+	}
+	if config.ServiceAccountCreatePath != "" {
+		if err := paved.SetValue(config.ServiceAccountCreatePath, false); err != nil {
+			return fmt.Errorf("failed to disable chart service account: %w", err)
+		}
+	}
+	return nil
+}
+
+// generateRBAC creates the instance's ServiceAccount and a namespace-scoped binding for the customer's
+// user group, so customers can inspect their instance's workload without cluster-wide rights
+// The generated Role grants read access to workloads, logs and events but not to Secrets
+func generateRBAC(
+	resources map[string]*fnv1.Resource,
+	mergedConfig map[string]any,
+	composite *fnv1.Resource,
+	instanceName, instanceNamespace string,
+	claim ClaimReference,
+	results *Results,
+	log logr.Logger,
+) error {
+	config, err := getRBACConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid rbac config: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	if config.ServiceAccountNamePath != "" {
+		serviceAccount := NewServiceAccountBuilder(instanceName, instanceNamespace).
+			WithLabel("app.kubernetes.io/managed-by", "crossplane").
+			WithLabel("app.kubernetes.io/instance", instanceName).
+			WithLabel(LabelClaimName, claim.Name).
+			WithLabel(LabelClaimNamespace, claim.Namespace).
+			Build()
+		serviceAccountResource, err := toFunctionResource(serviceAccount)
+		if err != nil {
+			return fmt.Errorf("failed to convert service account: %w", err)
+		}
+		resources["serviceaccount"] = serviceAccountResource
+		log.Info("Created ServiceAccount", "name", instanceName)
+	}
+
+	if config.AdminGroupLabel == "" {
+		return nil
+	}
+	group, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString(fmt.Sprintf("metadata.labels[%s]", config.AdminGroupLabel))
+	if group == "" {
+		log.Info("Composite has no admin group label, skipping admin binding", "label", config.AdminGroupLabel)
+		results.Warning("AdminBindingSkipped", "Composite has no %s label, no admin binding created", config.AdminGroupLabel)
+		return nil
+	}
+
+	adminName := instanceName + "-admin"
+	binding := NewRoleBindingBuilder(adminName, instanceNamespace).
+		WithGroup(group).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", instanceName).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace)
+	if config.AdminClusterRole != "" {
+		binding = binding.WithClusterRole(config.AdminClusterRole)
+	} else {
+		role := NewRoleBuilder(adminName, instanceNamespace).
+			WithRule("", []string{"pods", "pods/log", "services", "endpoints", "events", "configmaps", "persistentvolumeclaims"}, []string{"get", "list", "watch"}).
+			WithRule("apps", []string{"statefulsets", "deployments", "replicasets"}, []string{"get", "list", "watch"}).
+			WithLabel("app.kubernetes.io/managed-by", "crossplane").
+			WithLabel("app.kubernetes.io/instance", instanceName).
+			WithLabel(LabelClaimName, claim.Name).
+			WithLabel(LabelClaimNamespace, claim.Namespace).
+			Build()
+		roleResource, err := toFunctionResource(role)
+		if err != nil {
+			return fmt.Errorf("failed to convert admin role: %w", err)
+		}
+		resources["role-admin"] = roleResource
+		binding = binding.WithRole(adminName)
+	}
+
+	bindingResource, err := toFunctionResource(binding.Build())
+	if err != nil {
+		return fmt.Errorf("failed to convert admin role binding: %w", err)
+	}
+	resources["rolebinding-admin"] = bindingResource
+
+	log.Info("Created admin binding", "group", group, "clusterRole", config.AdminClusterRole)
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// recorder dumps RunFunction requests and responses to disk for replaying them locally
+// Files are protojson and hold connection details and credentials, so they are only readable by the owner
+type recorder struct {
+	dir string
+	log logr.Logger
+	seq atomic.Uint64
+}
+
+// newRecorder creates a recorder writing into dir, creating it if needed
+func newRecorder(dir string, log logr.Logger) (*recorder, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create record dir: %w", err)
+	}
+	return &recorder{dir: dir, log: log.WithValues("component", "recorder")}, nil
+}
+
+// record writes a request and its outcome as <time>-<seq>-<namespace>-<name>.{request,response,error}.json
+// Recording is best effort; failures are logged and never fail the request
+func (r *recorder) record(req *fnv1.RunFunctionRequest, resp *fnv1.RunFunctionResponse, callErr error) {
+	prefix := r.filePrefix(req)
+
+	if err := r.write(prefix+".request.json", req); err != nil {
+		r.log.Error(err, "Failed to record request", "file", prefix)
+		return
+	}
+	if callErr != nil {
+		if err := os.WriteFile(filepath.Join(r.dir, prefix+".error.txt"), []byte(callErr.Error()+"\n"), 0o600); err != nil {
+			r.log.Error(err, "Failed to record error", "file", prefix)
+		}
+		return
+	}
+	if err := r.write(prefix+".response.json", resp); err != nil {
+		r.log.Error(err, "Failed to record response", "file", prefix)
+		return
+	}
+	r.log.Info("Recorded request", "file", prefix)
+}
+
+// filePrefix names the recording after the time, a sequence number and the composite
+// The sequence number keeps concurrent requests within the same millisecond apart
+func (r *recorder) filePrefix(req *fnv1.RunFunctionRequest) string {
+	paved := fieldpath.Pave(req.GetObserved().GetComposite().GetResource().AsMap())
+	namespace, _ := paved.GetString("metadata.namespace")
+	name, _ := paved.GetString("metadata.name")
+	if namespace == "" {
+		namespace = "_"
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	return fmt.Sprintf("%s-%06d-%s-%s", time.Now().UTC().Format("20060102T150405.000"), r.seq.Add(1), namespace, name)
+}
+
+// write marshals msg as indented protojson into the record dir
+func (r *recorder) write(name string, msg proto.Message) error {
+	raw, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, name), raw, 0o600)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// LabelService is stamped on generated resources with the name of the service they belong to
+const LabelService = "appcat.vshn.io/service"
+
+// serviceRegistry holds shared service profiles, one <service>.yaml per service in dir
+// A profile is a partial service config (e.g., chart, connectionSecret, passwordPath wiring) that
+// Composition inputs of that service build on, so services are onboarded with data instead of code
+type serviceRegistry struct {
+	dir string
+}
+
+// newServiceRegistry creates a registry reading profiles from dir
+func newServiceRegistry(dir string) *serviceRegistry {
+	return &serviceRegistry{dir: dir}
+}
+
+// profile reads the profile of a service
+// Read on every request so updates to the mounted ConfigMap apply without a restart
+// Returns nil without error if the registry has no profile for the service
+func (r *serviceRegistry) profile(service string) (map[string]any, error) {
+	if r == nil || service == "" {
+		return nil, nil
+	}
+	if filepath.Base(service) != service {
+		return nil, fmt.Errorf("invalid service name %q", service)
+	}
+
+	name := service + ".yaml"
+	raw, err := os.ReadFile(filepath.Join(r.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service profile: %w", err)
+	}
+
+	profile := map[string]any{}
+	if err := yaml.Unmarshal(raw, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return profile, nil
+}
+
+// getServiceName returns the service a Composition input belongs to
+// data.service takes precedence over the input's service label
+func getServiceName(input *structpb.Struct) string {
+	paved := fieldpath.Pave(input.AsMap())
+	if service, _ := paved.GetString("data.service"); service != "" {
+		return service
+	}
+	service, _ := paved.GetString("metadata.labels.service")
+	return service
+}
+
+// withServiceProfile layers the Composition input's service config over the service's profile
+// Maps merge key by key and the input wins, so a Composition only spells out what differs
+func withServiceProfile(profile, data map[string]any) map[string]any {
+	if profile == nil {
+		return data
+	}
+	return deepMerge(deepCopy(profile), data, replaceLists)
+}
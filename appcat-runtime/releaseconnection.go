@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	helmv1 "github.com/crossplane-contrib/provider-helm/apis/namespaced/release/v1beta1"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// requiredReleaseConnectionPrefix prefixes the required resources holding the Releases' connection secrets,
+// e.g. release-connection-helmrelease-next
+const requiredReleaseConnectionPrefix = "release-connection-"
+
+// ReleaseConnectionDetail surfaces a field of an object the chart deploys, e.g. a Service's spec.clusterIP or a
+// key of a generated Secret, in the instance's connection details
+type ReleaseConnectionDetail struct {
+	Key        string
+	APIVersion string
+	Kind       string
+	// Name may refer to ${instanceName}, the name of the helm release, and ${namespace}
+	Name string
+	// Namespace defaults to the instance namespace
+	Namespace string
+	// FieldPath is read by provider-helm, keys of Secrets (data.<key>) are decoded
+	FieldPath  string
+	Visibility string
+}
+
+// releaseConnectionDecision is the outcome of fetching the Releases' connection secrets
+type releaseConnectionDecision struct {
+	requirements *fnv1.Requirements
+}
+
+// parseReleaseConnectionDetails extracts connectionSecret.fromRelease, e.g.
+// [{key: host, apiVersion: v1, kind: Service, name: "${instanceName}-master", fieldPath: spec.clusterIP}]
+func parseReleaseConnectionDetails(entriesRaw any) ([]ReleaseConnectionDetail, error) {
+	entries, ok := entriesRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("fromRelease must be a list")
+	}
+	details := make([]ReleaseConnectionDetail, 0, len(entries))
+	for i, entryRaw := range entries {
+		entry, ok := entryRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("fromRelease[%d] must be a map", i)
+		}
+		detail := ReleaseConnectionDetail{}
+		detail.Key, _ = entry["key"].(string)
+		detail.APIVersion, _ = entry["apiVersion"].(string)
+		detail.Kind, _ = entry["kind"].(string)
+		detail.Name, _ = entry["name"].(string)
+		detail.Namespace, _ = entry["namespace"].(string)
+		detail.FieldPath, _ = entry["fieldPath"].(string)
+		detail.Visibility, _ = entry["visibility"].(string)
+		if detail.Key == "" || detail.APIVersion == "" || detail.Kind == "" || detail.Name == "" || detail.FieldPath == "" {
+			return nil, fmt.Errorf("fromRelease[%d] requires key, apiVersion, kind, name and fieldPath", i)
+		}
+		if _, err := fieldpath.Parse(detail.FieldPath); err != nil {
+			return nil, fmt.Errorf("fromRelease[%d]: invalid fieldPath: %w", i, err)
+		}
+		switch detail.Visibility {
+		case "":
+			detail.Visibility = VisibilityClaim
+		case VisibilityClaim, VisibilityPlatform:
+		default:
+			return nil, fmt.Errorf("fromRelease[%d]: unknown visibility %q", i, detail.Visibility)
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
+
+// releaseConnectionSecretName returns the Secret a Release writes its connection details to
+func releaseConnectionSecretName(releaseName string) string {
+	return releaseName + "-connection"
+}
+
+// applyReleaseConnectionDetails adds the connection details to a Release, which provider-helm then reads from
+// the deployed objects into the Release's connection secret
+func applyReleaseConnectionDetails(builder *HelmReleaseBuilder, details []ReleaseConnectionDetail, releaseName, namespace string) *HelmReleaseBuilder {
+	if len(details) == 0 {
+		return builder
+	}
+	variables := map[string]string{"instanceName": releaseName, "namespace": namespace}
+	for _, detail := range details {
+		objectNamespace := namespace
+		if detail.Namespace != "" {
+			objectNamespace = substituteVariables(detail.Namespace, variables)
+		}
+		builder = builder.WithConnectionDetail(helmv1.ConnectionDetail{
+			ObjectReference: corev1.ObjectReference{
+				APIVersion: detail.APIVersion,
+				Kind:       detail.Kind,
+				Name:       substituteVariables(detail.Name, variables),
+				Namespace:  objectNamespace,
+				FieldPath:  detail.FieldPath,
+			},
+			ToConnectionSecretKey: detail.Key,
+		})
+	}
+	return builder.WithConnectionSecret(releaseConnectionSecretName(releaseName))
+}
+
+// applyReleaseConnection fetches the connection secrets of the instance's Releases and records their data by
+// release name as mergedConfig["releaseConnection"] for the connection secret
+// Both possible Releases are fetched, as a blue/green upgrade serves the connection details of the next one after
+// the switch; Secrets not written yet are skipped
+func applyReleaseConnection(req *fnv1.RunFunctionRequest, composite *fnv1.Resource, mergedConfig map[string]any, log logr.Logger) (*releaseConnectionDecision, error) {
+	decision := &releaseConnectionDecision{}
+	connectionSecret, err := getConnectionSecretConfig(mergedConfig)
+	if err != nil || connectionSecret == nil || len(connectionSecret.FromRelease) == 0 {
+		return decision, err
+	}
+
+	instanceName, err := getInstanceName(composite, mergedConfig)
+	if err != nil {
+		return decision, err
+	}
+	namespace, err := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.namespace")
+	if err != nil {
+		return decision, fmt.Errorf("failed to get composite namespace: %w", err)
+	}
+
+	decision.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}
+	fetched := map[string]any{}
+	releases := map[string]string{releaseKey: instanceName, nextReleaseKey: instanceName + nextSuffix}
+	for key, releaseName := range releases {
+		requirement := requiredReleaseConnectionPrefix + key
+		decision.requirements.Resources[requirement] = &fnv1.ResourceSelector{
+			ApiVersion: "v1",
+			Kind:       "Secret",
+			Match:      &fnv1.ResourceSelector_MatchName{MatchName: releaseConnectionSecretName(releaseName)},
+			Namespace:  &namespace,
+		}
+		found, ok := getRequiredResources(req, requirement)
+		if !ok || len(found) == 0 {
+			continue
+		}
+		data, _ := found[0].GetResource().AsMap()["data"].(map[string]any)
+		values := make(map[string]any, len(data))
+		for dataKey, encoded := range data {
+			encodedValue, _ := encoded.(string)
+			value, err := base64.StdEncoding.DecodeString(encodedValue)
+			if err != nil {
+				return decision, fmt.Errorf("connection secret of release %s has an invalid %s: %w", releaseName, dataKey, err)
+			}
+			values[dataKey] = string(value)
+		}
+		fetched[releaseName] = values
+		log.Info("Fetched release connection details", "release", releaseName, "keys", len(values))
+	}
+	mergedConfig["releaseConnection"] = fetched
+	return decision, nil
+}
+
+// getReleaseConnection returns the connection details a Release wrote, as fetched by applyReleaseConnection
+// Returns nil if the Release hasn't written its connection secret yet
+func getReleaseConnection(mergedConfig map[string]any, releaseName string) map[string]any {
+	fetched, _ := mergedConfig["releaseConnection"].(map[string]any)
+	details, _ := fetched[releaseName].(map[string]any)
+	return details
+}
+
+// observedConnectionValue returns a key of the observed connection Secret, so details read from the deployed
+// objects don't disappear while the serving Release's connection secret is fetched
+func observedConnectionValue(observedResources map[string]*fnv1.Resource, key string) (string, bool) {
+	observed, ok := observedResources["secret"]
+	if !ok {
+		return "", false
+	}
+	encoded, err := fieldpath.Pave(observed.GetResource().AsMap()).GetString(fmt.Sprintf("data[%s]", key))
+	if err != nil {
+		return "", false
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	return string(value), true
+}
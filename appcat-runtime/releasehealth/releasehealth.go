@@ -0,0 +1,161 @@
+// Package releasehealth normalizes the health of provider-helm Releases, so readiness, conditions, events and
+// metrics, and tools outside the function, agree on what a Release's state means
+package releasehealth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// Health is the normalized health of a provider-helm Release
+type Health string
+
+// Release health values, shared by readiness, conditions, events and metrics
+const (
+	// Unknown means the Release hasn't been observed or reports a state without a clear meaning
+	Unknown Health = "Unknown"
+	// Progressing means the Release is being installed, upgraded, rolled back or uninstalled
+	Progressing Health = "Progressing"
+	// Healthy means the Release is deployed, synced and ready
+	Healthy Health = "Healthy"
+	// Degraded means a deployed Release is no longer ready or can't be reconciled
+	Degraded Health = "Degraded"
+	// Failed means Helm failed to install or upgrade the Release
+	Failed Health = "Failed"
+)
+
+// Values lists all health values, e.g. for resetting metric series
+var Values = []Health{
+	Unknown,
+	Progressing,
+	Healthy,
+	Degraded,
+	Failed,
+}
+
+// Helm release states reported in status.atProvider.state
+const (
+	helmStateDeployed     = "deployed"
+	helmStateFailed       = "failed"
+	helmStateUninstalling = "uninstalling"
+	helmStatePending      = "pending-"
+)
+
+// Status is a Release's health with a machine-readable reason and a human-readable message
+type Status struct {
+	Health  Health
+	Reason  string
+	Message string
+}
+
+// Ready reports whether the Release serves the instance
+func (s Status) Ready() bool {
+	return s.Health == Healthy
+}
+
+// condition is a status condition read from an observed Release
+type condition struct {
+	status  string
+	reason  string
+	message string
+}
+
+// Interpret derives the health of an observed provider-helm Release from its Helm state,
+// failed attempt count and Ready/Synced conditions
+func Interpret(release *fnv1.Resource) Status {
+	if release == nil || release.GetResource() == nil {
+		return Status{Health: Unknown, Reason: "NotObserved", Message: "HelmRelease has not been observed yet"}
+	}
+
+	paved := fieldpath.Pave(release.GetResource().AsMap())
+	state, _ := paved.GetString("status.atProvider.state")
+	description, _ := paved.GetString("status.atProvider.releaseDescription")
+	failedAttempts := getNumber(paved, "status.failed")
+	revision := getNumber(paved, "status.atProvider.revision")
+	ready, hasReady := getCondition(paved, "Ready")
+	synced, hasSynced := getCondition(paved, "Synced")
+	syncFailed := hasSynced && synced.status == "False"
+
+	if deletionTimestamp, _ := paved.GetString("metadata.deletionTimestamp"); deletionTimestamp != "" {
+		return Status{Health: Progressing, Reason: "Deleting", Message: "HelmRelease is being deleted"}
+	}
+
+	switch {
+	case state == helmStateFailed:
+		message := description
+		if syncFailed && synced.message != "" {
+			message = synced.message
+		}
+		if failedAttempts > 0 {
+			message = fmt.Sprintf("%s (%d failed attempts)", message, int(failedAttempts))
+		}
+		return Status{Health: Failed, Reason: "ReleaseFailed", Message: message}
+	case syncFailed && state == helmStateDeployed:
+		return Status{Health: Degraded, Reason: synced.reasonOr("ReconcileError"), Message: synced.message}
+	case syncFailed:
+		// Nothing deployed and the provider can't get there, e.g. the chart can't be pulled
+		return Status{Health: Failed, Reason: synced.reasonOr("ReconcileError"), Message: synced.message}
+	case strings.HasPrefix(state, helmStatePending):
+		return Status{Health: Progressing, Reason: "Pending", Message: fmt.Sprintf("Helm release is %s", state)}
+	case state == helmStateUninstalling:
+		return Status{Health: Progressing, Reason: "Uninstalling", Message: "Helm release is being uninstalled"}
+	case (state == helmStateDeployed || state == "") && hasReady && ready.status == "True":
+		// Ready is only set once the release is deployed, even if the provider doesn't report the state
+		message := "Helm release is deployed and ready"
+		if revision > 0 {
+			message = fmt.Sprintf("Helm release revision %d is deployed and ready", int(revision))
+		}
+		return Status{Health: Healthy, Reason: "Deployed", Message: message}
+	case state == helmStateDeployed:
+		message := "Helm release is deployed but not ready"
+		if hasReady && ready.message != "" {
+			message = ready.message
+		}
+		return Status{Health: Degraded, Reason: ready.reasonOr("Unavailable"), Message: message}
+	case state == "":
+		return Status{Health: Progressing, Reason: "Creating", Message: "Helm release is being installed"}
+	default:
+		return Status{Health: Unknown, Reason: "UnknownState", Message: fmt.Sprintf("Helm release is in state %s", state)}
+	}
+}
+
+// getNumber returns the number at path, zero if unset; observed resources decode numbers as float64
+func getNumber(paved *fieldpath.Paved, path string) float64 {
+	value, err := paved.GetValue(path)
+	if err != nil {
+		return 0
+	}
+	number, _ := value.(float64)
+	return number
+}
+
+// getCondition returns the status condition of the given type from an observed Release
+func getCondition(paved *fieldpath.Paved, conditionType string) (*condition, bool) {
+	conditionsRaw, err := paved.GetValue("status.conditions")
+	if err != nil {
+		return nil, false
+	}
+	conditions, _ := conditionsRaw.([]any)
+	for _, conditionRaw := range conditions {
+		c, ok := conditionRaw.(map[string]any)
+		if !ok || c["type"] != conditionType {
+			continue
+		}
+		status, _ := c["status"].(string)
+		reason, _ := c["reason"].(string)
+		message, _ := c["message"].(string)
+		return &condition{status: status, reason: reason, message: message}, true
+	}
+	return nil, false
+}
+
+// reasonOr returns the reason of the condition, or fallback if it has none
+func (c *condition) reasonOr(fallback string) string {
+	if c == nil || c.reason == "" {
+		return fallback
+	}
+	return c.reason
+}
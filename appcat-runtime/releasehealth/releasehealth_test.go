@@ -0,0 +1,64 @@
+package releasehealth
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestInterpret(t *testing.T) {
+	release := func(atProvider map[string]any, conditions ...map[string]any) *fnv1.Resource {
+		conditionList := make([]any, 0, len(conditions))
+		for _, c := range conditions {
+			conditionList = append(conditionList, c)
+		}
+		s, err := structpb.NewStruct(map[string]any{
+			"apiVersion": "helm.m.crossplane.io/v1beta1",
+			"kind":       "Release",
+			"status":     map[string]any{"atProvider": atProvider, "conditions": conditionList, "failed": float64(2)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	ready := map[string]any{"type": "Ready", "status": "True"}
+	notReady := map[string]any{"type": "Ready", "status": "False", "reason": "Unavailable", "message": "0/1 pods ready"}
+	syncFailed := map[string]any{"type": "Synced", "status": "False", "reason": "ReconcileError", "message": "chart not found"}
+
+	cases := map[string]struct {
+		release    *fnv1.Resource
+		want       Health
+		wantReason string
+	}{
+		"NotObserved":   {want: Unknown, wantReason: "NotObserved"},
+		"Deployed":      {release: release(map[string]any{"state": "deployed", "revision": float64(3)}, ready), want: Healthy, wantReason: "Deployed"},
+		"ReadyNoState":  {release: release(map[string]any{}, ready), want: Healthy, wantReason: "Deployed"},
+		"Creating":      {release: release(map[string]any{}), want: Progressing, wantReason: "Creating"},
+		"Pending":       {release: release(map[string]any{"state": "pending-upgrade"}), want: Progressing, wantReason: "Pending"},
+		"Failed":        {release: release(map[string]any{"state": "failed", "releaseDescription": "timed out"}), want: Failed, wantReason: "ReleaseFailed"},
+		"NotReady":      {release: release(map[string]any{"state": "deployed"}, notReady), want: Degraded, wantReason: "Unavailable"},
+		"SyncFailed":    {release: release(map[string]any{"state": "deployed"}, ready, syncFailed), want: Degraded, wantReason: "ReconcileError"},
+		"NeverDeployed": {release: release(map[string]any{}, syncFailed), want: Failed, wantReason: "ReconcileError"},
+		"OddState":      {release: release(map[string]any{"state": "superseded"}), want: Unknown, wantReason: "UnknownState"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Interpret(tc.release)
+			if got.Health != tc.want || got.Reason != tc.wantReason {
+				t.Errorf("Interpret() = %s/%s, want %s/%s", got.Health, got.Reason, tc.want, tc.wantReason)
+			}
+			if got.Ready() != (tc.want == Healthy) {
+				t.Errorf("Ready() = %v for %s", got.Ready(), got.Health)
+			}
+		})
+	}
+
+	if got := Interpret(release(map[string]any{"state": "failed", "releaseDescription": "timed out"})); got.Message != "timed out (2 failed attempts)" {
+		t.Errorf("failed message = %q, want the description with the failed attempts", got.Message)
+	}
+	if got := Interpret(release(map[string]any{"state": "deployed", "revision": float64(3)}, ready)); got.Message != "Helm release revision 3 is deployed and ready" {
+		t.Errorf("healthy message = %q, want the revision", got.Message)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReleaseOptions are provider-helm's install and upgrade options of a service's Releases
+type ReleaseOptions struct {
+	// Wait keeps a release pending until its resources are ready, so slow-starting charts aren't marked deployed early
+	Wait bool
+	// WaitTimeout is how long helm waits before failing the release; zero keeps helm's default of 5m
+	WaitTimeout time.Duration
+	// SkipCreateNamespace is nil to keep the default of skipping, as the instance namespace exists on the control plane
+	SkipCreateNamespace *bool
+	SkipCRDs            bool
+	// RollbackLimit is how often a failed release is rolled back and retried; nil keeps provider-helm's default
+	RollbackLimit *int32
+}
+
+// getReleaseOptions extracts releaseOptions from merged config
+// Returns nil without error if the Releases use provider-helm's defaults
+func getReleaseOptions(mergedConfig map[string]any) (*ReleaseOptions, error) {
+	optionsRaw, ok := mergedConfig["releaseOptions"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	options := &ReleaseOptions{}
+	if raw, ok := optionsRaw["wait"]; ok {
+		wait, ok := raw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("wait must be a bool")
+		}
+		options.Wait = wait
+	}
+	if raw, ok := optionsRaw["waitTimeout"]; ok {
+		timeout, _ := raw.(string)
+		duration, err := time.ParseDuration(timeout)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("waitTimeout must be a positive duration, e.g. 15m")
+		}
+		if !options.Wait {
+			return nil, fmt.Errorf("waitTimeout requires wait")
+		}
+		options.WaitTimeout = duration
+	}
+	if raw, ok := optionsRaw["skipCreateNamespace"]; ok {
+		skip, ok := raw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("skipCreateNamespace must be a bool")
+		}
+		options.SkipCreateNamespace = &skip
+	}
+	if raw, ok := optionsRaw["skipCRDs"]; ok {
+		skip, ok := raw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("skipCRDs must be a bool")
+		}
+		options.SkipCRDs = skip
+	}
+	if raw, ok := optionsRaw["rollbackLimit"]; ok {
+		limit, ok := raw.(float64)
+		if !ok || limit < 0 || limit != float64(int32(limit)) {
+			return nil, fmt.Errorf("rollbackLimit must be a non-negative integer")
+		}
+		rollbackLimit := int32(limit)
+		options.RollbackLimit = &rollbackLimit
+	}
+	return options, nil
+}
+
+// applyReleaseOptions sets the release options on a Release builder
+func applyReleaseOptions(builder *HelmReleaseBuilder, options *ReleaseOptions) *HelmReleaseBuilder {
+	if options == nil {
+		return builder
+	}
+	if options.Wait {
+		builder = builder.WithWait(options.WaitTimeout)
+	}
+	if options.SkipCreateNamespace != nil {
+		builder = builder.WithSkipCreateNamespace(*options.SkipCreateNamespace)
+	}
+	if options.RollbackLimit != nil {
+		builder = builder.WithRollbackLimit(*options.RollbackLimit)
+	}
+	return builder.WithSkipCRDs(options.SkipCRDs)
+}
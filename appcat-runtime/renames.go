@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// ResourceRename declares that a desired resource key has been renamed
+type ResourceRename struct {
+	From string
+	To   string
+}
+
+// getResourceRenames extracts resourceRenames from service config
+func getResourceRenames(serviceConfig map[string]any) ([]ResourceRename, error) {
+	renamesRaw, ok := serviceConfig["resourceRenames"].([]any)
+	if !ok {
+		return nil, nil
+	}
+
+	renames := make([]ResourceRename, 0, len(renamesRaw))
+	for i, renameRaw := range renamesRaw {
+		renameMap, ok := renameRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("resourceRenames[%d] is not a map", i)
+		}
+		from, _ := renameMap["from"].(string)
+		to, _ := renameMap["to"].(string)
+		if from == "" || to == "" || from == to {
+			return nil, fmt.Errorf("resourceRenames[%d]: from and to must be set and differ", i)
+		}
+		renames = append(renames, ResourceRename{From: from, To: to})
+	}
+	return renames, nil
+}
+
+// resourceIdentity returns apiVersion/kind/namespace/name of a resource
+func resourceIdentity(res *fnv1.Resource) string {
+	paved := fieldpath.Pave(res.GetResource().AsMap())
+	apiVersion, _ := paved.GetString("apiVersion")
+	kind, _ := paved.GetString("kind")
+	namespace, _ := paved.GetString("metadata.namespace")
+	name, _ := paved.GetString("metadata.name")
+	return fmt.Sprintf("%s/%s/%s/%s", apiVersion, kind, namespace, name)
+}
+
+// isObservedReady reports whether an observed resource is ready
+// Resources without a Ready condition (e.g. Secrets) are ready once they exist
+func isObservedReady(res *fnv1.Resource) bool {
+	if res == nil {
+		return false
+	}
+	ready, ok := getObservedCondition(res, "Ready")
+	return !ok || ready.Status == "True"
+}
+
+// applyResourceRenames keeps renamed resource keys from orphaning or duplicating objects
+// Crossplane deletes resources whose key disappears from the desired state, so for existing instances:
+//   - if both keys describe the same object, the old key is kept (dropping it would delete the object)
+//   - otherwise both keys are emitted until the object under the new key is ready, then the old key is dropped
+func applyResourceRenames(
+	resources map[string]*fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	renames []ResourceRename,
+	log logr.Logger,
+) error {
+	for _, rename := range renames {
+		// Generated resources still using the previous key move to the new one
+		if desired, ok := resources[rename.From]; ok {
+			if _, exists := resources[rename.To]; !exists {
+				resources[rename.To] = desired
+				delete(resources, rename.From)
+			}
+		}
+
+		desired, ok := resources[rename.To]
+		if !ok {
+			continue
+		}
+		old, ok := observedResources[rename.From]
+		if !ok {
+			// Fresh instance or transition already complete
+			continue
+		}
+
+		if resourceIdentity(old) == resourceIdentity(desired) {
+			resources[rename.From] = desired
+			delete(resources, rename.To)
+			log.Info("Keeping previous resource key for shared object", "from", rename.From, "to", rename.To)
+			continue
+		}
+
+		if isObservedReady(observedResources[rename.To]) {
+			log.Info("Resource rename complete, releasing previous key", "from", rename.From, "to", rename.To)
+			continue
+		}
+
+		// Keep the previous object alive with its last observed spec until the replacement is ready;
+		// under server-side apply, a desired object without its spec would release those fields, and
+		// managed resources like Releases would fail validation
+		previous, err := observedDesired(old)
+		if err != nil {
+			return fmt.Errorf("failed to keep previous resource %s: %w", rename.From, err)
+		}
+		resources[rename.From] = previous
+		log.Info("Resource rename in transition, emitting both keys", "from", rename.From, "to", rename.To)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/yaml"
+)
+
+// runRender implements the render subcommand: it runs a recorded or hand-written RunFunctionRequest
+// through RunFunction and prints the desired resources as a YAML stream
+// Returns the process exit code, non-zero if the request can't be read or the render failed
+func runRender(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	full := flags.Bool("full", false, "Print the full RunFunctionResponse instead of the desired resources")
+	verbose := flags.Bool("verbose", false, "Log the render to stderr")
+	at := flags.String("time", "", "Render as of this RFC 3339 time instead of now, for reproducible output")
+	seed := flags.String("seed", "", "Derive generated passwords from this seed instead of crypto/rand, for reproducible output")
+	registryDir := flags.String("service-registry-dir", "", "Directory of <service>.yaml service profiles, as mounted in the cluster")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: appcat-runtime render [flags] <request.yaml|request.json>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	if *at != "" {
+		renderTime, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			fmt.Fprintf(stderr, "render: invalid --time: %v\n", err)
+			return 2
+		}
+		now = func() time.Time { return renderTime }
+	}
+
+	if *seed != "" {
+		seedRandomSource(*seed)
+	}
+
+	req, err := readRunFunctionRequest(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "render: %v\n", err)
+		return 1
+	}
+
+	log := logr.Discard()
+	if *verbose {
+		log = zap.New(zap.WriteTo(stderr))
+	}
+	mgr := NewManager(log, "").WithOffline(true)
+	if *registryDir != "" {
+		mgr = mgr.WithServiceRegistry(newServiceRegistry(*registryDir))
+	}
+	resp, err := mgr.RunFunction(context.Background(), req)
+	if err != nil {
+		fmt.Fprintf(stderr, "render: %v\n", err)
+		return 1
+	}
+
+	if *full {
+		err = writeYAMLMessage(stdout, resp)
+	} else {
+		err = writeDesiredYAML(stdout, resp)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "render: %v\n", err)
+		return 1
+	}
+
+	// Results explain what happened; fatal ones fail the command like they fail the pipeline
+	code := 0
+	for _, result := range resp.GetResults() {
+		fmt.Fprintf(stderr, "%s %s: %s\n", result.GetSeverity(), result.GetReason(), result.GetMessage())
+		if result.GetSeverity() == fnv1.Severity_SEVERITY_FATAL {
+			code = 1
+		}
+	}
+	return code
+}
+
+// readRunFunctionRequest reads a RunFunctionRequest from a YAML or JSON file in protojson form,
+// as written by --record-dir
+func readRunFunctionRequest(path string) (*fnv1.RunFunctionRequest, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	jsonRaw, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	req := &fnv1.RunFunctionRequest{}
+	if err := protojson.Unmarshal(jsonRaw, req); err != nil {
+		return nil, fmt.Errorf("invalid RunFunctionRequest in %s: %w", path, err)
+	}
+	return req, nil
+}
+
+// writeDesiredYAML prints the desired composite followed by the desired resources sorted by key
+func writeDesiredYAML(w io.Writer, resp *fnv1.RunFunctionResponse) error {
+	desired := resp.GetDesired()
+	if composite := desired.GetComposite().GetResource(); composite != nil {
+		if err := writeYAMLDocument(w, "composite", composite.AsMap()); err != nil {
+			return err
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(desired.GetResources())) {
+		if err := writeYAMLDocument(w, key, desired.GetResources()[key].GetResource().AsMap()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYAMLDocument prints one YAML document, headed by a comment naming its resource key
+func writeYAMLDocument(w io.Writer, key string, obj map[string]any) error {
+	raw, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", key, err)
+	}
+	_, err = fmt.Fprintf(w, "---\n# %s\n%s", key, raw)
+	return err
+}
+
+// writeYAMLMessage prints a protobuf message as YAML
+func writeYAMLMessage(w io.Writer, msg proto.Message) error {
+	jsonRaw, err := protojson.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+	raw, err := yaml.JSONToYAML(jsonRaw)
+	if err != nil {
+		return fmt.Errorf("failed to convert response to YAML: %w", err)
+	}
+	_, err = w.Write(raw)
+	return err
+}
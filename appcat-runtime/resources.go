@@ -2,51 +2,22 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
 	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
 	"github.com/go-logr/logr"
+	"github.com/vshn/appcat-poc/appcat-runtime/releasehealth"
 	"google.golang.org/protobuf/types/known/structpb"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
-// getOrGeneratePassword retrieves existing password from observed Secret or generates new one
-func getOrGeneratePassword(observedResources map[string]*fnv1.Resource, instanceName string, log logr.Logger) (string, error) {
-	// Check for existing Secret in observed resources
-	if secretResource, exists := observedResources["secret"]; exists && secretResource != nil {
-		secretMap := secretResource.Resource.AsMap()
-		paved := fieldpath.Pave(secretMap)
-
-		// Try to extract existing password from Secret data
-		if dataRaw, err := paved.GetValue("data.password"); err == nil {
-			if passwordBase64, ok := dataRaw.(string); ok && passwordBase64 != "" {
-				// Decode base64 (Kubernetes stores Secret data as base64)
-				if passwordBytes, err := base64.StdEncoding.DecodeString(passwordBase64); err == nil {
-					log.Info("Reusing existing password from Secret", "instance", instanceName)
-					return string(passwordBytes), nil
-				}
-			}
-		}
-	}
-
-	// No existing password - generate new one
-	log.Info("Generating new password", "instance", instanceName)
-	return generateRandomPassword(32), nil
-}
-
-// generateRandomPassword generates a random base64 password
-func generateRandomPassword(length int) string {
-	bytes := make([]byte, length)
-	rand.Read(bytes)
-	return base64.URLEncoding.EncodeToString(bytes)[:length]
-}
-
 // getSecretName extracts secret name from writeConnectionSecretToRef or falls back to composite name
-func getSecretName(composite *fnv1.Resource, compositeNamespace string, log logr.Logger) (string, string, error) {
+func getSecretName(composite *fnv1.Resource, compositeNamespace string, results *Results, log logr.Logger) (string, string, error) {
 	compositeMap := composite.Resource.AsMap()
 	paved := fieldpath.Pave(compositeMap)
 
@@ -60,6 +31,7 @@ func getSecretName(composite *fnv1.Resource, compositeNamespace string, log logr
 	writeSecretRef, err := paved.GetValue("spec.writeConnectionSecretToRef")
 	if err != nil || writeSecretRef == nil {
 		log.Info("No writeConnectionSecretToRef, using composite name", "name", compositeName)
+		results.Warning("NoConnectionSecretRef", "No writeConnectionSecretToRef set, connection Secret is named %s", compositeName)
 		return compositeName, compositeNamespace, nil
 	}
 
@@ -87,136 +59,498 @@ func getSecretName(composite *fnv1.Resource, compositeNamespace string, log logr
 	return secretName, secretNamespace, nil
 }
 
+// Crossplane labels identifying the claim that owns a composite
+const (
+	labelClaimName      = "crossplane.io/claim-name"
+	labelClaimNamespace = "crossplane.io/claim-namespace"
+)
+
+// Labels stamped on generated resources to trace them back to the requesting claim
+const (
+	LabelClaimName      = "appcat.vshn.io/claim-name"
+	LabelClaimNamespace = "appcat.vshn.io/claim-namespace"
+)
+
+// ClaimReference identifies the claim (or namespaced composite) that requested an instance
+type ClaimReference struct {
+	Name      string
+	Namespace string
+}
+
+// getClaimReference extracts the claim reference from the composite's Crossplane labels
+// Namespaced composites without a claim are their own reference
+func getClaimReference(composite *fnv1.Resource, instanceName, compositeNamespace string) ClaimReference {
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+
+	ref := ClaimReference{Name: instanceName, Namespace: compositeNamespace}
+	if name, err := paved.GetString(fmt.Sprintf("metadata.labels[%s]", labelClaimName)); err == nil && name != "" {
+		ref.Name = name
+	}
+	if ns, err := paved.GetString(fmt.Sprintf("metadata.labels[%s]", labelClaimNamespace)); err == nil && ns != "" {
+		ref.Namespace = ns
+	}
+	return ref
+}
+
 // generateResources creates the desired Kubernetes resources
-// Returns: resources, connectionDetails, error
+// Returns: resources, connectionDetails, the one-time link to create for a new password (if any), error
 func generateResources(
 	ctx context.Context,
 	composite *fnv1.Resource,
 	observedResources map[string]*fnv1.Resource,
 	mergedConfig map[string]interface{},
+	release ReleaseTarget,
+	results *Results,
 	log logr.Logger,
-) (map[string]*fnv1.Resource, map[string][]byte, error) {
+) (map[string]*fnv1.Resource, map[string][]byte, *oneTimeLinkRequest, error) {
 	// Extract instance name from composite metadata
 	compositeMap := composite.Resource.AsMap()
 	paved := fieldpath.Pave(compositeMap)
 
-	instanceName, err := paved.GetString("metadata.name")
+	compositeName, err := paved.GetString("metadata.name")
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get instance name: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to get composite name: %w", err)
+	}
+	instanceName, err := getInstanceName(composite, mergedConfig)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	// Get composite namespace - all resources go in the same namespace for namespace-scoped composites
 	compositeNamespace, err := paved.GetString("metadata.namespace")
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get composite namespace: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to get composite namespace: %w", err)
+	}
+
+	// Without an upgrade in progress the Release is named after the instance
+	if release.Key == "" {
+		release = ReleaseTarget{Key: releaseKey, Name: instanceName, ServingName: instanceName}
 	}
 
 	// For namespace-scoped Releases, the Helm chart deploys to the same namespace as the Release resource
+	claim := getClaimReference(composite, compositeName, compositeNamespace)
+
 	log.Info("Generating resources",
 		"instance", instanceName,
-		"compositeNamespace", compositeNamespace)
+		"compositeNamespace", compositeNamespace,
+		"claimName", claim.Name,
+		"claimNamespace", claim.Namespace)
 
 	resources := make(map[string]*fnv1.Resource)
+	var linkRequest *oneTimeLinkRequest
 
-	// 1. Get or generate password
-	password, err := getOrGeneratePassword(observedResources, instanceName, log)
+	// 1. Get the password from the service's secret sources
+	secretStore, err := getSecretStore(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid secret store config: %w", err)
+	}
+	// A password due for rotation is generated anew instead of reused
+	passwordRotatedAt, rotatePassword := getPasswordRotation(mergedConfig)
+	lookup := SecretLookup{
+		Composite:        composite,
+		Observed:         observedResources,
+		InstanceName:     instanceName,
+		RotatedAt:        passwordRotatedAt,
+		ExternalPassword: getExternalPassword(mergedConfig),
+		Results:          results,
+		Log:              log,
+	}
+	var password string
+	if rotatePassword {
+		password, err = secretStore.rotatedPassword(lookup)
+	} else {
+		password, err = secretStore.password(lookup)
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get password: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to get password: %w", err)
 	}
 
 	// 2. Extract chart and Helm values configuration
 	chartRepo, chartName, chartVersion, err := extractChartConfig(mergedConfig)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	helmValues, ok := mergedConfig["helmValues"].(map[string]any)
 	if !ok {
-		return nil, nil, fmt.Errorf("helmValues not found in merged config")
+		return nil, nil, nil, fmt.Errorf("helmValues not found in merged config")
 	}
 
 	// 3. Process connection secret configuration (optional)
 	connectionSecret, err := getConnectionSecretConfig(mergedConfig)
 	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid connection secret config: %w", err)
+	}
+	if connectionSecret == nil {
 		log.Info("No connection secret configured")
-		connectionSecret = nil
 	}
 
 	var secretName, secretNamespace string
 	if connectionSecret != nil {
-		secretName, secretNamespace, err = getSecretName(composite, compositeNamespace, log)
+		secretName, secretNamespace, err = getSecretName(composite, compositeNamespace, results, log)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get secret name: %w", err)
+			return nil, nil, nil, fmt.Errorf("failed to get secret name: %w", err)
 		}
 
 		// Inject password and secret name into Helm values
 		if connectionSecret.PasswordPath != "" {
 			if err := injectPasswordIntoHelmValues(helmValues, connectionSecret.PasswordPath, password); err != nil {
-				return nil, nil, fmt.Errorf("failed to inject password: %w", err)
+				return nil, nil, nil, fmt.Errorf("failed to inject password: %w", err)
 			}
 		}
 		if connectionSecret.SecretNamePath != "" {
 			paved := fieldpath.Pave(helmValues)
 			if err := paved.SetValue(connectionSecret.SecretNamePath, secretName); err != nil {
-				return nil, nil, fmt.Errorf("failed to inject secret name: %w", err)
+				return nil, nil, nil, fmt.Errorf("failed to inject secret name: %w", err)
 			}
 		}
 	}
 
-	// 4. Create HelmRelease resource
-	helmRelease := NewHelmReleaseBuilder(instanceName).
+	// 3a. Wire the credentials into the chart's own auth values (optional)
+	secretMapping, err := getSecretMapping(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid secret mapping: %w", err)
+	}
+	if secretMapping != nil {
+		variables := map[string]string{
+			"instanceName":   release.ServingName,
+			"namespace":      compositeNamespace,
+			"claimName":      claim.Name,
+			"claimNamespace": claim.Namespace,
+			"password":       password,
+		}
+		if connectionSecret != nil {
+			variables["secretName"] = secretName
+		}
+		if err := applySecretMapping(helmValues, secretMapping, variables); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to apply secret mapping: %w", err)
+		}
+		log.Info("Applied secret mapping", "paths", len(secretMapping))
+	}
+
+	// 3b. Run the instance under its own ServiceAccount (optional)
+	rbacConfig, err := getRBACConfig(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid rbac config: %w", err)
+	}
+	if err := applyServiceAccount(helmValues, rbacConfig, instanceName); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 4. Create HelmRelease resource, moving bulky values into a Secret if the Release would get too large
+	threshold, err := getExternalizeThreshold(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid values externalization config: %w", err)
+	}
+	// The values of additional charts live below their keys, e.g. exporter.*, and go to their own Releases
+	charts, err := getCompanionCharts(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid charts config: %w", err)
+	}
+	mainValues, chartValues, err := splitChartValues(helmValues, charts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	inlineValues, externalValues, err := externalizeValues(mainValues, threshold)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to externalize helm values: %w", err)
+	}
+
+	// Copy the credentials of an authenticated chart repository next to the Releases (optional)
+	pullSecret, err := generateChartPullSecret(resources, mergedConfig, instanceName, compositeNamespace, claim)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	helmReleaseBuilder := NewHelmReleaseBuilder(release.Name).
 		WithNamespace(compositeNamespace).
 		WithChart(chartRepo, chartName, chartVersion).
-		WithValues(helmValues).
-		Build()
+		WithValues(inlineValues).
+		WithLabel(LabelClaimName, claim.Name).
+		WithLabel(LabelClaimNamespace, claim.Namespace)
+
+	// OCI registries have no index, the chart is pulled by its tag
+	if isOCIRepository(chartRepo) {
+		helmReleaseBuilder = helmReleaseBuilder.WithChartURL(ociChartURL(chartRepo, chartName, chartVersion))
+	}
+	if pullSecret != "" {
+		helmReleaseBuilder = helmReleaseBuilder.WithPullSecretRef(pullSecret)
+	}
+
+	// Install and upgrade options such as waiting for slow-starting charts (optional)
+	releaseOptions, err := getReleaseOptions(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid releaseOptions config: %w", err)
+	}
+	helmReleaseBuilder = applyReleaseOptions(helmReleaseBuilder, releaseOptions)
+
+	// Target cluster selected by the placement (optional)
+	if ref := getProviderConfigRef(mergedConfig); ref != nil {
+		helmReleaseBuilder = helmReleaseBuilder.WithProviderConfigRef(ref.Kind, ref.Name)
+	}
+
+	// Controller the Release is deployed with (optional)
+	output, err := getOutputConfig(mergedConfig)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid output config: %w", err)
+	}
+
+	// Connection details read from the deployed objects, e.g. a generated Secret (optional)
+	if connectionSecret != nil && len(connectionSecret.FromRelease) > 0 {
+		if output != nil {
+			return nil, nil, nil, fmt.Errorf("connectionSecret.fromRelease requires the %s output mode", OutputModeProviderHelm)
+		}
+		helmReleaseBuilder = applyReleaseConnectionDetails(helmReleaseBuilder, connectionSecret.FromRelease, release.Name, compositeNamespace)
+	}
+
+	// Service label so instances of all services can be told apart fleet-wide
+	if service, _ := mergedConfig["service"].(string); service != "" {
+		helmReleaseBuilder = helmReleaseBuilder.WithLabel(LabelService, service)
+	}
+
+	// Metering label for fleet-wide version reporting
+	appVersion := getChartAppVersion(mergedConfig)
+	if label := sanitizeLabelValue(appVersion); label != "" {
+		helmReleaseBuilder = helmReleaseBuilder.WithLabel(LabelAppVersion, label)
+	}
+	recordInstanceInfo(compositeNamespace, instanceName, chartName, chartVersion, appVersion)
 
-	helmReleaseResource, err := toFunctionResource(helmRelease)
+	health := releasehealth.Interpret(observedResources[release.Key])
+	recordReleaseHealth(compositeNamespace, instanceName, health.Health)
+	reportReleaseHealth(health, release.Name, results)
+
+	// Values kept in Secrets and ConfigMaps (optional), merged before the externalized values so the function's win
+	valuesFrom, err := getValuesFrom(mergedConfig)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to convert helm release: %w", err)
+		return nil, nil, nil, fmt.Errorf("invalid valuesFrom config: %w", err)
+	}
+	if len(valuesFrom) > 0 {
+		variables := map[string]string{
+			"instanceName":   release.ServingName,
+			"namespace":      compositeNamespace,
+			"claimName":      claim.Name,
+			"claimNamespace": claim.Namespace,
+		}
+		if connectionSecret != nil {
+			variables["secretName"] = secretName
+		}
+		if helmReleaseBuilder, err = applyValuesFrom(helmReleaseBuilder, valuesFrom, variables); err != nil {
+			return nil, nil, nil, err
+		}
+		log.Info("Referenced helm values from Secrets and ConfigMaps", "references", len(valuesFrom))
+	}
+
+	if externalValues != nil {
+		externalJSON, err := json.Marshal(externalValues)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to marshal externalized values: %w", err)
+		}
+
+		// The moved sections may hold the injected password, so they're kept in a Secret
+		valuesSecretName := release.Name + "-values"
+		valuesSecret := NewSecretBuilder(valuesSecretName, compositeNamespace).
+			WithData(externalValuesKey, externalJSON).
+			WithLabel("app.kubernetes.io/managed-by", "crossplane").
+			WithLabel("app.kubernetes.io/instance", instanceName).
+			WithLabel("app.kubernetes.io/component", "helm-values").
+			WithLabel(LabelClaimName, claim.Name).
+			WithLabel(LabelClaimNamespace, claim.Namespace).
+			Build()
+
+		valuesSecretResource, err := toFunctionResource(valuesSecret)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to convert values secret: %w", err)
+		}
+		resources[valuesSecretKey(release.Key)] = valuesSecretResource
+		helmReleaseBuilder = helmReleaseBuilder.WithValuesFromSecret(valuesSecretName, externalValuesKey)
+
+		log.Info("Externalized bulky helm values into Secret",
+			"secret", valuesSecretName,
+			"sections", len(externalValues),
+			"thresholdBytes", threshold)
+		results.Normal("ValuesExternalized", "Moved %d helm value sections into Secret %s to stay below %d bytes", len(externalValues), valuesSecretName, threshold)
+	}
+
+	helmRelease := helmReleaseBuilder.Build()
+
+	// The Release is handed to another controller, e.g. Flux, in their output modes
+	releaseResources, err := releaseOutputResources(release.Key, helmRelease, output)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	maps.Copy(resources, releaseResources)
+	if valuesSecret, ok := resources[valuesSecretKey(release.Key)]; ok {
+		if resources[valuesSecretKey(release.Key)], err = wrapOutputResource(valuesSecret, helmRelease, output); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to wrap values secret: %w", err)
+		}
+	}
+
+	// 4a. Create the Releases of the additional charts of multi-chart services
+	if err := generateCompanionReleases(resources, mergedConfig, charts, chartValues, pullSecret, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
 	}
-	resources["helmrelease"] = helmReleaseResource
 
 	// 5. Create connection secret resource (if configured)
 	connDetails := make(map[string][]byte)
 	if connectionSecret != nil {
 		// Build variable map for template substitution
 		variables := map[string]string{
-			"instanceName": instanceName,
-			"namespace":    compositeNamespace,
-			"password":     password,
+			"instanceName":   release.ServingName,
+			"namespace":      compositeNamespace,
+			"claimName":      claim.Name,
+			"claimNamespace": claim.Namespace,
+			"password":       password,
 		}
 
 		// Generate connection details from templates
 		secretBuilder := NewSecretBuilder(secretName, secretNamespace)
 
+		platformOnly := 0
 		for _, field := range connectionSecret.Fields {
+			// With one-time link delivery the password never lands in connection details
+			if connectionSecret.OneTimeLink != nil && strings.Contains(field.Value, "${password}") {
+				continue
+			}
+
 			// Substitute variables in template
 			value := substituteVariables(field.Value, variables)
 			connDetails[field.Key] = []byte(value)
+
+			// Platform-only fields stay in the composite connection details and are not copied to the claim namespace
+			if field.Visibility == VisibilityPlatform {
+				platformOnly++
+				continue
+			}
 			secretBuilder = secretBuilder.WithData(field.Key, []byte(value))
 		}
 
+		// Details read from the serving Release's objects, keeping their last values until its connection secret is fetched
+		releaseDetails := getReleaseConnection(mergedConfig, release.ServingName)
+		for _, detail := range connectionSecret.FromRelease {
+			value, ok := releaseDetails[detail.Key].(string)
+			if !ok {
+				if value, ok = observedConnectionValue(observedResources, detail.Key); !ok {
+					continue
+				}
+			}
+			connDetails[detail.Key] = []byte(value)
+			if detail.Visibility == VisibilityPlatform {
+				platformOnly++
+				continue
+			}
+			secretBuilder = secretBuilder.WithData(detail.Key, []byte(value))
+		}
+
+		// A new link is created by the manager once the render is final, until then it's left out
+		if connectionSecret.OneTimeLink != nil {
+			var link string
+			link, linkRequest, err = publishedOneTimeLink(connectionSecret.OneTimeLink, secretStore, composite, observedResources, password, rotatePassword)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to deliver password link: %w", err)
+			}
+			if linkRequest == nil {
+				connDetails[connectionSecret.OneTimeLink.Key] = []byte(link)
+				secretBuilder = secretBuilder.WithData(connectionSecret.OneTimeLink.Key, []byte(link))
+			}
+		}
+
 		log.Info("Creating connection Secret",
 			"secretName", secretName,
 			"secretNamespace", secretNamespace,
 			"instanceName", instanceName,
-			"fieldsCount", len(connectionSecret.Fields))
+			"fieldsCount", len(connectionSecret.Fields),
+			"platformOnlyCount", platformOnly)
 
+		if passwordRotatedAt != "" {
+			secretBuilder = secretBuilder.WithAnnotation(AnnotationPasswordRotatedAt, passwordRotatedAt)
+		}
 		secret := secretBuilder.
 			WithLabel("app.kubernetes.io/managed-by", "crossplane").
 			WithLabel("app.kubernetes.io/instance", instanceName).
 			WithLabel("app.kubernetes.io/component", "connection-secret").
+			WithLabel(LabelClaimName, claim.Name).
+			WithLabel(LabelClaimNamespace, claim.Namespace).
 			Build()
 
 		secretResource, err := toFunctionResource(secret)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to convert secret to function resource: %w", err)
+			return nil, nil, nil, fmt.Errorf("failed to convert secret to function resource: %w", err)
 		}
 		resources["secret"] = secretResource
+
+		// Publish the connection Secret to the configured sinks (e.g. Vault), including a link created later
+		keys := slices.Collect(maps.Keys(secret.Data))
+		if linkRequest != nil {
+			keys = append(keys, linkRequest.config.Key)
+		}
+		slices.Sort(keys)
+		sinkResources, err := secretStore.resources(SecretTarget{
+			SecretName:      secretName,
+			SecretNamespace: secretNamespace,
+			Keys:            keys,
+			InstanceName:    instanceName,
+			Namespace:       compositeNamespace,
+			Claim:           claim,
+		})
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to publish connection secret: %w", err)
+		}
+		maps.Copy(resources, sinkResources)
+	}
+
+	// 6. Create network isolation policies (if enabled)
+	if err := generateNetworkPolicies(resources, mergedConfig, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 7. Create PodDisruptionBudget for multi-replica instances (if configured)
+	if err := generatePodDisruptionBudget(resources, mergedConfig, helmValues, instanceName, compositeNamespace, claim, results, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 8. Create log Output shipping the instance's logs to the tenant's sink (if requested)
+	if err := generateLogOutput(resources, mergedConfig, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 9. Create NetworkPolicy admitting the tenant's Prometheus to the metrics port (if requested)
+	if err := generateExternalScrapePolicy(resources, mergedConfig, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 10. Create the instance's ServiceAccount and the customer's admin binding (if configured)
+	if err := generateRBAC(resources, mergedConfig, composite, instanceName, compositeNamespace, claim, results, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 11. Create the ServiceMonitor and PodMonitor for the platform's Prometheus (if configured)
+	if err := generateMonitors(resources, mergedConfig, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 12. Create the service's alert rules for the instance (if configured)
+	alertVariables := map[string]string{
+		"instanceName":   release.ServingName,
+		"namespace":      compositeNamespace,
+		"claimName":      claim.Name,
+		"claimNamespace": claim.Namespace,
+	}
+	if err := generateAlertRules(resources, mergedConfig, alertVariables, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 13. Create the backup schedule and its repository and bucket Secrets (if configured)
+	if err := generateBackup(resources, observedResources, mergedConfig, alertVariables, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// 14. Create the instance's TLS Issuers and Certificates (if spec.tls is enabled)
+	if err := generateTLS(resources, mergedConfig, instanceName, compositeNamespace, claim, log); err != nil {
+		return nil, nil, nil, err
 	}
 
 	log.Info("Generated all resources", "count", len(resources))
-	return resources, connDetails, nil
+	return resources, connDetails, linkRequest, nil
 }
 
 // toFunctionResource converts a Kubernetes runtime.Object to a function Resource
@@ -263,10 +597,19 @@ func extractChartConfig(mergedConfig map[string]any) (repo, name, version string
 	return repo, name, version, nil
 }
 
+// Connection detail visibility values for SecretFieldTemplate.Visibility
+const (
+	// VisibilityClaim publishes the field in the composite connection details and the claim-namespace Secret
+	VisibilityClaim = "claim"
+	// VisibilityPlatform publishes the field in the composite connection details only
+	VisibilityPlatform = "platform"
+)
+
 // SecretFieldTemplate represents a single secret field with templated value
 type SecretFieldTemplate struct {
-	Key   string
-	Value string
+	Key        string
+	Value      string
+	Visibility string
 }
 
 // ConnectionSecretConfig defines the structure and content of connection secrets
@@ -274,13 +617,18 @@ type ConnectionSecretConfig struct {
 	Fields         []SecretFieldTemplate
 	PasswordPath   string
 	SecretNamePath string
+	// OneTimeLink replaces the password in connection details with a one-time retrieval link
+	OneTimeLink *OneTimeLinkConfig
+	// FromRelease are read from the objects the chart deploys
+	FromRelease []ReleaseConnectionDetail
 }
 
 // getConnectionSecretConfig extracts connectionSecret configuration from merged config
+// Returns nil without error if no connectionSecret is configured
 func getConnectionSecretConfig(mergedConfig map[string]any) (*ConnectionSecretConfig, error) {
 	secretConfig, ok := mergedConfig["connectionSecret"].(map[string]any)
 	if !ok {
-		return nil, fmt.Errorf("connectionSecret not found in merged config")
+		return nil, nil
 	}
 
 	// Parse fields array
@@ -290,7 +638,15 @@ func getConnectionSecretConfig(mergedConfig map[string]any) (*ConnectionSecretCo
 			if fieldMap, ok := fieldRaw.(map[string]any); ok {
 				key, _ := fieldMap["key"].(string)
 				value, _ := fieldMap["value"].(string)
-				fields = append(fields, SecretFieldTemplate{Key: key, Value: value})
+				visibility, _ := fieldMap["visibility"].(string)
+				switch visibility {
+				case "":
+					visibility = VisibilityClaim
+				case VisibilityClaim, VisibilityPlatform:
+				default:
+					return nil, fmt.Errorf("field %s: unknown visibility %q", key, visibility)
+				}
+				fields = append(fields, SecretFieldTemplate{Key: key, Value: value, Visibility: visibility})
 			}
 		}
 	}
@@ -298,15 +654,35 @@ func getConnectionSecretConfig(mergedConfig map[string]any) (*ConnectionSecretCo
 	passwordPath, _ := secretConfig["passwordPath"].(string)
 	secretNamePath, _ := secretConfig["secretNamePath"].(string)
 
+	var oneTimeLink *OneTimeLinkConfig
+	if linkConfig, ok := secretConfig["oneTimeLink"].(map[string]any); ok {
+		var err error
+		oneTimeLink, err = parseOneTimeLinkConfig(linkConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fromRelease []ReleaseConnectionDetail
+	if entries, ok := secretConfig["fromRelease"]; ok {
+		var err error
+		fromRelease, err = parseReleaseConnectionDetails(entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &ConnectionSecretConfig{
 		Fields:         fields,
 		PasswordPath:   passwordPath,
 		SecretNamePath: secretNamePath,
+		OneTimeLink:    oneTimeLink,
+		FromRelease:    fromRelease,
 	}, nil
 }
 
 // substituteVariables performs ${var} substitution in template strings
-// Supported variables: ${instanceName}, ${namespace}, ${password}
+// Supported variables: ${instanceName}, ${namespace}, ${claimName}, ${claimNamespace}, ${password}
 func substituteVariables(template string, variables map[string]string) string {
 	result := template
 	for key, value := range variables {
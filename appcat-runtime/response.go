@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ConditionRenderSynced reports whether the last render succeeded
+// Crossplane reserves Synced and Ready, so failures surface under this type instead
+const ConditionRenderSynced = "RenderSynced"
+
+// phaseError marks a render error with the standard condition it invalidates
+type phaseError struct {
+	condition string
+	reason    string
+	err       error
+}
+
+// Error implements error, reporting the wrapped error's message
+func (e *phaseError) Error() string { return e.err.Error() }
+
+// Unwrap returns the wrapped error for errors.Is and errors.As
+func (e *phaseError) Unwrap() error { return e.err }
+
+// failedPhase wraps err so the failure response also sets condition to False
+func failedPhase(condition, reason string, err error) error {
+	return &phaseError{condition: condition, reason: reason, err: err}
+}
+
+// renderFailure builds the response for a failed render
+// A fatal result stops the pipeline, so Crossplane keeps the previously applied resources,
+// while the conditions and event explain the failure on the composite and claim
+func renderFailure(ctx context.Context, req *fnv1.RunFunctionRequest, results *Results, err error) *fnv1.RunFunctionResponse {
+	span := trace.SpanFromContext(ctx)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	conditions := []*fnv1.Condition{
+		newCondition(ConditionRenderSynced, fnv1.Status_STATUS_CONDITION_FALSE, "RenderFailed", err.Error()),
+	}
+	var pe *phaseError
+	if errors.As(err, &pe) {
+		conditions = append(conditions, newCondition(pe.condition, fnv1.Status_STATUS_CONDITION_FALSE, pe.reason, err.Error()))
+	}
+	results.Add(newResult(fnv1.Severity_SEVERITY_FATAL, "RenderFailed", err.Error()))
+
+	return &fnv1.RunFunctionResponse{
+		Meta:       &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)},
+		Desired:    req.GetDesired(),
+		Conditions: conditions,
+		Results:    results.List(),
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Desired resource keys of a restore
+const (
+	restoreKey      = "restore"
+	restoreClaimKey = "restore-claim"
+)
+
+// requiredRestoreSnapshots names the required K8up Snapshots a point in time is resolved against
+const requiredRestoreSnapshots = "restore-snapshots"
+
+// Restore phases reported in status.restore.phase
+const (
+	// RestorePhasePending means the snapshot to restore hasn't been resolved yet
+	RestorePhasePending = "Pending"
+	// RestorePhaseRestoring means the K8up Restore is running; the Release is held back
+	RestorePhaseRestoring = "Restoring"
+	// RestorePhaseCompleted means the data is restored and the Release can be installed
+	RestorePhaseCompleted = "Completed"
+	// RestorePhaseFailed means the K8up Restore failed; the Release stays held back
+	RestorePhaseFailed = "Failed"
+)
+
+// RestoreConfig defines where restored data goes before the chart is installed
+type RestoreConfig struct {
+	// ClaimName is the PersistentVolumeClaim the chart's workload will use, e.g. "redis-data-${instanceName}-master-0"
+	ClaimName string
+	// SizePath and StorageClassPath are the helm values sizing the chart's volume
+	SizePath         string
+	StorageClassPath string
+}
+
+// RestoreSpec is the tenant's restore request from spec.restore
+type RestoreSpec struct {
+	// Source is the instance whose backups are restored, in the same namespace
+	Source string
+	// Snapshot is a restic snapshot ID; PointInTime selects the latest snapshot taken before it instead
+	Snapshot    string
+	PointInTime time.Time
+}
+
+// restoreDecision is the outcome of seeding a new instance from a backup
+type restoreDecision struct {
+	requirements *fnv1.Requirements
+	status       map[string]any
+}
+
+// getRestoreConfig extracts restore configuration from service config
+// Returns nil without error if the service doesn't support restores
+func getRestoreConfig(serviceConfig map[string]any) (*RestoreConfig, error) {
+	restore, ok := serviceConfig["restore"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	config := &RestoreConfig{}
+	config.ClaimName, _ = restore["claimName"].(string)
+	config.SizePath, _ = restore["sizePath"].(string)
+	config.StorageClassPath, _ = restore["storageClassPath"].(string)
+	if config.ClaimName == "" || config.SizePath == "" {
+		return nil, fmt.Errorf("claimName and sizePath are required")
+	}
+	return config, nil
+}
+
+// getRestoreSpec extracts spec.restore from the user spec
+// Returns nil without error if the tenant didn't request a restore
+func getRestoreSpec(userSpec map[string]any) (*RestoreSpec, error) {
+	restore, ok := userSpec["restore"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	spec := &RestoreSpec{}
+	spec.Source, _ = restore["source"].(string)
+	if spec.Source == "" {
+		return nil, fmt.Errorf("restore.source is required")
+	}
+	spec.Snapshot, _ = restore["snapshot"].(string)
+	if pointInTime, _ := restore["pointInTime"].(string); pointInTime != "" {
+		if spec.Snapshot != "" {
+			return nil, fmt.Errorf("restore.snapshot and restore.pointInTime are mutually exclusive")
+		}
+		t, err := time.Parse(time.RFC3339, pointInTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid restore.pointInTime: %w", err)
+		}
+		spec.PointInTime = t
+	}
+	return spec, nil
+}
+
+// generateRestore seeds a new instance from a backup of another instance: it pre-creates the chart's
+// volume claim, restores the snapshot into it with a K8up Restore and holds back the Release until
+// the restore completed
+// Existing instances are never restored into; the claim is kept once created, since dropping it would delete the data
+func generateRestore(
+	req *fnv1.RunFunctionRequest,
+	resources map[string]*fnv1.Resource,
+	serviceConfig, mergedConfig, userSpec map[string]any,
+	composite *fnv1.Resource,
+	release ReleaseTarget,
+	results *Results,
+	log logr.Logger,
+) (*restoreDecision, error) {
+	decision := &restoreDecision{}
+	observed := req.GetObserved().GetResources()
+
+	// The claim is kept as observed once created: dropping it would delete the data, and re-applying it without its
+	// spec would release the required, immutable accessModes and resources
+	if claim := observed[restoreClaimKey]; claim != nil {
+		kept, err := observedDesired(claim)
+		if err != nil {
+			return decision, fmt.Errorf("failed to keep restored volume claim: %w", err)
+		}
+		resources[restoreClaimKey] = kept
+	}
+
+	spec, err := getRestoreSpec(userSpec)
+	if err != nil {
+		return decision, err
+	}
+	if spec == nil {
+		return decision, nil
+	}
+	config, err := getRestoreConfig(serviceConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid restore config: %w", err)
+	}
+	if config == nil {
+		log.Info("Service doesn't support restores, ignoring spec.restore")
+		results.Warning("RestoreUnsupported", "This service doesn't support restores, spec.restore is ignored")
+		return decision, nil
+	}
+	if observed[release.Key] != nil {
+		log.Info("Instance already exists, ignoring spec.restore", "source", spec.Source)
+		return decision, nil
+	}
+	backup, err := getBackupConfig(serviceConfig)
+	if err != nil {
+		return decision, fmt.Errorf("invalid backup config: %w", err)
+	}
+	if backup == nil {
+		return decision, fmt.Errorf("restores require a backup configuration")
+	}
+
+	namespace, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.namespace")
+	bucket := substituteVariables(backup.Bucket, map[string]string{"instanceName": spec.Source, "namespace": namespace})
+	decision.status = map[string]any{"source": spec.Source, "phase": RestorePhasePending}
+
+	snapshot := spec.Snapshot
+	if !spec.PointInTime.IsZero() {
+		decision.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{
+			requiredRestoreSnapshots: {
+				ApiVersion: "k8up.io/v1",
+				Kind:       "Snapshot",
+				Match:      &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: map[string]string{}}},
+				Namespace:  &namespace,
+			},
+		}}
+		found, fetched := getRequiredResources(req, requiredRestoreSnapshots)
+		if !fetched {
+			delete(resources, release.Key)
+			return decision, nil
+		}
+		snapshot = latestSnapshotBefore(found, bucket, spec.PointInTime)
+		if snapshot == "" {
+			return decision, fmt.Errorf("no snapshot of %s taken before %s", spec.Source, spec.PointInTime.Format(time.RFC3339))
+		}
+	}
+	if snapshot != "" {
+		decision.status["snapshot"] = snapshot
+	}
+
+	helmValues, _ := mergedConfig["helmValues"].(map[string]any)
+	paved := fieldpath.Pave(helmValues)
+	sizeRaw, err := paved.GetString(config.SizePath)
+	if err != nil {
+		return decision, fmt.Errorf("failed to get volume size from %s: %w", config.SizePath, err)
+	}
+	size, err := resource.ParseQuantity(sizeRaw)
+	if err != nil {
+		return decision, fmt.Errorf("invalid volume size %q at %s: %w", sizeRaw, config.SizePath, err)
+	}
+	claimName := substituteVariables(config.ClaimName, map[string]string{"instanceName": release.Name, "namespace": namespace})
+	claim := NewPersistentVolumeClaimBuilder(claimName, namespace, size).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", release.Name).
+		WithLabel("app.kubernetes.io/component", "restore")
+	if config.StorageClassPath != "" {
+		if storageClass, _ := paved.GetString(config.StorageClassPath); storageClass != "" {
+			claim = claim.WithStorageClass(storageClass)
+		}
+	}
+	if resources[restoreClaimKey] == nil {
+		claimResource, err := toFunctionResource(claim.Build())
+		if err != nil {
+			return decision, fmt.Errorf("failed to convert restore volume claim: %w", err)
+		}
+		resources[restoreClaimKey] = claimResource
+	}
+
+	restore := NewRestoreBuilder(release.Name+"-restore", namespace).
+		WithS3Backend(backup.Endpoint, bucket, spec.Source+"-backup-bucket").
+		WithRepositoryPassword(spec.Source+"-backup-repo", "password").
+		WithSnapshot(snapshot).
+		WithClaim(claimName).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", release.Name).
+		WithLabel("app.kubernetes.io/component", "restore").
+		Build()
+	restoreResource, err := toFunctionResource(restore)
+	if err != nil {
+		return decision, fmt.Errorf("failed to convert restore: %w", err)
+	}
+	resources[restoreKey] = restoreResource
+
+	// K8up reports the outcome in the Completed condition
+	completed, ok := getObservedCondition(observed[restoreKey], "Completed")
+	switch {
+	case ok && completed.Status == "True" && completed.Reason == "Succeeded":
+		decision.status["phase"] = RestorePhaseCompleted
+		log.Info("Restore completed, installing release", "source", spec.Source, "snapshot", snapshot)
+		return decision, nil
+	case ok && completed.Status == "True":
+		decision.status["phase"] = RestorePhaseFailed
+		results.Warning("RestoreFailed", "Restoring %s failed: %s", spec.Source, completed.Message)
+	default:
+		decision.status["phase"] = RestorePhaseRestoring
+	}
+	delete(resources, release.Key)
+	log.Info("Holding back release until the restore completed", "source", spec.Source, "phase", decision.status["phase"])
+	return decision, nil
+}
+
+// latestSnapshotBefore returns the ID of the latest K8up Snapshot of the bucket's repository taken at or before t
+func latestSnapshotBefore(snapshots []*fnv1.Resource, bucket string, t time.Time) string {
+	var latestID string
+	var latest time.Time
+	for _, snapshot := range snapshots {
+		paved := fieldpath.Pave(snapshot.GetResource().AsMap())
+		repository, _ := paved.GetString("spec.repository")
+		if !strings.HasSuffix(strings.TrimSuffix(repository, "/"), "/"+bucket) {
+			continue
+		}
+		id, _ := paved.GetString("spec.id")
+		dateRaw, _ := paved.GetString("spec.date")
+		date, err := time.Parse(time.RFC3339, dateRaw)
+		if id == "" || err != nil || date.After(t) {
+			continue
+		}
+		if latestID == "" || date.After(latest) {
+			latestID, latest = id, date
+		}
+	}
+	return latestID
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGenerateRestoreHoldsBackRelease(t *testing.T) {
+	toResource := func(object map[string]any) *fnv1.Resource {
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	completedRestore := func(reason string) *fnv1.Resource {
+		return toResource(map[string]any{
+			"apiVersion": "k8up.io/v1",
+			"kind":       "Restore",
+			"status":     map[string]any{"conditions": []any{map[string]any{"type": "Completed", "status": "True", "reason": reason, "message": "restic exited"}}},
+		})
+	}
+	snapshot := func(id, date string) *fnv1.Resource {
+		return toResource(map[string]any{"spec": map[string]any{"id": id, "date": date, "repository": "s3:https://s3.example.com/redis-prod-backup"}})
+	}
+	serviceConfig := map[string]any{
+		"restore": map[string]any{"claimName": "redis-data-${instanceName}-master-0", "sizePath": "master.persistence.size"},
+		"backup": map[string]any{"bucket": map[string]any{
+			"endpoint":             "https://s3.example.com",
+			"name":                 "${instanceName}-backup",
+			"credentialsSecretRef": map[string]any{"name": "s3-credentials", "namespace": "appcat"},
+		}},
+	}
+	composite := toResource(map[string]any{"metadata": map[string]any{"name": "redis-a", "namespace": "team"}})
+	target := ReleaseTarget{Key: releaseKey, Name: "redis-a", ServingName: "redis-a"}
+
+	cases := map[string]struct {
+		restore      map[string]any
+		observed     map[string]*fnv1.Resource
+		required     map[string]*fnv1.Resources
+		wantPhase    string
+		wantSnapshot string
+		wantHeld     bool
+	}{
+		"Restoring": {
+			restore:   map[string]any{"source": "redis-prod"},
+			wantPhase: RestorePhaseRestoring,
+			wantHeld:  true,
+		},
+		"Succeeded": {
+			restore:   map[string]any{"source": "redis-prod"},
+			observed:  map[string]*fnv1.Resource{restoreKey: completedRestore("Succeeded")},
+			wantPhase: RestorePhaseCompleted,
+		},
+		"Failed": {
+			restore:   map[string]any{"source": "redis-prod"},
+			observed:  map[string]*fnv1.Resource{restoreKey: completedRestore("Failed")},
+			wantPhase: RestorePhaseFailed,
+			wantHeld:  true,
+		},
+		"ExistingInstance": {
+			restore:  map[string]any{"source": "redis-prod"},
+			observed: map[string]*fnv1.Resource{releaseKey: toResource(map[string]any{"kind": "Release"})},
+		},
+		"PointInTimeUnresolved": {
+			restore:   map[string]any{"source": "redis-prod", "pointInTime": "2026-03-01T00:00:00Z"},
+			wantPhase: RestorePhasePending,
+			wantHeld:  true,
+		},
+		"PointInTime": {
+			restore: map[string]any{"source": "redis-prod", "pointInTime": "2026-03-01T00:00:00Z"},
+			required: map[string]*fnv1.Resources{requiredRestoreSnapshots: {Items: []*fnv1.Resource{
+				snapshot("older", "2026-02-01T00:00:00Z"),
+				snapshot("before", "2026-02-28T00:00:00Z"),
+				snapshot("after", "2026-03-02T00:00:00Z"),
+			}}},
+			wantPhase:    RestorePhaseRestoring,
+			wantSnapshot: "before",
+			wantHeld:     true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			resources := map[string]*fnv1.Resource{releaseKey: toResource(map[string]any{"kind": "Release"})}
+			mergedConfig := map[string]any{"helmValues": map[string]any{"master": map[string]any{"persistence": map[string]any{"size": "8Gi"}}}}
+			req := &fnv1.RunFunctionRequest{Observed: &fnv1.State{Composite: composite, Resources: tc.observed}, RequiredResources: tc.required}
+			decision, err := generateRestore(req, resources, serviceConfig, mergedConfig, map[string]any{"restore": tc.restore}, composite, target, &Results{}, logr.Discard())
+			if err != nil {
+				t.Fatalf("generateRestore() error = %v", err)
+			}
+			if held := resources[releaseKey] == nil; held != tc.wantHeld {
+				t.Errorf("release held back = %v, want %v", held, tc.wantHeld)
+			}
+			if phase, _ := decision.status["phase"].(string); phase != tc.wantPhase {
+				t.Errorf("phase = %q, want %q", phase, tc.wantPhase)
+			}
+			if snapshot, _ := decision.status["snapshot"].(string); snapshot != tc.wantSnapshot {
+				t.Errorf("snapshot = %q, want %q", snapshot, tc.wantSnapshot)
+			}
+			if restoring := resources[restoreKey] != nil; restoring != (tc.wantPhase != "" && tc.wantPhase != RestorePhasePending) {
+				t.Errorf("restore rendered = %v in phase %q", restoring, tc.wantPhase)
+			}
+		})
+	}
+}
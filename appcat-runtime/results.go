@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"k8s.io/utils/ptr"
+)
+
+// Results collects the results of a render, which Crossplane emits as events on the composite and claim
+// Warnings cover skipped input and recovered errors, so users see them without reading function logs
+type Results struct {
+	items []*fnv1.Result
+	// docs are the service's documentation links by reason, see linkDocs
+	docs map[string]string
+}
+
+// newResult creates a result targeting both the composite and its claim
+func newResult(severity fnv1.Severity, reason, message string) *fnv1.Result {
+	return &fnv1.Result{
+		Severity: severity,
+		Reason:   ptr.To(reason),
+		Message:  message,
+		Target:   fnv1.Target_TARGET_COMPOSITE_AND_CLAIM.Enum(),
+	}
+}
+
+// Normal records an informational result
+func (r *Results) Normal(reason, format string, args ...any) {
+	r.items = append(r.items, newResult(fnv1.Severity_SEVERITY_NORMAL, reason, fmt.Sprintf(format, args...)))
+}
+
+// Warning records a warning result
+func (r *Results) Warning(reason, format string, args ...any) {
+	r.items = append(r.items, newResult(fnv1.Severity_SEVERITY_WARNING, reason, fmt.Sprintf(format, args...)))
+}
+
+// Add records a prebuilt result
+func (r *Results) Add(result *fnv1.Result) {
+	r.items = append(r.items, result)
+}
+
+// List returns the collected results in the order they were recorded
+func (r *Results) List() []*fnv1.Result {
+	return r.items
+}
+
+// Warnings returns the messages of all warning results
+func (r *Results) Warnings() []string {
+	var warnings []string
+	for _, result := range r.items {
+		if result.GetSeverity() == fnv1.Severity_SEVERITY_WARNING {
+			warnings = append(warnings, result.GetMessage())
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// AnnotationPasswordRotatedAt records on the connection Secret when its password was generated
+const AnnotationPasswordRotatedAt = "appcat.vshn.io/password-rotated-at"
+
+// maxPasswordRotationDays bounds spec.security.passwordRotationDays to ten years
+const maxPasswordRotationDays = 3650
+
+// rotationDecision is the outcome of checking spec.security.passwordRotationDays
+type rotationDecision struct {
+	// status holds status.credentials, nil without a rotation policy
+	status map[string]any
+}
+
+// getPasswordRotationDays extracts spec.security.passwordRotationDays from the user spec
+// Returns 0 without error if the password isn't rotated
+func getPasswordRotationDays(userSpec map[string]any) (int, error) {
+	daysRaw, ok := lookupNumber(fieldpath.Pave(userSpec), "security.passwordRotationDays")
+	if !ok {
+		return 0, nil
+	}
+	days := int(daysRaw)
+	if float64(days) != daysRaw || days < 1 || days > maxPasswordRotationDays {
+		return 0, fmt.Errorf("security.passwordRotationDays must be an integer between 1 and %d", maxPasswordRotationDays)
+	}
+	return days, nil
+}
+
+// applyPasswordRotation decides whether the instance password is due and records mergedConfig["passwordRotation"]
+// for generateResources, which then generates the password anew instead of reusing it
+// The rotation time is stamped on the connection Secret; Secrets created before rotation was enabled count from
+// their creation. The Secret, connection details and helm values all get the new password in the same render
+func applyPasswordRotation(
+	observedResources map[string]*fnv1.Resource,
+	mergedConfig, userSpec map[string]any,
+	results *Results,
+	log logr.Logger,
+) (*rotationDecision, error) {
+	decision := &rotationDecision{}
+	days, err := getPasswordRotationDays(userSpec)
+	if err != nil {
+		return decision, err
+	}
+	if days == 0 {
+		return decision, nil
+	}
+
+	current := now().UTC()
+	rotatedAt, rotate := current, false
+	if secret := observedResources["secret"]; secret != nil {
+		paved := fieldpath.Pave(secret.GetResource().AsMap())
+		stamp, _ := paved.GetString(fmt.Sprintf("metadata.annotations[%s]", AnnotationPasswordRotatedAt))
+		if stamp == "" {
+			stamp, _ = paved.GetString("metadata.creationTimestamp")
+		}
+		if previous, err := time.Parse(time.RFC3339, stamp); err == nil {
+			rotatedAt = previous
+		}
+		if !current.Before(rotatedAt.AddDate(0, 0, days)) {
+			rotatedAt, rotate = current, true
+		}
+	}
+
+	mergedConfig["passwordRotation"] = map[string]any{
+		"rotatedAt": rotatedAt.Format(time.RFC3339),
+		"rotate":    rotate,
+	}
+	decision.status = map[string]any{
+		"passwordRotatedAt":    rotatedAt.Format(time.RFC3339),
+		"nextPasswordRotation": rotatedAt.AddDate(0, 0, days).Format(time.RFC3339),
+	}
+	if rotate {
+		log.Info("Rotating instance password", "rotationDays", days)
+		results.Normal("PasswordRotated", "Rotated the instance password, the next rotation is due %s", decision.status["nextPasswordRotation"])
+	}
+	return decision, nil
+}
+
+// getPasswordRotation returns the rotation time recorded by applyPasswordRotation and whether the password is due
+// Returns "" without a rotation policy
+func getPasswordRotation(mergedConfig map[string]any) (string, bool) {
+	rotation, ok := mergedConfig["passwordRotation"].(map[string]any)
+	if !ok {
+		return "", false
+	}
+	rotatedAt, _ := rotation["rotatedAt"].(string)
+	rotate, _ := rotation["rotate"].(bool)
+	return rotatedAt, rotate
+}
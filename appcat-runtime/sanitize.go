@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Built-in sanitizer types for the sanitizers section of the service config
+const (
+	// SanitizerPrivileged removes privileged: true and allowPrivilegeEscalation: true
+	SanitizerPrivileged = "privileged"
+	// SanitizerHostNamespaces removes hostNetwork, hostPID and hostIPC: true
+	SanitizerHostNamespaces = "hostNamespaces"
+	// SanitizerNodeSelector removes nodeSelector labels outside the allowlist
+	SanitizerNodeSelector = "nodeSelector"
+)
+
+// Sanitizer scrubs forbidden fields from desired resources
+// Sanitize is called for every map of a resource, including helm values however deeply nested,
+// and returns the keys it removed from that map
+type Sanitizer interface {
+	Sanitize(object map[string]any) []string
+}
+
+// sanitizerFactories builds Sanitizers from their sanitizers entry, keyed by type
+var sanitizerFactories = map[string]func(config map[string]any) (Sanitizer, error){
+	SanitizerPrivileged: func(map[string]any) (Sanitizer, error) {
+		return enabledFieldSanitizer{"privileged", "allowPrivilegeEscalation"}, nil
+	},
+	SanitizerHostNamespaces: func(map[string]any) (Sanitizer, error) {
+		return enabledFieldSanitizer{"hostNetwork", "hostPID", "hostIPC"}, nil
+	},
+	SanitizerNodeSelector: newNodeSelectorSanitizer,
+}
+
+// getSanitizers extracts the sanitizers section from service config
+// Returns nil without error if the service doesn't scrub its output
+func getSanitizers(serviceConfig map[string]any) ([]Sanitizer, error) {
+	entries, _ := serviceConfig["sanitizers"].([]any)
+	var sanitizers []Sanitizer
+	for i, entryRaw := range entries {
+		config, typ, err := typedEntryConfig(entryRaw)
+		if err != nil {
+			return nil, fmt.Errorf("sanitizers[%d]: %w", i, err)
+		}
+		factory, ok := sanitizerFactories[typ]
+		if !ok {
+			return nil, fmt.Errorf("sanitizers[%d]: unknown type %q", i, typ)
+		}
+		sanitizer, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("sanitizers[%d]: %w", i, err)
+		}
+		sanitizers = append(sanitizers, sanitizer)
+	}
+	return sanitizers, nil
+}
+
+// enabledFieldSanitizer removes boolean fields set to true
+type enabledFieldSanitizer []string
+
+// Sanitize implements Sanitizer
+func (s enabledFieldSanitizer) Sanitize(object map[string]any) []string {
+	var removed []string
+	for _, field := range s {
+		if enabled, _ := object[field].(bool); enabled {
+			delete(object, field)
+			removed = append(removed, field)
+		}
+	}
+	return removed
+}
+
+// nodeSelectorSanitizer removes nodeSelector labels that aren't allowed
+type nodeSelectorSanitizer struct {
+	allowedLabels []string
+}
+
+// newNodeSelectorSanitizer creates a nodeSelector sanitizer from its config, e.g.
+// {type: nodeSelector, allowedLabels: [appuio.io/node-class]}; without allowedLabels every label is removed
+func newNodeSelectorSanitizer(config map[string]any) (Sanitizer, error) {
+	sanitizer := nodeSelectorSanitizer{}
+	labelsRaw, _ := config["allowedLabels"].([]any)
+	for i, labelRaw := range labelsRaw {
+		label, _ := labelRaw.(string)
+		if label == "" {
+			return nil, fmt.Errorf("allowedLabels[%d] must be a label key", i)
+		}
+		sanitizer.allowedLabels = append(sanitizer.allowedLabels, label)
+	}
+	return sanitizer, nil
+}
+
+// Sanitize implements Sanitizer
+func (s nodeSelectorSanitizer) Sanitize(object map[string]any) []string {
+	selector, ok := object["nodeSelector"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	var removed []string
+	for _, label := range slices.Sorted(maps.Keys(selector)) {
+		if !slices.Contains(s.allowedLabels, label) {
+			delete(selector, label)
+			removed = append(removed, fmt.Sprintf("nodeSelector[%s]", label))
+		}
+	}
+	return removed
+}
+
+// sanitizeResources runs the sanitizers over all desired resources as the last step of rendering, so forbidden
+// fields never reach the cluster, no matter whether they came from defaults, value sources, user spec or patches
+// Externalized values documents are decoded and sanitized like inline values
+func sanitizeResources(resources map[string]*fnv1.Resource, sanitizers []Sanitizer, results *Results, log logr.Logger) error {
+	if len(sanitizers) == 0 {
+		return nil
+	}
+	for _, key := range slices.Sorted(maps.Keys(resources)) {
+		object := resources[key].GetResource().AsMap()
+		removed := sanitizeObject(object, "", sanitizers)
+		if len(removed) > 0 {
+			sanitized, err := structpb.NewStruct(object)
+			if err != nil {
+				return fmt.Errorf("failed to convert sanitized %s: %w", key, err)
+			}
+			resources[key].Resource = sanitized
+		}
+
+		if key == valuesSecretKey(releaseKey) || key == valuesSecretKey(nextReleaseKey) {
+			values, ok, err := readExternalValues(resources[key])
+			if err != nil {
+				return fmt.Errorf("failed to read values of %s: %w", key, err)
+			}
+			if ok {
+				if removedValues := sanitizeObject(values, externalValuesPath+".", sanitizers); len(removedValues) > 0 {
+					if err := writeExternalValues(resources[key], values); err != nil {
+						return fmt.Errorf("failed to write values of %s: %w", key, err)
+					}
+					removed = append(removed, removedValues...)
+				}
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+
+		log.Info("Removed forbidden fields", "resource", key, "fields", removed)
+		results.Warning("FieldsSanitized", "Removed forbidden fields from %s: %s", key, strings.Join(removed, ", "))
+	}
+	return nil
+}
+
+// sanitizeObject runs the sanitizers over a map and every map nested in it, returning the removed paths
+func sanitizeObject(object map[string]any, prefix string, sanitizers []Sanitizer) []string {
+	var removed []string
+	for _, sanitizer := range sanitizers {
+		for _, field := range sanitizer.Sanitize(object) {
+			removed = append(removed, prefix+field)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		removed = append(removed, sanitizeValue(object[key], prefix+key, sanitizers)...)
+	}
+	return removed
+}
+
+// sanitizeValue descends into maps and lists of a value
+func sanitizeValue(value any, path string, sanitizers []Sanitizer) []string {
+	switch v := value.(type) {
+	case map[string]any:
+		return sanitizeObject(v, path+".", sanitizers)
+	case []any:
+		var removed []string
+		for i, item := range v {
+			removed = append(removed, sanitizeValue(item, fmt.Sprintf("%s[%d]", path, i), sanitizers)...)
+		}
+		return removed
+	}
+	return nil
+}
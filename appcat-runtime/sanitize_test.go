@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSanitizeResourcesExternalizedValues(t *testing.T) {
+	sanitizers, err := getSanitizers(map[string]any{"sanitizers": []any{
+		map[string]any{"type": SanitizerPrivileged},
+		map[string]any{"type": SanitizerNodeSelector, "allowedLabels": []any{"appuio.io/node-class"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := map[string]any{
+		"master": map[string]any{
+			"containerSecurityContext": map[string]any{"privileged": true, "runAsNonRoot": true},
+			"nodeSelector":             map[string]any{"appuio.io/node-class": "plus", "kubernetes.io/hostname": "node-1"},
+		},
+		"sidecars": []any{map[string]any{"securityContext": map[string]any{"allowPrivilegeEscalation": true}}},
+	}
+	document, err := json.Marshal(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valuesSecret, err := toFunctionResource(NewSecretBuilder("redis-a-values", "team").WithData(externalValuesKey, document).Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := structpb.NewStruct(map[string]any{
+		"apiVersion": "helm.m.crossplane.io/v1beta1",
+		"kind":       "Release",
+		"spec":       map[string]any{"forProvider": map[string]any{"values": map[string]any{"hostNetwork": true, "privileged": true}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := map[string]*fnv1.Resource{releaseKey: {Resource: release}, valuesSecretKey(releaseKey): valuesSecret}
+
+	results := &Results{}
+	if err := sanitizeResources(resources, sanitizers, results, logr.Discard()); err != nil {
+		t.Fatalf("sanitizeResources() error = %v", err)
+	}
+
+	sanitized, ok, err := readExternalValues(resources[valuesSecretKey(releaseKey)])
+	if err != nil || !ok {
+		t.Fatalf("readExternalValues() = %v, %v", ok, err)
+	}
+	master := sanitized["master"].(map[string]any)
+	if _, ok := master["containerSecurityContext"].(map[string]any)["privileged"]; ok {
+		t.Errorf("externalized privileged not removed")
+	}
+	if master["containerSecurityContext"].(map[string]any)["runAsNonRoot"] != true {
+		t.Errorf("externalized runAsNonRoot removed")
+	}
+	nodeSelector := master["nodeSelector"].(map[string]any)
+	if _, ok := nodeSelector["kubernetes.io/hostname"]; ok || nodeSelector["appuio.io/node-class"] != "plus" {
+		t.Errorf("externalized nodeSelector = %v, want only the allowed label", nodeSelector)
+	}
+	if _, ok := sanitized["sidecars"].([]any)[0].(map[string]any)["securityContext"].(map[string]any)["allowPrivilegeEscalation"]; ok {
+		t.Errorf("externalized allowPrivilegeEscalation in a list not removed")
+	}
+
+	inline := resources[releaseKey].GetResource().AsMap()["spec"].(map[string]any)["forProvider"].(map[string]any)["values"].(map[string]any)
+	if _, ok := inline["privileged"]; ok {
+		t.Errorf("inline privileged not removed")
+	}
+	if inline["hostNetwork"] != true {
+		t.Errorf("hostNetwork removed without the hostNamespaces sanitizer")
+	}
+
+	if got := len(results.List()); got != 2 {
+		t.Errorf("results = %d, want a FieldsSanitized warning per resource", got)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/singleflight"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// schemaFetchTimeout bounds how long fetching a remote values.schema.json may take
+const schemaFetchTimeout = 10 * time.Second
+
+// ValuesSchemaConfig defines where the chart's values.schema.json comes from
+type ValuesSchemaConfig struct {
+	// URL to fetch values.schema.json from (fetched once per URL and cached)
+	URL string
+	// Inline is a bundled copy of values.schema.json
+	Inline map[string]any
+}
+
+// getValuesSchemaConfig extracts valuesSchema configuration from merged config
+// Returns nil without error if no valuesSchema is configured
+func getValuesSchemaConfig(mergedConfig map[string]any) (*ValuesSchemaConfig, error) {
+	schemaConfig, ok := mergedConfig["valuesSchema"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	url, _ := schemaConfig["url"].(string)
+	inline, _ := schemaConfig["inline"].(map[string]any)
+
+	if url == "" && inline == nil {
+		return nil, fmt.Errorf("valuesSchema requires either url or inline")
+	}
+	if url != "" && inline != nil {
+		return nil, fmt.Errorf("valuesSchema url and inline are mutually exclusive")
+	}
+
+	return &ValuesSchemaConfig{URL: url, Inline: inline}, nil
+}
+
+// schemaCache caches fetched values.schema.json documents by URL
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string][]byte
+	client  *http.Client
+	// fetches deduplicates concurrent fetches of the same URL, which run without holding mu
+	fetches singleflight.Group
+}
+
+// newSchemaCache creates an empty schema cache
+func newSchemaCache() *schemaCache {
+	return &schemaCache{
+		schemas: make(map[string][]byte),
+		client:  &http.Client{Timeout: schemaFetchTimeout},
+	}
+}
+
+// get returns the schema for url, fetching it on first use
+// A slow URL only blocks the renders waiting for it; the fetch outlives a cancelled caller, bounded by
+// schemaFetchTimeout, so the renders sharing it still get the schema
+func (c *schemaCache) get(ctx context.Context, url string) ([]byte, error) {
+	c.mu.Lock()
+	schema, ok := c.schemas[url]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	fetch := c.fetches.DoChan(url, func() (any, error) {
+		schema, err := c.fetch(context.WithoutCancel(ctx), url)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.schemas[url] = schema
+		c.mu.Unlock()
+		return schema, nil
+	})
+	select {
+	case result := <-fetch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, ctx.Err())
+	}
+}
+
+// fetch downloads the schema at url
+func (c *schemaCache) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for %s: %w", url, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", url, resp.Status)
+	}
+
+	schema, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	return schema, nil
+}
+
+// flush drops all cached schemas and returns their URLs, sorted
+func (c *schemaCache) flush() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	dropped := slices.Sorted(maps.Keys(c.schemas))
+	clear(c.schemas)
+	return dropped
+}
+
+// resolve returns the raw schema JSON for the given config
+func (c *schemaCache) resolve(ctx context.Context, schemaConfig *ValuesSchemaConfig) ([]byte, error) {
+	if schemaConfig.Inline != nil {
+		return json.Marshal(schemaConfig.Inline)
+	}
+	return c.get(ctx, schemaConfig.URL)
+}
+
+// validateHelmValues validates the final helm values against the chart's values.schema.json
+// Uses Helm's own validator so errors match what provider-helm would report on install
+// resources are the desired resources holding the Release at key, whose values are checked as rendered after
+// ignoreDifferences and spec.patches; without them (e.g. in the admission webhook) the merged helm values are
+func validateHelmValues(ctx context.Context, cache *schemaCache, mergedConfig map[string]any, resources map[string]*fnv1.Resource, key string, log logr.Logger) error {
+	schemaConfig, err := getValuesSchemaConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid valuesSchema config: %w", err)
+	}
+	if schemaConfig == nil {
+		return nil
+	}
+
+	helmValues, err := renderedHelmValues(mergedConfig, resources, key)
+	if err != nil {
+		return err
+	}
+
+	schema, err := cache.resolve(ctx, schemaConfig)
+	if err != nil {
+		return fmt.Errorf("failed to resolve values schema: %w", err)
+	}
+
+	// Helm drops null values, which remove keys, before validating; so does the check
+	if err := chartutil.ValidateAgainstSingleSchema(withoutNullValues(helmValues), schema); err != nil {
+		return errors.New(strings.TrimSpace(err.Error()))
+	}
+
+	log.Info("Helm values passed chart schema validation")
+	return nil
+}
+
+// renderedHelmValues returns the values the main chart is installed with: the inline values of the desired Release
+// at key over its externalized values, or the merged helm values if the Release isn't rendered
+// The schema is the main chart's, the values of additional charts aren't checked
+func renderedHelmValues(mergedConfig map[string]any, resources map[string]*fnv1.Resource, key string) (map[string]any, error) {
+	release, ok := resources[key]
+	if !ok {
+		helmValues, ok := mergedConfig["helmValues"].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("helmValues not found in merged config")
+		}
+		charts, err := getCompanionCharts(mergedConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid charts config: %w", err)
+		}
+		helmValues, _, err = splitChartValues(helmValues, charts)
+		return helmValues, err
+	}
+
+	helmValues := map[string]any{}
+	if valuesSecret, ok := resources[valuesSecretKey(key)]; ok {
+		external, _, err := readExternalValues(valuesSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values of %s: %w", key, err)
+		}
+		maps.Copy(helmValues, external)
+	}
+	inline, _ := fieldpath.Pave(release.GetResource().AsMap()).GetValue(releaseValuesPath(release))
+	if inline, ok := inline.(map[string]any); ok {
+		maps.Copy(helmValues, inline)
+	}
+	return helmValues, nil
+}
+
+// withoutNullValues returns a copy of values without the keys set to null
+func withoutNullValues(values map[string]any) map[string]any {
+	pruned := make(map[string]any, len(values))
+	for key, value := range values {
+		switch v := value.(type) {
+		case nil:
+		case map[string]any:
+			pruned[key] = withoutNullValues(v)
+		default:
+			pruned[key] = v
+		}
+	}
+	return pruned
+}
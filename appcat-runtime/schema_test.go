@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSchemaCacheGet(t *testing.T) {
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path == "/slow.json" {
+			<-release
+		}
+		_, _ = w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+	cache := newSchemaCache()
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.get(context.Background(), server.URL+"/slow.json"); err != nil {
+				t.Errorf("get(slow) error = %v", err)
+			}
+		}()
+	}
+
+	// A slow schema doesn't block fetching others
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := cache.get(ctx, server.URL+"/fast.json"); err != nil {
+		t.Fatalf("get(fast) error = %v", err)
+	}
+
+	// A cancelled caller gives up without failing the fetch it shares
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := cache.get(cancelled, server.URL+"/slow.json"); err == nil {
+		t.Errorf("get(slow) with cancelled context succeeded, want error")
+	}
+
+	close(release)
+	wg.Wait()
+	if _, err := cache.get(context.Background(), server.URL+"/slow.json"); err != nil {
+		t.Fatalf("get(slow) error = %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("fetches = %d, want 2", got)
+	}
+}
+
+func TestValidateHelmValues(t *testing.T) {
+	mergedConfig := map[string]any{
+		"helmValues": map[string]any{"replica": map[string]any{"count": float64(1)}},
+		"valuesSchema": map[string]any{"inline": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"replica": map[string]any{"type": "object", "properties": map[string]any{"count": map[string]any{"type": "integer"}}},
+				"auth":    map[string]any{"type": "object", "properties": map[string]any{"enabled": map[string]any{"type": "boolean"}}},
+			},
+		}},
+	}
+	resource := func(object map[string]any) *fnv1.Resource {
+		s, err := structpb.NewStruct(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	release := func(values map[string]any) *fnv1.Resource {
+		return resource(map[string]any{"apiVersion": "helm.m.crossplane.io/v1beta1", "kind": "Release", "spec": map[string]any{"forProvider": map[string]any{"values": values}}})
+	}
+	valuesSecret := func(document string) *fnv1.Resource {
+		return resource(map[string]any{"apiVersion": "v1", "kind": "Secret", "data": map[string]any{externalValuesKey: base64.StdEncoding.EncodeToString([]byte(document))}})
+	}
+
+	cases := map[string]struct {
+		resources map[string]*fnv1.Resource
+		wantErr   bool
+	}{
+		"MergedValues": {},
+		"RenderedValues": {
+			resources: map[string]*fnv1.Resource{releaseKey: release(map[string]any{"replica": map[string]any{"count": float64(2)}})},
+		},
+		"PatchedValues": {
+			resources: map[string]*fnv1.Resource{releaseKey: release(map[string]any{"replica": map[string]any{"count": "two"}})},
+			wantErr:   true,
+		},
+		"ExternalizedValues": {
+			resources: map[string]*fnv1.Resource{
+				releaseKey:                  release(map[string]any{"replica": map[string]any{"count": float64(2)}}),
+				valuesSecretKey(releaseKey): valuesSecret(`{"auth": {"enabled": "yes"}}`),
+			},
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validateHelmValues(context.Background(), newSchemaCache(), mergedConfig, tc.resources, releaseKey, logr.Discard())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("validateHelmValues() error = %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
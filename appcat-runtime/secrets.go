@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"maps"
+	mathrand "math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// Secret store backend types for secretStore.sources and secretStore.sinks
+const (
+	// SecretSourceObserved reuses the password of the observed connection Secret or composite connection details
+	SecretSourceObserved = "observed"
+	// SecretSourceRandom generates a new random password in the function
+	SecretSourceRandom = "random"
+	// SecretSourceDerived derives the password from a key mounted into the function, so it's never stored or read back
+	SecretSourceDerived = "derived"
+	// SecretSourceExternalSecret reads the password an ESO ExternalSecret syncs from an external store (e.g. Vault)
+	SecretSourceExternalSecret = "externalSecret"
+	// SecretSinkPushSecret pushes the connection Secret to an external store (e.g. Vault) via an ESO PushSecret
+	SecretSinkPushSecret = "pushSecret"
+)
+
+// defaultPasswordLength is the length of generated passwords
+const defaultPasswordLength = 32
+
+// SecretLookup is the context a SecretSource looks up the instance password in
+type SecretLookup struct {
+	Composite    *fnv1.Resource
+	Observed     map[string]*fnv1.Resource
+	InstanceName string
+	// RotatedAt is when the password was last rotated, "" without a rotation policy
+	RotatedAt string
+	// ExternalPassword is the password an externalSecret source synced, "" until ESO has written it
+	ExternalPassword string
+	Results          *Results
+	Log              logr.Logger
+}
+
+// SecretSource provides the instance password
+type SecretSource interface {
+	// Password returns the password, or false if the source has none and the next source should be asked
+	Password(lookup SecretLookup) (string, bool, error)
+}
+
+// SecretTarget describes the connection Secret a SecretSink publishes
+type SecretTarget struct {
+	SecretName      string
+	SecretNamespace string
+	Keys            []string
+	InstanceName    string
+	Namespace       string
+	Claim           ClaimReference
+}
+
+// SecretSink publishes the connection Secret beyond the cluster
+type SecretSink interface {
+	// Resources returns the desired resources, keyed by resource key, that publish the Secret
+	Resources(target SecretTarget) (map[string]*fnv1.Resource, error)
+}
+
+// secretSourceFactories builds SecretSources from their secretStore.sources entry, keyed by type
+var secretSourceFactories = map[string]func(config map[string]any) (SecretSource, error){
+	SecretSourceObserved:       func(map[string]any) (SecretSource, error) { return observedSecretSource{}, nil },
+	SecretSourceRandom:         newRandomSecretSource,
+	SecretSourceDerived:        newDerivedSecretSource,
+	SecretSourceExternalSecret: newExternalSecretSource,
+}
+
+// secretSinkFactories builds SecretSinks from their secretStore.sinks entry, keyed by type
+var secretSinkFactories = map[string]func(config map[string]any) (SecretSink, error){
+	SecretSinkPushSecret: newPushSecretSink,
+}
+
+// SecretStore is the chain of sources asked for the instance password and the sinks publishing it
+type SecretStore struct {
+	Sources []SecretSource
+	Sinks   []SecretSink
+}
+
+// getSecretStore extracts secretStore configuration from merged config
+// Without a secretStore section passwords are reused from observed state or generated, and not published
+func getSecretStore(mergedConfig map[string]any) (*SecretStore, error) {
+	storeConfig, ok := mergedConfig["secretStore"].(map[string]any)
+	if !ok {
+		return &SecretStore{Sources: []SecretSource{observedSecretSource{}, randomSecretSource{policy: defaultPasswordPolicy}}}, nil
+	}
+
+	store := &SecretStore{}
+	sources, _ := storeConfig["sources"].([]any)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("secretStore requires at least one source")
+	}
+	for i, sourceRaw := range sources {
+		sourceConfig, typ, err := typedEntryConfig(sourceRaw)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+		factory, ok := secretSourceFactories[typ]
+		if !ok {
+			return nil, fmt.Errorf("sources[%d]: unknown type %q", i, typ)
+		}
+		source, err := factory(sourceConfig)
+		if err != nil {
+			return nil, fmt.Errorf("sources[%d]: %w", i, err)
+		}
+		store.Sources = append(store.Sources, source)
+	}
+
+	sinks, _ := storeConfig["sinks"].([]any)
+	for i, sinkRaw := range sinks {
+		sinkConfig, typ, err := typedEntryConfig(sinkRaw)
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		factory, ok := secretSinkFactories[typ]
+		if !ok {
+			return nil, fmt.Errorf("sinks[%d]: unknown type %q", i, typ)
+		}
+		sink, err := factory(sinkConfig)
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		store.Sinks = append(store.Sinks, sink)
+	}
+	return store, nil
+}
+
+// typedEntryConfig returns an entry of a typed list (secretStore sources and sinks, valueSources) as a map along with its type
+func typedEntryConfig(raw any) (map[string]any, string, error) {
+	config, ok := raw.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("not a map")
+	}
+	typ, _ := config["type"].(string)
+	if typ == "" {
+		return nil, "", fmt.Errorf("type is required")
+	}
+	return config, typ, nil
+}
+
+// password asks the sources in order for the instance password
+func (s *SecretStore) password(lookup SecretLookup) (string, error) {
+	for _, source := range s.Sources {
+		password, ok, err := source.Password(lookup)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("no secret source provided a password")
+}
+
+// rotatedPassword asks only the generating sources for a new password, skipping those reusing the current one
+func (s *SecretStore) rotatedPassword(lookup SecretLookup) (string, error) {
+	for _, source := range s.Sources {
+		switch source.(type) {
+		case randomSecretSource, derivedSecretSource:
+		default:
+			continue
+		}
+		password, ok, err := source.Password(lookup)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("password rotation requires a random or derived secret source")
+}
+
+// resources collects the resources of all sinks publishing the connection Secret
+func (s *SecretStore) resources(target SecretTarget) (map[string]*fnv1.Resource, error) {
+	resources := map[string]*fnv1.Resource{}
+	for i, sink := range s.Sinks {
+		sinkResources, err := sink.Resources(target)
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		for key, resource := range sinkResources {
+			if _, exists := resources[key]; exists {
+				return nil, fmt.Errorf("sinks[%d]: resource %s is already published by another sink", i, key)
+			}
+			resources[key] = resource
+		}
+	}
+	return resources, nil
+}
+
+// observedSecretSource reuses the password the instance already has
+// Lookup order: observed connection Secret, then observed composite connection details
+type observedSecretSource struct{}
+
+// Password implements SecretSource
+func (observedSecretSource) Password(lookup SecretLookup) (string, bool, error) {
+	// Check for existing Secret in observed resources
+	if secretResource, exists := lookup.Observed["secret"]; exists && secretResource != nil {
+		paved := fieldpath.Pave(secretResource.Resource.AsMap())
+
+		// Try to extract existing password from Secret data
+		if passwordBase64, err := paved.GetString("data.password"); err == nil && passwordBase64 != "" {
+			// Decode base64 (Kubernetes stores Secret data as base64)
+			passwordBytes, err := base64.StdEncoding.DecodeString(passwordBase64)
+			if err == nil {
+				lookup.Log.Info("Reusing existing password from Secret", "instance", lookup.InstanceName)
+				return string(passwordBytes), true, nil
+			}
+			lookup.Results.Warning("UnreadablePassword", "Existing password in connection Secret is not valid base64: %v", err)
+		}
+	}
+
+	// Fall back to the composite connection details, which also hold platform-only fields
+	if password, ok := lookup.Composite.GetConnectionDetails()["password"]; ok && len(password) > 0 {
+		lookup.Log.Info("Reusing existing password from composite connection details", "instance", lookup.InstanceName)
+		return string(password), true, nil
+	}
+	return "", false, nil
+}
+
+// derivedSecretSource derives the password from a key mounted into the function and the instance it's for
+// The same instance always gets the same password, so it doesn't have to be stored anywhere, e.g. for one-time
+// links; a rotation derives a new one from the rotation time. Replacing the key changes every derived password
+type derivedSecretSource struct {
+	keyFile string
+	policy  PasswordPolicy
+}
+
+// minDerivedKeyLength is the minimal length of the key of a derived source, as for an HMAC-SHA256 key
+const minDerivedKeyLength = 32
+
+// newDerivedSecretSource creates a derived source from its config, e.g. {type: derived, keyFile: /etc/appcat/password-key}
+// The policy fields of random sources, e.g. length, apply as well
+func newDerivedSecretSource(config map[string]any) (SecretSource, error) {
+	keyFile, _ := config["keyFile"].(string)
+	if keyFile == "" {
+		return nil, fmt.Errorf("keyFile is required")
+	}
+	policy, err := newPasswordPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	return derivedSecretSource{keyFile: keyFile, policy: policy}, nil
+}
+
+// Password implements SecretSource
+func (s derivedSecretSource) Password(lookup SecretLookup) (string, bool, error) {
+	raw, err := os.ReadFile(s.keyFile)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read password key: %w", err)
+	}
+	key := bytes.TrimSpace(raw)
+	if len(key) < minDerivedKeyLength {
+		return "", false, fmt.Errorf("password key %s must hold at least %d bytes", s.keyFile, minDerivedKeyLength)
+	}
+
+	namespace, _ := fieldpath.Pave(lookup.Composite.GetResource().AsMap()).GetString("metadata.namespace")
+	identity := namespace + "/" + lookup.InstanceName + "/password"
+	if lookup.RotatedAt != "" {
+		identity += "@" + lookup.RotatedAt
+	}
+	password, err := generatePassword(s.policy, keyedRandomSource(key, identity))
+	if err != nil {
+		return "", false, err
+	}
+	lookup.Log.Info("Derived password", "instance", lookup.InstanceName)
+	return password, true, nil
+}
+
+// randomSecretSource generates a new password, so it always provides one
+type randomSecretSource struct {
+	policy PasswordPolicy
+}
+
+// newRandomSecretSource creates a random source from its config, e.g. {type: random, length: 24, excludeAmbiguous: true}
+func newRandomSecretSource(config map[string]any) (SecretSource, error) {
+	policy, err := newPasswordPolicy(config)
+	if err != nil {
+		return nil, err
+	}
+	return randomSecretSource{policy: policy}, nil
+}
+
+// Password implements SecretSource
+func (s randomSecretSource) Password(lookup SecretLookup) (string, bool, error) {
+	lookup.Log.Info("Generating new password", "instance", lookup.InstanceName)
+	namespace, _ := fieldpath.Pave(lookup.Composite.GetResource().AsMap()).GetString("metadata.namespace")
+	password, err := generateRandomPassword(s.policy, namespace+"/"+lookup.InstanceName+"/password")
+	if err != nil {
+		return "", false, err
+	}
+	return password, true, nil
+}
+
+// randomSeed makes generated passwords deterministic when set, see seedRandomSource
+var randomSeed []byte
+
+// seedRandomSource makes generated passwords deterministic, derived from seed
+// Only for golden files and local renders: anyone knowing the seed can reproduce every password
+func seedRandomSource(seed string) {
+	randomSeed = []byte(seed)
+}
+
+// passwordRandomSource returns the source of the bytes of a generated password, crypto/rand in production
+// Seeded, each password gets its own stream keyed by HMAC(seed, identity), so concurrent renders don't share
+// state and a password only depends on the seed and what it's for, not on the order of requests
+func passwordRandomSource(identity string) io.Reader {
+	if randomSeed == nil {
+		return rand.Reader
+	}
+	return keyedRandomSource(randomSeed, identity)
+}
+
+// keyedRandomSource returns a stream that only depends on key and identity, ChaCha8 keyed by HMAC(key, identity)
+func keyedRandomSource(key []byte, identity string) io.Reader {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(identity))
+	return mathrand.NewChaCha8([32]byte(mac.Sum(nil)))
+}
+
+// pushSecretSink pushes every key of the connection Secret to an external store through an ESO PushSecret
+// Backs credentials up to Vault (or any store ESO supports) without the function talking to the store
+type pushSecretSink struct {
+	storeName       string
+	storeKind       string
+	remoteKey       string
+	refreshInterval string
+}
+
+// newPushSecretSink creates a PushSecret sink from its config, e.g.
+// {type: pushSecret, storeRef: {name: vault, kind: ClusterSecretStore}, remoteKey: "appcat/${namespace}/${instanceName}"}
+func newPushSecretSink(config map[string]any) (SecretSink, error) {
+	paved := fieldpath.Pave(config)
+	sink := pushSecretSink{storeKind: "ClusterSecretStore", refreshInterval: "1h"}
+	sink.storeName, _ = paved.GetString("storeRef.name")
+	if sink.storeName == "" {
+		return nil, fmt.Errorf("storeRef.name is required")
+	}
+	if kind, _ := paved.GetString("storeRef.kind"); kind != "" {
+		if !slices.Contains([]string{"SecretStore", "ClusterSecretStore"}, kind) {
+			return nil, fmt.Errorf("storeRef.kind must be SecretStore or ClusterSecretStore")
+		}
+		sink.storeKind = kind
+	}
+	sink.remoteKey, _ = paved.GetString("remoteKey")
+	if sink.remoteKey == "" {
+		return nil, fmt.Errorf("remoteKey is required")
+	}
+	if interval, _ := paved.GetString("refreshInterval"); interval != "" {
+		sink.refreshInterval = interval
+	}
+	return sink, nil
+}
+
+// Resources implements SecretSink
+func (s pushSecretSink) Resources(target SecretTarget) (map[string]*fnv1.Resource, error) {
+	remoteKey := substituteVariables(s.remoteKey, map[string]string{
+		"instanceName":   target.InstanceName,
+		"namespace":      target.Namespace,
+		"claimName":      target.Claim.Name,
+		"claimNamespace": target.Claim.Namespace,
+	})
+
+	builder := NewPushSecretBuilder(target.SecretName+"-push", target.SecretNamespace).
+		WithStore(s.storeName, s.storeKind).
+		WithSecret(target.SecretName).
+		WithRefreshInterval(s.refreshInterval).
+		WithLabel("app.kubernetes.io/managed-by", "crossplane").
+		WithLabel("app.kubernetes.io/instance", target.InstanceName).
+		WithLabel("app.kubernetes.io/component", "secret-push").
+		WithLabel(LabelClaimName, target.Claim.Name).
+		WithLabel(LabelClaimNamespace, target.Claim.Namespace)
+	for _, key := range target.Keys {
+		builder = builder.WithData(key, remoteKey, key)
+	}
+
+	pushSecret, err := toFunctionResource(builder.Build())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert push secret: %w", err)
+	}
+	return map[string]*fnv1.Resource{"secret-push": pushSecret}, nil
+}
+
+// getSecretMapping extracts secretMapping from merged config: helm value paths to value templates
+// Lets charts with any auth values layout consume the instance credentials, e.g.
+// {"auth.existingSecret": "${secretName}", "auth.existingSecretPasswordKey": "redis-password"}
+// Returns nil without error if no secretMapping is configured
+func getSecretMapping(mergedConfig map[string]any) (map[string]string, error) {
+	mappingRaw, ok := mergedConfig["secretMapping"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	mapping := make(map[string]string, len(mappingRaw))
+	for path, valueRaw := range mappingRaw {
+		value, ok := valueRaw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: value must be a string template", path)
+		}
+		if _, err := fieldpath.Parse(path); err != nil {
+			return nil, fmt.Errorf("invalid path %s: %w", path, err)
+		}
+		mapping[path] = value
+	}
+	return mapping, nil
+}
+
+// applySecretMapping sets the chart's credential wiring in helm values
+// Templates support ${password} and ${secretName} besides the connection secret variables;
+// ${secretName} is only known when a connection Secret is generated
+func applySecretMapping(helmValues map[string]any, mapping, variables map[string]string) error {
+	paved := fieldpath.Pave(helmValues)
+	for _, path := range slices.Sorted(maps.Keys(mapping)) {
+		value := substituteVariables(mapping[path], variables)
+		if strings.Contains(value, "${secretName}") {
+			return fmt.Errorf("%s: ${secretName} requires a connectionSecret", path)
+		}
+		if err := paved.SetValue(path, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", path, err)
+		}
+	}
+	return nil
+}
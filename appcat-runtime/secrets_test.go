@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestDerivedSecretSource(t *testing.T) {
+	dir := t.TempDir()
+	writeKey := func(name, key string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	keyFile := writeKey("key", strings.Repeat("a", minDerivedKeyLength))
+	otherKeyFile := writeKey("other", strings.Repeat("b", minDerivedKeyLength))
+	shortKeyFile := writeKey("short", "abc")
+
+	composite, err := structpb.NewStruct(map[string]any{"metadata": map[string]any{"namespace": "team"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	derive := func(keyFile, instance, rotatedAt string) (string, error) {
+		source, err := newDerivedSecretSource(map[string]any{"keyFile": keyFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		password, _, err := source.Password(SecretLookup{
+			Composite:    &fnv1.Resource{Resource: composite},
+			InstanceName: instance,
+			RotatedAt:    rotatedAt,
+			Results:      &Results{},
+			Log:          logr.Discard(),
+		})
+		return password, err
+	}
+	first, err := derive(keyFile, "redis-a", "")
+	if err != nil {
+		t.Fatalf("Password() error = %v", err)
+	}
+	if len(first) != defaultPasswordLength {
+		t.Errorf("len(password) = %d, want %d", len(first), defaultPasswordLength)
+	}
+
+	cases := map[string]struct {
+		keyFile   string
+		instance  string
+		rotatedAt string
+		same      bool
+		wantErr   bool
+	}{
+		"SameInstance":  {keyFile: keyFile, instance: "redis-a", same: true},
+		"OtherInstance": {keyFile: keyFile, instance: "redis-b"},
+		"Rotated":       {keyFile: keyFile, instance: "redis-a", rotatedAt: "2026-01-01T00:00:00Z"},
+		"OtherKey":      {keyFile: otherKeyFile, instance: "redis-a"},
+		"ShortKey":      {keyFile: shortKeyFile, instance: "redis-a", wantErr: true},
+		"MissingKey":    {keyFile: filepath.Join(dir, "missing"), instance: "redis-a", wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			password, err := derive(tc.keyFile, tc.instance, tc.rotatedAt)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Password() error = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if same := password == first; same != tc.same {
+				t.Errorf("password equal to redis-a's = %v, want %v", same, tc.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AnnotationPurge ends a soft-deleted composite's retention early, e.g., for instances deleted by mistake
+// being recreated under the same name or data that must be erased right away
+const AnnotationPurge = "appcat.vshn.io/purge"
+
+// TeardownPhaseRetained means the instance is scaled down and its data is kept until the retention ends
+const TeardownPhaseRetained = "Retained"
+
+// retainUsageKeyPrefix prefixes the desired resource keys of Usages protecting retained resources
+const retainUsageKeyPrefix = "retain-"
+
+// defaultRetainedKeys are the resources kept during retention: the Releases (and with them the data volumes),
+// the backups and the credentials needed to read them
+var defaultRetainedKeys = []string{releaseKey, nextReleaseKey, backupScheduleKey, backupRepositoryKey, backupBucketKey, "secret"}
+
+// SoftDeleteConfig defines how long deleted instances are kept before they're torn down
+type SoftDeleteConfig struct {
+	Retention time.Duration
+	// ScaleDownValues are helm values merged into retained Releases, e.g., {"replica": {"replicaCount": 0}}
+	ScaleDownValues map[string]any
+	// RetainKeys are the desired resource keys protected from deletion during retention
+	RetainKeys []string
+}
+
+// getSoftDeleteConfig extracts softDelete configuration from service config
+// Returns nil without error if deleted instances are torn down right away
+func getSoftDeleteConfig(serviceConfig map[string]any) (*SoftDeleteConfig, error) {
+	softDelete, ok := serviceConfig["softDelete"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	retentionRaw, _ := softDelete["retention"].(string)
+	retention, err := time.ParseDuration(retentionRaw)
+	if err != nil || retention <= 0 {
+		return nil, fmt.Errorf("invalid retention %q, expected a positive duration such as 168h", retentionRaw)
+	}
+	config := &SoftDeleteConfig{Retention: retention, RetainKeys: defaultRetainedKeys}
+	if scaleDown, ok := softDelete["scaleDownValues"].(map[string]any); ok {
+		config.ScaleDownValues = scaleDown
+	}
+	if retainRaw, ok := softDelete["retain"].([]any); ok {
+		config.RetainKeys = nil
+		for i, keyRaw := range retainRaw {
+			key, _ := keyRaw.(string)
+			if key == "" {
+				return nil, fmt.Errorf("retain[%d] must be a resource key", i)
+			}
+			config.RetainKeys = append(config.RetainKeys, key)
+		}
+	}
+	return config, nil
+}
+
+// retainedUntil returns the end of a deleted composite's retention, counted from its deletion
+// Returns false once the retention is over or the composite is annotated for purging
+func (c *SoftDeleteConfig) retainedUntil(composite *fnv1.Resource) (time.Time, bool) {
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	if purge, _ := paved.GetString(fmt.Sprintf("metadata.annotations[%s]", AnnotationPurge)); purge == "true" {
+		return time.Time{}, false
+	}
+	deletionTimestamp, _ := paved.GetString("metadata.deletionTimestamp")
+	deletedAt, err := time.Parse(time.RFC3339, deletionTimestamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	retainUntil := deletedAt.Add(c.Retention)
+	return retainUntil, now().Before(retainUntil)
+}
+
+// softDeleteResources renders a retained composite: Releases are kept with the scale-down values, and every
+// retained resource gets a Usage blocking its deletion until the retention ends
+// Other observed resources are kept identity-only, like during teardown
+func softDeleteResources(
+	composite *fnv1.Resource,
+	observedResources map[string]*fnv1.Resource,
+	config *SoftDeleteConfig,
+	retainUntil time.Time,
+) (map[string]*fnv1.Resource, error) {
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	compositeName, _ := paved.GetString("metadata.name")
+	reason := fmt.Sprintf("Instance %s was deleted and is retained until %s", compositeName, retainUntil.Format(time.RFC3339))
+
+	desired := map[string]*fnv1.Resource{}
+	for key, observed := range observedResources {
+		if strings.HasPrefix(key, retainUsageKeyPrefix) {
+			continue
+		}
+		if key == releaseKey || key == nextReleaseKey {
+			release, err := scaledDownRelease(observed, config.ScaleDownValues)
+			if err != nil {
+				return nil, fmt.Errorf("failed to scale down %s: %w", key, err)
+			}
+			desired[key] = &fnv1.Resource{Resource: release}
+		} else {
+			kept, err := observedDesired(observed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to keep %s during retention: %w", key, err)
+			}
+			desired[key] = kept
+		}
+		if !slices.Contains(config.RetainKeys, key) {
+			continue
+		}
+
+		retained := fieldpath.Pave(observed.GetResource().AsMap())
+		apiVersion, _ := retained.GetString("apiVersion")
+		kind, _ := retained.GetString("kind")
+		name, _ := retained.GetString("metadata.name")
+		namespace, _ := retained.GetString("metadata.namespace")
+		usage := NewUsageBuilder(truncateName(fmt.Sprintf("%s-retain-%s", compositeName, key), 253), namespace).
+			Of(apiVersion, kind, name).
+			WithReason(reason).
+			WithLabel("app.kubernetes.io/managed-by", "crossplane").
+			Build()
+		usageResource, err := toFunctionResource(usage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert usage retaining %s: %w", key, err)
+		}
+		desired[retainUsageKeyPrefix+key] = usageResource
+	}
+	return desired, nil
+}
+
+// scaledDownRelease returns an observed Release's desired state with the scale-down values merged over its values
+func scaledDownRelease(observed *fnv1.Resource, scaleDown map[string]any) (*structpb.Struct, error) {
+	paved := fieldpath.Pave(observed.GetResource().AsMap())
+	release := map[string]any{}
+	for _, path := range []string{"apiVersion", "kind", "metadata.name", "metadata.namespace", "metadata.labels", "spec"} {
+		value, err := paved.GetValue(path)
+		if err != nil {
+			continue
+		}
+		if err := setValueByPath(release, path, value); err != nil {
+			return nil, err
+		}
+	}
+	if len(scaleDown) > 0 {
+		valuesPath := releaseValuesPath(observed)
+		values, _ := paved.GetValue(valuesPath)
+		current, _ := values.(map[string]any)
+		if current == nil {
+			current = map[string]any{}
+		}
+		if err := setValueByPath(release, valuesPath, deepMerge(deepCopy(current), deepCopy(scaleDown), replaceLists)); err != nil {
+			return nil, err
+		}
+	}
+	return structpb.NewStruct(release)
+}
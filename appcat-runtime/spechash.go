@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+)
+
+// lastAppliedAnnotation is kubectl's copy of the applied object, which changes with the spec it duplicates
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// specChange tells whether the inputs of a render changed since the previous one
+type specChange struct {
+	// hash covers the function version, the service config, the user spec after defaulting, without the
+	// fields Crossplane writes itself (resourceRefs, compositionRevisionRef, ...), and the composite's metadata
+	hash string
+	// unchanged is set if the previous render was made from the same spec and service config
+	// Values of value sources and resolved chart and app versions aren't covered, so it must not gate checks of
+	// the rendered values, e.g. schema validation
+	unchanged bool
+}
+
+// detectSpecChange hashes the semantically relevant render inputs and compares them to status.specHash
+// Labels and annotations are hashed too: they are propagated to generated resources and mapping expressions
+// and value templates may refer to them; only updates touching Crossplane's own spec fields or kubectl's
+// last-applied annotation are recognized as no-ops
+func detectSpecChange(composite *fnv1.Resource, serviceConfig, userSpec map[string]any) (*specChange, error) {
+	relevantSpec := make(map[string]any, len(userSpec))
+	for key, value := range userSpec {
+		relevantSpec[key] = value
+	}
+	for _, field := range crossplaneSpecFields {
+		delete(relevantSpec, field)
+	}
+
+	paved := fieldpath.Pave(composite.GetResource().AsMap())
+	labels, _ := paved.GetValue("metadata.labels")
+	annotationsRaw, _ := paved.GetValue("metadata.annotations")
+	annotations, _ := annotationsRaw.(map[string]any)
+	relevantAnnotations := make(map[string]any, len(annotations))
+	for key, value := range annotations {
+		if key != lastAppliedAnnotation {
+			relevantAnnotations[key] = value
+		}
+	}
+
+	hash, err := hashConfig(map[string]any{
+		"functionVersion": version,
+		"serviceConfig":   serviceConfig,
+		"spec":            relevantSpec,
+		"labels":          labels,
+		"annotations":     relevantAnnotations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash spec: %w", err)
+	}
+
+	observed, _ := paved.GetString("status.specHash")
+	return &specChange{hash: hash, unchanged: observed == hash}, nil
+}
+
+// renderedAt returns the time to stamp as rendered-at
+// No-op renders keep the timestamp of the observed release, so they don't rewrite every resource
+func (c *specChange) renderedAt(release *fnv1.Resource) time.Time {
+	if c.unchanged && release != nil {
+		previous, _ := fieldpath.Pave(release.GetResource().AsMap()).GetString(fmt.Sprintf("metadata.annotations[%s]", AnnotationRenderedAt))
+		if renderedAt, err := time.Parse(time.RFC3339, previous); err == nil {
+			return renderedAt
+		}
+	}
+	return now()
+}
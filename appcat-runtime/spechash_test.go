@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestDetectSpecChange(t *testing.T) {
+	serviceConfig := map[string]any{"service": "redis"}
+	composite := func(metadata map[string]any, specHash string) *fnv1.Resource {
+		s, err := structpb.NewStruct(map[string]any{"metadata": metadata, "status": map[string]any{"specHash": specHash}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.Resource{Resource: s}
+	}
+	previous := map[string]any{"labels": map[string]any{"team": "a"}}
+	first, err := detectSpecChange(composite(previous, ""), serviceConfig, map[string]any{"size": "small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		metadata      map[string]any
+		spec          map[string]any
+		wantUnchanged bool
+	}{
+		"Unchanged": {
+			metadata:      previous,
+			spec:          map[string]any{"size": "small"},
+			wantUnchanged: true,
+		},
+		"CrossplaneSpecField": {
+			metadata:      previous,
+			spec:          map[string]any{"size": "small", "resourceRefs": []any{"a"}},
+			wantUnchanged: true,
+		},
+		"LastAppliedAnnotation": {
+			metadata:      map[string]any{"labels": map[string]any{"team": "a"}, "annotations": map[string]any{lastAppliedAnnotation: "{}"}},
+			spec:          map[string]any{"size": "small"},
+			wantUnchanged: true,
+		},
+		"Spec": {
+			metadata: previous,
+			spec:     map[string]any{"size": "large"},
+		},
+		"Label": {
+			metadata: map[string]any{"labels": map[string]any{"team": "b"}},
+			spec:     map[string]any{"size": "small"},
+		},
+		"Annotation": {
+			metadata: map[string]any{"labels": map[string]any{"team": "a"}, "annotations": map[string]any{"billing.vshn.io/id": "1"}},
+			spec:     map[string]any{"size": "small"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			change, err := detectSpecChange(composite(tc.metadata, first.hash), serviceConfig, tc.spec)
+			if err != nil {
+				t.Fatalf("detectSpecChange() error = %v", err)
+			}
+			if change.unchanged != tc.wantUnchanged {
+				t.Errorf("unchanged = %v, want %v", change.unchanged, tc.wantUnchanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// crossplaneSpecFields are top-level spec fields managed by Crossplane, never subject to the spec schema
+var crossplaneSpecFields = []string{
+	"crossplane",
+	"claimRef",
+	"compositionRef",
+	"compositionRevisionRef",
+	"compositionRevisionSelector",
+	"compositionSelector",
+	"compositionUpdatePolicy",
+	"environmentConfigRefs",
+	"publishConnectionDetailsTo",
+	"resourceRef",
+	"resourceRefs",
+	"writeConnectionSecretToRef",
+}
+
+// SpecSchemaConfig declares the schema of the user spec
+type SpecSchemaConfig struct {
+	// Schema is an OpenAPI v3 schema of the user spec, as in the XRD
+	Schema map[string]any
+	// Strict rejects unknown fields instead of pruning them
+	Strict bool
+}
+
+// getSpecSchemaConfig extracts specSchema configuration from service config
+// Returns nil without error if no specSchema is configured
+func getSpecSchemaConfig(serviceConfig map[string]any) (*SpecSchemaConfig, error) {
+	schemaConfig, ok := serviceConfig["specSchema"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	inline, ok := schemaConfig["inline"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("specSchema requires inline")
+	}
+	strict, _ := schemaConfig["strict"].(bool)
+	return &SpecSchemaConfig{Schema: inline, Strict: strict}, nil
+}
+
+// pruneUserSpec removes user spec fields the spec schema doesn't declare, reporting each as a warning
+// In strict mode unknown fields are an error instead, so nothing undeclared reaches the chart values
+func pruneUserSpec(serviceConfig, userSpec map[string]any, results *Results, log logr.Logger) error {
+	config, err := getSpecSchemaConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid specSchema: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	// Crossplane's own fields stay, whatever the schema says
+	reserved := map[string]any{}
+	for _, field := range crossplaneSpecFields {
+		if value, ok := userSpec[field]; ok {
+			reserved[field] = value
+			delete(userSpec, field)
+		}
+	}
+	var unknown []string
+	pruneUnknownFields(userSpec, config.Schema, "spec", &unknown)
+	maps.Copy(userSpec, reserved)
+
+	if len(unknown) == 0 {
+		return nil
+	}
+	slices.Sort(unknown)
+	if config.Strict {
+		return fmt.Errorf("unknown fields %s", strings.Join(unknown, ", "))
+	}
+	for _, path := range unknown {
+		results.Warning("UnknownSpecField", "Ignored unknown field %s", path)
+	}
+	log.Info("Pruned unknown user spec fields", "fields", unknown)
+	return nil
+}
+
+// pruneUnknownFields walks value along schema, deleting undeclared map keys and collecting their paths
+// Follows properties, additionalProperties and items; x-kubernetes-preserve-unknown-fields keeps a subtree
+func pruneUnknownFields(value any, schema map[string]any, path string, unknown *[]string) {
+	if preserve, _ := schema["x-kubernetes-preserve-unknown-fields"].(bool); preserve {
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for key, child := range v {
+			childPath := path + "." + key
+			if propertySchema, ok := properties[key].(map[string]any); ok {
+				pruneUnknownFields(child, propertySchema, childPath, unknown)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case map[string]any:
+				pruneUnknownFields(child, additional, childPath, unknown)
+			case bool:
+				if !additional {
+					delete(v, key)
+					*unknown = append(*unknown, childPath)
+				}
+			default:
+				delete(v, key)
+				*unknown = append(*unknown, childPath)
+			}
+		}
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return
+		}
+		for i, item := range v {
+			pruneUnknownFields(item, items, fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	}
+}
+
+// validateUserSpec checks the user spec against the spec schema, so a wrong type or value is reported by its
+// field instead of surfacing as an obscure error while mapping or installing the chart
+// Supports type, nullable, enum, pattern, format (quantity, duration, date-time), minimum/maximum,
+// length and item bounds and required, as XRDs use them; all violations are reported at once
+func validateUserSpec(serviceConfig, userSpec map[string]any) error {
+	config, err := getSpecSchemaConfig(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid specSchema: %w", err)
+	}
+	if config == nil {
+		return nil
+	}
+
+	spec := maps.Clone(userSpec)
+	for _, field := range crossplaneSpecFields {
+		delete(spec, field)
+	}
+	var violations []string
+	if err := validateSpecValue(spec, config.Schema, "spec", &violations); err != nil {
+		return fmt.Errorf("invalid specSchema: %w", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	slices.Sort(violations)
+	return fmt.Errorf("%s", strings.Join(violations, "; "))
+}
+
+// validateSpecValue walks value along schema like pruneUnknownFields, collecting violations
+// Returns an error only if the schema itself is invalid
+func validateSpecValue(value any, schema map[string]any, path string, violations *[]string) error {
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); !nullable {
+			*violations = append(*violations, path+" must not be null")
+		}
+		return nil
+	}
+
+	intOrString, _ := schema["x-kubernetes-int-or-string"].(bool)
+	typ, _ := schema["type"].(string)
+	if intOrString {
+		if _, ok := value.(string); !ok && !isInteger(value) {
+			*violations = append(*violations, path+" must be an integer or a string")
+			return nil
+		}
+	} else if typ != "" && !hasSpecType(value, typ) {
+		*violations = append(*violations, fmt.Sprintf("%s must be of type %s", path, typ))
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(allowed any) bool { return fmt.Sprint(allowed) == fmt.Sprint(value) }) {
+		allowed := make([]string, 0, len(enum))
+		for _, v := range enum {
+			allowed = append(allowed, fmt.Sprint(v))
+		}
+		*violations = append(*violations, fmt.Sprintf("%s must be one of %s", path, strings.Join(allowed, ", ")))
+	}
+
+	switch v := value.(type) {
+	case string:
+		return validateSpecString(v, schema, path, violations)
+	case float64:
+		validateSpecBounds(v, schema, "minimum", "maximum", path, "", violations)
+	case map[string]any:
+		required, _ := schema["required"].([]any)
+		for _, fieldRaw := range required {
+			if field, _ := fieldRaw.(string); field != "" {
+				if _, ok := v[field]; !ok {
+					*violations = append(*violations, fmt.Sprintf("%s.%s is required", path, field))
+				}
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			childSchema, ok := properties[key].(map[string]any)
+			if !ok {
+				childSchema, ok = schema["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				continue
+			}
+			if err := validateSpecValue(v[key], childSchema, path+"."+key, violations); err != nil {
+				return err
+			}
+		}
+	case []any:
+		validateSpecBounds(float64(len(v)), schema, "minItems", "maxItems", path, " items", violations)
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range v {
+			if err := validateSpecValue(item, items, fmt.Sprintf("%s[%d]", path, i), violations); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateSpecString checks the pattern, format and length of a string value
+func validateSpecString(value string, schema map[string]any, path string, violations *[]string) error {
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", path, pattern, err)
+		}
+		if !re.MatchString(value) {
+			*violations = append(*violations, fmt.Sprintf("%s must match pattern %s", path, pattern))
+		}
+	}
+
+	var err error
+	switch format, _ := schema["format"].(string); format {
+	case "quantity":
+		_, err = resource.ParseQuantity(value)
+	case "duration":
+		_, err = time.ParseDuration(value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		*violations = append(*violations, fmt.Sprintf("%s must be a %s, got %q", path, schema["format"], value))
+	}
+	validateSpecBounds(float64(len(value)), schema, "minLength", "maxLength", path, " characters", violations)
+	return nil
+}
+
+// validateSpecBounds checks a number, length or item count against the schema's lower and upper bound keywords
+// exclusiveMinimum/exclusiveMaximum apply to minimum/maximum, as booleans like in OpenAPI v3.0
+func validateSpecBounds(value float64, schema map[string]any, minKey, maxKey, path, unit string, violations *[]string) {
+	if minimum, ok := schema[minKey].(float64); ok {
+		exclusive, _ := schema["exclusiveMinimum"].(bool)
+		if value < minimum || (exclusive && minKey == "minimum" && value == minimum) {
+			qualifier := "at least"
+			if exclusive && minKey == "minimum" {
+				qualifier = "greater than"
+			}
+			*violations = append(*violations, fmt.Sprintf("%s must be %s %v%s", path, qualifier, minimum, unit))
+		}
+	}
+	if maximum, ok := schema[maxKey].(float64); ok {
+		exclusive, _ := schema["exclusiveMaximum"].(bool)
+		if value > maximum || (exclusive && maxKey == "maximum" && value == maximum) {
+			qualifier := "at most"
+			if exclusive && maxKey == "maximum" {
+				qualifier = "less than"
+			}
+			*violations = append(*violations, fmt.Sprintf("%s must be %s %v%s", path, qualifier, maximum, unit))
+		}
+	}
+}
+
+// hasSpecType reports whether a decoded JSON value is of an OpenAPI type
+func hasSpecType(value any, typ string) bool {
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		return isInteger(value)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	}
+	return true
+}
+
+// isInteger reports whether a decoded JSON number has no fractional part
+func isInteger(value any) bool {
+	number, ok := value.(float64)
+	return ok && number == float64(int64(number))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+)
+
+// runtimeStatusFields are the top-level status fields the runtime writes itself, never targets of statusMapping
+var runtimeStatusFields = []string{
+	"appVersion",
+	"autoUpgrade",
+	"clone",
+	"conditions",
+	"credentials",
+	"freeze",
+	"instance",
+	"maintenance",
+	"plan",
+	"planTransition",
+	"restore",
+	"specHash",
+	"teardown",
+	"upgrade",
+}
+
+// getStatusMapping extracts statusMapping from service config, by observed resource key, e.g.
+// {helmrelease: {"status.atProvider.revision": "status.deployedRevision"}}
+// Targets are status paths, optionally with a transform like mapping entries ({path: ..., transform: toString})
+// Returns nil without error if the service exposes no observed fields
+func getStatusMapping(serviceConfig map[string]any) (map[string]map[string]*MappingTarget, error) {
+	mappingRaw, ok := serviceConfig["statusMapping"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	mapping := make(map[string]map[string]*MappingTarget, len(mappingRaw))
+	for key, fieldsRaw := range mappingRaw {
+		fields, ok := fieldsRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("statusMapping.%s must be a map of observed paths to status paths", key)
+		}
+		mapping[key] = make(map[string]*MappingTarget, len(fields))
+		for source, targetRaw := range fields {
+			target, err := parseMappingTarget(targetRaw)
+			if err != nil {
+				return nil, fmt.Errorf("statusMapping.%s[%s]: %w", key, source, err)
+			}
+			field, ok := strings.CutPrefix(target.HelmPath, "status.")
+			if !ok || field == "" {
+				return nil, fmt.Errorf("statusMapping.%s[%s]: target %s must be below status", key, source, target.HelmPath)
+			}
+			segments, err := parsePath(field)
+			if err != nil {
+				return nil, fmt.Errorf("statusMapping.%s[%s]: %w", key, source, err)
+			}
+			if slices.Contains(runtimeStatusFields, segments[0].key) {
+				return nil, fmt.Errorf("statusMapping.%s[%s]: status.%s is written by the runtime", key, source, segments[0].key)
+			}
+			target.HelmPath = field
+			mapping[key][source] = target
+		}
+	}
+	return mapping, nil
+}
+
+// applyStatusMapping copies fields of observed resources into the composite's status
+// releaseKey stands for the release serving the instance, which is the next release during blue/green upgrades
+// Fields not observed yet are left out, transform failures are reported as warnings
+func applyStatusMapping(
+	status map[string]any,
+	observedResources map[string]*fnv1.Resource,
+	servingKey string,
+	serviceConfig map[string]any,
+	results *Results,
+	log logr.Logger,
+) error {
+	mapping, err := getStatusMapping(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid statusMapping: %w", err)
+	}
+	for _, key := range slices.Sorted(maps.Keys(mapping)) {
+		observedKey := key
+		if key == releaseKey {
+			observedKey = servingKey
+		}
+		observed, ok := observedResources[observedKey]
+		if !ok || observed.GetResource() == nil {
+			continue
+		}
+		object := observed.GetResource().AsMap()
+		for _, source := range slices.Sorted(maps.Keys(mapping[key])) {
+			target := mapping[key][source]
+			value, err := lookupValueByPath(object, source)
+			if err != nil {
+				continue
+			}
+			if target.Transform != nil {
+				if value, err = target.Transform(value); err != nil {
+					results.Warning("StatusMappingFailed", "Skipped status.%s: failed to transform %s of %s: %v", target.HelmPath, source, key, err)
+					continue
+				}
+			}
+			if err := setValueByPath(status, target.HelmPath, value); err != nil {
+				return fmt.Errorf("failed to set status.%s: %w", target.HelmPath, err)
+			}
+		}
+		log.Info("Mapped observed fields into status", "resource", observedKey, "fields", len(mapping[key]))
+	}
+	return nil
+}
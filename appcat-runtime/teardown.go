@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Teardown phases reported in status.teardown.phase while a composite is being deleted
+// Phases follow the order resources go away; each is derived from the observed resources
+const (
+	// TeardownPhaseUninstalling means the Helm release is being uninstalled
+	TeardownPhaseUninstalling = "Uninstalling"
+	// TeardownPhaseNamespaceTerminating means the instance namespace is terminating
+	TeardownPhaseNamespaceTerminating = "NamespaceTerminating"
+	// TeardownPhaseCleaningUp means only remaining supporting resources are being deleted
+	TeardownPhaseCleaningUp = "CleaningUp"
+	// TeardownPhaseCompleted means no composed resources are left
+	TeardownPhaseCompleted = "Completed"
+)
+
+// namespaceKey is the observed resource key of the instance namespace
+const namespaceKey = "namespace"
+
+// ConditionTeardown reports deletion progress, only set while the composite is being deleted
+const ConditionTeardown = "Teardown"
+
+// isDeleting reports whether the composite has been marked for deletion
+func isDeleting(composite *fnv1.Resource) bool {
+	deletionTimestamp, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.deletionTimestamp")
+	return deletionTimestamp != ""
+}
+
+// teardownStatus derives status.teardown from the resources still observed
+func teardownStatus(composite *fnv1.Resource, observedResources map[string]*fnv1.Resource) map[string]any {
+	remaining := slices.Sorted(maps.Keys(observedResources))
+
+	var phase, message string
+	release := activeReleaseKey(composite)
+	switch {
+	case observedResources[release] != nil || observedResources[nextReleaseKey] != nil:
+		phase, message = TeardownPhaseUninstalling, "Uninstalling the Helm release"
+	case observedResources[namespaceKey] != nil:
+		phase, message = TeardownPhaseNamespaceTerminating, "Waiting for the instance namespace to terminate"
+	case len(remaining) > 0:
+		phase, message = TeardownPhaseCleaningUp, fmt.Sprintf("Deleting %s", strings.Join(remaining, ", "))
+	default:
+		phase, message = TeardownPhaseCompleted, "All composed resources are deleted"
+	}
+
+	deletionTimestamp, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.deletionTimestamp")
+	remainingList := make([]any, 0, len(remaining))
+	for _, key := range remaining {
+		remainingList = append(remainingList, key)
+	}
+	return map[string]any{
+		"phase":     phase,
+		"message":   message,
+		"remaining": remainingList,
+		"startedAt": deletionTimestamp,
+	}
+}
+
+// teardownResponse publishes deletion progress instead of rendering
+// Observed resources are re-emitted as desired with their observed spec and data, so keys dropping out
+// don't change Crossplane's deletion order and nothing deleted is rendered again
+// Crossplane doesn't run the pipeline for composites with a deletionTimestamp and deletes composed
+// resources through garbage collection instead; this path only runs where the function is called with a
+// deleting composite, e.g., render previewing a teardown
+// Services with softDelete first retain the scaled-down instance; the Usages retaining it are dropped once
+// the retention ends, which lets the teardown proceed
+func teardownResponse(req *fnv1.RunFunctionRequest, composite *fnv1.Resource, registry *serviceRegistry, log logr.Logger) (*fnv1.RunFunctionResponse, error) {
+	if req.GetInput() == nil {
+		return nil, fmt.Errorf("input is nil")
+	}
+	serviceConfig, err := extractServiceConfig(req.GetInput(), registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract service config: %w", err)
+	}
+	softDelete, err := getSoftDeleteConfig(serviceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid softDelete config: %w", err)
+	}
+
+	teardown := teardownStatus(composite, req.GetObserved().GetResources())
+	resources := map[string]*fnv1.Resource{}
+	retainUntil, retained := time.Time{}, false
+	if softDelete != nil {
+		retainUntil, retained = softDelete.retainedUntil(composite)
+	}
+	if retained {
+		resources, err = softDeleteResources(composite, req.GetObserved().GetResources(), softDelete, retainUntil)
+		if err != nil {
+			return nil, err
+		}
+		teardown["phase"] = TeardownPhaseRetained
+		teardown["message"] = fmt.Sprintf("Instance is scaled down, its data is retained until %s", retainUntil.Format(time.RFC3339))
+		teardown["retainUntil"] = retainUntil.Format(time.RFC3339)
+		log.Info("Composite is soft-deleted", "retainUntil", retainUntil)
+	} else {
+		log.Info("Composite is being deleted", "phase", teardown["phase"], "remaining", len(teardown["remaining"].([]any)))
+		instanceName, err := getInstanceName(composite, serviceConfig)
+		if err != nil {
+			return nil, err
+		}
+		namespace, _ := fieldpath.Pave(composite.GetResource().AsMap()).GetString("metadata.namespace")
+		forgetInstanceMetrics(namespace, instanceName)
+		for key, observed := range req.GetObserved().GetResources() {
+			if strings.HasPrefix(key, retainUsageKeyPrefix) {
+				continue
+			}
+			kept, err := observedDesired(observed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to keep %s during teardown: %w", key, err)
+			}
+			resources[key] = kept
+		}
+	}
+
+	compositeStatus, err := structpb.NewStruct(map[string]any{"status": map[string]any{"teardown": teardown}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build teardown status: %w", err)
+	}
+	desired := &fnv1.State{
+		Composite: &fnv1.Resource{Resource: compositeStatus, Ready: fnv1.Ready_READY_FALSE},
+		Resources: resources,
+	}
+
+	status := fnv1.Status_STATUS_CONDITION_FALSE
+	if teardown["phase"] == TeardownPhaseCompleted {
+		status = fnv1.Status_STATUS_CONDITION_TRUE
+	}
+	return &fnv1.RunFunctionResponse{
+		Meta:       &fnv1.ResponseMeta{Ttl: durationpb.New(defaultResponseTTL)},
+		Desired:    desired,
+		Conditions: []*fnv1.Condition{newCondition(ConditionTeardown, status, teardown["phase"].(string), teardown["message"].(string))},
+	}, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestTeardownStatus(t *testing.T) {
+	composite, err := structpb.NewStruct(map[string]any{
+		"metadata": map[string]any{"name": "redis-a", "deletionTimestamp": "2026-01-01T00:00:00Z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	observed := func(keys ...string) map[string]*fnv1.Resource {
+		resources := map[string]*fnv1.Resource{}
+		for _, key := range keys {
+			resources[key] = &fnv1.Resource{Resource: &structpb.Struct{}}
+		}
+		return resources
+	}
+
+	cases := map[string]struct {
+		observed map[string]*fnv1.Resource
+		want     string
+	}{
+		"Release":     {observed: observed(releaseKey, namespaceKey, "secret"), want: TeardownPhaseUninstalling},
+		"NextRelease": {observed: observed(nextReleaseKey), want: TeardownPhaseUninstalling},
+		"Namespace":   {observed: observed(namespaceKey, "secret"), want: TeardownPhaseNamespaceTerminating},
+		"Supporting":  {observed: observed("secret"), want: TeardownPhaseCleaningUp},
+		"Nothing":     {observed: observed(), want: TeardownPhaseCompleted},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			status := teardownStatus(&fnv1.Resource{Resource: composite}, tc.observed)
+			if status["phase"] != tc.want {
+				t.Errorf("phase = %v, want %v", status["phase"], tc.want)
+			}
+			if remaining := status["remaining"].([]any); len(remaining) != len(tc.observed) {
+				t.Errorf("remaining = %v, want %d keys", remaining, len(tc.observed))
+			}
+			if status["startedAt"] != "2026-01-01T00:00:00Z" {
+				t.Errorf("startedAt = %v, want the deletion timestamp", status["startedAt"])
+			}
+		})
+	}
+}
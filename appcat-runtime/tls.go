@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	function "github.com/crossplane/function-sdk-go"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc/credentials"
+)
+
+// Certificate file names inside the TLS directory, matching what Crossplane mounts
+const (
+	tlsCertFile = "tls.crt"
+	tlsKeyFile  = "tls.key"
+	tlsCAFile   = "ca.crt"
+)
+
+// certReloadDebounce delays reloading until a rotation has finished writing all files
+const certReloadDebounce = 500 * time.Millisecond
+
+// loadServerTLSConfig loads the server keypair and client CA from dir
+// Clients (Crossplane) must present a certificate signed by ca.crt
+func loadServerTLSConfig(dir string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(
+		filepath.Join(dir, tlsCertFile),
+		filepath.Join(dir, tlsKeyFile),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server keypair: %w", err)
+	}
+
+	ca, err := os.ReadFile(filepath.Join(dir, tlsCAFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no valid CA certificate in %s", tlsCAFile)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// loadWebhookTLSConfig loads the server keypair from dir without requiring client certificates
+// The API server calls admission webhooks without presenting a client certificate
+func loadWebhookTLSConfig(dir string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(
+		filepath.Join(dir, tlsCertFile),
+		filepath.Join(dir, tlsKeyFile),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server keypair: %w", err)
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+// certReloader serves the TLS config from dir and reloads it whenever the files change
+// Crossplane rotates function certificates in place, so long-running pods must pick them up without a restart
+type certReloader struct {
+	dir    string
+	log    logr.Logger
+	load   func(dir string) (*tls.Config, error)
+	config atomic.Pointer[tls.Config]
+}
+
+// newCertReloader loads the initial mTLS server config from dir
+func newCertReloader(dir string, log logr.Logger) (*certReloader, error) {
+	return newCertReloaderWith(dir, log, loadServerTLSConfig)
+}
+
+// newCertReloaderWith loads the initial TLS config from dir using load
+func newCertReloaderWith(dir string, log logr.Logger, load func(dir string) (*tls.Config, error)) (*certReloader, error) {
+	r := &certReloader{dir: dir, log: log.WithValues("tlsDir", dir), load: load}
+	config, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	r.config.Store(config)
+	return r, nil
+}
+
+// tlsConfig returns a config that resolves the current certificate and client CA per handshake
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config.Load(), nil
+		},
+	}
+}
+
+// reload re-reads the certificates, keeping the previous config if they are invalid
+func (r *certReloader) reload() {
+	config, err := r.load(r.dir)
+	if err != nil {
+		r.log.Error(err, "Failed to reload TLS certificates, keeping previous ones")
+		return
+	}
+	r.config.Store(config)
+	r.log.Info("Reloaded TLS certificates")
+}
+
+// watch reloads the certificates on changes in dir until ctx is done
+// Watches the directory rather than the files, since Secret volume updates swap a symlink
+func (r *certReloader) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	if err := watcher.Add(r.dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", r.dir, err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// Debounce bursts of events from a single rotation
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+					continue
+				}
+				debounce = time.After(certReloadDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.log.Error(err, "TLS certificate watcher error")
+			case <-debounce:
+				debounce = nil
+				r.reload()
+			}
+		}
+	}()
+	return nil
+}
+
+// withTLSConfig serves the function with the given TLS configuration
+func withTLSConfig(config *tls.Config) function.ServeOption {
+	return func(o *function.ServeOptions) error {
+		o.Credentials = credentials.NewTLS(config)
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	function "github.com/crossplane/function-sdk-go"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// tracerName identifies spans created by this function
+const tracerName = "github.com/vshn/appcat-poc/appcat-runtime"
+
+// tracer creates spans; it is a no-op until setupTracing installs a provider
+var tracer = otel.Tracer(tracerName)
+
+// setupTracing installs an OTLP trace exporter if one is configured via the standard OTEL_* env vars
+// Tracing is disabled unless OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set
+// Returns a shutdown func flushing pending spans, or nil if tracing is disabled
+func setupTracing(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil, nil
+	}
+
+	// Endpoint, headers, TLS and timeout all come from the OTEL_EXPORTER_OTLP_* env vars
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.Merge(
+		resource.NewSchemaless(
+			semconv.ServiceName(healthServiceName),
+			semconv.ServiceVersion(version),
+		),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// metadataCarrier adapts gRPC metadata to the OTel propagation carrier interface
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// outgoingTraceMetadata returns gRPC metadata carrying the current trace context
+func outgoingTraceMetadata(ctx context.Context) metadata.MD {
+	md := metadata.MD{}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return md
+}
+
+// traceUnaryInterceptor starts a server span per gRPC call, continuing any trace propagated by the caller
+func traceUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+
+	ctx, span := tracer.Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", info.FullMethod)),
+	)
+	defer span.End()
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+// withTracing adds the tracing interceptor to the function server
+// The SDK only installs interceptors together with its metrics server, which is enabled by default
+func withTracing() function.ServeOption {
+	return func(o *function.ServeOptions) error {
+		o.UnaryInterceptors = append(o.UnaryInterceptors, traceUnaryInterceptor)
+		return nil
+	}
+}
+
+// tracePhase runs fn inside a child span named after a RunFunction phase
+func tracePhase(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name)
+	defer span.End()
+
+	if err := fn(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// traceComposite records the composite's identity on the current span
+func traceComposite(ctx context.Context, composite *fnv1.Resource) {
+	paved := fieldpath.Pave(composite.Resource.AsMap())
+	apiVersion, _ := paved.GetString("apiVersion")
+	kind, _ := paved.GetString("kind")
+	name, _ := paved.GetString("metadata.name")
+	namespace, _ := paved.GetString("metadata.namespace")
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("composite.apiVersion", apiVersion),
+		attribute.String("composite.kind", kind),
+		attribute.String("composite.name", name),
+		attribute.String("composite.namespace", namespace),
+	)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Transform converts a user spec value into the representation a chart expects
+type Transform func(value any) (any, error)
+
+// MappingTarget is the resolved right-hand side of a mapping entry
+// Mapping entries are either a plain helm path string or an object with path, expression and transform
+type MappingTarget struct {
+	HelmPath string
+	// Expression is a CEL expression computing the value, see evaluateExpression
+	Expression string
+	Transform  Transform
+	// Remove makes the entry a switch: a true value removes the helm value, see applyMapping
+	Remove bool
+}
+
+// parseMappingTarget parses a mapping entry value
+// Accepts "master.count", {path: "master.resources.requests.memory", transform: "toMi"} or
+// {path: "architecture", expression: "spec.replicas > 1 ? 'replication' : 'standalone'"} or
+// {path: "master.persistence", remove: true}
+// Transforms are either a name ("toMi", "toMillicores", "toString") or an object ({multiply: 2})
+func parseMappingTarget(raw any) (*MappingTarget, error) {
+	switch target := raw.(type) {
+	case string:
+		return &MappingTarget{HelmPath: target}, nil
+	case map[string]any:
+		helmPath, _ := target["path"].(string)
+		if helmPath == "" {
+			return nil, fmt.Errorf("mapping object requires a path")
+		}
+		mappingTarget := &MappingTarget{HelmPath: helmPath}
+		if expression, ok := target["expression"]; ok {
+			mappingTarget.Expression, _ = expression.(string)
+			if mappingTarget.Expression == "" {
+				return nil, fmt.Errorf("path %s: expression must be a string", helmPath)
+			}
+			if _, err := compileExpression(mappingTarget.Expression); err != nil {
+				return nil, fmt.Errorf("path %s: %w", helmPath, err)
+			}
+		}
+		if removeRaw, ok := target["remove"]; ok {
+			if mappingTarget.Remove, ok = removeRaw.(bool); !ok {
+				return nil, fmt.Errorf("path %s: remove must be a bool", helmPath)
+			}
+		}
+		transformRaw, ok := target["transform"]
+		if !ok {
+			return mappingTarget, nil
+		}
+		if mappingTarget.Remove {
+			return nil, fmt.Errorf("path %s: remove and transform are mutually exclusive", helmPath)
+		}
+		transform, err := parseTransform(transformRaw)
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", helmPath, err)
+		}
+		mappingTarget.Transform = transform
+		return mappingTarget, nil
+	default:
+		return nil, fmt.Errorf("unsupported mapping value type %T", raw)
+	}
+}
+
+// parseTransform resolves a transform definition into a Transform
+func parseTransform(raw any) (Transform, error) {
+	switch t := raw.(type) {
+	case string:
+		switch t {
+		case "toMi":
+			return toMi, nil
+		case "toMillicores":
+			return toMillicores, nil
+		case "toString":
+			return toString, nil
+		default:
+			return nil, fmt.Errorf("unknown transform %q", t)
+		}
+	case map[string]any:
+		if factorRaw, ok := t["multiply"]; ok {
+			factor, ok := factorRaw.(float64)
+			if !ok {
+				return nil, fmt.Errorf("multiply factor must be a number, got %T", factorRaw)
+			}
+			return multiply(factor), nil
+		}
+		return nil, fmt.Errorf("unknown transform %v", t)
+	default:
+		return nil, fmt.Errorf("unsupported transform type %T", raw)
+	}
+}
+
+// toQuantity interprets a string ("2Gi", "500m") or number as a Kubernetes quantity
+func toQuantity(value any) (resource.Quantity, error) {
+	switch v := value.(type) {
+	case string:
+		return resource.ParseQuantity(v)
+	case float64:
+		return *resource.NewMilliQuantity(int64(math.Round(v*1000)), resource.DecimalSI), nil
+	default:
+		return resource.Quantity{}, fmt.Errorf("expected quantity, got %T", value)
+	}
+}
+
+// toMi converts a memory quantity to whole mebibytes, e.g. "2Gi" -> 2048
+func toMi(value any) (any, error) {
+	q, err := toQuantity(value)
+	if err != nil {
+		return nil, err
+	}
+	return q.Value() / (1024 * 1024), nil
+}
+
+// toMillicores converts a CPU quantity to millicores, e.g. "1.5" -> 1500
+func toMillicores(value any) (any, error) {
+	q, err := toQuantity(value)
+	if err != nil {
+		return nil, err
+	}
+	return q.MilliValue(), nil
+}
+
+// toString renders any scalar as a string, e.g. 3 -> "3"
+func toString(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to string", value)
+	}
+}
+
+// multiply scales a number or quantity by factor, e.g. multiply(2) turns 3 -> 6 and "2Gi" -> "4Gi"
+func multiply(factor float64) Transform {
+	return func(value any) (any, error) {
+		switch v := value.(type) {
+		case float64:
+			return v * factor, nil
+		case string:
+			q, err := resource.ParseQuantity(v)
+			if err != nil {
+				return nil, err
+			}
+			scaled := resource.NewMilliQuantity(int64(math.Round(float64(q.MilliValue())*factor)), q.Format)
+			return scaled.String(), nil
+		default:
+			return nil, fmt.Errorf("cannot multiply %T", value)
+		}
+	}
+}
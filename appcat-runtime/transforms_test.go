@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseTransform(t *testing.T) {
+	cases := map[string]struct {
+		transform any
+		value     any
+		want      any
+		wantErr   bool
+	}{
+		"ToMiFromGi":             {transform: "toMi", value: "2Gi", want: int64(2048)},
+		"ToMiFromNumber":         {transform: "toMi", value: float64(1024 * 1024), want: int64(1)},
+		"ToMiInvalid":            {transform: "toMi", value: "2 gigs", wantErr: true},
+		"ToMillicoresFromCores":  {transform: "toMillicores", value: "1.5", want: int64(1500)},
+		"ToMillicoresFromNumber": {transform: "toMillicores", value: float64(2), want: int64(2000)},
+		"ToMillicoresFromMilli":  {transform: "toMillicores", value: "250m", want: int64(250)},
+		"ToStringNumber":         {transform: "toString", value: float64(3), want: "3"},
+		"ToStringFraction":       {transform: "toString", value: float64(0.5), want: "0.5"},
+		"ToStringBool":           {transform: "toString", value: true, want: "true"},
+		"ToStringMap":            {transform: "toString", value: map[string]any{}, wantErr: true},
+		"MultiplyNumber":         {transform: map[string]any{"multiply": float64(2)}, value: float64(3), want: float64(6)},
+		"MultiplyBinaryQuantity": {transform: map[string]any{"multiply": float64(2)}, value: "2Gi", want: "4Gi"},
+		"MultiplyMilliQuantity":  {transform: map[string]any{"multiply": float64(3)}, value: "500m", want: "1500m"},
+		"MultiplyBool":           {transform: map[string]any{"multiply": float64(2)}, value: true, wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			transform, err := parseTransform(tc.transform)
+			if err != nil {
+				t.Fatalf("parseTransform(%v) error = %v", tc.transform, err)
+			}
+			got, err := transform(tc.value)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("transform(%v) error = %v, want error %v", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("transform(%v) = %v (%T), want %v (%T)", tc.value, got, got, tc.want, tc.want)
+			}
+		})
+	}
+
+	for name, transform := range map[string]any{
+		"UnknownName":      "toGi",
+		"UnknownObject":    map[string]any{"divide": float64(2)},
+		"NonNumericFactor": map[string]any{"multiply": "2"},
+		"UnsupportedType":  float64(2),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseTransform(transform); err == nil {
+				t.Errorf("parseTransform(%v) succeeded, want error", transform)
+			}
+		})
+	}
+}
+
+func TestParseMappingTarget(t *testing.T) {
+	cases := map[string]struct {
+		raw        any
+		wantPath   string
+		wantRemove bool
+		wantErr    bool
+	}{
+		"Path":                 {raw: "master.count", wantPath: "master.count"},
+		"ObjectWithTransform":  {raw: map[string]any{"path": "master.memory", "transform": "toMi"}, wantPath: "master.memory"},
+		"Remove":               {raw: map[string]any{"path": "master.persistence", "remove": true}, wantPath: "master.persistence", wantRemove: true},
+		"MissingPath":          {raw: map[string]any{"transform": "toMi"}, wantErr: true},
+		"RemoveAndTransform":   {raw: map[string]any{"path": "a", "remove": true, "transform": "toMi"}, wantErr: true},
+		"NonBoolRemove":        {raw: map[string]any{"path": "a", "remove": "yes"}, wantErr: true},
+		"UnknownTransform":     {raw: map[string]any{"path": "a", "transform": "toGi"}, wantErr: true},
+		"UnsupportedEntryType": {raw: float64(1), wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			target, err := parseMappingTarget(tc.raw)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseMappingTarget(%v) error = %v, want error %v", tc.raw, err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if target.HelmPath != tc.wantPath || target.Remove != tc.wantRemove {
+				t.Errorf("parseMappingTarget(%v) = %+v, want path %s and remove %v", tc.raw, target, tc.wantPath, tc.wantRemove)
+			}
+		})
+	}
+}
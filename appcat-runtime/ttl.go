@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// defaultResponseTTL is how long Crossplane may cache a response before re-rendering
+const defaultResponseTTL = 60 * time.Second
+
+// ReconcileConfig controls how often instances of a service are re-rendered
+type ReconcileConfig struct {
+	TTL time.Duration
+	// Jitter is the maximum random extension added to TTL, spreading re-renders of many instances
+	Jitter time.Duration
+}
+
+// getReconcileConfig extracts reconcile configuration from service config
+func getReconcileConfig(serviceConfig map[string]any) (*ReconcileConfig, error) {
+	config := &ReconcileConfig{TTL: defaultResponseTTL}
+
+	reconcile, ok := serviceConfig["reconcile"].(map[string]any)
+	if !ok {
+		return config, nil
+	}
+
+	if ttlRaw, ok := reconcile["ttl"].(string); ok {
+		ttl, err := time.ParseDuration(ttlRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl: %w", err)
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("ttl must be positive, got %s", ttl)
+		}
+		config.TTL = ttl
+	}
+
+	if jitterRaw, ok := reconcile["jitter"].(string); ok {
+		jitter, err := time.ParseDuration(jitterRaw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jitter: %w", err)
+		}
+		if jitter < 0 {
+			return nil, fmt.Errorf("jitter must not be negative, got %s", jitter)
+		}
+		config.Jitter = jitter
+	}
+
+	return config, nil
+}
+
+// responseTTL returns the TTL for this render, extended by a random share of the jitter
+func (c *ReconcileConfig) responseTTL() time.Duration {
+	if c.Jitter <= 0 {
+		return c.TTL
+	}
+	return c.TTL + rand.N(c.Jitter)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	helmv1 "github.com/crossplane-contrib/provider-helm/apis/namespaced/release/v1beta1"
+)
+
+// ValuesFromRef references helm values kept out of the Release spec in a Secret or ConfigMap of the instance namespace,
+// e.g. a license or a password managed outside the function
+type ValuesFromRef struct {
+	// Path sets the key's value at a single helm value, e.g. auth.password; empty merges the key as a values document
+	Path string
+	// Secret is true for secretKeyRef, false for configMapKeyRef
+	Secret bool
+	// Name may refer to ${instanceName}, ${namespace}, ${claimName}, ${claimNamespace} and ${secretName}
+	Name string
+	Key  string
+	// Optional references may be missing, otherwise provider-helm fails the Release until they exist
+	Optional bool
+}
+
+// getValuesFrom extracts the valuesFrom references from merged config, e.g.
+// [{secretKeyRef: {name: "${instanceName}-license", key: values.yaml}}, {path: auth.password, secretKeyRef: {...}}]
+// Returns nil without error if all values are inlined
+func getValuesFrom(mergedConfig map[string]any) ([]ValuesFromRef, error) {
+	entries, ok := mergedConfig["valuesFrom"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := entries.([]any)
+	if !ok {
+		return nil, fmt.Errorf("valuesFrom must be a list")
+	}
+
+	refs := make([]ValuesFromRef, 0, len(list))
+	for i, entryRaw := range list {
+		entry, ok := entryRaw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("valuesFrom[%d] must be a map", i)
+		}
+		secretRef, isSecret := entry["secretKeyRef"].(map[string]any)
+		configMapRef, isConfigMap := entry["configMapKeyRef"].(map[string]any)
+		if isSecret == isConfigMap {
+			return nil, fmt.Errorf("valuesFrom[%d] requires either secretKeyRef or configMapKeyRef", i)
+		}
+		selector := configMapRef
+		if isSecret {
+			selector = secretRef
+		}
+
+		ref := ValuesFromRef{Secret: isSecret}
+		ref.Path, _ = entry["path"].(string)
+		ref.Name, _ = selector["name"].(string)
+		ref.Key, _ = selector["key"].(string)
+		ref.Optional, _ = selector["optional"].(bool)
+		if ref.Name == "" {
+			return nil, fmt.Errorf("valuesFrom[%d] requires a name", i)
+		}
+		if ref.Path != "" {
+			if ref.Key == "" {
+				return nil, fmt.Errorf("valuesFrom[%d]: path %s requires a key", i, ref.Path)
+			}
+			if _, err := helmSetPath(ref.Path); err != nil {
+				return nil, fmt.Errorf("valuesFrom[%d]: %w", i, err)
+			}
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// applyValuesFrom adds the valuesFrom references to the Release
+// Documents are merged beneath the inline values, single values set over them
+func applyValuesFrom(builder *HelmReleaseBuilder, refs []ValuesFromRef, variables map[string]string) (*HelmReleaseBuilder, error) {
+	for _, ref := range refs {
+		name := substituteVariables(ref.Name, variables)
+		if strings.Contains(name, "${secretName}") {
+			return nil, fmt.Errorf("valuesFrom %s: ${secretName} requires a connectionSecret", ref.Name)
+		}
+		selector := &helmv1.DataKeySelector{Name: name, Key: ref.Key, Optional: ref.Optional}
+		source := helmv1.ValueFromSource{ConfigMapKeyRef: selector}
+		if ref.Secret {
+			source = helmv1.ValueFromSource{SecretKeyRef: selector}
+		}
+		if ref.Path == "" {
+			builder = builder.WithValuesFrom(source)
+			continue
+		}
+		// Validated by getValuesFrom
+		path, _ := helmSetPath(ref.Path)
+		builder = builder.WithValueFrom(path, source)
+	}
+	return builder, nil
+}
+
+// helmSetPath renders a value path in the syntax of helm's --set, which provider-helm's set entries use
+// Keys are dot-separated with dots, commas, equal signs and brackets escaped, e.g. "podAnnotations.prometheus\.io/scrape"
+func helmSetPath(path string) (string, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for i, segment := range segments {
+		switch {
+		case segment.isAppend:
+			return "", fmt.Errorf("path %s: values can't be appended to lists", path)
+		case segment.isIndex:
+			fmt.Fprintf(&out, "[%d]", segment.index)
+		default:
+			if i > 0 {
+				out.WriteByte('.')
+			}
+			for _, r := range segment.key {
+				if strings.ContainsRune(`.,=[]\`, r) {
+					out.WriteByte('\\')
+				}
+				out.WriteRune(r)
+			}
+		}
+	}
+	return out.String(), nil
+}
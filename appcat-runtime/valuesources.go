@@ -0,0 +1,681 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"golang.org/x/sync/singleflight"
+	"sigs.k8s.io/yaml"
+)
+
+// Value source types for the valueSources list
+const (
+	ValueSourceStatic            = "static"
+	ValueSourceConfigMap         = "configMap"
+	ValueSourceEnvironmentConfig = "environmentConfig"
+	ValueSourceHTTP              = "http"
+	ValueSourceOCI               = "oci"
+)
+
+// requiredValueSourcePrefix prefixes the required resource names of cluster value sources
+const requiredValueSourcePrefix = "values-"
+
+// defaultValuesFile is the ConfigMap key or OCI artifact file holding a values document
+const defaultValuesFile = "values.yaml"
+
+// valuesFetchTimeout bounds how long fetching a remote values document may take
+const valuesFetchTimeout = 10 * time.Second
+
+// defaultValuesRefreshInterval is how long fetched remote values are reused before fetching them again
+const defaultValuesRefreshInterval = 5 * time.Minute
+
+// errValuesNotFound is returned by sources whose document doesn't exist, tolerated for optional sources
+var errValuesNotFound = errors.New("not found")
+
+// ValueLookup is the context a ValueSource resolves its helm values in
+type ValueLookup struct {
+	Context context.Context
+	// Request is nil outside of renders (e.g. in the admission webhook), where cluster sources can't be fetched
+	Request *fnv1.RunFunctionRequest
+	// Requirement names the resource a cluster source requires from Crossplane
+	Requirement string
+	Fetcher     *valuesFetcher
+	Results     *Results
+	Log         logr.Logger
+}
+
+// ValueSource provides a layer of helm values
+// Layers are merged over defaultHelmValues in the declared order, and user values over all of them
+type ValueSource interface {
+	// Values returns the source's helm values; ok is false while its required resource hasn't been fetched
+	Values(lookup ValueLookup) (values map[string]any, ok bool, err error)
+	// Requires returns the resource the source needs Crossplane to fetch, or nil
+	Requires() *fnv1.ResourceSelector
+}
+
+// valueSourceFactories builds ValueSources from their valueSources entry, keyed by type
+var valueSourceFactories = map[string]func(config map[string]any) (ValueSource, error){
+	ValueSourceStatic:            newStaticValueSource,
+	ValueSourceConfigMap:         newConfigMapValueSource,
+	ValueSourceEnvironmentConfig: newEnvironmentConfigValueSource,
+	ValueSourceHTTP:              newHTTPValueSource,
+	ValueSourceOCI:               newOCIValueSource,
+}
+
+// declaredValueSource is a valueSources entry with the settings common to all types
+type declaredValueSource struct {
+	ValueSource
+	name string
+	// optional sources may be missing, otherwise a missing document fails the render
+	optional bool
+}
+
+// valueLayer is the helm values resolved from one source
+type valueLayer struct {
+	name   string
+	values map[string]any
+}
+
+// valueResolution is the outcome of resolving the value sources
+type valueResolution struct {
+	layers       []valueLayer
+	requirements *fnv1.Requirements
+	// pending is true until Crossplane has fetched the resources of all cluster sources
+	pending bool
+}
+
+// getValueSources extracts the valueSources list from service config
+// Returns nil without error if the service has no value sources besides defaultHelmValues
+func getValueSources(serviceConfig map[string]any) ([]declaredValueSource, error) {
+	sourcesRaw, ok := serviceConfig["valueSources"]
+	if !ok {
+		return nil, nil
+	}
+	sourceList, ok := sourcesRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("valueSources must be a list")
+	}
+
+	sources := make([]declaredValueSource, 0, len(sourceList))
+	for i, raw := range sourceList {
+		config, sourceType, err := typedEntryConfig(raw)
+		if err != nil {
+			return nil, fmt.Errorf("valueSources[%d]: %w", i, err)
+		}
+		factory, ok := valueSourceFactories[sourceType]
+		if !ok {
+			return nil, fmt.Errorf("valueSources[%d]: unknown type %q", i, sourceType)
+		}
+		source, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("valueSources[%d]: %w", i, err)
+		}
+		optional, _ := config["optional"].(bool)
+		sources = append(sources, declaredValueSource{
+			ValueSource: source,
+			name:        fmt.Sprintf("valueSources[%d] (%s)", i, sourceType),
+			optional:    optional,
+		})
+	}
+	return sources, nil
+}
+
+// resolveValueSources resolves the service's value sources into layers for mergeConfigs
+// Never returns a nil resolution; all sources are resolved even if one is pending, so every
+// requirement is known after the first pass
+func resolveValueSources(ctx context.Context, req *fnv1.RunFunctionRequest, serviceConfig map[string]any, fetcher *valuesFetcher, results *Results, log logr.Logger) (*valueResolution, error) {
+	resolution := &valueResolution{}
+	sources, err := getValueSources(serviceConfig)
+	if err != nil {
+		return resolution, fmt.Errorf("invalid valueSources: %w", err)
+	}
+
+	for i, source := range sources {
+		requirement := fmt.Sprintf("%s%d", requiredValueSourcePrefix, i)
+		if selector := source.Requires(); selector != nil && req != nil {
+			if resolution.requirements == nil {
+				resolution.requirements = &fnv1.Requirements{Resources: map[string]*fnv1.ResourceSelector{}}
+			}
+			resolution.requirements.Resources[requirement] = selector
+		}
+
+		values, ok, err := source.Values(ValueLookup{
+			Context:     ctx,
+			Request:     req,
+			Requirement: requirement,
+			Fetcher:     fetcher,
+			Results:     results,
+			Log:         log,
+		})
+		if errors.Is(err, errValuesNotFound) && source.optional {
+			log.Info("Optional value source not found, skipping it", "source", source.name)
+			continue
+		}
+		if err != nil {
+			return resolution, fmt.Errorf("failed to resolve %s: %w", source.name, err)
+		}
+		if !ok {
+			resolution.pending = resolution.pending || req != nil
+			continue
+		}
+		resolution.layers = append(resolution.layers, valueLayer{name: source.name, values: values})
+	}
+	return resolution, nil
+}
+
+// staticValueSource provides values declared inline in the service config
+type staticValueSource struct {
+	values map[string]any
+}
+
+// newStaticValueSource creates a static source from its config, e.g. {type: static, values: {image: {registry: ...}}}
+func newStaticValueSource(config map[string]any) (ValueSource, error) {
+	values, ok := config["values"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("values is required")
+	}
+	return staticValueSource{values: values}, nil
+}
+
+// Values implements ValueSource
+func (s staticValueSource) Values(lookup ValueLookup) (map[string]any, bool, error) {
+	return deepCopy(s.values), true, nil
+}
+
+// Requires implements ValueSource
+func (staticValueSource) Requires() *fnv1.ResourceSelector {
+	return nil
+}
+
+// configMapValueSource reads a values document from a ConfigMap key
+type configMapValueSource struct {
+	name      string
+	namespace string
+	key       string
+}
+
+// newConfigMapValueSource creates a ConfigMap source from its config, e.g.
+// {type: configMap, name: redis-values, namespace: syn-appcat, key: values.yaml}
+func newConfigMapValueSource(config map[string]any) (ValueSource, error) {
+	s := configMapValueSource{key: defaultValuesFile}
+	s.name, _ = config["name"].(string)
+	s.namespace, _ = config["namespace"].(string)
+	if s.name == "" || s.namespace == "" {
+		return nil, fmt.Errorf("name and namespace are required")
+	}
+	if key, _ := config["key"].(string); key != "" {
+		s.key = key
+	}
+	return s, nil
+}
+
+// Values implements ValueSource
+func (s configMapValueSource) Values(lookup ValueLookup) (map[string]any, bool, error) {
+	res, ok, err := requiredValueResource(lookup, fmt.Sprintf("ConfigMap %s/%s", s.namespace, s.name))
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	document, err := fieldpath.Pave(res.GetResource().AsMap()).GetString(fmt.Sprintf("data[%s]", s.key))
+	if err != nil {
+		return nil, false, fmt.Errorf("ConfigMap %s/%s has no key %s: %w", s.namespace, s.name, s.key, errValuesNotFound)
+	}
+	values, err := parseValuesDocument([]byte(document))
+	if err != nil {
+		return nil, false, fmt.Errorf("ConfigMap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return values, true, nil
+}
+
+// Requires implements ValueSource
+func (s configMapValueSource) Requires() *fnv1.ResourceSelector {
+	namespace := s.namespace
+	return &fnv1.ResourceSelector{
+		ApiVersion: "v1",
+		Kind:       "ConfigMap",
+		Match:      &fnv1.ResourceSelector_MatchName{MatchName: s.name},
+		Namespace:  &namespace,
+	}
+}
+
+// environmentConfigValueSource reads values from the data of an EnvironmentConfig
+type environmentConfigValueSource struct {
+	name string
+	// path selects the values within data; empty uses all of data
+	path string
+}
+
+// newEnvironmentConfigValueSource creates an EnvironmentConfig source from its config, e.g.
+// {type: environmentConfig, name: platform-defaults, path: redis.helmValues}
+func newEnvironmentConfigValueSource(config map[string]any) (ValueSource, error) {
+	s := environmentConfigValueSource{}
+	s.name, _ = config["name"].(string)
+	if s.name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	s.path, _ = config["path"].(string)
+	return s, nil
+}
+
+// Values implements ValueSource
+func (s environmentConfigValueSource) Values(lookup ValueLookup) (map[string]any, bool, error) {
+	res, ok, err := requiredValueResource(lookup, "EnvironmentConfig "+s.name)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	path := "data"
+	if s.path != "" {
+		path += "." + s.path
+	}
+	valuesRaw, err := fieldpath.Pave(res.GetResource().AsMap()).GetValue(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("EnvironmentConfig %s has no %s: %w", s.name, path, errValuesNotFound)
+	}
+	values, ok := valuesRaw.(map[string]any)
+	if !ok {
+		return nil, false, fmt.Errorf("EnvironmentConfig %s: %s is not a map", s.name, path)
+	}
+	return deepCopy(values), true, nil
+}
+
+// Requires implements ValueSource
+func (s environmentConfigValueSource) Requires() *fnv1.ResourceSelector {
+	return &fnv1.ResourceSelector{
+		ApiVersion: defaultEnvironmentAPIVersion,
+		Kind:       defaultEnvironmentKind,
+		Match:      &fnv1.ResourceSelector_MatchName{MatchName: s.name},
+	}
+}
+
+// requiredValueResource returns the resource Crossplane fetched for a cluster source
+// ok is false while it hasn't been fetched, and outside of renders
+func requiredValueResource(lookup ValueLookup, description string) (*fnv1.Resource, bool, error) {
+	if lookup.Request == nil {
+		return nil, false, nil
+	}
+	found, fetched := getRequiredResources(lookup.Request, lookup.Requirement)
+	if !fetched {
+		return nil, false, nil
+	}
+	if len(found) == 0 {
+		return nil, false, fmt.Errorf("%s: %w", description, errValuesNotFound)
+	}
+	return found[0], true, nil
+}
+
+// httpValueSource fetches a values document over HTTP(S)
+type httpValueSource struct {
+	url             string
+	refreshInterval time.Duration
+}
+
+// newHTTPValueSource creates an HTTP source from its config, e.g.
+// {type: http, url: https://config.example.com/redis/values.yaml, refreshInterval: 10m}
+func newHTTPValueSource(config map[string]any) (ValueSource, error) {
+	s := httpValueSource{}
+	s.url, _ = config["url"].(string)
+	if s.url == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+	interval, err := getRefreshInterval(config)
+	if err != nil {
+		return nil, err
+	}
+	s.refreshInterval = interval
+	return s, nil
+}
+
+// Values implements ValueSource
+func (s httpValueSource) Values(lookup ValueLookup) (map[string]any, bool, error) {
+	document, err := lookup.Fetcher.get(lookup.Context, s.url, s.refreshInterval, func(ctx context.Context) ([]byte, error) {
+		return lookup.Fetcher.fetchHTTP(ctx, s.url)
+	})
+	return remoteValues(lookup, s.url, document, err)
+}
+
+// Requires implements ValueSource
+func (httpValueSource) Requires() *fnv1.ResourceSelector {
+	return nil
+}
+
+// ociValueSource pulls a values document from a file of an OCI artifact
+// Only anonymous pulls (and registries handing out anonymous tokens) are supported
+type ociValueSource struct {
+	ref             string
+	file            string
+	refreshInterval time.Duration
+}
+
+// newOCIValueSource creates an OCI source from its config, e.g.
+// {type: oci, ref: oci://ghcr.io/vshn/appcat-values/redis:v1, file: values.yaml}
+func newOCIValueSource(config map[string]any) (ValueSource, error) {
+	s := ociValueSource{file: defaultValuesFile}
+	s.ref, _ = config["ref"].(string)
+	if !strings.HasPrefix(s.ref, "oci://") {
+		return nil, fmt.Errorf("ref must be an oci:// reference")
+	}
+	if file, _ := config["file"].(string); file != "" {
+		s.file = file
+	}
+	interval, err := getRefreshInterval(config)
+	if err != nil {
+		return nil, err
+	}
+	s.refreshInterval = interval
+	return s, nil
+}
+
+// Values implements ValueSource
+func (s ociValueSource) Values(lookup ValueLookup) (map[string]any, bool, error) {
+	document, err := lookup.Fetcher.get(lookup.Context, s.ref+"#"+s.file, s.refreshInterval, func(ctx context.Context) ([]byte, error) {
+		return lookup.Fetcher.fetchOCI(ctx, s.ref, s.file)
+	})
+	return remoteValues(lookup, s.ref, document, err)
+}
+
+// Requires implements ValueSource
+func (ociValueSource) Requires() *fnv1.ResourceSelector {
+	return nil
+}
+
+// getRefreshInterval extracts a remote source's refreshInterval, defaulting to defaultValuesRefreshInterval
+func getRefreshInterval(config map[string]any) (time.Duration, error) {
+	intervalRaw, _ := config["refreshInterval"].(string)
+	if intervalRaw == "" {
+		return defaultValuesRefreshInterval, nil
+	}
+	interval, err := time.ParseDuration(intervalRaw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid refreshInterval: %w", err)
+	}
+	return interval, nil
+}
+
+// remoteValues parses a fetched document, warning if it's a stale copy kept after a failed refresh
+func remoteValues(lookup ValueLookup, location string, document []byte, err error) (map[string]any, bool, error) {
+	if err != nil && document == nil {
+		return nil, false, err
+	}
+	if err != nil {
+		lookup.Log.Info("Failed to refresh values, using the previous copy", "location", location, "error", err.Error())
+		lookup.Results.Warning("StaleValues", "Failed to refresh values from %s, using the previous copy: %v", location, err)
+	}
+	values, err := parseValuesDocument(document)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", location, err)
+	}
+	return values, true, nil
+}
+
+// parseValuesDocument parses a YAML (or JSON) values document
+func parseValuesDocument(document []byte) (map[string]any, error) {
+	values := map[string]any{}
+	if err := yaml.Unmarshal(document, &values); err != nil {
+		return nil, fmt.Errorf("invalid values document: %w", err)
+	}
+	return values, nil
+}
+
+// cachedDocument is a fetched remote document
+type cachedDocument struct {
+	document  []byte
+	fetchedAt time.Time
+}
+
+// valuesFetcher fetches and caches remote values documents
+type valuesFetcher struct {
+	mu        sync.Mutex
+	documents map[string]cachedDocument
+	client    *http.Client
+	// fetches deduplicates concurrent fetches of the same key, which run without holding mu
+	fetches singleflight.Group
+}
+
+// newValuesFetcher creates an empty fetcher
+func newValuesFetcher() *valuesFetcher {
+	return &valuesFetcher{
+		documents: make(map[string]cachedDocument),
+		client:    &http.Client{Timeout: valuesFetchTimeout},
+	}
+}
+
+// get returns the document cached under key, fetching it if it's older than refreshInterval
+// If fetching fails the previous copy is returned along with the error, so an outage of the
+// source doesn't fail every render
+// A slow source only blocks the renders waiting for it; the fetch outlives a cancelled caller, bounded
+// by the client's timeout, so the renders sharing it still get the document
+func (f *valuesFetcher) get(ctx context.Context, key string, refreshInterval time.Duration, fetch func(context.Context) ([]byte, error)) ([]byte, error) {
+	f.mu.Lock()
+	cached, ok := f.documents[key]
+	f.mu.Unlock()
+	if ok && now().Sub(cached.fetchedAt) < refreshInterval {
+		return cached.document, nil
+	}
+
+	fetched := f.fetches.DoChan(key, func() (any, error) {
+		document, err := fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		f.documents[key] = cachedDocument{document: document, fetchedAt: now()}
+		f.mu.Unlock()
+		return document, nil
+	})
+	select {
+	case result := <-fetched:
+		if result.Err != nil {
+			return cached.document, result.Err
+		}
+		return result.Val.([]byte), nil
+	case <-ctx.Done():
+		return cached.document, fmt.Errorf("failed to fetch %s: %w", key, ctx.Err())
+	}
+}
+
+// flush drops all cached documents and returns their keys, sorted
+func (f *valuesFetcher) flush() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	dropped := slices.Sorted(maps.Keys(f.documents))
+	clear(f.documents)
+	return dropped
+}
+
+// fetchHTTP fetches a document with a GET request
+func (f *valuesFetcher) fetchHTTP(ctx context.Context, documentURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, documentURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request for %s: %w", documentURL, err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", documentURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", documentURL, resp.Status)
+	}
+	document, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", documentURL, err)
+	}
+	return document, nil
+}
+
+// ociManifest is the subset of an OCI image manifest needed to find a file's layer
+type ociManifest struct {
+	Layers []struct {
+		Digest      string            `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// ociTitleAnnotation holds the file name of a layer in artifacts pushed with oras
+const ociTitleAnnotation = "org.opencontainers.image.title"
+
+// fetchOCI pulls a file from an OCI artifact through the registry's distribution API
+// The file is the layer titled like it, or the only layer of single-layer artifacts without titles
+func (f *valuesFetcher) fetchOCI(ctx context.Context, ref, file string) ([]byte, error) {
+	host, repository, reference, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	base := "https://" + host + "/v2/" + repository
+
+	raw, err := f.fetchRegistry(ctx, base+"/manifests/"+reference, repository, "application/vnd.oci.image.manifest.v1+json")
+	if err != nil {
+		return nil, err
+	}
+	manifest := ociManifest{}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s: %w", ref, err)
+	}
+
+	digest := ""
+	for _, layer := range manifest.Layers {
+		if layer.Annotations[ociTitleAnnotation] == file {
+			digest = layer.Digest
+		}
+	}
+	if digest == "" && len(manifest.Layers) == 1 && manifest.Layers[0].Annotations[ociTitleAnnotation] == "" {
+		digest = manifest.Layers[0].Digest
+	}
+	if digest == "" {
+		return nil, fmt.Errorf("%s has no file %s: %w", ref, file, errValuesNotFound)
+	}
+
+	document, err := f.fetchRegistry(ctx, base+"/blobs/"+digest, repository, "")
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(document)
+	if digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("%s: digest mismatch for %s", ref, file)
+	}
+	return document, nil
+}
+
+// fetchRegistry GETs a registry URL, requesting an anonymous pull token if the registry asks for one
+func (f *valuesFetcher) fetchRegistry(ctx context.Context, registryURL, repository, accept string) ([]byte, error) {
+	token := ""
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, registryURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request for %s: %w", registryURL, err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := f.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", registryURL, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", registryURL, err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return body, nil
+		case resp.StatusCode == http.StatusUnauthorized && token == "":
+			token, err = f.anonymousToken(ctx, resp.Header.Get("WWW-Authenticate"), repository)
+			if err != nil {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, fmt.Errorf("%s: %w", registryURL, errValuesNotFound)
+		default:
+			return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", registryURL, resp.Status)
+		}
+	}
+	return nil, fmt.Errorf("failed to fetch %s: registry denied anonymous access", registryURL)
+}
+
+// anonymousToken requests a pull token from the realm of a Bearer challenge
+func (f *valuesFetcher) anonymousToken(ctx context.Context, challenge, repository string) (string, error) {
+	params, ok := parseBearerChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("registry requires unsupported authentication %q", challenge)
+	}
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + repository + ":pull"
+	}
+	query.Set("scope", scope)
+
+	raw, err := f.fetchHTTP(ctx, params["realm"]+"?"+query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	var response struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return "", fmt.Errorf("invalid registry token response: %w", err)
+	}
+	if response.Token != "" {
+		return response.Token, nil
+	}
+	return response.AccessToken, nil
+}
+
+// parseBearerChallenge parses a WWW-Authenticate header like Bearer realm="...",service="...",scope="..."
+func parseBearerChallenge(challenge string) (map[string]string, bool) {
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, ", "), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return params, true
+}
+
+// parseOCIReference splits oci://<host>/<repository>[:<tag>|@<digest>], defaulting to the latest tag
+func parseOCIReference(ref string) (host, repository, reference string, err error) {
+	host, path, ok := strings.Cut(strings.TrimPrefix(ref, "oci://"), "/")
+	if !ok || host == "" || path == "" {
+		return "", "", "", fmt.Errorf("%q is not oci://<host>/<repository>[:<tag>]", ref)
+	}
+	if repo, digest, ok := strings.Cut(path, "@"); ok {
+		return host, repo, digest, nil
+	}
+	if idx := strings.LastIndex(path, ":"); idx > strings.LastIndex(path, "/") {
+		return host, path[:idx], path[idx+1:], nil
+	}
+	return host, path, "latest", nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValuesFetcherGet(t *testing.T) {
+	fetcher := newValuesFetcher()
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	slow := func(context.Context) ([]byte, error) {
+		fetches.Add(1)
+		<-release
+		return []byte("slow"), nil
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := fetcher.get(context.Background(), "slow", time.Hour, slow); err != nil {
+				t.Errorf("get(slow) error = %v", err)
+			}
+		}()
+	}
+
+	// A slow source doesn't block fetching others
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	fast := func(context.Context) ([]byte, error) { return []byte("fast"), nil }
+	if document, err := fetcher.get(ctx, "fast", 0, fast); err != nil || string(document) != "fast" {
+		t.Fatalf("get(fast) = %q, %v", document, err)
+	}
+
+	// A failed refresh returns the previous copy along with the error
+	failing := func(context.Context) ([]byte, error) { return nil, errors.New("unavailable") }
+	if document, err := fetcher.get(ctx, "fast", 0, failing); err == nil || string(document) != "fast" {
+		t.Errorf("get(fast) after failed refresh = %q, %v, want previous copy and error", document, err)
+	}
+
+	close(release)
+	wg.Wait()
+	if document, err := fetcher.get(context.Background(), "slow", time.Hour, slow); err != nil || string(document) != "slow" {
+		t.Fatalf("get(slow) = %q, %v", document, err)
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("slow fetches = %d, want 1", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// valueTemplateData is what templates in defaultHelmValues and resources strings see, e.g. "{{ .InstanceName }}-headless"
+type valueTemplateData struct {
+	// InstanceName is the name the instance's resources are named after, see getInstanceName
+	InstanceName string
+	Namespace    string
+	Spec         map[string]any
+}
+
+// missingTemplateValue is what text/template prints for map keys that don't exist
+const missingTemplateValue = "<no value>"
+
+// renderValueTemplates renders the Go templates in the string values of a document in place, e.g. defaultHelmValues
+// Strings without "{{" are left alone; values the chart renders itself with tpl escape their braces,
+// e.g. "{{ `{{ .Release.Name }}` }}"
+// Templates referring to spec fields the user didn't set fail, unless guarded with {{ with }} or {{ if }}
+func renderValueTemplates(helmValues, metadata, userSpec, serviceConfig map[string]any, log logr.Logger) error {
+	var data *valueTemplateData
+	rendered := 0
+	render := func(path, text string) (string, error) {
+		if data == nil {
+			composite, err := structpb.NewStruct(map[string]any{"metadata": metadata})
+			if err != nil {
+				return "", fmt.Errorf("failed to read composite metadata: %w", err)
+			}
+			instanceName, err := getInstanceName(&fnv1.Resource{Resource: composite}, serviceConfig)
+			if err != nil {
+				return "", err
+			}
+			namespace, _ := metadata["namespace"].(string)
+			data = &valueTemplateData{InstanceName: instanceName, Namespace: namespace, Spec: userSpec}
+		}
+		tmpl, err := template.New(path).Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid template at %s: %w", path, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, data); err != nil {
+			return "", fmt.Errorf("failed to render template at %s: %w", path, err)
+		}
+		if strings.Contains(out.String(), missingTemplateValue) && !strings.Contains(text, missingTemplateValue) {
+			return "", fmt.Errorf("template at %s refers to a spec field that isn't set", path)
+		}
+		rendered++
+		return out.String(), nil
+	}
+
+	var walk func(path string, value any) (any, error)
+	walk = func(path string, value any) (any, error) {
+		switch v := value.(type) {
+		case string:
+			if !strings.Contains(v, "{{") {
+				return v, nil
+			}
+			return render(path, v)
+		case map[string]any:
+			for key, child := range v {
+				childPath := key
+				if path != "" {
+					childPath = path + "." + key
+				}
+				result, err := walk(childPath, child)
+				if err != nil {
+					return nil, err
+				}
+				v[key] = result
+			}
+		case []any:
+			for i, child := range v {
+				result, err := walk(fmt.Sprintf("%s[%d]", path, i), child)
+				if err != nil {
+					return nil, err
+				}
+				v[i] = result
+			}
+		}
+		return value, nil
+	}
+
+	if _, err := walk("", helmValues); err != nil {
+		return err
+	}
+	if rendered > 0 {
+		log.Info("Rendered value templates", "values", rendered)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-logr/logr"
+)
+
+// versionLatest requests the newest chart version the service allows
+const versionLatest = "latest"
+
+// minorVersionPattern matches a minor version such as "18.1", which follows its latest patch
+var minorVersionPattern = regexp.MustCompile(`^v?\d+\.\d+$`)
+
+// VersionPolicy restricts the chart versions spec.version may select
+type VersionPolicy struct {
+	// Allowed is a semver range, e.g. ">=17.0.0 <19.0.0"; nil allows any version
+	Allowed    *semver.Constraints
+	AllowedRaw string
+	// Versions lists the available chart versions; empty reads them from the repository index
+	Versions []string
+}
+
+// getVersionPolicy extracts versionPolicy from service config
+// Returns nil without error if the service doesn't let users choose a version
+func getVersionPolicy(serviceConfig map[string]any) (*VersionPolicy, error) {
+	versionPolicy, ok := serviceConfig["versionPolicy"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+
+	policy := &VersionPolicy{}
+	if allowed, _ := versionPolicy["allowed"].(string); allowed != "" {
+		constraints, err := semver.NewConstraint(allowed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed range %q: %w", allowed, err)
+		}
+		policy.Allowed, policy.AllowedRaw = constraints, allowed
+	}
+	if versionsRaw, ok := versionPolicy["versions"].([]any); ok {
+		for i, versionRaw := range versionsRaw {
+			version, _ := versionRaw.(string)
+			if _, err := semver.NewVersion(version); err != nil {
+				return nil, fmt.Errorf("versions[%d]: invalid version %q", i, version)
+			}
+			policy.Versions = append(policy.Versions, version)
+		}
+	}
+	return policy, nil
+}
+
+// availableVersions returns the chart versions the policy allows, newest first
+func (p *VersionPolicy) availableVersions(ctx context.Context, resolver *appVersionResolver, serviceConfig map[string]any) ([]*semver.Version, error) {
+	versions := p.Versions
+	if len(versions) == 0 {
+		chart, _ := serviceConfig["chart"].(map[string]any)
+		repo, _ := chart["repository"].(string)
+		name, _ := chart["name"].(string)
+		if isOCIRepository(repo) {
+			return nil, fmt.Errorf("versionPolicy.versions is required for OCI charts")
+		}
+		listed, err := resolver.chartVersions(ctx, repo, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list versions of chart %s: %w", name, err)
+		}
+		versions = listed
+	}
+
+	var allowed []*semver.Version
+	for _, raw := range versions {
+		version, err := semver.NewVersion(raw)
+		if err != nil || (p.Allowed != nil && !p.Allowed.Check(version)) {
+			continue
+		}
+		allowed = append(allowed, version)
+	}
+	slices.SortFunc(allowed, func(a, b *semver.Version) int { return b.Compare(a) })
+	return allowed, nil
+}
+
+// resolveChartVersion resolves spec.version against the service's version policy
+// An exact version pins the chart, a minor version ("18.1") follows its latest patch and "latest" the newest
+// allowed version; without spec.version the chart's defaultVersion is used and "" is returned
+func resolveChartVersion(ctx context.Context, resolver *appVersionResolver, serviceConfig, userSpec map[string]any, log logr.Logger) (string, error) {
+	requested, _ := userSpec["version"].(string)
+	if requested == "" {
+		return "", nil
+	}
+	policy, err := getVersionPolicy(serviceConfig)
+	if err != nil {
+		return "", fmt.Errorf("invalid versionPolicy: %w", err)
+	}
+	if policy == nil {
+		return "", fmt.Errorf("this service doesn't support spec.version")
+	}
+
+	var match func(*semver.Version) bool
+	switch {
+	case requested == versionLatest:
+		match = func(version *semver.Version) bool { return version.Prerelease() == "" }
+	case minorVersionPattern.MatchString(requested):
+		minor, err := semver.NewConstraint("~" + strings.TrimPrefix(requested, "v"))
+		if err != nil {
+			return "", fmt.Errorf("invalid version %q: %w", requested, err)
+		}
+		match = minor.Check
+	default:
+		pinned, err := semver.NewVersion(requested)
+		if err != nil {
+			return "", fmt.Errorf("invalid version %q, expected a version such as 18.1.4, a minor version such as 18.1 or %q", requested, versionLatest)
+		}
+		match = pinned.Equal
+	}
+
+	available, err := policy.availableVersions(ctx, resolver, serviceConfig)
+	if err != nil {
+		return "", err
+	}
+	for _, version := range available {
+		if match(version) {
+			log.Info("Resolved requested chart version", "requested", requested, "version", version.Original())
+			return version.Original(), nil
+		}
+	}
+	// A minor version is outside the range if even its first patch is
+	if candidate, err := semver.NewVersion(requested); err == nil && policy.Allowed != nil && !policy.Allowed.Check(candidate) {
+		return "", fmt.Errorf("version %s is not supported, this service allows %s", requested, policy.AllowedRaw)
+	}
+	return "", fmt.Errorf("no chart version matching %s is available", requested)
+}
+
+// isPinnedVersion reports whether spec.version pins an exact chart version, rather than following newer ones
+func isPinnedVersion(userSpec map[string]any) bool {
+	requested, _ := userSpec["version"].(string)
+	return requested != "" && requested != versionLatest && !minorVersionPattern.MatchString(requested)
+}
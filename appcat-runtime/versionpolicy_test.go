@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestResolveChartVersion(t *testing.T) {
+	serviceConfig := map[string]any{"versionPolicy": map[string]any{
+		"allowed":  ">=17.0.0 <19.0.0",
+		"versions": []any{"16.9.0", "17.3.1", "18.1.2", "18.1.4", "18.2.0", "18.3.0-rc.1", "19.0.0"},
+	}}
+	cases := map[string]struct {
+		requested     string
+		serviceConfig map[string]any
+		want          string
+		wantErr       bool
+	}{
+		"Unset":              {want: ""},
+		"Pinned":             {requested: "18.1.2", want: "18.1.2"},
+		"Minor":              {requested: "18.1", want: "18.1.4"},
+		"MinorWithPrefix":    {requested: "v17.3", want: "17.3.1"},
+		"Latest":             {requested: versionLatest, want: "18.2.0"},
+		"PinnedOutsideRange": {requested: "19.0.0", wantErr: true},
+		"MinorOutsideRange":  {requested: "16.9", wantErr: true},
+		"NotListed":          {requested: "18.1.3", wantErr: true},
+		"Invalid":            {requested: "eighteen", wantErr: true},
+		"NoPolicy":           {requested: "18.1.2", serviceConfig: map[string]any{}, wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := serviceConfig
+			if tc.serviceConfig != nil {
+				config = tc.serviceConfig
+			}
+			userSpec := map[string]any{}
+			if tc.requested != "" {
+				userSpec["version"] = tc.requested
+			}
+			got, err := resolveChartVersion(context.Background(), newAppVersionResolver(), config, userSpec, logr.Discard())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("resolveChartVersion(%q) error = %v, want error %v", tc.requested, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("resolveChartVersion(%q) = %q, want %q", tc.requested, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsPinnedVersion(t *testing.T) {
+	for version, want := range map[string]bool{"": false, versionLatest: false, "18.1": false, "18.1.4": true} {
+		if got := isPinnedVersion(map[string]any{"version": version}); got != want {
+			t.Errorf("isPinnedVersion(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	fnv1 "github.com/crossplane/function-sdk-go/proto/v1"
+	"github.com/go-logr/logr"
+	"google.golang.org/protobuf/types/known/structpb"
+	admissionv1 "k8s.io/api/admission/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// webhookValidatePath is the HTTP path the ValidatingWebhookConfiguration points at
+const webhookValidatePath = "/validate"
+
+// webhookConvertPath is the HTTP path CRD conversion webhooks point at, {crd} is <plural>.<group>
+const webhookConvertPath = "/convert/{crd}"
+
+// webhookPlaceholderCredential stands in for generated credentials during admission
+// The real values only exist at render time, but the chart schema may require them
+const webhookPlaceholderCredential = "admission-placeholder"
+
+// WebhookServer validates claims and composites against their service config at admission time
+// and converts them between XRD versions
+// Service configs are read from configDir, one function input file per resource named <plural>.<group>.yaml
+type WebhookServer struct {
+	log       logr.Logger
+	configDir string
+	schemas   *schemaCache
+	values    *valuesFetcher
+	services  *serviceRegistry
+}
+
+// NewWebhookServer creates a new WebhookServer reading service configs from configDir
+// services may be nil if no service registry is configured
+func NewWebhookServer(log logr.Logger, configDir string, schemas *schemaCache, values *valuesFetcher, services *serviceRegistry) *WebhookServer {
+	return &WebhookServer{
+		log:       log.WithValues("component", "webhook"),
+		configDir: configDir,
+		schemas:   schemas,
+		values:    values,
+		services:  services,
+	}
+}
+
+// Handler returns the HTTP handler serving admission reviews
+func (w *WebhookServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(webhookValidatePath, w.serveValidate)
+	mux.HandleFunc(webhookConvertPath, w.serveConvert)
+	return mux
+}
+
+// serveValidate decodes an AdmissionReview, validates the object and writes the response
+func (w *WebhookServer) serveValidate(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review := &admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(rw, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = w.review(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		w.log.Error(err, "Failed to write admission response")
+	}
+}
+
+// review validates a single admission request
+// Objects without a service config are allowed, so the webhook can't block unrelated resources
+func (w *WebhookServer) review(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	log := w.log.WithValues("resource", req.Resource.String(), "name", req.Name, "namespace", req.Namespace)
+
+	if req.Operation == admissionv1.Delete {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	serviceConfig, err := w.loadServiceConfig(req.Resource)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Info("No service config for resource, allowing")
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	if err != nil {
+		log.Error(err, "Failed to load service config")
+		return deny(fmt.Sprintf("failed to load service config: %v", err))
+	}
+
+	obj := map[string]any{}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return deny(fmt.Sprintf("failed to decode object: %v", err))
+	}
+
+	results := &Results{}
+	if err := validateSpec(ctx, w.schemas, w.values, serviceConfig, obj, results, log); err != nil {
+		log.Info("Rejecting invalid spec", "error", err.Error())
+		return deny(err.Error())
+	}
+
+	// Render warnings are shown by kubectl right away
+	log.Info("Spec passed admission validation")
+	return &admissionv1.AdmissionResponse{Allowed: true, Warnings: results.Warnings()}
+}
+
+// loadServiceConfig reads the service config for a resource from configDir
+// Read on every request so updates to the mounted ConfigMap apply without a restart
+func (w *WebhookServer) loadServiceConfig(resource metav1.GroupVersionResource) (map[string]any, error) {
+	return w.loadServiceConfigFile(schema.GroupResource{Group: resource.Group, Resource: resource.Resource}.String())
+}
+
+// loadServiceConfigFile reads the service config for the CRD named crd (<plural>.<group>)
+func (w *WebhookServer) loadServiceConfigFile(crd string) (map[string]any, error) {
+	name := crd + ".yaml"
+	raw, err := os.ReadFile(filepath.Join(w.configDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	input := map[string]any{}
+	if err := yaml.Unmarshal(raw, &input); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	inputStruct, err := structpb.NewStruct(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s to structpb: %w", name, err)
+	}
+
+	return extractServiceConfig(inputStruct, w.services)
+}
+
+// serveConvert decodes a ConversionReview and converts its objects with the service config's conversion steps
+func (w *WebhookServer) serveConvert(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(body, review); err != nil || review.Request == nil {
+		http.Error(rw, "invalid ConversionReview", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = w.convert(r.PathValue("crd"), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(review); err != nil {
+		w.log.Error(err, "Failed to write conversion response")
+	}
+}
+
+// convert translates all objects of a conversion request to the desired API version
+func (w *WebhookServer) convert(crd string, req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	log := w.log.WithValues("crd", crd, "desiredAPIVersion", req.DesiredAPIVersion)
+
+	failed := func(err error) *apiextensionsv1.ConversionResponse {
+		log.Error(err, "Conversion failed")
+		return &apiextensionsv1.ConversionResponse{
+			Result: metav1.Status{Status: metav1.StatusFailure, Message: err.Error()},
+		}
+	}
+
+	serviceConfig, err := w.loadServiceConfigFile(crd)
+	if err != nil {
+		return failed(fmt.Errorf("failed to load service config: %w", err))
+	}
+	conversion, err := getConversionConfig(serviceConfig)
+	if err != nil {
+		return failed(fmt.Errorf("invalid conversion config: %w", err))
+	}
+	if conversion == nil {
+		return failed(fmt.Errorf("no conversion configured for %s", crd))
+	}
+
+	_, toVersion := splitAPIVersion(req.DesiredAPIVersion)
+	converted := make([]runtime.RawExtension, 0, len(req.Objects))
+	for _, object := range req.Objects {
+		obj := map[string]any{}
+		if err := json.Unmarshal(object.Raw, &obj); err != nil {
+			return failed(fmt.Errorf("failed to decode object: %w", err))
+		}
+		if err := conversion.convertObject(obj, toVersion); err != nil {
+			return failed(err)
+		}
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return failed(fmt.Errorf("failed to encode object: %w", err))
+		}
+		converted = append(converted, runtime.RawExtension{Raw: raw})
+	}
+
+	log.Info("Converted objects", "count", len(converted))
+	return &apiextensionsv1.ConversionResponse{
+		ConvertedObjects: converted,
+		Result:           metav1.Status{Status: metav1.StatusSuccess},
+	}
+}
+
+// validateSpec runs the spec through the same conversion, extract, merge and schema validation as RunFunction
+func validateSpec(ctx context.Context, schemas *schemaCache, fetcher *valuesFetcher, serviceConfig map[string]any, obj map[string]any, results *Results, log logr.Logger) error {
+	objStruct, err := structpb.NewStruct(obj)
+	if err != nil {
+		return fmt.Errorf("failed to convert object to structpb: %w", err)
+	}
+
+	hubObj, err := convertCompositeToHub(&fnv1.Resource{Resource: objStruct}, serviceConfig, log)
+	if err != nil {
+		return fmt.Errorf("failed to convert object: %w", err)
+	}
+	userSpec, err := extractUserSpec(hubObj, serviceConfig)
+	if err != nil {
+		return fmt.Errorf("failed to extract user spec: %w", err)
+	}
+
+	if err := pruneUserSpec(serviceConfig, userSpec, results, log); err != nil {
+		return fmt.Errorf("invalid user spec: %w", err)
+	}
+
+	patchPolicy, err := getPatchPolicy(serviceConfig)
+	if err != nil {
+		return fmt.Errorf("invalid patches config: %w", err)
+	}
+	if _, err := getUserPatches(userSpec, patchPolicy); err != nil {
+		return fmt.Errorf("invalid spec.patches: %w", err)
+	}
+
+	// ConfigMap and EnvironmentConfig sources require a render, the webhook validates without them
+	values, err := resolveValueSources(ctx, nil, serviceConfig, fetcher, results, log)
+	if err != nil {
+		return err
+	}
+	metadata, _ := obj["metadata"].(map[string]any)
+	mergedConfig, err := mergeConfigs(serviceConfig, values.layers, userSpec, metadata, results, log)
+	if err != nil {
+		return fmt.Errorf("failed to merge configs: %w", err)
+	}
+	if err := applyLogging(mergedConfig, serviceConfig, userSpec, results, log); err != nil {
+		return fmt.Errorf("failed to configure log forwarding: %w", err)
+	}
+	if err := applyExternalScrape(mergedConfig, serviceConfig, userSpec, results, log); err != nil {
+		return fmt.Errorf("failed to configure external scraping: %w", err)
+	}
+
+	// Fill in credentials generated at render time so the schema sees the final shape
+	connectionSecret, err := getConnectionSecretConfig(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid connection secret config: %w", err)
+	}
+	if connectionSecret != nil {
+		helmValues, _ := mergedConfig["helmValues"].(map[string]any)
+		for _, path := range []string{connectionSecret.PasswordPath, connectionSecret.SecretNamePath} {
+			if err := injectPasswordIntoHelmValues(helmValues, path, webhookPlaceholderCredential); err != nil {
+				return fmt.Errorf("failed to set placeholder at %s: %w", path, err)
+			}
+		}
+	}
+	secretMapping, err := getSecretMapping(mergedConfig)
+	if err != nil {
+		return fmt.Errorf("invalid secret mapping: %w", err)
+	}
+	if secretMapping != nil {
+		helmValues, _ := mergedConfig["helmValues"].(map[string]any)
+		placeholders := map[string]string{"password": webhookPlaceholderCredential, "secretName": webhookPlaceholderCredential}
+		if err := applySecretMapping(helmValues, secretMapping, placeholders); err != nil {
+			return fmt.Errorf("failed to set placeholders: %w", err)
+		}
+	}
+
+	if err := validateHelmValues(ctx, schemas, mergedConfig, nil, "", log); err != nil {
+		return fmt.Errorf("helm values failed chart schema validation: %w", err)
+	}
+	return nil
+}
+
+// deny builds a rejecting admission response with the given message
+func deny(message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: message,
+			Code:    http.StatusUnprocessableEntity,
+		},
+	}
+}
+
+// serveWebhook serves the webhook over TLS on addr until ctx is done
+func serveWebhook(ctx context.Context, addr string, reloader *certReloader, webhook *WebhookServer) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           webhook.Handler(),
+		TLSConfig:         reloader.tlsConfig(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	if err := srv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}